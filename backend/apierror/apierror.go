@@ -0,0 +1,151 @@
+package apierror
+
+import (
+	"net/http"
+
+	"questionnaire-system/backend/i18n"
+)
+
+// Code 稳定的机器可读错误码，客户端应根据错误码而不是提示信息判断错误类型
+type Code string
+
+const (
+	CodeValidationFailed      Code = "VALIDATION_FAILED"       // 参数校验失败，details中列出字段
+	CodeInvalidRequest        Code = "INVALID_REQUEST"         // 请求体无法解析
+	CodeUnauthorized          Code = "UNAUTHORIZED"            // 缺少或无效的认证信息
+	CodeInvalidCredentials    Code = "INVALID_CREDENTIALS"     // 用户名或密码错误
+	CodeForbidden             Code = "FORBIDDEN"               // 没有权限
+	CodeNotFound              Code = "NOT_FOUND"               // 接口或记录不存在
+	CodeUserNotFound          Code = "USER_NOT_FOUND"          // 用户不存在
+	CodeQuestionnaireNotFound Code = "QUESTIONNAIRE_NOT_FOUND" // 问卷不存在
+	CodeUsernameTaken         Code = "USERNAME_TAKEN"          // 用户名已存在
+	CodeEmailTaken            Code = "EMAIL_TAKEN"             // 邮箱已存在
+	CodeEmailUnverified       Code = "EMAIL_UNVERIFIED"        // 需要先验证邮箱
+	CodeQuestionnaireClosed   Code = "QUESTIONNAIRE_CLOSED"    // 问卷未发布或不在填写时间内
+	CodeQuestionnaireReadOnly Code = "QUESTIONNAIRE_READ_ONLY" // 已发布的问卷不能编辑
+	CodeAlreadySubmitted      Code = "ALREADY_SUBMITTED"       // 重复提交
+	CodeConflict              Code = "CONFLICT"                // 与当前状态冲突
+	CodeAccountLocked         Code = "ACCOUNT_LOCKED"          // 连续登录失败，账户被临时锁定
+	CodeMFARequired           Code = "MFA_REQUIRED"            // 需要先启用两步验证
+	CodeRateLimited           Code = "RATE_LIMITED"            // 请求过于频繁
+	CodeInternal              Code = "INTERNAL_ERROR"          // 服务器内部错误
+)
+
+// 字段错误的原因
+const (
+	ReasonRequired    = "required"
+	ReasonInvalid     = "invalid"
+	ReasonUnsupported = "unsupported"
+)
+
+// FieldError 字段级错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// Error 接口错误：HTTP状态码、错误码和提示信息（i18n消息目录中的键）
+// 由处理器通过c.Error记录，统一由middleware.ErrorHandler写入响应
+type Error struct {
+	Status  int
+	Code    Code
+	Key     string        // 提示信息在消息目录中的键
+	Args    []interface{} // 提示信息中的参数
+	Details []FieldError  // 字段错误，Message在写入响应时按语言填充
+	Cause   error         // 内部原因，只写入日志，不返回给客户端
+}
+
+// New 创建接口错误
+func New(status int, code Code, key string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Key: key, Args: args}
+}
+
+// Validation 参数校验失败
+func Validation(key, field, reason string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, key).WithField(field, reason)
+}
+
+// InvalidRequest 请求体无法解析
+func InvalidRequest(cause error) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, "request.invalid").WithCause(cause)
+}
+
+// Unauthorized 未认证
+func Unauthorized(key string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, key)
+}
+
+// Forbidden 没有权限
+func Forbidden(key string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, key)
+}
+
+// NotFound 记录不存在
+func NotFound(key string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, key)
+}
+
+// Internal 服务器内部错误，key为返回给客户端的提示信息
+func Internal(key string, cause error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, key).WithCause(cause)
+}
+
+// WithField 添加字段错误
+func (e *Error) WithField(field, reason string) *Error {
+	e.Details = append(e.Details, FieldError{Field: field, Reason: reason})
+	return e
+}
+
+// WithArgs 设置提示信息中的参数
+func (e *Error) WithArgs(args ...interface{}) *Error {
+	e.Args = args
+	return e
+}
+
+// WithCause 记录内部原因
+func (e *Error) WithCause(err error) *Error {
+	e.Cause = err
+	return e
+}
+
+// Error 中文提示信息及内部原因，用于日志
+func (e *Error) Error() string {
+	msg := string(e.Code) + ": " + i18n.T(i18n.Default, e.Key, e.Args...)
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// Body 错误响应中的error字段
+type Body struct {
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"` // 请求带有追踪上下文时，对应链路追踪中的trace
+}
+
+// Envelope 统一的错误响应
+type Envelope struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"` // 与error.message相同，兼容只读取message的旧客户端
+	Error   Body   `json:"error"`
+}
+
+// Envelope 按语言生成错误响应
+func (e *Error) Envelope(locale, requestID string) Envelope {
+	body := Body{
+		Code:      e.Code,
+		Message:   i18n.T(locale, e.Key, e.Args...),
+		RequestID: requestID,
+	}
+	for _, d := range e.Details {
+		d.Message = i18n.T(locale, "field."+d.Reason)
+		body.Details = append(body.Details, d)
+	}
+	return Envelope{Success: false, Message: body.Message, Error: body}
+}
@@ -1,13 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"questionnaire-system/backend/config"
 	"questionnaire-system/backend/database"
-	"questionnaire-system/backend/models"
-
-	"golang.org/x/crypto/bcrypt"
+	"questionnaire-system/backend/password"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/service"
 )
 
 func main() {
@@ -20,44 +21,19 @@ func main() {
 		log.Fatalf("数据库连接失败: %v", err)
 	}
 
-	// 查找管理员账号
-	var admin models.User
-	result := db.Where("username = ? AND is_admin = ?", "admin", true).First(&admin)
-	if result.Error != nil {
-		log.Printf("管理员账号不存在，正在创建...")
-
-		// 创建管理员账号
-		admin = models.User{
-			Username: "admin",
-			Email:    "admin@example.com",
-			IsAdmin:  true,
-		}
-	}
+	users := service.NewUserService(repository.NewGormStore(db.DB))
+	users.Passwords = password.NewHasher(cfg.Auth.Password)
 
-	// 设置新密码并使用bcrypt加密
+	// 创建管理员账号，已存在时重置密码
 	plainPassword := "admin123"
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plainPassword), bcrypt.DefaultCost)
+	created, err := users.EnsureAdmin(context.Background(), "admin", "admin@example.com", plainPassword)
 	if err != nil {
-		log.Fatalf("密码加密失败: %v", err)
+		log.Fatalf("重置管理员账号失败: %v", err)
 	}
-
-	admin.Password = string(hashedPassword)
-
-	// 保存或更新管理员账号
-	if admin.ID > 0 {
-		// 更新现有账号
-		result = db.Save(&admin)
-		if result.Error != nil {
-			log.Fatalf("更新管理员密码失败: %v", result.Error)
-		}
-		log.Printf("管理员密码已重置: admin/%s", plainPassword)
+	if created {
+		log.Printf("管理员账号已创建")
 	} else {
-		// 创建新账号
-		result = db.Create(&admin)
-		if result.Error != nil {
-			log.Fatalf("创建管理员账号失败: %v", result.Error)
-		}
-		log.Printf("管理员账号已创建: admin/%s", plainPassword)
+		log.Printf("管理员密码已重置")
 	}
 
 	fmt.Println("========================================")
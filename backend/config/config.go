@@ -1,42 +1,740 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 运行环境
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+	EnvTest        = "test"
+)
+
 // Config 应用配置
+// 加载顺序：默认值 → 配置文件（YAML/TOML）→ 环境变量 → 命令行参数，后者覆盖前者
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Env       string          `yaml:"env" toml:"env"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	SMTP      SMTPConfig      `yaml:"smtp" toml:"smtp"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Webhook   WebhookConfig   `yaml:"webhook" toml:"webhook"`
+
+	// File 实际加载的配置文件路径，未使用配置文件时为空
+	File string `yaml:"-" toml:"-"`
+	// Args 命令行参数之后的位置参数（如 migrate 子命令）
+	Args []string `yaml:"-" toml:"-"`
 }
 
 // ServerConfig 服务器配置
+// 超时为0表示不限制；SSE等长连接自行设置每次写入的超时，不受WriteTimeout影响
 type ServerConfig struct {
-	Port string
+	Addr              string   `yaml:"addr" toml:"addr"`                               // 监听地址，如 ":8080"
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout"`               // 读取整个请求（含请求体）的超时
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"` // 读取请求头的超时
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout"`             // 写入响应的超时
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout"`               // keep-alive连接的空闲超时
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`       // 关闭时等待处理中请求完成的最长时间
+	DrainDelay        Duration `yaml:"drain_delay" toml:"drain_delay"`                 // 收到关闭信号后就绪检查先返回503，等待该时间再停止接收请求
+	// TrustedProxies 受信任的反向代理（CIDR或IP），只有来自这些地址的请求才按X-Forwarded-For识别客户端IP，
+	// 访问日志和按IP限流使用该地址；为空时不信任任何代理
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
 }
 
 // DatabaseConfig 数据库配置
+// 设置了DSN时直接使用，否则由Host、Port等字段拼接
+// SQLite的DSN为数据库文件路径，未设置时使用 DBName + ".db"
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Driver          string   `yaml:"driver" toml:"driver"` // mysql、postgres、sqlite
+	DSN             Secret   `yaml:"dsn" toml:"dsn"`
+	Host            string   `yaml:"host" toml:"host"`
+	Port            string   `yaml:"port" toml:"port"` // 为空时使用驱动的默认端口
+	User            string   `yaml:"user" toml:"user"`
+	Password        Secret   `yaml:"password" toml:"password"`
+	DBName          string   `yaml:"dbname" toml:"dbname"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	AutoMigrate     bool     `yaml:"auto_migrate" toml:"auto_migrate"` // 启动时自动执行未执行的迁移
+}
+
+// LogConfig 日志配置
+// File为空时只输出到控制台；设置后同时写入文件，超过MaxSizeMB时轮转
+type LogConfig struct {
+	Level      string `yaml:"level" toml:"level"`               // debug、info、warn、error
+	Format     string `yaml:"format" toml:"format"`             // json、text
+	File       string `yaml:"file" toml:"file"`                 // 日志文件路径
+	MaxSizeMB  int    `yaml:"max_size_mb" toml:"max_size_mb"`   // 单个日志文件的大小上限，0表示不轮转
+	MaxBackups int    `yaml:"max_backups" toml:"max_backups"`   // 保留的旧日志文件数量，0表示不限
+	MaxAgeDays int    `yaml:"max_age_days" toml:"max_age_days"` // 旧日志文件的保留天数，0表示不限
+	// Sample 访问日志的按路由采样比例（0~1），键为路由模板，以*结尾时按前缀匹配；错误请求总是记录
+	Sample map[string]float64 `yaml:"sample" toml:"sample"`
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"` // "*" 表示允许所有来源
+}
+
+// AuthConfig 认证配置
+// 会话令牌使用TokenSecret签名（HMAC-SHA256），在TokenTTL后过期；TokenSecret为空时启动时随机生成，重启后令牌全部失效
+type AuthConfig struct {
+	TokenSecret       Secret                  `yaml:"token_secret" toml:"token_secret"`
+	TokenTTL          Duration                `yaml:"token_ttl" toml:"token_ttl"`
+	Lockout           LockoutConfig           `yaml:"lockout" toml:"lockout"`
+	PasswordReset     PasswordResetConfig     `yaml:"password_reset" toml:"password_reset"`
+	EmailVerification EmailVerificationConfig `yaml:"email_verification" toml:"email_verification"`
+	Password          PasswordConfig          `yaml:"password" toml:"password"`
+	MFA               MFAConfig               `yaml:"mfa" toml:"mfa"`
+	OIDC              OIDCConfig              `yaml:"oidc" toml:"oidc"`
+	// PasswordLogin 为false时关闭密码登录、注册和重置密码，只能通过单点登录登录（需配置oidc）
+	PasswordLogin bool `yaml:"password_login" toml:"password_login"`
+}
+
+// OIDCConfig OpenID Connect单点登录（授权码流程，带PKCE），Issuer为空时不启用
+// 外部身份第一次登录时自动创建用户；RoleMapping不为空时每次登录都按组重新设置管理员权限
+type OIDCConfig struct {
+	Issuer       string   `yaml:"issuer" toml:"issuer"` // 如 https://login.example.com/realms/staff
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret Secret   `yaml:"client_secret" toml:"client_secret"` // 公共客户端为空，仅使用PKCE
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`   // 前端的回调页面，如 https://example.com/sso/callback
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+	Name         string   `yaml:"name" toml:"name"`                 // 登录页中按钮显示的名称
+	GroupsClaim  string   `yaml:"groups_claim" toml:"groups_claim"` // ID令牌中组的声明名称
+	// RoleMapping 组 → 角色（admin 或 user）
+	RoleMapping map[string]string `yaml:"role_mapping" toml:"role_mapping"`
+	LoginTTL    Duration          `yaml:"login_ttl" toml:"login_ttl"` // 发起登录后完成回调的时限
+}
+
+// MFAConfig 基于TOTP的两步验证
+// 用户启用后，登录时密码正确只返回登录挑战令牌，需在ChallengeTTL内提交验证码；RequireAdmin为true时未启用两步验证的管理员不能访问管理接口
+type MFAConfig struct {
+	Issuer       string   `yaml:"issuer" toml:"issuer"`               // 验证器App中显示的发行方
+	ChallengeTTL Duration `yaml:"challenge_ttl" toml:"challenge_ttl"` // 密码校验通过后提交验证码的时限
+	MaxFailures  int      `yaml:"max_failures" toml:"max_failures"`   // 每次登录最多可输错验证码的次数
+	RequireAdmin bool     `yaml:"require_admin" toml:"require_admin"`
+}
+
+// EmailVerificationConfig 注册后验证邮箱
+// 验证链接为URL加上token查询参数，指向前端的验证页面；URL为空时邮件中只包含令牌
+type EmailVerificationConfig struct {
+	TTL            Duration `yaml:"ttl" toml:"ttl"`                         // 验证令牌的有效期
+	ResendInterval Duration `yaml:"resend_interval" toml:"resend_interval"` // 同一用户两次发送验证邮件的最短间隔
+	URL            string   `yaml:"url" toml:"url"`                         // 如 https://example.com/verify-email
+}
+
+// PasswordConfig 密码哈希算法和密码强度策略
+// 修改算法或参数后，已有用户在下次登录成功时自动改用新的算法和参数（早期版本的MD5哈希同样如此）
+type PasswordConfig struct {
+	Algorithm  string       `yaml:"algorithm" toml:"algorithm"`     // bcrypt 或 argon2id
+	BcryptCost int          `yaml:"bcrypt_cost" toml:"bcrypt_cost"` // 4到31
+	Argon2     Argon2Config `yaml:"argon2" toml:"argon2"`
+	// 注册和重置密码时检查的策略，MinClasses为至少包含的字符类别数（小写字母、大写字母、数字、其他符号），0表示不限
+	MinLength      int  `yaml:"min_length" toml:"min_length"`
+	MaxLength      int  `yaml:"max_length" toml:"max_length"` // 字节数，bcrypt最多72
+	MinClasses     int  `yaml:"min_classes" toml:"min_classes"`
+	RejectUsername bool `yaml:"reject_username" toml:"reject_username"` // 不允许密码中包含用户名
+}
+
+// Argon2Config argon2id的参数
+type Argon2Config struct {
+	Memory      int `yaml:"memory" toml:"memory"` // KiB
+	Iterations  int `yaml:"iterations" toml:"iterations"`
+	Parallelism int `yaml:"parallelism" toml:"parallelism"`
+}
+
+// PasswordResetConfig 自助重置密码
+// 重置链接为URL加上token查询参数，指向前端的重置密码页面；URL为空时邮件中只包含令牌
+type PasswordResetConfig struct {
+	TTL Duration `yaml:"ttl" toml:"ttl"` // 重置令牌的有效期
+	URL string   `yaml:"url" toml:"url"` // 如 https://example.com/reset-password
+}
+
+// LockoutConfig 连续登录失败后锁定账户
+// 每连续失败Threshold次锁定一次，时长从Cooldown开始逐次翻倍，不超过MaxCooldown；登录成功或管理员解锁后重新计数
+type LockoutConfig struct {
+	Threshold   int      `yaml:"threshold" toml:"threshold"` // 0表示不锁定
+	Cooldown    Duration `yaml:"cooldown" toml:"cooldown"`
+	MaxCooldown Duration `yaml:"max_cooldown" toml:"max_cooldown"`
+}
+
+// RateLimitConfig 登录和重置密码接口的令牌桶限流
+// Backend为memory时每个实例单独计数；多实例部署时使用database，令牌桶保存在数据库中
+type RateLimitConfig struct {
+	Backend  string `yaml:"backend" toml:"backend"`   // memory、database
+	IP       Rate   `yaml:"ip" toml:"ip"`             // 每个客户端IP
+	Username Rate   `yaml:"username" toml:"username"` // 每个用户名或重置密码的邮箱（不区分大小写）
+}
+
+// WebhookConfig Webhook投递配置
+// 目标地址默认不能指向本机、内网、链路本地地址或云平台元数据服务；
+// AllowPrivateTargets用于开发和测试时把订阅指向本机的接收程序，生产环境不能开启
+type WebhookConfig struct {
+	AllowPrivateTargets bool `yaml:"allow_private_targets" toml:"allow_private_targets"`
+}
+
+// SMTPConfig 邮件发送配置
+// Host为空时不通过SMTP发送：设置了OutboxDir时邮件写入该目录（每封一个.eml文件），否则只记录到日志，用于开发和离线测试
+type SMTPConfig struct {
+	Host      string `yaml:"host" toml:"host"`
+	Port      int    `yaml:"port" toml:"port"` // 465为隐式TLS，其他端口在服务器支持时使用STARTTLS
+	Username  string `yaml:"username" toml:"username"`
+	Password  Secret `yaml:"password" toml:"password"`
+	From      string `yaml:"from" toml:"from"`
+	OutboxDir string `yaml:"outbox_dir" toml:"outbox_dir"`
+}
+
+// MetricsConfig 监控指标接口配置
+// 设置了Token时请求需携带 Authorization: Bearer <token>；AllowedNetworks不为空时只允许这些网段访问，为空表示不限制来源
+type MetricsConfig struct {
+	Enabled         bool     `yaml:"enabled" toml:"enabled"`
+	Path            string   `yaml:"path" toml:"path"`
+	Token           Secret   `yaml:"token" toml:"token"`
+	AllowedNetworks []string `yaml:"allowed_networks" toml:"allowed_networks"` // CIDR或IP
+}
+
+// TracingConfig 链路追踪配置
+// Exporter为none时不导出span，但仍会传递请求头中的追踪上下文，日志和错误响应中仍带有调用方的trace_id
+type TracingConfig struct {
+	Exporter    string            `yaml:"exporter" toml:"exporter"`         // none、otlp、stdout
+	Endpoint    string            `yaml:"endpoint" toml:"endpoint"`         // OTLP/HTTP地址，如 localhost:4318 或完整URL；为空时使用OTEL_EXPORTER_OTLP_*环境变量或默认地址
+	Insecure    bool              `yaml:"insecure" toml:"insecure"`         // 使用HTTP而不是HTTPS
+	Headers     map[string]string `yaml:"headers" toml:"headers"`           // OTLP请求头，如后端的认证信息
+	ServiceName string            `yaml:"service_name" toml:"service_name"` // 资源属性service.name
+	SampleRatio float64           `yaml:"sample_ratio" toml:"sample_ratio"` // 没有上游采样决定时的采样比例（0~1）
+}
+
+// Secret 敏感配置项，打印或序列化时显示为掩码，通过Value获取原值
+type Secret string
+
+// Value 返回原始值
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String 实现fmt.Stringer，避免日志中泄露原值
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "******"
 }
 
-// GetConfig 获取配置 (保留兼容性)
-func GetConfig() *Config {
-	return LoadConfig()
+// GoString 实现fmt.GoStringer（%#v）
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
 }
 
-// LoadConfig 加载配置
-func LoadConfig() *Config {
+// MarshalText 序列化时同样使用掩码
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 从配置文件读取原值
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
+
+// Rate 限流速度，格式为 "次数/时长"，如 "10/1m" 表示最多连续请求10次，之后每分钟恢复10次；
+// 为空或 "0" 表示不限
+type Rate struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Unlimited 是否不限流
+func (r Rate) Unlimited() bool {
+	return r.Burst <= 0 || r.Period <= 0
+}
+
+// UnmarshalText 解析 "次数/时长"
+func (r *Rate) UnmarshalText(text []byte) error {
+	v := strings.TrimSpace(string(text))
+	if v == "" || v == "0" {
+		*r = Rate{}
+		return nil
+	}
+	count, period, ok := strings.Cut(v, "/")
+	if !ok {
+		return fmt.Errorf("无效的限流速度 %q，格式应为 次数/时长，如 10/1m", text)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || burst < 0 {
+		return fmt.Errorf("无效的限流次数 %q", count)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return fmt.Errorf("无效的限流时长 %q", period)
+	}
+	*r = Rate{Burst: burst, Period: d}
+	return nil
+}
+
+// MarshalText 序列化为 "次数/时长"
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// String 实现fmt.Stringer，不限流时为 "0"
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "0"
+	}
+	return strconv.Itoa(r.Burst) + "/" + r.Period.String()
+}
+
+// Duration 支持 "30s"、"1h" 格式的时长
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText 解析时长字符串
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return fmt.Errorf("无效的时长 %q: %w", text, err)
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalText 序列化为时长字符串
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// Default 返回默认配置
+// 默认值不包含任何凭据，数据库账号密码需通过配置文件或环境变量提供
+func Default() *Config {
 	return &Config{
+		Env: EnvDevelopment,
 		Server: ServerConfig{
-			Port: "8080",
+			Addr:              ":8080",
+			ReadTimeout:       Duration{30 * time.Second},
+			ReadHeaderTimeout: Duration{10 * time.Second},
+			WriteTimeout:      Duration{2 * time.Minute},
+			IdleTimeout:       Duration{2 * time.Minute},
+			ShutdownTimeout:   Duration{30 * time.Second},
+			TrustedProxies:    privateNetworks(),
 		},
 		Database: DatabaseConfig{
-			Host:     "localhost",
-			Port:     "3306",
-			User:     "qsuser",
-			Password: "qq123123", //MySQL用户密码
-			DBName:   "questionnaire_db",
+			Driver:          "mysql",
+			Host:            "localhost",
+			DBName:          "questionnaire_db",
+			MaxOpenConns:    100,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration{time.Hour},
+		},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     "json",
+			MaxSizeMB:  100,
+			MaxBackups: 7,
+			MaxAgeDays: 30,
+			// 探针每隔几秒请求一次，成功时不记录
+			Sample: map[string]float64{
+				"/api/v1/health/live":  0,
+				"/api/v1/health/ready": 0,
+			},
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+		},
+		Auth: AuthConfig{
+			TokenTTL: Duration{24 * time.Hour},
+			Lockout: LockoutConfig{
+				Threshold:   5,
+				Cooldown:    Duration{time.Minute},
+				MaxCooldown: Duration{time.Hour},
+			},
+			PasswordReset: PasswordResetConfig{
+				TTL: Duration{time.Hour},
+			},
+			EmailVerification: EmailVerificationConfig{
+				TTL:            Duration{24 * time.Hour},
+				ResendInterval: Duration{time.Minute},
+			},
+			Password: PasswordConfig{
+				Algorithm:      "bcrypt",
+				BcryptCost:     10,
+				Argon2:         Argon2Config{Memory: 64 * 1024, Iterations: 3, Parallelism: 2},
+				MinLength:      8,
+				MaxLength:      72,
+				RejectUsername: true,
+			},
+			MFA: MFAConfig{
+				Issuer:       "问卷系统",
+				ChallengeTTL: Duration{5 * time.Minute},
+				MaxFailures:  5,
+			},
+			OIDC: OIDCConfig{
+				Scopes:      []string{"openid", "email", "profile"},
+				Name:        "企业账号",
+				GroupsClaim: "groups",
+				LoginTTL:    Duration{10 * time.Minute},
+			},
+			PasswordLogin: true,
+		},
+		RateLimit: RateLimitConfig{
+			Backend:  "memory",
+			IP:       Rate{Burst: 20, Period: time.Minute},
+			Username: Rate{Burst: 10, Period: time.Minute},
 		},
+		SMTP: SMTPConfig{
+			Port: 587,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "questionnaire-system",
+			SampleRatio: 1,
+		},
+		// 默认只允许本机和内网抓取
+		Metrics: MetricsConfig{
+			Enabled:         true,
+			Path:            "/metrics",
+			AllowedNetworks: privateNetworks(),
+		},
+	}
+}
+
+// privateNetworks 本机和内网地址
+func privateNetworks() []string {
+	return []string{
+		"127.0.0.0/8", "::1/128",
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+	}
+}
+
+// minTokenSecretLength 生产环境令牌密钥的最小长度
+const minTokenSecretLength = 32
+
+// Validate 校验配置，一次性返回所有问题
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Env {
+	case EnvDevelopment, EnvProduction, EnvTest:
+	default:
+		add("env: 不支持的运行环境 %q（可选 development、production、test）", c.Env)
+	}
+
+	if strings.TrimSpace(c.Server.Addr) == "" {
+		add("server.addr: 不能为空")
+	} else if !strings.Contains(c.Server.Addr, ":") {
+		add("server.addr: %q 缺少端口，格式应为 host:port 或 :port", c.Server.Addr)
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"read_timeout", c.Server.ReadTimeout},
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+		{"drain_delay", c.Server.DrainDelay},
+	}
+	for _, t := range timeouts {
+		if t.value.Duration < 0 {
+			add("server.%s: 不能为负数", t.name)
+		}
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		add("server.shutdown_timeout: 必须大于0")
+	}
+	for _, n := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(n); err != nil && net.ParseIP(n) == nil {
+			add("server.trusted_proxies: 无效的IP或网段 %q", n)
+		}
+	}
+
+	db := c.Database
+	switch db.Driver {
+	case "mysql", "postgres", "sqlite":
+	default:
+		add("database.driver: 不支持的数据库驱动 %q（可选 mysql、postgres、sqlite）", db.Driver)
+	}
+	if db.DSN == "" && db.Driver != "sqlite" {
+		if db.Host == "" {
+			add("database.host: 未设置dsn时不能为空")
+		}
+		if db.User == "" {
+			add("database.user: 未设置dsn时不能为空")
+		}
+		if db.DBName == "" {
+			add("database.dbname: 未设置dsn时不能为空")
+		}
+	}
+	if db.MaxOpenConns < 0 {
+		add("database.max_open_conns: 不能为负数")
+	}
+	if db.MaxIdleConns < 0 {
+		add("database.max_idle_conns: 不能为负数")
+	}
+	if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		add("database.max_idle_conns: 不能大于 max_open_conns（%d）", db.MaxOpenConns)
+	}
+	if db.ConnMaxLifetime.Duration < 0 {
+		add("database.conn_max_lifetime: 不能为负数")
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log.level: 不支持的日志级别 %q（可选 debug、info、warn、error）", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		add("log.format: 不支持的日志格式 %q（可选 text、json）", c.Log.Format)
+	}
+
+	if c.Log.MaxSizeMB < 0 || c.Log.MaxBackups < 0 || c.Log.MaxAgeDays < 0 {
+		add("log.max_size_mb、log.max_backups、log.max_age_days: 不能为负数")
+	}
+	routes := make([]string, 0, len(c.Log.Sample))
+	for route := range c.Log.Sample {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		if rate := c.Log.Sample[route]; rate < 0 || rate > 1 {
+			add("log.sample: 路由 %s 的采样比例 %v 应在0到1之间", route, rate)
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			add("cors.allowed_origins: 无效的来源 %q，格式应为 https://example.com", origin)
+		}
+	}
+
+	if c.Auth.TokenTTL.Duration <= 0 {
+		add("auth.token_ttl: 必须大于0")
+	}
+	if c.Env == EnvProduction && len(c.Auth.TokenSecret) < minTokenSecretLength {
+		add("auth.token_secret: 生产环境必须设置，且长度不少于%d个字符", minTokenSecretLength)
+	}
+
+	if lockout := c.Auth.Lockout; lockout.Threshold < 0 {
+		add("auth.lockout.threshold: 不能为负数")
+	} else if lockout.Threshold > 0 {
+		if lockout.Cooldown.Duration <= 0 {
+			add("auth.lockout.cooldown: 启用账户锁定时必须大于0")
+		}
+		if lockout.MaxCooldown.Duration < lockout.Cooldown.Duration {
+			add("auth.lockout.max_cooldown: 不能小于 cooldown（%s）", lockout.Cooldown.Duration)
+		}
+	}
+
+	if c.Auth.PasswordReset.TTL.Duration <= 0 {
+		add("auth.password_reset.ttl: 必须大于0")
+	}
+	if resetURL := c.Auth.PasswordReset.URL; resetURL != "" {
+		u, err := url.Parse(resetURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("auth.password_reset.url: 无效的地址 %q，格式应为 https://example.com/reset-password", resetURL)
+		}
+	}
+
+	if c.Auth.EmailVerification.TTL.Duration <= 0 {
+		add("auth.email_verification.ttl: 必须大于0")
+	}
+	if c.Auth.EmailVerification.ResendInterval.Duration <= 0 {
+		add("auth.email_verification.resend_interval: 必须大于0")
+	}
+	if verifyURL := c.Auth.EmailVerification.URL; verifyURL != "" {
+		u, err := url.Parse(verifyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("auth.email_verification.url: 无效的地址 %q，格式应为 https://example.com/verify-email", verifyURL)
+		}
+	}
+
+	pw := c.Auth.Password
+	switch pw.Algorithm {
+	case "bcrypt":
+		if pw.BcryptCost < 4 || pw.BcryptCost > 31 {
+			add("auth.password.bcrypt_cost: 应在4到31之间，当前为 %d", pw.BcryptCost)
+		}
+		if pw.MaxLength <= 0 || pw.MaxLength > 72 {
+			add("auth.password.max_length: bcrypt只支持不超过72个字节的密码，当前为 %d", pw.MaxLength)
+		}
+	case "argon2id":
+		if pw.Argon2.Memory < 8*pw.Argon2.Parallelism || pw.Argon2.Iterations < 1 || pw.Argon2.Parallelism < 1 || pw.Argon2.Parallelism > 255 {
+			add("auth.password.argon2: 无效的参数（memory=%d, iterations=%d, parallelism=%d），memory 至少为 8×parallelism KiB", pw.Argon2.Memory, pw.Argon2.Iterations, pw.Argon2.Parallelism)
+		}
+	default:
+		add("auth.password.algorithm: 不支持的算法 %q（可选 bcrypt、argon2id）", pw.Algorithm)
+	}
+	if pw.MinLength < 0 || pw.MaxLength < 0 || (pw.MaxLength > 0 && pw.MaxLength < pw.MinLength) {
+		add("auth.password: min_length 和 max_length 不能为负数，且 max_length 不能小于 min_length")
+	}
+	if pw.MinClasses < 0 || pw.MinClasses > 4 {
+		add("auth.password.min_classes: 应在0到4之间，当前为 %d", pw.MinClasses)
+	}
+
+	if c.Auth.MFA.Issuer == "" || strings.Contains(c.Auth.MFA.Issuer, ":") {
+		add("auth.mfa.issuer: 不能为空，且不能包含冒号")
+	}
+	if c.Auth.MFA.ChallengeTTL.Duration <= 0 {
+		add("auth.mfa.challenge_ttl: 必须大于0")
+	}
+	if c.Auth.MFA.MaxFailures <= 0 {
+		add("auth.mfa.max_failures: 必须大于0")
+	}
+
+	if sso := c.Auth.OIDC; sso.Issuer != "" {
+		if u, err := url.Parse(sso.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("auth.oidc.issuer: 无效的地址 %q", sso.Issuer)
+		}
+		if sso.ClientID == "" {
+			add("auth.oidc.client_id: 设置了auth.oidc.issuer时不能为空")
+		}
+		if u, err := url.Parse(sso.RedirectURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("auth.oidc.redirect_url: 无效的地址 %q，格式应为 https://example.com/sso/callback", sso.RedirectURL)
+		}
+		hasOpenID := false
+		for _, scope := range sso.Scopes {
+			hasOpenID = hasOpenID || scope == "openid"
+		}
+		if !hasOpenID {
+			add("auth.oidc.scopes: 必须包含openid")
+		}
+		if sso.GroupsClaim == "" {
+			add("auth.oidc.groups_claim: 不能为空")
+		}
+		for group, role := range sso.RoleMapping {
+			if role != "admin" && role != "user" {
+				add("auth.oidc.role_mapping: 组 %q 的角色 %q 无效（可选 admin、user）", group, role)
+			}
+		}
+		if sso.LoginTTL.Duration <= 0 {
+			add("auth.oidc.login_ttl: 必须大于0")
+		}
+	}
+	if !c.Auth.PasswordLogin && c.Auth.OIDC.Issuer == "" {
+		add("auth.password_login: 关闭密码登录时必须配置auth.oidc.issuer")
+	}
+
+	switch c.RateLimit.Backend {
+	case "memory", "database":
+	default:
+		add("rate_limit.backend: 不支持的限流存储 %q（可选 memory、database）", c.RateLimit.Backend)
+	}
+
+	if c.Env == EnvProduction && c.Webhook.AllowPrivateTargets {
+		add("webhook.allow_private_targets: 生产环境不能允许投递到本机和内网地址")
+	}
+
+	if c.SMTP.Host != "" {
+		if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
+			add("smtp.port: 无效的端口 %d", c.SMTP.Port)
+		}
+		if c.SMTP.From == "" {
+			add("smtp.from: 设置了smtp.host时不能为空")
+		} else if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			add("smtp.from: 无效的邮件地址 %q", c.SMTP.From)
+		}
+	} else if c.Env == EnvProduction && c.Auth.PasswordLogin && c.SMTP.OutboxDir == "" {
+		add("smtp.host: 生产环境启用密码登录时必须设置（或设置smtp.outbox_dir），否则重置密码和验证邮件只会记录到日志")
+	}
+
+	if c.Metrics.Enabled {
+		if !strings.HasPrefix(c.Metrics.Path, "/") || strings.HasPrefix(c.Metrics.Path, "/api/") {
+			add("metrics.path: %q 应以/开头，且不能位于/api/下", c.Metrics.Path)
+		}
+		for _, n := range c.Metrics.AllowedNetworks {
+			if _, _, err := net.ParseCIDR(n); err != nil && net.ParseIP(n) == nil {
+				add("metrics.allowed_networks: 无效的IP或网段 %q", n)
+			}
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		add("tracing.exporter: 不支持的导出方式 %q（可选 none、otlp、stdout）", c.Tracing.Exporter)
 	}
+	if c.Tracing.Exporter != "none" && c.Tracing.ServiceName == "" {
+		add("tracing.service_name: 启用追踪时不能为空")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio: %v 应在0到1之间", c.Tracing.SampleRatio)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("配置无效:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Summary 用于启动日志的配置摘要，不包含任何敏感信息
+func (c *Config) Summary() string {
+	source := c.File
+	if source == "" {
+		source = "无"
+	}
+
+	database := c.Database.Driver + " " + c.Database.Host + "/" + c.Database.DBName
+	if c.Database.Driver == "sqlite" && c.Database.DSN == "" {
+		database = "sqlite " + c.Database.DBName + ".db"
+	} else if c.Database.DSN != "" {
+		database = c.Database.Driver + " (dsn)"
+	}
+
+	mailer := "日志"
+	if c.SMTP.Host != "" {
+		mailer = "smtp " + c.SMTP.Host
+	} else if c.SMTP.OutboxDir != "" {
+		mailer = "文件 " + c.SMTP.OutboxDir
+	}
+
+	metrics := "关闭"
+	if c.Metrics.Enabled {
+		metrics = c.Metrics.Path
+	}
+
+	adminMFA := "可选"
+	if c.Auth.MFA.RequireAdmin {
+		adminMFA = "必须"
+	}
+
+	sso := "关闭"
+	if c.Auth.OIDC.Issuer != "" {
+		sso = c.Auth.OIDC.Issuer
+	}
+	passwordLogin := "开启"
+	if !c.Auth.PasswordLogin {
+		passwordLogin = "关闭"
+	}
+
+	return fmt.Sprintf("环境=%s, 配置文件=%s, 监听地址=%s, 数据库=%s, 连接池=%d/%d, 日志=%s/%s, 跨域来源=%s, 邮件=%s, 监控指标=%s, 链路追踪=%s, 密码哈希=%s, 登录限流=%s（IP %s，用户名 %s）, 账户锁定=%d次, 管理员两步验证=%s, 单点登录=%s, 密码登录=%s",
+		c.Env, source, c.Server.Addr, database,
+		c.Database.MaxIdleConns, c.Database.MaxOpenConns,
+		c.Log.Level, c.Log.Format,
+		strings.Join(c.CORS.AllowedOrigins, ","),
+		mailer, metrics, c.Tracing.Exporter, c.Auth.Password.Algorithm,
+		c.RateLimit.Backend, c.RateLimit.IP, c.RateLimit.Username, c.Auth.Lockout.Threshold, adminMFA, sso, passwordLogin)
 }
@@ -0,0 +1,344 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFiles 未指定配置文件时依次查找的路径（相对于工作目录）
+var DefaultFiles = []string{
+	"config.yaml",
+	"config.yml",
+	"config.toml",
+	"config/config.yaml",
+	"config/config.toml",
+}
+
+// envVar 环境变量与配置项的对应关系
+type envVar struct {
+	name  string
+	apply func(c *Config, value string) error
+}
+
+// envVars 支持的环境变量
+var envVars = []envVar{
+	{"APP_ENV", func(c *Config, v string) error { c.Env = v; return nil }},
+	{"APP_ADDR", func(c *Config, v string) error { c.Server.Addr = v; return nil }},
+	{"APP_PORT", func(c *Config, v string) error { c.Server.Addr = ":" + v; return nil }},
+	{"SERVER_READ_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.ReadTimeout })},
+	{"SERVER_READ_HEADER_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.ReadHeaderTimeout })},
+	{"SERVER_WRITE_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.WriteTimeout })},
+	{"SERVER_IDLE_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.IdleTimeout })},
+	{"SERVER_SHUTDOWN_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.ShutdownTimeout })},
+	{"SERVER_DRAIN_DELAY", durationSetter(func(c *Config) *Duration { return &c.Server.DrainDelay })},
+	{"SERVER_TRUSTED_PROXIES", func(c *Config, v string) error { c.Server.TrustedProxies = splitList(v); return nil }},
+	{"DB_DRIVER", func(c *Config, v string) error { c.Database.Driver = v; return nil }},
+	{"DB_DSN", func(c *Config, v string) error { c.Database.DSN = Secret(v); return nil }},
+	{"DB_HOST", func(c *Config, v string) error { c.Database.Host = v; return nil }},
+	{"DB_PORT", func(c *Config, v string) error { c.Database.Port = v; return nil }},
+	{"DB_USER", func(c *Config, v string) error { c.Database.User = v; return nil }},
+	{"DB_PASSWORD", func(c *Config, v string) error { c.Database.Password = Secret(v); return nil }},
+	{"DB_NAME", func(c *Config, v string) error { c.Database.DBName = v; return nil }},
+	{"DB_MAX_OPEN_CONNS", intSetter(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", intSetter(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", func(c *Config, v string) error { return c.Database.ConnMaxLifetime.UnmarshalText([]byte(v)) }},
+	{"DB_AUTO_MIGRATE", boolSetter(func(c *Config) *bool { return &c.Database.AutoMigrate })},
+	{"LOG_LEVEL", func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{"LOG_FORMAT", func(c *Config, v string) error { c.Log.Format = v; return nil }},
+	{"LOG_FILE", func(c *Config, v string) error { c.Log.File = v; return nil }},
+	{"LOG_MAX_SIZE_MB", intSetter(func(c *Config) *int { return &c.Log.MaxSizeMB })},
+	{"LOG_MAX_BACKUPS", intSetter(func(c *Config) *int { return &c.Log.MaxBackups })},
+	{"LOG_MAX_AGE_DAYS", intSetter(func(c *Config) *int { return &c.Log.MaxAgeDays })},
+	{"LOG_SAMPLE", func(c *Config, v string) error {
+		sample, err := parseSample(v)
+		if err != nil {
+			return err
+		}
+		c.Log.Sample = sample
+		return nil
+	}},
+	{"CORS_ALLOWED_ORIGINS", func(c *Config, v string) error { c.CORS.AllowedOrigins = splitList(v); return nil }},
+	{"JWT_SECRET", func(c *Config, v string) error { c.Auth.TokenSecret = Secret(v); return nil }}, // 兼容旧名称
+	{"TOKEN_SECRET", func(c *Config, v string) error { c.Auth.TokenSecret = Secret(v); return nil }},
+	{"TOKEN_TTL", func(c *Config, v string) error { return c.Auth.TokenTTL.UnmarshalText([]byte(v)) }},
+	{"AUTH_LOCKOUT_THRESHOLD", intSetter(func(c *Config) *int { return &c.Auth.Lockout.Threshold })},
+	{"AUTH_LOCKOUT_COOLDOWN", durationSetter(func(c *Config) *Duration { return &c.Auth.Lockout.Cooldown })},
+	{"AUTH_LOCKOUT_MAX_COOLDOWN", durationSetter(func(c *Config) *Duration { return &c.Auth.Lockout.MaxCooldown })},
+	{"AUTH_PASSWORD_RESET_TTL", durationSetter(func(c *Config) *Duration { return &c.Auth.PasswordReset.TTL })},
+	{"AUTH_PASSWORD_RESET_URL", func(c *Config, v string) error { c.Auth.PasswordReset.URL = v; return nil }},
+	{"AUTH_EMAIL_VERIFICATION_TTL", durationSetter(func(c *Config) *Duration { return &c.Auth.EmailVerification.TTL })},
+	{"AUTH_EMAIL_VERIFICATION_RESEND_INTERVAL", durationSetter(func(c *Config) *Duration { return &c.Auth.EmailVerification.ResendInterval })},
+	{"AUTH_EMAIL_VERIFICATION_URL", func(c *Config, v string) error { c.Auth.EmailVerification.URL = v; return nil }},
+	{"AUTH_PASSWORD_ALGORITHM", func(c *Config, v string) error { c.Auth.Password.Algorithm = v; return nil }},
+	{"AUTH_PASSWORD_BCRYPT_COST", intSetter(func(c *Config) *int { return &c.Auth.Password.BcryptCost })},
+	{"AUTH_PASSWORD_MIN_LENGTH", intSetter(func(c *Config) *int { return &c.Auth.Password.MinLength })},
+	{"AUTH_PASSWORD_MAX_LENGTH", intSetter(func(c *Config) *int { return &c.Auth.Password.MaxLength })},
+	{"AUTH_PASSWORD_MIN_CLASSES", intSetter(func(c *Config) *int { return &c.Auth.Password.MinClasses })},
+	{"AUTH_MFA_ISSUER", func(c *Config, v string) error { c.Auth.MFA.Issuer = v; return nil }},
+	{"AUTH_MFA_CHALLENGE_TTL", durationSetter(func(c *Config) *Duration { return &c.Auth.MFA.ChallengeTTL })},
+	{"AUTH_MFA_MAX_FAILURES", intSetter(func(c *Config) *int { return &c.Auth.MFA.MaxFailures })},
+	{"AUTH_MFA_REQUIRE_ADMIN", boolSetter(func(c *Config) *bool { return &c.Auth.MFA.RequireAdmin })},
+	{"AUTH_PASSWORD_LOGIN", boolSetter(func(c *Config) *bool { return &c.Auth.PasswordLogin })},
+	{"AUTH_OIDC_ISSUER", func(c *Config, v string) error { c.Auth.OIDC.Issuer = v; return nil }},
+	{"AUTH_OIDC_CLIENT_ID", func(c *Config, v string) error { c.Auth.OIDC.ClientID = v; return nil }},
+	{"AUTH_OIDC_CLIENT_SECRET", func(c *Config, v string) error { c.Auth.OIDC.ClientSecret = Secret(v); return nil }},
+	{"AUTH_OIDC_REDIRECT_URL", func(c *Config, v string) error { c.Auth.OIDC.RedirectURL = v; return nil }},
+	{"AUTH_OIDC_SCOPES", func(c *Config, v string) error { c.Auth.OIDC.Scopes = splitList(v); return nil }},
+	{"AUTH_OIDC_NAME", func(c *Config, v string) error { c.Auth.OIDC.Name = v; return nil }},
+	{"AUTH_OIDC_GROUPS_CLAIM", func(c *Config, v string) error { c.Auth.OIDC.GroupsClaim = v; return nil }},
+	{"AUTH_OIDC_ROLE_MAPPING", func(c *Config, v string) error {
+		mapping, err := parseRoleMapping(v)
+		if err != nil {
+			return err
+		}
+		c.Auth.OIDC.RoleMapping = mapping
+		return nil
+	}},
+	{"AUTH_OIDC_LOGIN_TTL", durationSetter(func(c *Config) *Duration { return &c.Auth.OIDC.LoginTTL })},
+	{"RATE_LIMIT_BACKEND", func(c *Config, v string) error { c.RateLimit.Backend = v; return nil }},
+	{"RATE_LIMIT_IP", func(c *Config, v string) error { return c.RateLimit.IP.UnmarshalText([]byte(v)) }},
+	{"RATE_LIMIT_USERNAME", func(c *Config, v string) error { return c.RateLimit.Username.UnmarshalText([]byte(v)) }},
+	{"WEBHOOK_ALLOW_PRIVATE_TARGETS", boolSetter(func(c *Config) *bool { return &c.Webhook.AllowPrivateTargets })},
+	{"SMTP_HOST", func(c *Config, v string) error { c.SMTP.Host = v; return nil }},
+	{"SMTP_PORT", intSetter(func(c *Config) *int { return &c.SMTP.Port })},
+	{"SMTP_USERNAME", func(c *Config, v string) error { c.SMTP.Username = v; return nil }},
+	{"SMTP_PASSWORD", func(c *Config, v string) error { c.SMTP.Password = Secret(v); return nil }},
+	{"SMTP_FROM", func(c *Config, v string) error { c.SMTP.From = v; return nil }},
+	{"SMTP_OUTBOX_DIR", func(c *Config, v string) error { c.SMTP.OutboxDir = v; return nil }},
+	{"METRICS_ENABLED", boolSetter(func(c *Config) *bool { return &c.Metrics.Enabled })},
+	{"METRICS_PATH", func(c *Config, v string) error { c.Metrics.Path = v; return nil }},
+	{"METRICS_TOKEN", func(c *Config, v string) error { c.Metrics.Token = Secret(v); return nil }},
+	{"METRICS_ALLOWED_NETWORKS", func(c *Config, v string) error { c.Metrics.AllowedNetworks = splitList(v); return nil }},
+	{"TRACING_EXPORTER", func(c *Config, v string) error { c.Tracing.Exporter = v; return nil }},
+	{"TRACING_ENDPOINT", func(c *Config, v string) error { c.Tracing.Endpoint = v; return nil }},
+	{"TRACING_INSECURE", boolSetter(func(c *Config) *bool { return &c.Tracing.Insecure })},
+	{"TRACING_SERVICE_NAME", func(c *Config, v string) error { c.Tracing.ServiceName = v; return nil }},
+	{"TRACING_SAMPLE_RATIO", func(c *Config, v string) error {
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("应为0到1之间的小数: %q", v)
+		}
+		c.Tracing.SampleRatio = ratio
+		return nil
+	}},
+}
+
+func intSetter(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("应为整数: %q", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolSetter(field func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("应为布尔值: %q", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func durationSetter(field func(c *Config) *Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		return field(c).UnmarshalText([]byte(v))
+	}
+}
+
+// parseSample 解析 "路由=比例,路由=比例" 格式的采样规则
+func parseSample(v string) (map[string]float64, error) {
+	sample := make(map[string]float64)
+	for _, item := range splitList(v) {
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("应为 路由=比例: %q", item)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(item[i+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的采样比例: %q", item)
+		}
+		sample[strings.TrimSpace(item[:i])] = rate
+	}
+	return sample, nil
+}
+
+// parseRoleMapping 解析 "组=角色,组=角色" 格式的组到角色的映射
+func parseRoleMapping(v string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, item := range splitList(v) {
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("应为 组=角色: %q", item)
+		}
+		mapping[strings.TrimSpace(item[:i])] = strings.TrimSpace(item[i+1:])
+	}
+	return mapping, nil
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Load 按默认值、配置文件、环境变量、命令行参数的顺序加载并校验配置
+// args通常为os.Args[1:]；密码等敏感项不提供命令行参数，避免出现在进程列表中
+func Load(args []string) (*Config, error) {
+	return load(args, os.LookupEnv, os.Stderr)
+}
+
+// LoadConfig 加载配置，失败时退出程序
+func LoadConfig() *Config {
+	cfg, err := Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return cfg
+}
+
+func load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	cfg := Default()
+
+	// 命令行参数，稍后在环境变量之后应用
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.SetOutput(output)
+	configFile := fs.String("config", "", "配置文件路径（.yaml、.yml 或 .toml）")
+	env := fs.String("env", "", "运行环境：development、production、test")
+	addr := fs.String("addr", "", "监听地址，如 :8080")
+	dbDriver := fs.String("db-driver", "", "数据库驱动")
+	dbHost := fs.String("db-host", "", "数据库主机")
+	dbPort := fs.String("db-port", "", "数据库端口")
+	dbName := fs.String("db-name", "", "数据库名")
+	dbMaxOpen := fs.Int("db-max-open-conns", 0, "最大打开连接数")
+	dbMaxIdle := fs.Int("db-max-idle-conns", 0, "最大空闲连接数")
+	dbAutoMigrate := fs.Bool("db-auto-migrate", false, "启动时自动执行数据库迁移")
+	logLevel := fs.String("log-level", "", "日志级别：debug、info、warn、error")
+	logFormat := fs.String("log-format", "", "日志格式：text、json")
+	logFile := fs.String("log-file", "", "日志文件路径")
+	corsOrigins := fs.String("cors-origins", "", "允许的跨域来源，逗号分隔")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	// 配置文件
+	path := *configFile
+	explicit := path != ""
+	if !explicit {
+		if v, ok := lookupEnv("APP_CONFIG"); ok && v != "" {
+			path, explicit = v, true
+		}
+	}
+	if !explicit {
+		for _, candidate := range DefaultFiles {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+		cfg.File = path
+	}
+
+	// 环境变量
+	for _, ev := range envVars {
+		value, ok := lookupEnv(ev.name)
+		if !ok {
+			continue
+		}
+		if err := ev.apply(cfg, value); err != nil {
+			return nil, fmt.Errorf("环境变量 %s 无效: %w", ev.name, err)
+		}
+	}
+
+	// 只应用显式设置过的命令行参数
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "env":
+			cfg.Env = *env
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "db-driver":
+			cfg.Database.Driver = *dbDriver
+		case "db-host":
+			cfg.Database.Host = *dbHost
+		case "db-port":
+			cfg.Database.Port = *dbPort
+		case "db-name":
+			cfg.Database.DBName = *dbName
+		case "db-max-open-conns":
+			cfg.Database.MaxOpenConns = *dbMaxOpen
+		case "db-max-idle-conns":
+			cfg.Database.MaxIdleConns = *dbMaxIdle
+		case "db-auto-migrate":
+			cfg.Database.AutoMigrate = *dbAutoMigrate
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		case "log-file":
+			cfg.Log.File = *logFile
+		case "cors-origins":
+			cfg.CORS.AllowedOrigins = splitList(*corsOrigins)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile 读取配置文件覆盖当前配置，未知的配置项视为错误
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s（仅支持 .yaml、.yml、.toml）", path)
+	}
+	return nil
+}
@@ -2,13 +2,13 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"questionnaire-system/backend/config"
+	"questionnaire-system/backend/database/migrations"
 	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/password"
+	"time"
 
-	"crypto/md5"
-
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
@@ -17,40 +17,63 @@ type Database struct {
 	*gorm.DB
 }
 
-// InitDB 初始化数据库连接
-func InitDB(cfg *config.Config) (*Database, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.DBName)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// Open 打开数据库连接并设置连接池，不检查表结构
+func Open(cfg *config.Config) (*Database, error) {
+	dialector, err := Dialector(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		slog.Error("数据库连接失败", "error", err)
+		return nil, err
+	}
+
+	// 连接池
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Printf("数据库连接失败: %v", err)
 		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime.Duration)
+	if IsMemorySQLite(cfg.Database) {
+		// 内存数据库的每个连接都是独立的数据库
+		sqlDB.SetMaxOpenConns(1)
+	}
 
-	// 自动迁移数据表结构
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Questionnaire{},
-		&models.Question{},
-		&models.Answer{},
-		&models.Submission{},
-	)
+	return &Database{db}, nil
+}
+
+// InitDB 初始化数据库连接
+// 表结构由版本化迁移管理（见 migrate 子命令），结构版本与程序不一致时返回错误
+func InitDB(cfg *config.Config) (*Database, error) {
+	database, err := Open(cfg)
 	if err != nil {
-		log.Printf("数据库迁移失败: %v", err)
+		return nil, err
+	}
+	db := database.DB
+
+	migrator := migrations.New(db)
+	migrator.Logf = func(format string, args ...interface{}) { slog.Info(fmt.Sprintf(format, args...)) }
+	if cfg.Database.AutoMigrate {
+		if err := migrator.Up(); err != nil {
+			slog.Error("数据库迁移失败", "error", err)
+			return nil, err
+		}
+	}
+	if err := migrator.Check(); err != nil {
+		slog.Error("数据库结构检查失败", "error", err)
 		return nil, err
 	}
 
-	log.Println("数据库连接成功")
+	slog.Info("数据库连接成功", "driver", cfg.Database.Driver)
 
 	// 创建测试账号
 	createTestAccounts(db)
 
-	return &Database{db}, nil
+	return database, nil
 }
 
 // createTestAccounts 创建测试账号
@@ -61,21 +84,26 @@ func createTestAccounts(db *gorm.DB) {
 
 	// 如果没有管理员账号，创建一个
 	if adminCount == 0 {
-		// 简单的密码加密（实际应用中应使用bcrypt等更安全的方式）
-		adminPassword := fmt.Sprintf("%x", md5.Sum([]byte("admin123")))
+		adminPassword, err := password.Default().Hash("admin123")
+		if err != nil {
+			slog.Error("生成管理员密码哈希失败", "error", err)
+			return
+		}
 
+		verifiedAt := time.Now()
 		admin := models.User{
-			Username: "admin",
-			Password: adminPassword,
-			Email:    "admin@example.com",
-			IsAdmin:  true,
+			Username:        "admin",
+			Password:        adminPassword,
+			Email:           "admin@example.com",
+			IsAdmin:         true,
+			EmailVerifiedAt: &verifiedAt,
 		}
 
 		result := db.Create(&admin)
 		if result.Error != nil {
-			log.Printf("创建管理员账号失败: %v", result.Error)
+			slog.Error("创建管理员账号失败", "error", result.Error)
 		} else {
-			log.Printf("已创建管理员账号: admin/admin123")
+			slog.Warn("已创建默认管理员账号，请尽快修改密码", "username", admin.Username)
 		}
 	}
 
@@ -85,21 +113,26 @@ func createTestAccounts(db *gorm.DB) {
 
 	// 如果没有测试用户账号，创建一个
 	if userCount == 0 {
-		// 简单的密码加密
-		testPassword := fmt.Sprintf("%x", md5.Sum([]byte("test123")))
+		testPassword, err := password.Default().Hash("test123")
+		if err != nil {
+			slog.Error("生成测试用户密码哈希失败", "error", err)
+			return
+		}
 
+		verifiedAt := time.Now()
 		user := models.User{
-			Username: "test",
-			Password: testPassword,
-			Email:    "test@example.com",
-			IsAdmin:  false,
+			Username:        "test",
+			Password:        testPassword,
+			Email:           "test@example.com",
+			IsAdmin:         false,
+			EmailVerifiedAt: &verifiedAt,
 		}
 
 		result := db.Create(&user)
 		if result.Error != nil {
-			log.Printf("创建测试用户账号失败: %v", result.Error)
+			slog.Error("创建测试用户账号失败", "error", result.Error)
 		} else {
-			log.Printf("已创建测试用户账号: test/test123")
+			slog.Warn("已创建测试用户账号", "username", user.Username)
 		}
 	}
 }
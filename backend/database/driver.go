@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"questionnaire-system/backend/config"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// 支持的数据库驱动
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// sqlitePragmas SQLite连接参数：启用外键、等待锁而不是立即报错
+var sqlitePragmas = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+}
+
+// Dialector 根据配置的驱动创建GORM方言
+func Dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	dsn := DSN(cfg)
+	switch cfg.Driver {
+	case DriverMySQL:
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
+	}
+}
+
+// DSN 返回连接字符串：优先使用配置的DSN，否则根据主机、端口等字段拼接
+func DSN(cfg config.DatabaseConfig) string {
+	dsn := cfg.DSN.Value()
+
+	switch cfg.Driver {
+	case DriverMySQL:
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+				cfg.User,
+				cfg.Password.Value(),
+				cfg.Host,
+				portOrDefault(cfg.Port, "3306"),
+				cfg.DBName)
+		}
+	case DriverPostgres:
+		if dsn == "" {
+			dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=prefer",
+				cfg.Host,
+				portOrDefault(cfg.Port, "5432"),
+				cfg.User,
+				quotePostgres(cfg.Password.Value()),
+				cfg.DBName)
+		}
+	case DriverSQLite:
+		if dsn == "" {
+			dsn = cfg.DBName + ".db"
+		}
+		if !strings.Contains(dsn, "_pragma=") {
+			sep := "?"
+			if strings.Contains(dsn, "?") {
+				sep = "&"
+			}
+			dsn += sep + strings.Join(sqlitePragmas, "&")
+		}
+	}
+
+	return dsn
+}
+
+// IsMemorySQLite 是否为SQLite内存数据库（每个连接各自独立，只能使用单个连接）
+func IsMemorySQLite(cfg config.DatabaseConfig) bool {
+	return cfg.Driver == DriverSQLite && strings.Contains(cfg.DSN.Value(), ":memory:")
+}
+
+func portOrDefault(port, def string) string {
+	if port == "" {
+		return def
+	}
+	return port
+}
+
+// quotePostgres 按libpq连接串规则为包含空格或引号的值加引号
+func quotePostgres(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
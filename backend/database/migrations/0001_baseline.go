@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 基线迁移：引入版本化迁移之前由AutoMigrate创建的表
+// 这里使用当时的结构快照，而不是models包中的模型，模型之后的变化由新的迁移描述
+
+type user0001 struct {
+	ID        uint      `gorm:"primaryKey"`
+	Username  string    `gorm:"size:50;not null;uniqueIndex"`
+	Password  string    `gorm:"size:255;not null"`
+	Email     string    `gorm:"size:100;uniqueIndex"`
+	Phone     string    `gorm:"size:20"`
+	IsAdmin   bool      `gorm:"default:false"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (user0001) TableName() string { return "users" }
+
+type questionnaire0001 struct {
+	ID          uint   `gorm:"primaryKey"`
+	Title       string `gorm:"size:255;not null"`
+	Description string `gorm:"type:text"`
+	CreatedBy   uint   `gorm:"not null"`
+	StartTime   time.Time
+	EndTime     time.Time
+	IsPublished bool      `gorm:"default:false"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (questionnaire0001) TableName() string { return "questionnaires" }
+
+type question0001 struct {
+	ID              uint      `gorm:"primaryKey"`
+	QuestionnaireID uint      `gorm:"not null"`
+	Title           string    `gorm:"size:255;not null"`
+	Type            string    `gorm:"size:50;not null"`
+	Required        bool      `gorm:"default:false"`
+	Options         string    `gorm:"type:text"`
+	Sort            int       `gorm:"default:0"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+func (question0001) TableName() string { return "questions" }
+
+type answer0001 struct {
+	ID         uint      `gorm:"primaryKey"`
+	QuestionID uint      `gorm:"not null"`
+	UserID     uint      `gorm:"not null"`
+	Content    string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+func (answer0001) TableName() string { return "answers" }
+
+type submission0001 struct {
+	ID              uint      `gorm:"primaryKey"`
+	QuestionnaireID uint      `gorm:"not null"`
+	UserID          uint      `gorm:"not null"`
+	SubmittedAt     time.Time `gorm:"autoCreateTime"`
+	IPAddress       string    `gorm:"size:50"`
+}
+
+func (submission0001) TableName() string { return "submissions" }
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx,
+				&user0001{},
+				&questionnaire0001{},
+				&question0001{},
+				&answer0001{},
+				&submission0001{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx,
+				&submission0001{},
+				&answer0001{},
+				&question0001{},
+				&questionnaire0001{},
+				&user0001{},
+			)
+		},
+	})
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook订阅、发件箱和投递记录
+
+type webhookSubscription0002 struct {
+	ID              uint      `gorm:"primaryKey"`
+	QuestionnaireID *uint     `gorm:"index"`
+	TargetURL       string    `gorm:"size:500;not null"`
+	Secret          string    `gorm:"size:100;not null"`
+	Events          string    `gorm:"size:255;not null"`
+	IsActive        bool      `gorm:"default:true"`
+	CreatedBy       uint      `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+func (webhookSubscription0002) TableName() string { return "webhook_subscriptions" }
+
+type webhookOutbox0002 struct {
+	ID             uint      `gorm:"primaryKey"`
+	SubscriptionID uint      `gorm:"not null;index"`
+	EventType      string    `gorm:"size:50;not null"`
+	Payload        string    `gorm:"type:text"`
+	Status         string    `gorm:"size:20;not null;index"`
+	Attempts       int       `gorm:"default:0"`
+	NextAttemptAt  time.Time `gorm:"index"`
+	LockedUntil    *time.Time
+	LastError      string `gorm:"type:text"`
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+func (webhookOutbox0002) TableName() string { return "webhook_outbox" }
+
+type webhookDeliveryAttempt0002 struct {
+	ID           uint `gorm:"primaryKey"`
+	OutboxID     uint `gorm:"not null;index"`
+	Attempt      int
+	StatusCode   int
+	ResponseBody string `gorm:"type:text"`
+	Error        string `gorm:"type:text"`
+	DurationMs   int64
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+func (webhookDeliveryAttempt0002) TableName() string { return "webhook_delivery_attempts" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "webhooks",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx,
+				&webhookSubscription0002{},
+				&webhookOutbox0002{},
+				&webhookDeliveryAttempt0002{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx,
+				&webhookDeliveryAttempt0002{},
+				&webhookOutbox0002{},
+				&webhookSubscription0002{},
+			)
+		},
+	})
+}
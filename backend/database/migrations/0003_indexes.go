@@ -0,0 +1,49 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 问卷列表、结果和级联删除按外键查询所需的索引
+
+type question0003 struct {
+	QuestionnaireID uint `gorm:"index"`
+}
+
+func (question0003) TableName() string { return "questions" }
+
+type answer0003 struct {
+	QuestionID uint `gorm:"index"`
+}
+
+func (answer0003) TableName() string { return "answers" }
+
+type submission0003 struct {
+	QuestionnaireID uint `gorm:"index"`
+	UserID          uint `gorm:"index"`
+}
+
+func (submission0003) TableName() string { return "submissions" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "foreign_key_indexes",
+		Up: func(tx *gorm.DB) error {
+			if err := createIndexes(tx, &question0003{}, "QuestionnaireID"); err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &answer0003{}, "QuestionID"); err != nil {
+				return err
+			}
+			return createIndexes(tx, &submission0003{}, "QuestionnaireID", "UserID")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &submission0003{}, "QuestionnaireID", "UserID"); err != nil {
+				return err
+			}
+			if err := dropIndexes(tx, &answer0003{}, "QuestionID"); err != nil {
+				return err
+			}
+			return dropIndexes(tx, &question0003{}, "QuestionnaireID")
+		},
+	})
+}
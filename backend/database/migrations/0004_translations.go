@@ -0,0 +1,63 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 问卷的多语言翻译：问卷的原文语言、翻译表，以及提交时使用的语言
+
+type questionnaire0004 struct {
+	DefaultLocale string `gorm:"size:20;not null;default:zh-CN"`
+}
+
+func (questionnaire0004) TableName() string { return "questionnaires" }
+
+type submission0004 struct {
+	Locale string `gorm:"size:20"`
+}
+
+func (submission0004) TableName() string { return "submissions" }
+
+type questionnaireTranslation0004 struct {
+	ID              uint   `gorm:"primaryKey"`
+	QuestionnaireID uint   `gorm:"not null;uniqueIndex:idx_questionnaire_translations_locale"`
+	Locale          string `gorm:"size:20;not null;uniqueIndex:idx_questionnaire_translations_locale"`
+	Title           string `gorm:"size:255"`
+	Description     string `gorm:"type:text"`
+}
+
+func (questionnaireTranslation0004) TableName() string { return "questionnaire_translations" }
+
+type questionTranslation0004 struct {
+	ID              uint   `gorm:"primaryKey"`
+	QuestionnaireID uint   `gorm:"not null;index"`
+	QuestionID      uint   `gorm:"not null;uniqueIndex:idx_question_translations_locale"`
+	Locale          string `gorm:"size:20;not null;uniqueIndex:idx_question_translations_locale"`
+	Title           string `gorm:"size:255"`
+	Options         string `gorm:"type:text"`
+}
+
+func (questionTranslation0004) TableName() string { return "question_translations" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "translations",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &questionnaire0004{}, "DefaultLocale"); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &submission0004{}, "Locale"); err != nil {
+				return err
+			}
+			return createTables(tx, &questionnaireTranslation0004{}, &questionTranslation0004{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &questionTranslation0004{}, &questionnaireTranslation0004{}); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &submission0004{}, "Locale"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &questionnaire0004{}, "DefaultLocale")
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 登录保护：账户的连续登录失败次数和锁定时间，以及多实例共享的限流令牌桶
+
+type user0005 struct {
+	FailedLogins int `gorm:"not null;default:0"`
+	LockedUntil  *time.Time
+}
+
+func (user0005) TableName() string { return "users" }
+
+type rateLimitBucket0005 struct {
+	BucketKey  string    `gorm:"primaryKey;size:191"`
+	Tokens     float64   `gorm:"not null"`
+	RefilledAt int64     `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null;index"`
+	Version    int64     `gorm:"not null"`
+}
+
+func (rateLimitBucket0005) TableName() string { return "rate_limit_buckets" }
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "login_protection",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &user0005{}, "FailedLogins", "LockedUntil"); err != nil {
+				return err
+			}
+			return createTables(tx, &rateLimitBucket0005{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &rateLimitBucket0005{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &user0005{}, "FailedLogins", "LockedUntil")
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 自助重置密码：一次性的重置令牌（只保存哈希），以及重置后使旧会话失效的时间点
+
+type user0006 struct {
+	SessionsRevokedAt *time.Time
+}
+
+func (user0006) TableName() string { return "users" }
+
+type passwordResetToken0006 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (passwordResetToken0006) TableName() string { return "password_reset_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "password_resets",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &user0006{}, "SessionsRevokedAt"); err != nil {
+				return err
+			}
+			return createTables(tx, &passwordResetToken0006{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &passwordResetToken0006{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &user0006{}, "SessionsRevokedAt")
+		},
+	})
+}
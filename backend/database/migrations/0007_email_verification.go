@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 邮箱验证：用户的邮箱验证时间、一次性的验证令牌（只保存哈希），以及问卷是否只允许已验证邮箱的用户填写
+// 迁移之前注册的用户视为已验证，不影响其继续填写问卷
+
+type user0007 struct {
+	EmailVerifiedAt *time.Time
+}
+
+func (user0007) TableName() string { return "users" }
+
+type questionnaire0007 struct {
+	RequireVerifiedEmail bool `gorm:"not null;default:false"`
+}
+
+func (questionnaire0007) TableName() string { return "questionnaires" }
+
+type emailVerificationToken0007 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Email     string    `gorm:"size:100;not null"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (emailVerificationToken0007) TableName() string { return "email_verification_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "email_verification",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &user0007{}, "EmailVerifiedAt"); err != nil {
+				return err
+			}
+			err := tx.Table("users").Where("email_verified_at IS NULL").
+				UpdateColumn("email_verified_at", gorm.Expr("created_at")).Error
+			if err != nil {
+				return err
+			}
+			if err := addColumns(tx, &questionnaire0007{}, "RequireVerifiedEmail"); err != nil {
+				return err
+			}
+			return createTables(tx, &emailVerificationToken0007{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &emailVerificationToken0007{}); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &questionnaire0007{}, "RequireVerifiedEmail"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &user0007{}, "EmailVerifiedAt")
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证：用户的TOTP密钥和启用时间、一次性的恢复码，以及密码校验通过后等待输入验证码的登录挑战（都只保存哈希）
+
+type user0008 struct {
+	TOTPSecret      string `gorm:"size:64"`
+	TOTPEnabledAt   *time.Time
+	TOTPLastCounter int64 `gorm:"not null;default:0"`
+}
+
+func (user0008) TableName() string { return "users" }
+
+type recoveryCode0008 struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"size:64;not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (recoveryCode0008) TableName() string { return "recovery_codes" }
+
+type mfaChallenge0008 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Failures  int       `gorm:"not null;default:0"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (mfaChallenge0008) TableName() string { return "mfa_challenges" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "two_factor",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &user0008{}, "TOTPSecret", "TOTPEnabledAt", "TOTPLastCounter"); err != nil {
+				return err
+			}
+			return createTables(tx, &recoveryCode0008{}, &mfaChallenge0008{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, &mfaChallenge0008{}, &recoveryCode0008{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &user0008{}, "TOTPSecret", "TOTPEnabledAt", "TOTPLastCounter")
+		},
+	})
+}
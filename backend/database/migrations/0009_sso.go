@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 单点登录：用户在外部身份提供方的账号（按发行方和subject唯一），以及等待回调的登录（state只保存哈希）
+
+type identity0009 struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;index"`
+	Issuer      string `gorm:"size:255;not null;uniqueIndex:idx_identities_subject"`
+	Subject     string `gorm:"size:255;not null;uniqueIndex:idx_identities_subject"`
+	Email       string `gorm:"size:100"`
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (identity0009) TableName() string { return "identities" }
+
+type ssoLogin0009 struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func (ssoLogin0009) TableName() string { return "sso_logins" }
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "sso",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &identity0009{}, &ssoLogin0009{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &ssoLogin0009{}, &identity0009{})
+		},
+	})
+}
@@ -0,0 +1,79 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// createTables 创建不存在的表
+// 基线迁移在已由AutoMigrate建表的数据库上执行时只记录版本，不修改现有表
+func createTables(tx *gorm.DB, tables ...interface{}) error {
+	for _, table := range tables {
+		if tx.Migrator().HasTable(table) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropTables 按顺序删除存在的表
+func dropTables(tx *gorm.DB, tables ...interface{}) error {
+	for _, table := range tables {
+		if err := tx.Migrator().DropTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIndexes 创建表上不存在的索引，names为结构快照中的字段名或索引名
+func createIndexes(tx *gorm.DB, table interface{}, names ...string) error {
+	for _, name := range names {
+		if tx.Migrator().HasIndex(table, name) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(table, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndexes 删除表上存在的索引
+func dropIndexes(tx *gorm.DB, table interface{}, names ...string) error {
+	for _, name := range names {
+		if !tx.Migrator().HasIndex(table, name) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(table, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumns 添加表上不存在的列，names为结构快照中的字段名
+func addColumns(tx *gorm.DB, table interface{}, names ...string) error {
+	for _, name := range names {
+		if tx.Migrator().HasColumn(table, name) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(table, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns 删除表上存在的列
+func dropColumns(tx *gorm.DB, table interface{}, names ...string) error {
+	for _, name := range names {
+		if !tx.Migrator().HasColumn(table, name) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(table, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
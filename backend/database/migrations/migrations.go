@@ -0,0 +1,383 @@
+package migrations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一个版本化的数据库结构变更
+// Up和Down各自在一个事务中执行（MySQL的DDL不支持事务回滚，编写时应保证可重复执行）
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// registry 所有已注册的迁移，由各迁移文件在init中注册
+var registry []Migration
+
+// register 注册迁移，版本号重复时panic
+func register(m Migration) {
+	for _, existing := range registry {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("迁移版本重复: %d", m.Version))
+		}
+	}
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool { return registry[i].Version < registry[j].Version })
+}
+
+// All 按版本号排序的全部迁移
+func All() []Migration {
+	return append([]Migration(nil), registry...)
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 迁移记录表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrationLock 迁移锁，同一时间只允许一个实例执行迁移
+type migrationLock struct {
+	ID       uint      `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string    `gorm:"size:255"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (migrationLock) TableName() string {
+	return "schema_migrations_lock"
+}
+
+// ErrSchemaBehind 数据库结构落后于程序
+var ErrSchemaBehind = errors.New("数据库结构版本落后")
+
+// ErrSchemaAhead 数据库结构版本高于程序所支持的版本
+var ErrSchemaAhead = errors.New("数据库结构版本高于程序支持的版本")
+
+// Status 单个迁移的执行状态
+type Status struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator 执行迁移
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+
+	LockTimeout time.Duration // 等待其他实例释放锁的最长时间
+	StaleLock   time.Duration // 超过该时间未刷新的锁视为失效（持有者异常退出），持有者每隔三分之一该时间刷新一次
+	Logf        func(format string, args ...interface{})
+}
+
+// New 使用全部已注册迁移创建Migrator
+func New(db *gorm.DB) *Migrator {
+	return &Migrator{
+		DB:          db,
+		Migrations:  All(),
+		LockTimeout: time.Minute,
+		StaleLock:   10 * time.Minute,
+		Logf:        func(string, ...interface{}) {},
+	}
+}
+
+// Latest 程序支持的最新版本
+func (m *Migrator) Latest() uint {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// ensureTables 创建迁移记录表和锁表
+func (m *Migrator) ensureTables() error {
+	for _, table := range []interface{}{&SchemaMigration{}, &migrationLock{}} {
+		if !m.DB.Migrator().HasTable(table) {
+			if err := m.DB.Migrator().CreateTable(table); err != nil && !m.DB.Migrator().HasTable(table) {
+				return fmt.Errorf("创建迁移记录表失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// applied 已执行的迁移，按版本号索引
+func (m *Migrator) applied() (map[uint]SchemaMigration, error) {
+	result := make(map[uint]SchemaMigration)
+	if !m.DB.Migrator().HasTable(&SchemaMigration{}) {
+		return result, nil
+	}
+
+	var rows []SchemaMigration
+	if err := m.DB.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// Current 当前数据库已执行的最高版本，未执行任何迁移时为0
+func (m *Migrator) Current() (uint, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	var current uint
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+// Status 全部迁移的执行状态，包括数据库中存在但程序未知的版本
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	known := make(map[uint]bool)
+	for _, mig := range m.Migrations {
+		known[mig.Version] = true
+		row, ok := applied[mig.Version]
+		statuses = append(statuses, Status{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: row.AppliedAt,
+		})
+	}
+	for version, row := range applied {
+		if !known[version] {
+			statuses = append(statuses, Status{Version: version, Name: row.Name + "（未知）", Applied: true, AppliedAt: row.AppliedAt})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check 检查数据库结构是否与程序一致，不一致时返回ErrSchemaBehind或ErrSchemaAhead
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	var pending []uint
+	for _, mig := range m.Migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: 有%d个迁移未执行（%v），请先运行 migrate up", ErrSchemaBehind, len(pending), pending)
+	}
+
+	current, _ := m.Current()
+	if current > m.Latest() {
+		return fmt.Errorf("%w: 数据库版本%d，程序最新版本%d", ErrSchemaAhead, current, m.Latest())
+	}
+	return nil
+}
+
+// Up 执行所有未执行的迁移
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down 回滚最近的steps个迁移
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.Migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.revert(mig); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To 迁移到指定版本：高于当前版本时执行up，低于时按倒序执行down
+func (m *Migrator) To(target uint) error {
+	if target != 0 && !m.known(target) {
+		return fmt.Errorf("未知的迁移版本: %d", target)
+	}
+
+	return m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.Migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= target {
+				if err := m.apply(mig); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			mig := m.Migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > target {
+				if err := m.revert(mig); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) known(version uint) bool {
+	for _, mig := range m.Migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 执行一个迁移并记录版本
+func (m *Migrator) apply(mig Migration) error {
+	m.Logf("执行迁移 %04d_%s", mig.Version, mig.Name)
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("迁移 %04d_%s 执行失败: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// revert 回滚一个迁移并删除版本记录
+func (m *Migrator) revert(mig Migration) error {
+	if mig.Down == nil {
+		return fmt.Errorf("迁移 %04d_%s 不支持回滚", mig.Version, mig.Name)
+	}
+
+	m.Logf("回滚迁移 %04d_%s", mig.Version, mig.Name)
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, mig.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("迁移 %04d_%s 回滚失败: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// withLock 持有迁移锁执行fn，避免多个实例同时迁移
+// 锁通过向锁表插入主键固定的记录实现，适用于所有数据库驱动；
+// 持有期间定期刷新加锁时间，执行较久的迁移不会被其他实例当作失效的锁清除
+func (m *Migrator) withLock(fn func() error) error {
+	if err := m.ensureTables(); err != nil {
+		return err
+	}
+
+	owner := lockOwner()
+	deadline := time.Now().Add(m.LockTimeout)
+
+	for {
+		err := m.DB.Create(&migrationLock{ID: 1, LockedBy: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			break
+		}
+
+		var holder migrationLock
+		if m.DB.First(&holder, 1).Error == nil && time.Since(holder.LockedAt) > m.StaleLock {
+			m.Logf("清除失效的迁移锁: 持有者=%s, 加锁时间=%s", holder.LockedBy, holder.LockedAt.Format(time.RFC3339))
+			m.DB.Where("id = ? AND locked_by = ?", 1, holder.LockedBy).Delete(&migrationLock{})
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待迁移锁超时，当前持有者: %s", holder.LockedBy)
+		}
+		m.Logf("迁移锁被 %s 持有，等待中...", holder.LockedBy)
+		time.Sleep(time.Second)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.heartbeat(owner, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+		m.DB.Where("id = ? AND locked_by = ?", 1, owner).Delete(&migrationLock{})
+	}()
+	return fn()
+}
+
+// heartbeat 每隔StaleLock的三分之一刷新一次加锁时间，直到stop关闭
+func (m *Migrator) heartbeat(owner string, stop <-chan struct{}) {
+	if m.StaleLock <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.StaleLock / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			result := m.DB.Model(&migrationLock{}).
+				Where("id = ? AND locked_by = ?", 1, owner).
+				Update("locked_at", time.Now())
+			if result.Error != nil {
+				m.Logf("刷新迁移锁失败: %v", result.Error)
+			} else if result.RowsAffected == 0 {
+				m.Logf("迁移锁已被其他实例清除: 持有者=%s", owner)
+			}
+		}
+	}
+}
+
+// lockOwner 锁持有者标识：主机名和进程号便于排查，随机后缀区分同一进程中的多个Migrator
+func lockOwner() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,150 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Codebook CSV数据包中的编码手册（codebook.json）
+type Codebook struct {
+	Questionnaire struct {
+		ID          uint   `json:"id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	} `json:"questionnaire"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	DataFile    string     `json:"data_file"`
+	Encoding    string     `json:"encoding"`
+	NAValue     string     `json:"na_value"`        // 缺失值在CSV中的表示
+	DateFormat  string     `json:"datetime_format"` // 日期时间变量的格式
+	Variables   []Variable `json:"variables"`
+}
+
+// 数据包内的文件名
+const (
+	bundleDataFile     = "responses.csv"
+	bundleCodebookFile = "codebook.json"
+)
+
+const bundleDateTimeLayout = "2006-01-02 15:04:05"
+
+// WriteCSVBundle 写出适用于R和pandas的数据包（zip）
+// 包含responses.csv和codebook.json，CSV中以空单元格表示缺失值
+func WriteCSVBundle(w io.Writer, coded *Coded) error {
+	zw := zip.NewWriter(w)
+
+	fw, err := zw.Create(bundleDataFile)
+	if err != nil {
+		return err
+	}
+	if err := writeCodedCSV(fw, coded); err != nil {
+		return err
+	}
+
+	fw, err = zw.Create(bundleCodebookFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(bundleCodebook(coded)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeCodedCSV(w io.Writer, coded *Coded) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(coded.Variables))
+	for i, v := range coded.Variables {
+		header[i] = v.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range coded.Cases {
+		record := make([]string, len(coded.Variables))
+		for i, v := range coded.Variables {
+			record[i] = csvValue(v, row[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue 将取值格式化为CSV字段，缺失值输出为空
+func csvValue(v Variable, value Value) string {
+	switch v.Kind {
+	case KindString:
+		return escapeFormula(value.Str)
+	case KindDateTime:
+		if value.Time.IsZero() {
+			return ""
+		}
+		return value.Time.Format(bundleDateTimeLayout)
+	default:
+		if value.Missing || isMissingCode(v, value.Num) {
+			return ""
+		}
+		return strconv.FormatFloat(value.Num, 'f', -1, 64)
+	}
+}
+
+// escapeFormula 以 = + - @ 开头的文本前加单引号，避免在Excel等表格软件中打开时被当作公式执行
+// 只用于字符串变量，数值变量（包括负数编码）保持原样
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func isMissingCode(v Variable, num float64) bool {
+	for _, m := range v.Missing {
+		if num == m {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleCodebook 生成编码手册，去掉CSV中不会出现的缺失值编码
+func bundleCodebook(coded *Coded) Codebook {
+	cb := Codebook{
+		GeneratedAt: time.Now(),
+		DataFile:    bundleDataFile,
+		Encoding:    "UTF-8",
+		NAValue:     "",
+		DateFormat:  "%Y-%m-%d %H:%M:%S",
+	}
+	cb.Questionnaire.ID = coded.Questionnaire.ID
+	cb.Questionnaire.Title = coded.Questionnaire.Title
+	cb.Questionnaire.Description = coded.Questionnaire.Description
+
+	for _, v := range coded.Variables {
+		var labels []ValueLabel
+		for _, vl := range v.ValueLabels {
+			if !isMissingCode(v, vl.Value) {
+				labels = append(labels, vl)
+			}
+		}
+		v.ValueLabels = labels
+		v.Missing = nil
+		cb.Variables = append(cb.Variables, v)
+	}
+
+	return cb
+}
@@ -0,0 +1,240 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/models"
+)
+
+// MissingCode 未作答问题的缺失值编码
+const MissingCode = -99
+
+// 变量类型
+const (
+	KindNumeric  = "numeric"
+	KindString   = "string"
+	KindDateTime = "datetime"
+)
+
+// ValueLabel 数值编码及其标签
+type ValueLabel struct {
+	Value float64 `json:"value"`
+	Label string  `json:"label"`
+}
+
+// Variable 统计软件中的一个变量
+type Variable struct {
+	Name        string       `json:"name"`
+	Label       string       `json:"label"`
+	Kind        string       `json:"type"`
+	QuestionID  uint         `json:"question_id,omitempty"`
+	Decimals    int          `json:"decimals,omitempty"`
+	Width       int          `json:"width,omitempty"` // 字符串变量的字节宽度
+	ValueLabels []ValueLabel `json:"value_labels,omitempty"`
+	Missing     []float64    `json:"missing_values,omitempty"`
+}
+
+// Value 单个变量的取值
+type Value struct {
+	Num     float64
+	Str     string
+	Time    time.Time
+	Missing bool
+}
+
+// Coded 编码后的数据集：变量定义及每份答卷的取值
+type Coded struct {
+	Questionnaire models.Questionnaire
+	Variables     []Variable
+	Cases         [][]Value
+}
+
+// maxStringWidth 字符串变量的最大字节宽度
+const maxStringWidth = 255
+
+// Code 将数据集按变量编码
+// 单选题编码为选项序号，多选题拆分为每个选项一个0/1变量，评分题保留分值，填空题为字符串
+func Code(ds *Dataset) *Coded {
+	coded := &Coded{Questionnaire: ds.Questionnaire}
+
+	type column struct {
+		variable Variable
+		value    func(r Response) Value
+	}
+
+	columns := []column{
+		{
+			variable: Variable{Name: "SUBID", Label: "提交ID", Kind: KindNumeric},
+			value:    func(r Response) Value { return Value{Num: float64(r.Submission.ID)} },
+		},
+		{
+			variable: Variable{Name: "SUBTIME", Label: "提交时间", Kind: KindDateTime},
+			value:    func(r Response) Value { return Value{Time: r.Submission.SubmittedAt} },
+		},
+		{
+			variable: Variable{Name: "USERNAME", Label: "用户名", Kind: KindString},
+			value:    func(r Response) Value { return Value{Str: r.Username} },
+		},
+		{
+			variable: Variable{Name: "IPADDR", Label: "IP地址", Kind: KindString},
+			value:    func(r Response) Value { return Value{Str: r.Submission.IPAddress} },
+		},
+	}
+
+	missingLabel := ValueLabel{Value: MissingCode, Label: "未作答"}
+
+	for i, q := range ds.Questions {
+		name := VariableName(i)
+
+		switch q.Type {
+		case models.QuestionTypeSingleChoice:
+			labels, codes := choiceCodes(q, ds.Responses)
+			columns = append(columns, column{
+				variable: Variable{
+					Name:        name,
+					Label:       q.Title,
+					Kind:        KindNumeric,
+					QuestionID:  q.ID,
+					ValueLabels: append(labels, missingLabel),
+					Missing:     []float64{MissingCode},
+				},
+				value: func(r Response) Value {
+					values := models.AnswerValues(r.Answers[q.ID])
+					if len(values) == 0 {
+						return Value{Num: MissingCode}
+					}
+					return Value{Num: codes[values[0]]}
+				},
+			})
+
+		case models.QuestionTypeMultipleChoice:
+			labels, _ := choiceCodes(q, ds.Responses)
+			for j, opt := range labels {
+				option := opt.Label
+				columns = append(columns, column{
+					variable: Variable{
+						Name:       fmt.Sprintf("%s_%d", name, j+1),
+						Label:      q.Title + ": " + option,
+						Kind:       KindNumeric,
+						QuestionID: q.ID,
+						ValueLabels: []ValueLabel{
+							{Value: 0, Label: "未选"},
+							{Value: 1, Label: "已选"},
+							missingLabel,
+						},
+						Missing: []float64{MissingCode},
+					},
+					value: func(r Response) Value {
+						values := models.AnswerValues(r.Answers[q.ID])
+						if len(values) == 0 {
+							return Value{Num: MissingCode}
+						}
+						for _, v := range values {
+							if v == option {
+								return Value{Num: 1}
+							}
+						}
+						return Value{Num: 0}
+					},
+				})
+			}
+
+		case models.QuestionTypeRating:
+			decimals := 0
+			for _, r := range ds.Responses {
+				if v, ok := ParseNumber(r.Answers[q.ID]); ok && v != math.Trunc(v) {
+					decimals = 2
+					break
+				}
+			}
+			columns = append(columns, column{
+				variable: Variable{
+					Name:        name,
+					Label:       q.Title,
+					Kind:        KindNumeric,
+					QuestionID:  q.ID,
+					Decimals:    decimals,
+					ValueLabels: []ValueLabel{missingLabel},
+					Missing:     []float64{MissingCode},
+				},
+				value: func(r Response) Value {
+					v, ok := ParseNumber(r.Answers[q.ID])
+					if !ok {
+						return Value{Num: MissingCode}
+					}
+					return Value{Num: v}
+				},
+			})
+
+		default:
+			columns = append(columns, column{
+				variable: Variable{Name: name, Label: q.Title, Kind: KindString, QuestionID: q.ID},
+				value: func(r Response) Value {
+					content := strings.TrimSpace(r.Answers[q.ID])
+					return Value{Str: content, Missing: content == ""}
+				},
+			})
+		}
+	}
+
+	for _, col := range columns {
+		coded.Variables = append(coded.Variables, col.variable)
+	}
+
+	for _, r := range ds.Responses {
+		row := make([]Value, len(columns))
+		for i, col := range columns {
+			row[i] = col.value(r)
+		}
+		coded.Cases = append(coded.Cases, row)
+	}
+
+	// 字符串变量的宽度取最长取值
+	for i := range coded.Variables {
+		v := &coded.Variables[i]
+		if v.Kind != KindString {
+			continue
+		}
+		v.Width = 1
+		for _, row := range coded.Cases {
+			if n := len(row[i].Str); n > v.Width {
+				v.Width = n
+			}
+		}
+		if v.Width > maxStringWidth {
+			v.Width = maxStringWidth
+		}
+	}
+
+	return coded
+}
+
+// choiceCodes 为选择题的选项分配编码（从1开始）
+// 问卷中未声明但出现在答案里的选项追加在末尾
+func choiceCodes(q models.Question, responses []Response) ([]ValueLabel, map[string]float64) {
+	var labels []ValueLabel
+	codes := make(map[string]float64)
+
+	add := func(option string) {
+		if _, ok := codes[option]; ok {
+			return
+		}
+		code := float64(len(labels) + 1)
+		codes[option] = code
+		labels = append(labels, ValueLabel{Value: code, Label: option})
+	}
+
+	for _, opt := range q.OptionList() {
+		add(opt)
+	}
+	for _, r := range responses {
+		for _, v := range models.AnswerValues(r.Answers[q.ID]) {
+			add(v)
+		}
+	}
+
+	return labels, codes
+}
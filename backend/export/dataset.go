@@ -0,0 +1,93 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/models"
+)
+
+// Response 一份答卷（提交记录及其答案）
+type Response struct {
+	Submission models.Submission
+	Username   string
+	Answers    map[uint]string // 问题ID -> 答案内容
+}
+
+// Dataset 导出所需的问卷数据，与问卷结果接口使用相同的数据来源
+type Dataset struct {
+	Questionnaire models.Questionnaire
+	Questions     []models.Question
+	Responses     []Response
+}
+
+// NewDataset 按提交顺序组合问卷的答卷，usernames为用户ID到用户名的对应关系，answers按ID顺序排列
+func NewDataset(questionnaire models.Questionnaire, questions []models.Question, submissions []models.Submission,
+	usernames map[uint]string, answers []models.Answer) *Dataset {
+	ds := &Dataset{Questionnaire: questionnaire, Questions: questions}
+
+	answersByUser := make(map[uint]map[uint]string)
+	for _, a := range answers {
+		if answersByUser[a.UserID] == nil {
+			answersByUser[a.UserID] = make(map[uint]string)
+		}
+		answersByUser[a.UserID][a.QuestionID] = a.Content
+	}
+
+	for _, s := range submissions {
+		userAnswers := answersByUser[s.UserID]
+		if userAnswers == nil {
+			userAnswers = make(map[uint]string)
+		}
+		ds.Responses = append(ds.Responses, Response{
+			Submission: s,
+			Username:   usernames[s.UserID],
+			Answers:    userAnswers,
+		})
+	}
+
+	return ds
+}
+
+// dateLayouts 可识别为日期的答案格式
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// ParseNumber 尝试将答案解析为数字
+// 以0开头的多位整数（如电话号码、编号）仍按文本处理
+func ParseNumber(content string) (float64, bool) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return 0, false
+	}
+	if len(content) > 1 && content[0] == '0' && content[1] != '.' {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(content, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// ParseDate 尝试将答案解析为日期
+func ParseDate(content string) (time.Time, bool) {
+	content = strings.TrimSpace(content)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, content, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// DisplayValue 将答案内容转换为便于阅读的文本（多选题用分号连接）
+func DisplayValue(content string) string {
+	return strings.Join(models.AnswerValues(content), "; ")
+}
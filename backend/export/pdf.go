@@ -0,0 +1,198 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A4页面尺寸（单位：点）
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// Color RGB颜色，分量取值0到1
+type Color struct {
+	R, G, B float64
+}
+
+// PDFDocument 最小化的PDF文档写入器
+// 文字使用Adobe-GB1预置字体STSong-Light，无需嵌入字体即可离线显示中文
+type PDFDocument struct {
+	Title   string
+	pages   []*bytes.Buffer
+	current *bytes.Buffer
+}
+
+// NewPDFDocument 创建PDF文档
+func NewPDFDocument(title string) *PDFDocument {
+	return &PDFDocument{Title: title}
+}
+
+// AddPage 新增一页，后续绘制操作作用于该页
+func (d *PDFDocument) AddPage() {
+	d.current = &bytes.Buffer{}
+	d.pages = append(d.pages, d.current)
+}
+
+// PageCount 当前页数
+func (d *PDFDocument) PageCount() int {
+	return len(d.pages)
+}
+
+// Text 在(x, y)处绘制文字，y为基线位置（坐标原点在页面左下角）
+func (d *PDFDocument) Text(x, y, size float64, color Color, s string) {
+	fmt.Fprintf(d.current, "BT %s rg /F1 %s Tf %s %s Td <%s> Tj ET\n",
+		color.ops(), num(size), num(x), num(y), encodeUCS2(s))
+}
+
+// Rect 绘制填充矩形
+func (d *PDFDocument) Rect(x, y, w, h float64, color Color) {
+	fmt.Fprintf(d.current, "%s rg %s %s %s %s re f\n", color.ops(), num(x), num(y), num(w), num(h))
+}
+
+// Line 绘制直线
+func (d *PDFDocument) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(d.current, "%s RG %s w %s %s m %s %s l S\n",
+		color.ops(), num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// Slice 绘制扇形，角度以弧度表示，从start逆时针到end
+func (d *PDFDocument) Slice(cx, cy, r, start, end float64, color Color) {
+	fmt.Fprintf(d.current, "%s rg %s %s m ", color.ops(), num(cx), num(cy))
+	fmt.Fprintf(d.current, "%s %s l ", num(cx+r*math.Cos(start)), num(cy+r*math.Sin(start)))
+
+	// 每段不超过90度，用三次贝塞尔曲线近似圆弧
+	for a := start; a < end; {
+		b := math.Min(a+math.Pi/2, end)
+		k := 4.0 / 3.0 * math.Tan((b-a)/4)
+		x1 := cx + r*(math.Cos(a)-k*math.Sin(a))
+		y1 := cy + r*(math.Sin(a)+k*math.Cos(a))
+		x2 := cx + r*(math.Cos(b)+k*math.Sin(b))
+		y2 := cy + r*(math.Sin(b)-k*math.Cos(b))
+		fmt.Fprintf(d.current, "%s %s %s %s %s %s c ",
+			num(x1), num(y1), num(x2), num(y2), num(cx+r*math.Cos(b)), num(cy+r*math.Sin(b)))
+		a = b
+	}
+	d.current.WriteString("h f\n")
+}
+
+// TextWidth 估算文字宽度：ASCII字符按半角，其余按全角
+func TextWidth(s string, size float64) float64 {
+	width := 0.0
+	for _, r := range s {
+		if r < 0x80 {
+			width += 0.5
+		} else {
+			width += 1
+		}
+	}
+	return width * size
+}
+
+// WrapText 按最大宽度折行
+func WrapText(s string, size, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		var line []rune
+		width := 0.0
+		for _, r := range paragraph {
+			w := TextWidth(string(r), size)
+			if width+w > maxWidth && len(line) > 0 {
+				lines = append(lines, string(line))
+				line, width = nil, 0
+			}
+			line = append(line, r)
+			width += w
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// Write 将文档写入w
+func (d *PDFDocument) Write(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+
+	newObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 对象编号：1目录、2页面树、3-5字体、6文档信息，之后每页占两个对象（页面和内容流）
+	const firstPageObject = 7
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+i*2)
+	}
+
+	newObject("<< /Type /Catalog /Pages 2 0 R >>")
+	newObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	newObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light-UniGB-UCS2-H /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	newObject("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> " +
+		"/FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	newObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	newObject(fmt.Sprintf("<< /Title <%s> /Producer (questionnaire-system) /CreationDate (D:%s) >>",
+		"FEFF"+encodeUCS2(d.Title), time.Now().Format("20060102150405")))
+
+	for i, page := range d.pages {
+		newObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			num(pageWidth), num(pageHeight), firstPageObject+i*2+1))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		newObject(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			compressed.Len(), compressed.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func (c Color) ops() string {
+	return fmt.Sprintf("%s %s %s", num(c.R), num(c.G), num(c.B))
+}
+
+// num 格式化数字，最多保留两位小数
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// encodeUCS2 将文字编码为UCS-2大端十六进制串，基本平面以外的字符替换为问号
+func encodeUCS2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
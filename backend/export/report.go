@@ -0,0 +1,268 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/stats"
+)
+
+// ReportOptions PDF报告选项
+type ReportOptions struct {
+	IncludeIdentifying bool   // 是否包含可识别身份的信息
+	CreatorName        string // 问卷创建者用户名（仅在包含身份信息时显示）
+	TopTextAnswers     int    // 每个填空题显示的答案条数
+}
+
+// 页面布局
+const (
+	marginX      = 50.0
+	marginTop    = 60.0
+	marginBottom = 60.0
+	contentWidth = pageWidth - marginX*2
+)
+
+var (
+	colorText  = Color{0.13, 0.13, 0.13}
+	colorMuted = Color{0.45, 0.45, 0.45}
+	colorRule  = Color{0.8, 0.8, 0.8}
+	colorTrack = Color{0.93, 0.93, 0.93}
+
+	// chartPalette 图表配色
+	chartPalette = []Color{
+		{0.33, 0.44, 0.78},
+		{0.57, 0.80, 0.46},
+		{0.98, 0.78, 0.35},
+		{0.93, 0.40, 0.40},
+		{0.45, 0.75, 0.87},
+		{0.23, 0.64, 0.45},
+		{0.99, 0.52, 0.32},
+		{0.60, 0.38, 0.71},
+		{0.92, 0.49, 0.80},
+	}
+)
+
+// identifyingPatterns 匿名报告中需要遮盖的身份信息：邮箱、手机号、身份证号
+var identifyingPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\b\d{17}[\dXx]\b`),
+	regexp.MustCompile(`\b1[3-9]\d{9}\b`),
+}
+
+// RedactIdentifying 遮盖文本中的邮箱、手机号和身份证号
+func RedactIdentifying(s string) string {
+	for _, re := range identifyingPatterns {
+		s = re.ReplaceAllString(s, "***")
+	}
+	return s
+}
+
+// reportLayout 跟踪当前页的绘制位置
+type reportLayout struct {
+	doc  *PDFDocument
+	y    float64
+	foot string
+}
+
+func (l *reportLayout) newPage() {
+	l.doc.AddPage()
+	l.y = pageHeight - marginTop
+	l.doc.Text(marginX, marginBottom/2, 8, colorMuted, fmt.Sprintf("%s · 第 %d 页", l.foot, l.doc.PageCount()))
+}
+
+// ensure 剩余空间不足时换页
+func (l *reportLayout) ensure(height float64) {
+	if l.y-height < marginBottom {
+		l.newPage()
+	}
+}
+
+// paragraph 绘制自动折行的文字段落
+func (l *reportLayout) paragraph(s string, size float64, color Color, indent float64) {
+	lineHeight := size * 1.5
+	for _, line := range WrapText(s, size, contentWidth-indent) {
+		l.ensure(lineHeight)
+		l.y -= lineHeight
+		l.doc.Text(marginX+indent, l.y, size, color, line)
+	}
+}
+
+// BuildReport 根据汇总统计生成PDF报告
+func BuildReport(summary *stats.Summary, opts ReportOptions) *PDFDocument {
+	if opts.TopTextAnswers <= 0 {
+		opts.TopTextAnswers = 10
+	}
+
+	q := summary.Questionnaire
+	doc := NewPDFDocument(q.Title)
+	layout := &reportLayout{
+		doc:  doc,
+		foot: "生成时间 " + summary.GeneratedAt.Format("2006-01-02 15:04:05"),
+	}
+	layout.newPage()
+
+	// 标题与概要
+	layout.paragraph(q.Title, 20, colorText, 0)
+	if strings.TrimSpace(q.Description) != "" {
+		layout.y -= 4
+		layout.paragraph(q.Description, 10, colorMuted, 0)
+	}
+	layout.y -= 8
+
+	overview := []string{
+		fmt.Sprintf("答卷总数：%d", summary.TotalSubmissions),
+		fmt.Sprintf("问题数量：%d", len(summary.Questions)),
+	}
+	if !q.StartTime.IsZero() || !q.EndTime.IsZero() {
+		overview = append(overview, fmt.Sprintf("开放时间：%s 至 %s",
+			formatReportDate(q.StartTime), formatReportDate(q.EndTime)))
+	}
+	if opts.IncludeIdentifying && opts.CreatorName != "" {
+		overview = append(overview, "创建者："+opts.CreatorName)
+	}
+	overview = append(overview, "生成时间："+summary.GeneratedAt.Format("2006-01-02 15:04:05"))
+	for _, line := range overview {
+		layout.paragraph(line, 11, colorText, 0)
+	}
+
+	for i, qs := range summary.Questions {
+		layout.y -= 18
+		layout.ensure(60)
+		layout.doc.Line(marginX, layout.y, pageWidth-marginX, layout.y, 0.5, colorRule)
+		layout.y -= 4
+
+		layout.paragraph(fmt.Sprintf("%s. %s [%s]", VariableName(i), qs.Title, qs.Type), 13, colorText, 0)
+		layout.paragraph(fmt.Sprintf("作答 %d 人，未作答 %d 人", qs.Answered, qs.Skipped), 9, colorMuted, 0)
+
+		switch {
+		case qs.Type == models.QuestionTypeSingleChoice && qs.Answered > 0:
+			drawPieChart(layout, qs.Options)
+		case len(qs.Options) > 0:
+			if qs.Average != nil {
+				layout.paragraph(fmt.Sprintf("平均分 %.2f（最低 %s，最高 %s）",
+					*qs.Average, num(*qs.Min), num(*qs.Max)), 10, colorText, 0)
+			}
+			drawBarChart(layout, qs.Options)
+		case len(qs.TextAnswers) > 0:
+			drawTextAnswers(layout, qs.TopTextAnswers(opts.TopTextAnswers), len(qs.TextAnswers), opts.IncludeIdentifying)
+		default:
+			layout.paragraph("暂无回答", 10, colorMuted, 0)
+		}
+	}
+
+	return doc
+}
+
+// drawBarChart 绘制横向条形图
+func drawBarChart(l *reportLayout, options []stats.OptionCount) {
+	const (
+		barHeight  = 14.0
+		rowGap     = 6.0
+		labelWidth = 150.0
+		valueWidth = 70.0
+	)
+	trackWidth := contentWidth - labelWidth - valueWidth
+
+	var maxCount int64
+	for _, opt := range options {
+		if opt.Count > maxCount {
+			maxCount = opt.Count
+		}
+	}
+
+	l.y -= 6
+	for i, opt := range options {
+		l.ensure(barHeight + rowGap)
+		l.y -= barHeight + rowGap
+
+		label := truncateWidth(opt.Option, 10, labelWidth-8)
+		l.doc.Text(marginX, l.y+3, 10, colorText, label)
+
+		l.doc.Rect(marginX+labelWidth, l.y, trackWidth, barHeight, colorTrack)
+		if maxCount > 0 && opt.Count > 0 {
+			width := trackWidth * float64(opt.Count) / float64(maxCount)
+			l.doc.Rect(marginX+labelWidth, l.y, width, barHeight, chartPalette[i%len(chartPalette)])
+		}
+
+		l.doc.Text(marginX+labelWidth+trackWidth+6, l.y+3, 9, colorText,
+			fmt.Sprintf("%d (%.1f%%)", opt.Count, opt.Ratio*100))
+	}
+}
+
+// drawPieChart 绘制饼图及图例
+func drawPieChart(l *reportLayout, options []stats.OptionCount) {
+	const (
+		radius     = 60.0
+		legendRow  = 16.0
+		legendLeft = marginX + radius*2 + 40
+	)
+
+	height := math.Max(radius*2, float64(len(options))*legendRow) + 12
+	l.ensure(height)
+
+	cx := marginX + radius
+	cy := l.y - 6 - radius
+
+	var total int64
+	for _, opt := range options {
+		total += opt.Count
+	}
+
+	start := math.Pi / 2
+	for i, opt := range options {
+		if opt.Count == 0 || total == 0 {
+			continue
+		}
+		sweep := 2 * math.Pi * float64(opt.Count) / float64(total)
+		// 饼图按顺时针方向排列
+		l.doc.Slice(cx, cy, radius, start-sweep, start, chartPalette[i%len(chartPalette)])
+		start -= sweep
+	}
+
+	legendY := l.y - 6
+	for i, opt := range options {
+		legendY -= legendRow
+		l.doc.Rect(legendLeft, legendY, 10, 10, chartPalette[i%len(chartPalette)])
+		label := truncateWidth(opt.Option, 10, pageWidth-marginX-legendLeft-110)
+		l.doc.Text(legendLeft+16, legendY+1, 10, colorText, label)
+		l.doc.Text(pageWidth-marginX-80, legendY+1, 9, colorText,
+			fmt.Sprintf("%d (%.1f%%)", opt.Count, opt.Ratio*100))
+	}
+
+	l.y -= height
+}
+
+// drawTextAnswers 列出出现次数最多的填空题答案
+func drawTextAnswers(l *reportLayout, answers []stats.TextAnswer, distinct int, includeIdentifying bool) {
+	l.paragraph(fmt.Sprintf("共 %d 种不同回答，以下为出现次数最多的 %d 条：", distinct, len(answers)), 9, colorMuted, 0)
+	for _, ta := range answers {
+		content := ta.Content
+		if !includeIdentifying {
+			content = RedactIdentifying(content)
+		}
+		l.paragraph(fmt.Sprintf("%d×  %s", ta.Count, content), 10, colorText, 8)
+	}
+}
+
+// truncateWidth 超出宽度时截断并添加省略号
+func truncateWidth(s string, size, maxWidth float64) string {
+	if TextWidth(s, size) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && TextWidth(string(runes)+"…", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func formatReportDate(t time.Time) string {
+	if t.IsZero() {
+		return "未设置"
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,257 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SPSS格式代码
+const (
+	spssFormatA        = 1
+	spssFormatF        = 5
+	spssFormatDateTime = 22
+)
+
+// spssSysmis SPSS系统缺失值
+var spssSysmis = -math.MaxFloat64
+
+// spssEpoch SPSS日期时间的起点（格里高利历开始日）
+var spssEpoch = time.Date(1582, 10, 14, 0, 0, 0, 0, time.UTC)
+
+// 标签的最大字节长度
+const (
+	maxVariableLabel = 255
+	maxValueLabel    = 120
+)
+
+// WriteSAV 将编码后的数据集写为未压缩的SPSS系统文件（.sav）
+// 文件使用UTF-8编码，变量标签取自问题标题，值标签取自选项
+func WriteSAV(w io.Writer, coded *Coded) error {
+	sw := &savWriter{w: bufio.NewWriter(w)}
+
+	caseSize := 0
+	for _, v := range coded.Variables {
+		caseSize += segments(v)
+	}
+
+	sw.writeHeader(coded, caseSize)
+
+	// 变量记录（类型2），记录每个变量在字典中的位置
+	dictIndex := make([]int32, len(coded.Variables))
+	next := int32(1)
+	for i, v := range coded.Variables {
+		dictIndex[i] = next
+		sw.writeVariable(v)
+		next += int32(segments(v))
+	}
+
+	// 值标签记录（类型3和类型4）
+	for i, v := range coded.Variables {
+		if v.Kind != KindNumeric || len(v.ValueLabels) == 0 {
+			continue
+		}
+		sw.writeValueLabels(v.ValueLabels, dictIndex[i])
+	}
+
+	sw.writeMachineInfo()
+	sw.writeEncoding("UTF-8")
+
+	// 字典结束记录
+	sw.int32(999)
+	sw.int32(0)
+
+	for _, row := range coded.Cases {
+		for i, v := range coded.Variables {
+			sw.writeValue(v, row[i])
+		}
+	}
+
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// segments 变量占用的8字节段数
+func segments(v Variable) int {
+	if v.Kind != KindString {
+		return 1
+	}
+	return (v.Width + 7) / 8
+}
+
+// savWriter 以小端字节序写入记录，并保留第一个错误
+type savWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (sw *savWriter) write(data interface{}) {
+	if sw.err != nil {
+		return
+	}
+	sw.err = binary.Write(sw.w, binary.LittleEndian, data)
+}
+
+func (sw *savWriter) int32(v int32) {
+	sw.write(v)
+}
+
+func (sw *savWriter) float64(v float64) {
+	sw.write(v)
+}
+
+// padded 写入定长字段，不足部分以空格填充
+func (sw *savWriter) padded(s string, size int) {
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = ' '
+	}
+	copy(buf, truncateBytes(s, size))
+	sw.write(buf)
+}
+
+func (sw *savWriter) writeHeader(coded *Coded, caseSize int) {
+	now := time.Now()
+
+	sw.padded("$FL2", 4)
+	sw.padded("@(#) SPSS DATA FILE questionnaire-system", 60)
+	sw.int32(2)                       // layout_code
+	sw.int32(int32(caseSize))         // nominal_case_size
+	sw.int32(0)                       // 不压缩
+	sw.int32(0)                       // 无权重变量
+	sw.int32(int32(len(coded.Cases))) // 个案数
+	sw.float64(100)                   // 压缩偏移量
+	sw.padded(now.Format("02 Jan 06"), 9)
+	sw.padded(now.Format("15:04:05"), 8)
+	sw.padded(coded.Questionnaire.Title, 64)
+	sw.padded("", 3)
+}
+
+func (sw *savWriter) writeVariable(v Variable) {
+	varType := int32(0)
+	format := int32(spssFormatF<<16 | 8<<8 | v.Decimals)
+	switch v.Kind {
+	case KindString:
+		varType = int32(v.Width)
+		format = int32(spssFormatA<<16 | v.Width<<8)
+	case KindDateTime:
+		format = int32(spssFormatDateTime<<16 | 20<<8)
+	}
+
+	label := truncateBytes(v.Label, maxVariableLabel)
+
+	sw.int32(2)
+	sw.int32(varType)
+	if label != "" {
+		sw.int32(1)
+	} else {
+		sw.int32(0)
+	}
+	sw.int32(int32(len(v.Missing)))
+	sw.int32(format) // print format
+	sw.int32(format) // write format
+	sw.padded(strings.ToUpper(v.Name), 8)
+
+	if label != "" {
+		sw.int32(int32(len(label)))
+		sw.padded(label, (len(label)+3)/4*4)
+	}
+	for _, m := range v.Missing {
+		sw.float64(m)
+	}
+
+	// 长字符串变量的续接记录
+	for i := 1; i < segments(v); i++ {
+		sw.int32(2)
+		sw.int32(-1)
+		sw.int32(0)
+		sw.int32(0)
+		sw.int32(0)
+		sw.int32(0)
+		sw.padded("", 8)
+	}
+}
+
+func (sw *savWriter) writeValueLabels(labels []ValueLabel, index int32) {
+	sw.int32(3)
+	sw.int32(int32(len(labels)))
+	for _, vl := range labels {
+		label := truncateBytes(vl.Label, maxValueLabel)
+		sw.float64(vl.Value)
+		sw.write(uint8(len(label)))
+		// 标签长度字节与标签内容合计填充到8的倍数
+		sw.padded(label, (len(label)+1+7)/8*8-1)
+	}
+
+	sw.int32(4)
+	sw.int32(1)
+	sw.int32(index)
+}
+
+// writeMachineInfo 写入机器整数信息和浮点信息扩展记录
+func (sw *savWriter) writeMachineInfo() {
+	sw.int32(7)
+	sw.int32(3)
+	sw.int32(4)
+	sw.int32(8)
+	for _, v := range []int32{1, 0, 0, -1, 1, 1, 2, 65001} {
+		sw.int32(v)
+	}
+
+	sw.int32(7)
+	sw.int32(4)
+	sw.int32(8)
+	sw.int32(3)
+	sw.float64(spssSysmis)
+	sw.float64(math.MaxFloat64)
+	sw.float64(math.Nextafter(-math.MaxFloat64, 0))
+}
+
+// writeEncoding 写入字符编码扩展记录
+func (sw *savWriter) writeEncoding(encoding string) {
+	sw.int32(7)
+	sw.int32(20)
+	sw.int32(1)
+	sw.int32(int32(len(encoding)))
+	sw.write([]byte(encoding))
+}
+
+func (sw *savWriter) writeValue(v Variable, value Value) {
+	switch v.Kind {
+	case KindString:
+		sw.padded(value.Str, segments(v)*8)
+	case KindDateTime:
+		if value.Time.IsZero() {
+			sw.float64(spssSysmis)
+			return
+		}
+		wall := time.Date(value.Time.Year(), value.Time.Month(), value.Time.Day(),
+			value.Time.Hour(), value.Time.Minute(), value.Time.Second(), 0, time.UTC)
+		// 时间跨度超过time.Duration的范围，按Unix秒计算
+		sw.float64(float64(wall.Unix() - spssEpoch.Unix()))
+	default:
+		if value.Missing {
+			sw.float64(spssSysmis)
+			return
+		}
+		sw.float64(value.Num)
+	}
+}
+
+// truncateBytes 按字节截断字符串，且不截断多字节字符
+func truncateBytes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
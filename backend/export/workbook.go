@@ -0,0 +1,160 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/stats"
+)
+
+// 工作表名称
+const (
+	sheetResponses = "答卷数据"
+	sheetSummary   = "汇总统计"
+	sheetCodebook  = "编码手册"
+)
+
+// VariableName 按问题顺序生成变量名（Q1、Q2…）
+func VariableName(index int) string {
+	return fmt.Sprintf("Q%d", index+1)
+}
+
+// BuildWorkbook 生成包含答卷数据、汇总统计和编码手册的工作簿
+func BuildWorkbook(ds *Dataset, summary *stats.Summary) *Workbook {
+	wb := NewWorkbook()
+	buildResponsesSheet(wb.AddSheet(sheetResponses), ds)
+	buildSummarySheet(wb.AddSheet(sheetSummary), summary)
+	buildCodebookSheet(wb.AddSheet(sheetCodebook), ds.Questions)
+	return wb
+}
+
+// buildResponsesSheet 每份答卷一行，每个问题一列
+func buildResponsesSheet(sheet *Sheet, ds *Dataset) {
+	sheet.FreezeRows = 1
+
+	header := []Cell{Header("提交ID"), Header("提交时间"), Header("用户名"), Header("IP地址")}
+	for i, q := range ds.Questions {
+		header = append(header, Header(VariableName(i)+" "+q.Title))
+	}
+	sheet.AddRow(header...)
+
+	sheet.SetColumnWidth(0, 10)
+	sheet.SetColumnWidth(1, 20)
+	sheet.SetColumnWidth(2, 16)
+	sheet.SetColumnWidth(3, 16)
+	for i := range ds.Questions {
+		sheet.SetColumnWidth(4+i, 24)
+	}
+
+	for _, r := range ds.Responses {
+		row := []Cell{
+			Number(float64(r.Submission.ID)),
+			DateTime(r.Submission.SubmittedAt),
+			Text(r.Username),
+			Text(r.Submission.IPAddress),
+		}
+		for _, q := range ds.Questions {
+			row = append(row, answerCell(q, r.Answers[q.ID]))
+		}
+		sheet.AddRow(row...)
+	}
+}
+
+// answerCell 根据题型和内容确定单元格类型
+func answerCell(q models.Question, content string) Cell {
+	if strings.TrimSpace(content) == "" {
+		return Blank()
+	}
+
+	if q.Type == models.QuestionTypeMultipleChoice {
+		return Text(DisplayValue(content))
+	}
+
+	if v, ok := ParseNumber(content); ok {
+		return Number(v)
+	}
+
+	if q.Type == models.QuestionTypeText {
+		if t, ok := ParseDate(content); ok {
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+				return Date(t)
+			}
+			return DateTime(t)
+		}
+	}
+
+	return Text(content)
+}
+
+// buildSummarySheet 每个问题一张小表
+func buildSummarySheet(sheet *Sheet, summary *stats.Summary) {
+	sheet.FreezeRows = 1
+	sheet.SetColumnWidth(0, 36)
+	sheet.SetColumnWidth(1, 12)
+	sheet.SetColumnWidth(2, 12)
+
+	sheet.AddRow(Header(summary.Questionnaire.Title), Header("答卷总数"), Number(float64(summary.TotalSubmissions)))
+
+	for i, qs := range summary.Questions {
+		sheet.AddRow()
+		sheet.AddRow(Header(fmt.Sprintf("%s %s [%s]", VariableName(i), qs.Title, qs.Type)))
+		sheet.AddRow(Text("作答人数"), Number(float64(qs.Answered)))
+		sheet.AddRow(Text("未作答人数"), Number(float64(qs.Skipped)))
+
+		if qs.Average != nil {
+			sheet.AddRow(Text("平均分"), Decimal(*qs.Average))
+			sheet.AddRow(Text("最低分"), Number(*qs.Min))
+			sheet.AddRow(Text("最高分"), Number(*qs.Max))
+		}
+
+		if len(qs.Options) > 0 {
+			sheet.AddRow(Header("选项"), Header("人数"), Header("占比"))
+			for _, opt := range qs.Options {
+				sheet.AddRow(optionCell(qs, opt.Option), Number(float64(opt.Count)), Percent(opt.Ratio))
+			}
+		}
+
+		if len(qs.TextAnswers) > 0 {
+			sheet.AddRow(Header("回答"), Header("次数"))
+			for _, ta := range qs.TextAnswers {
+				sheet.AddRow(Text(ta.Content), Number(float64(ta.Count)))
+			}
+		}
+	}
+}
+
+// optionCell 评分题的选项按数字输出
+func optionCell(qs stats.QuestionStats, option string) Cell {
+	if qs.Type == models.QuestionTypeRating {
+		if v, ok := ParseNumber(option); ok {
+			return Number(v)
+		}
+	}
+	return Text(option)
+}
+
+// buildCodebookSheet 描述每个问题的变量名、题型和选项
+func buildCodebookSheet(sheet *Sheet, questions []models.Question) {
+	sheet.FreezeRows = 1
+	sheet.SetColumnWidth(0, 10)
+	sheet.SetColumnWidth(1, 36)
+	sheet.SetColumnWidth(2, 10)
+	sheet.SetColumnWidth(3, 8)
+	sheet.SetColumnWidth(4, 48)
+
+	sheet.AddRow(Header("变量名"), Header("问题"), Header("题型"), Header("必填"), Header("选项"))
+	for i, q := range questions {
+		required := "否"
+		if q.Required {
+			required = "是"
+		}
+
+		var options []string
+		for j, opt := range q.OptionList() {
+			options = append(options, fmt.Sprintf("%d=%s", j+1, opt))
+		}
+
+		sheet.AddRow(Text(VariableName(i)), Text(q.Title), Text(q.Type), Text(required), Text(strings.Join(options, "; ")))
+	}
+}
@@ -0,0 +1,297 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 单元格样式索引，对应styles.xml中cellXfs的顺序
+const (
+	styleDefault = iota
+	styleBold
+	styleDateTime
+	styleDate
+	stylePercent
+	styleDecimal
+)
+
+type cellKind int
+
+const (
+	cellBlank cellKind = iota
+	cellString
+	cellNumber
+)
+
+// Cell 工作表单元格
+type Cell struct {
+	kind  cellKind
+	str   string
+	num   float64
+	style int
+}
+
+// Blank 空单元格
+func Blank() Cell { return Cell{} }
+
+// Text 文本单元格
+func Text(s string) Cell { return Cell{kind: cellString, str: s} }
+
+// Header 加粗的表头单元格
+func Header(s string) Cell { return Cell{kind: cellString, str: s, style: styleBold} }
+
+// Number 数字单元格
+func Number(v float64) Cell { return Cell{kind: cellNumber, num: v} }
+
+// Decimal 保留两位小数的数字单元格
+func Decimal(v float64) Cell { return Cell{kind: cellNumber, num: v, style: styleDecimal} }
+
+// Percent 百分比单元格（v为0到1之间的小数）
+func Percent(v float64) Cell { return Cell{kind: cellNumber, num: v, style: stylePercent} }
+
+// DateTime 日期时间单元格
+func DateTime(t time.Time) Cell {
+	return Cell{kind: cellNumber, num: excelSerial(t), style: styleDateTime}
+}
+
+// Date 日期单元格
+func Date(t time.Time) Cell {
+	return Cell{kind: cellNumber, num: excelSerial(t), style: styleDate}
+}
+
+// excelSerial 将时间转换为Excel序列值（以1899-12-30为起点的天数）
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// Sheet 工作表
+type Sheet struct {
+	Name       string
+	FreezeRows int // 冻结的表头行数
+	rows       [][]Cell
+	widths     map[int]float64
+}
+
+// AddRow 追加一行
+func (s *Sheet) AddRow(cells ...Cell) {
+	s.rows = append(s.rows, cells)
+}
+
+// SetColumnWidth 设置列宽（列号从0开始）
+func (s *Sheet) SetColumnWidth(col int, width float64) {
+	if s.widths == nil {
+		s.widths = make(map[int]float64)
+	}
+	s.widths[col] = width
+}
+
+// Workbook 最小化的XLSX工作簿写入器
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// NewWorkbook 创建工作簿
+func NewWorkbook() *Workbook {
+	return &Workbook{}
+}
+
+// AddSheet 添加工作表
+func (wb *Workbook) AddSheet(name string) *Sheet {
+	sheet := &Sheet{Name: sanitizeSheetName(name, len(wb.sheets)+1)}
+	wb.sheets = append(wb.sheets, sheet)
+	return sheet
+}
+
+// Write 将工作簿以XLSX格式写入w
+func (wb *Workbook) Write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", wb.contentTypes()},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", wb.workbookXML()},
+		{"xl/_rels/workbook.xml.rels", wb.workbookRels()},
+		{"xl/styles.xml", stylesXML},
+	}
+	for i, sheet := range wb.sheets {
+		files = append(files, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.xml()})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const rootRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const stylesXML = xmlHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="6">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func (wb *Workbook) contentTypes() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range wb.sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func (wb *Workbook) workbookXML() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range wb.sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapeXML(sheet.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+func (wb *Workbook) workbookRels() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range wb.sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(wb.sheets)+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+func (s *Sheet) xml() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+
+	// 冻结表头行
+	if s.FreezeRows > 0 {
+		topLeft := fmt.Sprintf("A%d", s.FreezeRows+1)
+		fmt.Fprintf(&b, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="%d" topLeftCell="%s" activePane="bottomLeft" state="frozen"/><selection pane="bottomLeft" activeCell="%s" sqref="%s"/></sheetView></sheetViews>`,
+			s.FreezeRows, topLeft, topLeft, topLeft)
+	}
+	b.WriteString(`<sheetFormatPr defaultRowHeight="15"/>`)
+
+	if len(s.widths) > 0 {
+		b.WriteString(`<cols>`)
+		maxCol := 0
+		for col := range s.widths {
+			if col > maxCol {
+				maxCol = col
+			}
+		}
+		for col := 0; col <= maxCol; col++ {
+			if width, ok := s.widths[col]; ok {
+				fmt.Fprintf(&b, `<col min="%d" max="%d" width="%.2f" customWidth="1"/>`, col+1, col+1, width)
+			}
+		}
+		b.WriteString(`</cols>`)
+	}
+
+	b.WriteString(`<sheetData>`)
+	for r, row := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := columnName(c) + fmt.Sprint(r+1)
+			switch cell.kind {
+			case cellString:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr(cell.style), escapeXML(cell.str))
+			case cellNumber:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr(cell.style), formatNumber(cell.num))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+func styleAttr(style int) string {
+	if style == styleDefault {
+		return ""
+	}
+	return fmt.Sprintf(` s="%d"`, style)
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// columnName 将列号（从0开始）转换为A、B、…、AA形式
+func columnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
+
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// sanitizeSheetName 去除工作表名称中的非法字符并限制长度
+func sanitizeSheetName(name string, index int) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '[', ']', ':', '*', '?', '/', '\\':
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if strings.TrimSpace(name) == "" {
+		name = fmt.Sprintf("Sheet%d", index)
+	}
+	return name
+}
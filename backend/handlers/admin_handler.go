@@ -1,389 +1,186 @@
 package handlers
 
 import (
-	"log"
-	"questionnaire-system/backend/database"
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/middleware"
 	"questionnaire-system/backend/models"
-	"strconv"
-	"time"
+	"questionnaire-system/backend/pagination"
+	"questionnaire-system/backend/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AdminHandler 处理管理员相关请求
 type AdminHandler struct {
-	DB *database.Database
+	Users          *service.UserService
+	Questionnaires *service.QuestionnaireService
+	Submissions    *service.SubmissionService
+	Statistics     *service.StatisticsService
 }
 
 // NewAdminHandler 创建管理员处理器
-func NewAdminHandler(db *database.Database) *AdminHandler {
-	return &AdminHandler{DB: db}
+func NewAdminHandler(users *service.UserService, questionnaires *service.QuestionnaireService, submissions *service.SubmissionService, statistics *service.StatisticsService) *AdminHandler {
+	return &AdminHandler{
+		Users:          users,
+		Questionnaires: questionnaires,
+		Submissions:    submissions,
+		Statistics:     statistics,
+	}
 }
 
 // GetAllUsers 获取所有用户信息
 func (h *AdminHandler) GetAllUsers(c *gin.Context) {
-	log.Println("管理员请求: 获取所有用户")
-
-	// 分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	if page < 1 {
-		page = 1
+	params, ok := queryPage(c, pagination.ListLimits)
+	if !ok {
+		return
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
+	users, page, err := h.Users.List(c.Request.Context(), params)
+	if err != nil {
+		respondServiceError(c, err, "user.list_failed")
+		return
 	}
 
-	offset := (page - 1) * pageSize
-
-	// 查询用户总数
-	var total int64
-	h.DB.Model(&models.User{}).Count(&total)
-
-	// 查询用户列表（不返回密码字段）
-	var users []models.User
-	h.DB.Select("id, username, email, phone, is_admin, created_at, updated_at").
-		Offset(offset).Limit(pageSize).
-		Order("id desc").
-		Find(&users)
-
 	c.JSON(200, gin.H{
 		"success": true,
-		"data": gin.H{
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-			"users":     users,
-		},
+		"data":    withPage(gin.H{"users": users}, params, page),
 	})
 }
 
 // GetUserDetail 获取用户详情
 func (h *AdminHandler) GetUserDetail(c *gin.Context) {
-	log.Println("管理员请求: 获取用户详情")
-
-	// 获取用户ID
-	idStr := c.Query("id")
-	if idStr == "" {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "缺少用户ID",
-		})
+	id, ok := resourceID(c, "id", "user.id_missing", "user.id_invalid")
+	if !ok {
 		return
 	}
 
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	detail, err := h.Users.Detail(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "无效的用户ID",
-		})
+		respondServiceError(c, err, "user.detail_failed")
 		return
 	}
 
-	// 查询用户
-	var user models.User
-	result := h.DB.Select("id, username, email, phone, is_admin, created_at, updated_at").
-		First(&user, id)
-	if result.Error != nil {
-		c.JSON(404, gin.H{
-			"success": false,
-			"message": "用户不存在",
-		})
-		return
-	}
-
-	// 查询用户创建的问卷数量
-	var questionnaireCount int64
-	h.DB.Model(&models.Questionnaire{}).Where("created_by = ?", id).Count(&questionnaireCount)
-
-	// 查询用户提交的答卷数量
-	var submissionCount int64
-	h.DB.Model(&models.Submission{}).Where("user_id = ?", id).Count(&submissionCount)
-
 	c.JSON(200, gin.H{
 		"success": true,
-		"data": gin.H{
-			"user":                user,
-			"questionnaire_count": questionnaireCount,
-			"submission_count":    submissionCount,
-		},
+		"data":    detail,
 	})
 }
 
 // UpdateUser 更新用户信息
 func (h *AdminHandler) UpdateUser(c *gin.Context) {
-	log.Println("管理员请求: 更新用户信息")
-
-	// 解析请求
 	var request struct {
 		ID      uint   `json:"id"`
 		Email   string `json:"email"`
 		Phone   string `json:"phone"`
 		IsAdmin bool   `json:"is_admin"`
 	}
-
-	err := c.ShouldBindJSON(&request)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "无效的请求数据",
-		})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		fail(c, apierror.InvalidRequest(err))
 		return
 	}
-
-	// 查询用户
-	var user models.User
-	result := h.DB.First(&user, request.ID)
-	if result.Error != nil {
-		c.JSON(404, gin.H{
-			"success": false,
-			"message": "用户不存在",
-		})
+	if !pathID(c, &request.ID, "user.id_invalid") {
 		return
 	}
 
-	// 更新用户信息
-	user.Email = request.Email
-	user.Phone = request.Phone
-	user.IsAdmin = request.IsAdmin
-	user.UpdatedAt = time.Now()
-
-	result = h.DB.Save(&user)
-	if result.Error != nil {
-		c.JSON(500, gin.H{
-			"success": false,
-			"message": "更新用户失败: " + result.Error.Error(),
-		})
+	err := h.Users.Update(c.Request.Context(), service.UserUpdate{
+		ID:      request.ID,
+		Email:   request.Email,
+		Phone:   request.Phone,
+		IsAdmin: request.IsAdmin,
+	})
+	if err != nil {
+		respondServiceError(c, err, "user.update_failed")
 		return
 	}
 
 	c.JSON(200, gin.H{
 		"success": true,
-		"message": "用户更新成功",
+		"message": middleware.T(c, "user.updated"),
 	})
 }
 
 // DeleteUser 删除用户
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
-	log.Println("管理员请求: 删除用户")
-
-	// 获取用户ID
-	idStr := c.Query("id")
-	if idStr == "" {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "缺少用户ID",
-		})
+	id, ok := resourceID(c, "id", "user.id_missing", "user.id_invalid")
+	if !ok {
 		return
 	}
 
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "无效的用户ID",
-		})
+	if err := h.Users.Delete(c.Request.Context(), id); err != nil {
+		respondServiceError(c, err, "user.delete_failed")
 		return
 	}
 
-	// 查询用户
-	var user models.User
-	result := h.DB.First(&user, id)
-	if result.Error != nil {
-		c.JSON(404, gin.H{
-			"success": false,
-			"message": "用户不存在",
-		})
-		return
-	}
-
-	// 不允许删除管理员
-	if user.IsAdmin {
-		c.JSON(403, gin.H{
-			"success": false,
-			"message": "不允许删除管理员账户",
-		})
-		return
-	}
-
-	// 开始事务
-	tx := h.DB.Begin()
-
-	// 删除用户创建的问卷
-	if err := tx.Where("created_by = ?", id).Delete(&models.Questionnaire{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(500, gin.H{
-			"success": false,
-			"message": "删除用户问卷失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 删除用户提交的答案
-	if err := tx.Where("user_id = ?", id).Delete(&models.Answer{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(500, gin.H{
-			"success": false,
-			"message": "删除用户答案失败: " + err.Error(),
-		})
-		return
-	}
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "user.deleted"),
+	})
+}
 
-	// 删除用户提交记录
-	if err := tx.Where("user_id = ?", id).Delete(&models.Submission{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(500, gin.H{
-			"success": false,
-			"message": "删除用户提交记录失败: " + err.Error(),
-		})
+// UnlockUser 解除用户因连续登录失败造成的锁定
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	id, ok := resourceID(c, "id", "user.id_missing", "user.id_invalid")
+	if !ok {
 		return
 	}
 
-	// 删除用户
-	if err := tx.Delete(&user).Error; err != nil {
-		tx.Rollback()
-		c.JSON(500, gin.H{
-			"success": false,
-			"message": "删除用户失败: " + err.Error(),
-		})
+	if err := h.Users.Unlock(c.Request.Context(), id); err != nil {
+		respondServiceError(c, err, "user.unlock_failed")
 		return
 	}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(500, gin.H{
-			"success": false,
-			"message": "删除用户失败: " + err.Error(),
-		})
-		return
-	}
+	middleware.Logger(c).Info("管理员解锁用户", "user_id", id, "admin_id", c.GetUint("user_id"))
 
 	c.JSON(200, gin.H{
 		"success": true,
-		"message": "用户删除成功",
+		"message": middleware.T(c, "user.unlocked"),
 	})
 }
 
 // GetAllQuestionnaires 获取所有问卷
 func (h *AdminHandler) GetAllQuestionnaires(c *gin.Context) {
-	log.Println("管理员请求: 获取所有问卷")
-
-	// 分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
-	}
-
-	offset := (page - 1) * pageSize
-
-	// 查询问卷总数
-	var total int64
-	h.DB.Model(&models.Questionnaire{}).Count(&total)
-
-	// 查询问卷列表
-	var questionnaires []models.Questionnaire
-	h.DB.Offset(offset).Limit(pageSize).
-		Order("id desc").
-		Find(&questionnaires)
-
-	// 获取每个问卷的创建者信息和提交数量
-	type QuestionnaireWithInfo struct {
-		Questionnaire   models.Questionnaire `json:"questionnaire"`
-		CreatorName     string               `json:"creator_name"`
-		SubmissionCount int64                `json:"submission_count"`
-		QuestionCount   int64                `json:"question_count"`
+	params, ok := queryPage(c, pagination.ListLimits)
+	if !ok {
+		return
 	}
-
-	var result []QuestionnaireWithInfo
-	for _, q := range questionnaires {
-		var creator models.User
-		h.DB.Select("username").First(&creator, q.CreatedBy)
-
-		var submissionCount int64
-		h.DB.Model(&models.Submission{}).Where("questionnaire_id = ?", q.ID).Count(&submissionCount)
-
-		var questionCount int64
-		h.DB.Model(&models.Question{}).Where("questionnaire_id = ?", q.ID).Count(&questionCount)
-
-		result = append(result, QuestionnaireWithInfo{
-			Questionnaire:   q,
-			CreatorName:     creator.Username,
-			SubmissionCount: submissionCount,
-			QuestionCount:   questionCount,
-		})
+	questionnaires, page, err := h.Questionnaires.Overview(c.Request.Context(), params)
+	if err != nil {
+		respondServiceError(c, err, "questionnaire.list_failed")
+		return
 	}
 
 	c.JSON(200, gin.H{
 		"success": true,
-		"data": gin.H{
-			"total":          total,
-			"page":           page,
-			"page_size":      pageSize,
-			"questionnaires": result,
-		},
+		"data":    withPage(gin.H{"questionnaires": questionnaires}, params, page),
 	})
 }
 
 // GetSystemStatistics 获取系统统计信息
 func (h *AdminHandler) GetSystemStatistics(c *gin.Context) {
-	log.Println("管理员请求: 获取系统统计信息")
-
-	// 用户统计
-	var userCount int64
-	h.DB.Model(&models.User{}).Count(&userCount)
-
-	var adminCount int64
-	h.DB.Model(&models.User{}).Where("is_admin = ?", true).Count(&adminCount)
-
-	// 问卷统计
-	var questionnaireCount int64
-	h.DB.Model(&models.Questionnaire{}).Count(&questionnaireCount)
-
-	var publishedQuestionnaireCount int64
-	h.DB.Model(&models.Questionnaire{}).Where("is_published = ?", true).Count(&publishedQuestionnaireCount)
-
-	// 问题统计
-	var questionCount int64
-	h.DB.Model(&models.Question{}).Count(&questionCount)
-
-	// 提交统计
-	var submissionCount int64
-	h.DB.Model(&models.Submission{}).Count(&submissionCount)
-
-	var answerCount int64
-	h.DB.Model(&models.Answer{}).Count(&answerCount)
-
-	// 活跃度统计 - 最近7天的提交数量
-	var recentSubmissionCount int64
-	h.DB.Model(&models.Submission{}).
-		Where("submitted_at > ?", time.Now().AddDate(0, 0, -7)).
-		Count(&recentSubmissionCount)
+	st, err := h.Statistics.System(c.Request.Context())
+	if err != nil {
+		respondServiceError(c, err, "statistics.failed")
+		return
+	}
 
-	// 返回统计数据
 	c.JSON(200, gin.H{
 		"success": true,
 		"data": gin.H{
 			"user_statistics": gin.H{
-				"total_users":  userCount,
-				"admin_users":  adminCount,
-				"normal_users": userCount - adminCount,
+				"total_users":  st.Users,
+				"admin_users":  st.Admins,
+				"normal_users": st.Users - st.Admins,
 			},
 			"questionnaire_statistics": gin.H{
-				"total_questionnaires":       questionnaireCount,
-				"published_questionnaires":   publishedQuestionnaireCount,
-				"unpublished_questionnaires": questionnaireCount - publishedQuestionnaireCount,
-				"total_questions":            questionCount,
+				"total_questionnaires":       st.Questionnaires,
+				"published_questionnaires":   st.PublishedQuestionnaires,
+				"unpublished_questionnaires": st.Questionnaires - st.PublishedQuestionnaires,
+				"total_questions":            st.Questions,
 			},
 			"submission_statistics": gin.H{
-				"total_submissions":              submissionCount,
-				"total_answers":                  answerCount,
-				"recent_submissions":             recentSubmissionCount,
-				"average_answers_per_submission": float64(answerCount) / float64(submissionCount),
+				"total_submissions":              st.Submissions,
+				"total_answers":                  st.Answers,
+				"recent_submissions":             st.RecentSubmissions,
+				"average_answers_per_submission": st.AverageAnswersPerSubmission,
 			},
 		},
 	})
@@ -391,96 +188,62 @@ func (h *AdminHandler) GetSystemStatistics(c *gin.Context) {
 
 // GetQuestionnaireSubmissions 获取问卷提交详情
 func (h *AdminHandler) GetQuestionnaireSubmissions(c *gin.Context) {
-	log.Println("管理员请求: 获取问卷提交详情")
-
-	// 获取问卷ID
-	idStr := c.Query("id")
-	if idStr == "" {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "缺少问卷ID",
-		})
+	id, ok := resourceID(c, "id", "questionnaire.id_missing", "questionnaire.id_invalid")
+	if !ok {
 		return
 	}
+	params, ok := queryPage(c, pagination.ResultsLimits)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
 
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	questionnaire, questions, err := h.Questionnaires.Detail(ctx, id)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"success": false,
-			"message": "无效的问卷ID",
-		})
+		respondServiceError(c, err, "submission.details_failed")
 		return
 	}
 
-	// 查询问卷
-	var questionnaire models.Questionnaire
-	result := h.DB.First(&questionnaire, id)
-	if result.Error != nil {
-		c.JSON(404, gin.H{
-			"success": false,
-			"message": "问卷不存在",
-		})
+	responses, page, err := h.Submissions.Responses(ctx, id, true, params)
+	if err != nil {
+		respondServiceError(c, err, "submission.details_failed")
 		return
 	}
 
-	// 查询问题
-	var questions []models.Question
-	h.DB.Where("questionnaire_id = ?", id).Order("sort").Find(&questions)
-
-	// 查询提交记录
-	var submissions []models.Submission
-	h.DB.Where("questionnaire_id = ?", id).Order("submitted_at desc").Find(&submissions)
+	type AnswerInfo struct {
+		QuestionID uint   `json:"question_id"`
+		Content    string `json:"content"`
+	}
 
-	// 构建提交详情
 	type SubmissionDetail struct {
 		Submission models.Submission `json:"submission"`
 		User       struct {
 			ID       uint   `json:"id"`
 			Username string `json:"username"`
 		} `json:"user"`
-		Answers []struct {
-			QuestionID uint   `json:"question_id"`
-			Content    string `json:"content"`
-		} `json:"answers"`
+		Answers []AnswerInfo `json:"answers"`
 	}
 
 	var submissionDetails []SubmissionDetail
-	for _, submission := range submissions {
-		var detail SubmissionDetail
-		detail.Submission = submission
-
-		// 获取用户信息
-		var user models.User
-		h.DB.Select("id, username").First(&user, submission.UserID)
-		detail.User.ID = user.ID
-		detail.User.Username = user.Username
-
-		// 获取答案
-		var answers []models.Answer
-		h.DB.Where("user_id = ? AND question_id IN (?)",
-			submission.UserID,
-			h.DB.Model(&models.Question{}).Select("id").Where("questionnaire_id = ?", id),
-		).Find(&answers)
-
-		for _, answer := range answers {
-			detail.Answers = append(detail.Answers, struct {
-				QuestionID uint   `json:"question_id"`
-				Content    string `json:"content"`
-			}{
+	for _, response := range responses {
+		detail := SubmissionDetail{Submission: response.Submission}
+		detail.User.ID = response.User.ID
+		detail.User.Username = response.User.Username
+		for _, answer := range response.Answers {
+			detail.Answers = append(detail.Answers, AnswerInfo{
 				QuestionID: answer.QuestionID,
 				Content:    answer.Content,
 			})
 		}
-
 		submissionDetails = append(submissionDetails, detail)
 	}
 
 	c.JSON(200, gin.H{
 		"success": true,
-		"data": gin.H{
+		"data": withPage(gin.H{
 			"questionnaire":      questionnaire,
 			"questions":          questions,
 			"submission_details": submissionDetails,
-		},
+		}, params, page),
 	})
 }
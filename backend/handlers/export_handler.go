@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/export"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler 处理问卷结果导出请求
+type ExportHandler struct {
+	Results        *service.ResultsService
+	Questionnaires *service.QuestionnaireService
+}
+
+// NewExportHandler 创建导出处理器
+func NewExportHandler(results *service.ResultsService, questionnaires *service.QuestionnaireService) *ExportHandler {
+	return &ExportHandler{Results: results, Questionnaires: questionnaires}
+}
+
+// ExportXLSX 导出Excel工作簿（答卷数据、汇总统计、编码手册）
+func (h *ExportHandler) ExportXLSX(c *gin.Context) {
+	questionnaire, ok := authorizeResultsAccess(c, h.Questionnaires)
+	if !ok {
+		return
+	}
+
+	ds, err := h.Results.Dataset(c.Request.Context(), questionnaire.ID)
+	if err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("加载导出数据失败: %w", err)))
+		return
+	}
+
+	summary, err := h.Results.Summary(c.Request.Context(), questionnaire.ID)
+	if err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("计算汇总统计失败: %w", err)))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.BuildWorkbook(ds, summary).Write(&buf); err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("生成Excel失败: %w", err)))
+		return
+	}
+
+	middleware.Logger(c).Info("导出完成", "format", "xlsx", "questionnaire_id", questionnaire.ID, "responses", len(ds.Responses))
+
+	sendAttachment(c, exportFilename(questionnaire.ID, "xlsx"),
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+}
+
+// ExportSAV 导出SPSS数据文件（.sav）
+func (h *ExportHandler) ExportSAV(c *gin.Context) {
+	questionnaire, ok := authorizeResultsAccess(c, h.Questionnaires)
+	if !ok {
+		return
+	}
+
+	ds, err := h.Results.Dataset(c.Request.Context(), questionnaire.ID)
+	if err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("加载导出数据失败: %w", err)))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteSAV(&buf, export.Code(ds)); err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("生成SPSS文件失败: %w", err)))
+		return
+	}
+
+	middleware.Logger(c).Info("导出完成", "format", "sav", "questionnaire_id", questionnaire.ID, "responses", len(ds.Responses))
+
+	sendAttachment(c, exportFilename(questionnaire.ID, "sav"), "application/x-spss-sav", buf.Bytes())
+}
+
+// ExportCSVBundle 导出CSV数据和编码手册（适用于R和pandas）
+func (h *ExportHandler) ExportCSVBundle(c *gin.Context) {
+	questionnaire, ok := authorizeResultsAccess(c, h.Questionnaires)
+	if !ok {
+		return
+	}
+
+	ds, err := h.Results.Dataset(c.Request.Context(), questionnaire.ID)
+	if err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("加载导出数据失败: %w", err)))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteCSVBundle(&buf, export.Code(ds)); err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("生成CSV数据包失败: %w", err)))
+		return
+	}
+
+	middleware.Logger(c).Info("导出完成", "format", "csv-bundle", "questionnaire_id", questionnaire.ID, "responses", len(ds.Responses))
+
+	sendAttachment(c, exportFilename(questionnaire.ID, "zip"), "application/zip", buf.Bytes())
+}
+
+// ExportPDF 导出PDF报告
+// 报告基于汇总统计生成；include_identifying=true时显示创建者并保留填空题原文
+func (h *ExportHandler) ExportPDF(c *gin.Context) {
+	questionnaire, ok := authorizeResultsAccess(c, h.Questionnaires)
+	if !ok {
+		return
+	}
+
+	includeIdentifying, _ := strconv.ParseBool(c.DefaultQuery("include_identifying", "false"))
+
+	summary, err := h.Results.Summary(c.Request.Context(), questionnaire.ID)
+	if err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("计算汇总统计失败: %w", err)))
+		return
+	}
+
+	opts := export.ReportOptions{IncludeIdentifying: includeIdentifying}
+	if includeIdentifying {
+		if opts.CreatorName, err = h.Results.CreatorName(c.Request.Context(), questionnaire); err != nil {
+			fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("查询创建者失败: %w", err)))
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := export.BuildReport(summary, opts).Write(&buf); err != nil {
+		fail(c, apierror.Internal("questionnaire.export_failed", fmt.Errorf("生成PDF报告失败: %w", err)))
+		return
+	}
+
+	middleware.Logger(c).Info("导出完成", "format", "pdf", "questionnaire_id", questionnaire.ID, "identifying", includeIdentifying)
+
+	sendAttachment(c, exportFilename(questionnaire.ID, "pdf"), "application/pdf", buf.Bytes())
+}
+
+// exportFilename 生成导出文件名
+func exportFilename(questionnaireID uint, ext string) string {
+	return fmt.Sprintf("questionnaire_%d_%s.%s", questionnaireID, time.Now().Format("20060102150405"), ext)
+}
+
+// sendAttachment 以附件形式返回文件
+func sendAttachment(c *gin.Context, filename, contentType string, data []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(200, contentType, data)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"questionnaire-system/backend/health"
+	"questionnaire-system/backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler 健康检查：存活检查、就绪检查及兼容旧客户端的健康状态
+// 响应不使用统一的错误结构，探针只依据状态码判断
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+// NewHealthHandler 创建健康检查处理器
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{Checker: checker}
+}
+
+// Health 服务整体状态，关键依赖不可用时返回503
+func (h *HealthHandler) Health(c *gin.Context) {
+	report := h.Checker.Check(c.Request.Context())
+	c.JSON(readyStatus(report), gin.H{
+		"status":  report.Status,
+		"message": middleware.T(c, "health."+report.Status),
+	})
+}
+
+// Live 存活检查：进程能够处理请求即返回200，不检查任何依赖
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Ready 就绪检查：返回每项依赖的检查结果，关键依赖不可用或服务正在关闭时返回503
+func (h *HealthHandler) Ready(c *gin.Context) {
+	report := h.Checker.Check(c.Request.Context())
+	if !report.Ready() && !report.Draining {
+		middleware.Logger(c).Warn("就绪检查失败", "checks", report.Checks)
+	}
+	c.JSON(readyStatus(report), report)
+}
+
+func readyStatus(report health.Report) int {
+	if report.Ready() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
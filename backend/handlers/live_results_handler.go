@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/realtime"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// liveHeartbeatInterval 心跳间隔，防止代理关闭空闲连接
+	liveHeartbeatInterval = 15 * time.Second
+	// liveWriteTimeout 单次写入的超时时间，超时的慢客户端会被断开
+	liveWriteTimeout = 10 * time.Second
+)
+
+// LiveResultsHandler 通过SSE推送问卷的实时汇总结果
+type LiveResultsHandler struct {
+	Hub            *realtime.Hub
+	Results        *service.ResultsService
+	Questionnaires *service.QuestionnaireService
+	Users          *service.UserService // 签发实时结果令牌
+}
+
+// NewLiveResultsHandler 创建实时结果处理器
+func NewLiveResultsHandler(hub *realtime.Hub, results *service.ResultsService, questionnaires *service.QuestionnaireService, users *service.UserService) *LiveResultsHandler {
+	return &LiveResultsHandler{Hub: hub, Results: results, Questionnaires: questionnaires, Users: users}
+}
+
+// CreateStreamToken 签发只能订阅该问卷实时结果的短期令牌
+// 浏览器的EventSource不能设置Authorization头，以 ?token= 传给StreamResults；权限与StreamResults相同
+func (h *LiveResultsHandler) CreateStreamToken(c *gin.Context) {
+	questionnaire, ok := authorizeResultsAccess(c, h.Questionnaires)
+	if !ok {
+		return
+	}
+
+	token, expiresAt := h.Users.IssueStreamToken(c.GetUint("user_id"), questionnaire.ID)
+	c.JSON(201, gin.H{
+		"success":    true,
+		"message":    middleware.T(c, "questionnaire.stream_token_issued"),
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// StreamResults 以SSE方式推送问卷汇总结果
+// 连接建立后先发送当前汇总，之后每次有新的提交时推送最新汇总；登录验证见middleware.StreamAuthMiddleware
+func (h *LiveResultsHandler) StreamResults(c *gin.Context) {
+	questionnaire, ok := authorizeResultsAccess(c, h.Questionnaires)
+	if !ok {
+		return
+	}
+
+	// 先订阅再计算初始汇总，避免遗漏两者之间的提交
+	sub := h.Hub.Subscribe(questionnaire.ID)
+	defer h.Hub.Unsubscribe(sub)
+
+	summary, err := h.Results.Summary(c.Request.Context(), questionnaire.ID)
+	if err != nil {
+		fail(c, apierror.Internal("questionnaire.live_failed", fmt.Errorf("计算汇总统计失败: %w", err)))
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(200)
+
+	rc := http.NewResponseController(c.Writer)
+	write := func(frame string) error {
+		// 部分ResponseWriter不支持写超时，此时退化为普通写入
+		if err := rc.SetWriteDeadline(time.Now().Add(liveWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := c.Writer.WriteString(frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	logger := middleware.Logger(c).With("questionnaire_id", questionnaire.ID)
+	logger.Info("实时结果订阅开始", "subscribers", h.Hub.Subscribers(questionnaire.ID))
+
+	if err := write(resultsEvent(summary)); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			logger.Info("实时结果订阅结束", "dropped", sub.Dropped())
+			return
+		case <-h.Hub.Done():
+			logger.Info("服务关闭，结束实时结果订阅")
+			return
+		case summary := <-sub.Updates():
+			if err := write(resultsEvent(summary)); err != nil {
+				logger.Warn("推送实时结果失败，断开连接", "error", err)
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// resultsEvent 将汇总编码为SSE事件，事件ID为答卷总数
+func resultsEvent(summary *stats.Summary) string {
+	data, _ := json.Marshal(summary)
+	return fmt.Sprintf("id: %d\nevent: results\ndata: %s\n\n", summary.TotalSubmissions, data)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler 处理当前用户的两步验证设置，需经过middleware.AuthMiddleware
+type MFAHandler struct {
+	MFA *service.MFAService
+}
+
+// NewMFAHandler 创建两步验证处理器
+func NewMFAHandler(mfa *service.MFAService) *MFAHandler {
+	return &MFAHandler{MFA: mfa}
+}
+
+// codeRequest 需要提交验证码（或恢复码）的请求
+type codeRequest struct {
+	Code string `json:"code"`
+}
+
+// bindCode 解析请求体中的验证码，失败时已写入错误
+func bindCode(c *gin.Context) (string, bool) {
+	var request codeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return "", false
+	}
+	if err := requiredFields("code", request.Code); err != nil {
+		fail(c, err)
+		return "", false
+	}
+	return request.Code, true
+}
+
+// GetStatus 当前用户是否启用了两步验证及剩余的恢复码数量
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	status, err := h.MFA.Status(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		respondServiceError(c, err, "mfa.failed")
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "data": status})
+}
+
+// Enroll 生成待绑定的密钥和otpauth URI
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	enrollment, err := h.MFA.Enroll(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		respondServiceError(c, err, "mfa.failed")
+		return
+	}
+	c.JSON(200, gin.H{
+		"success":     true,
+		"message":     middleware.T(c, "mfa.enrolled"),
+		"secret":      enrollment.Secret,
+		"otpauth_uri": enrollment.URI,
+	})
+}
+
+// Activate 提交验证器App中的第一个验证码，启用两步验证
+// 响应中的恢复码只返回这一次；之前的会话令牌全部失效，客户端应改用响应中的token
+func (h *MFAHandler) Activate(c *gin.Context) {
+	code, ok := bindCode(c)
+	if !ok {
+		return
+	}
+	activation, err := h.MFA.Activate(c.Request.Context(), c.GetUint("user_id"), code)
+	if err != nil {
+		respondServiceError(c, err, "mfa.failed")
+		return
+	}
+	middleware.Logger(c).Info("用户启用两步验证", "user_id", c.GetUint("user_id"))
+	c.JSON(200, gin.H{
+		"success":        true,
+		"message":        middleware.T(c, "mfa.enabled"),
+		"recovery_codes": activation.RecoveryCodes,
+		"token":          activation.Token,
+	})
+}
+
+// Disable 提交验证码或恢复码，关闭两步验证
+func (h *MFAHandler) Disable(c *gin.Context) {
+	code, ok := bindCode(c)
+	if !ok {
+		return
+	}
+	if err := h.MFA.Disable(c.Request.Context(), c.GetUint("user_id"), code); err != nil {
+		respondServiceError(c, err, "mfa.failed")
+		return
+	}
+	middleware.Logger(c).Info("用户关闭两步验证", "user_id", c.GetUint("user_id"))
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "mfa.disabled"),
+	})
+}
+
+// RegenerateRecoveryCodes 提交验证码或恢复码，重新生成恢复码
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	code, ok := bindCode(c)
+	if !ok {
+		return
+	}
+	codes, err := h.MFA.RegenerateRecoveryCodes(c.Request.Context(), c.GetUint("user_id"), code)
+	if err != nil {
+		respondServiceError(c, err, "mfa.failed")
+		return
+	}
+	c.JSON(200, gin.H{
+		"success":        true,
+		"message":        middleware.T(c, "mfa.recovery_codes"),
+		"recovery_codes": codes,
+	})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryPage 解析列表接口的分页参数，游标无效时记录校验错误并返回false
+func queryPage(c *gin.Context, limits pagination.Limits) (pagination.Params, bool) {
+	params, err := pagination.Parse(c.Request.URL.Query(), limits)
+	if err != nil {
+		fail(c, apierror.Validation("pagination.cursor", "cursor", apierror.ReasonInvalid).WithCause(err))
+		return params, false
+	}
+	return params, true
+}
+
+// withPage 在响应数据中加入分页字段：page_size、next_cursor（没有下一页时为空），
+// 页码分页时的page，以及要求统计时的total
+func withPage(data gin.H, params pagination.Params, page pagination.Page) gin.H {
+	data["page_size"] = params.Limit
+	data["next_cursor"] = page.NextCursor
+	if params.Page > 0 {
+		data["page"] = params.Page
+	}
+	if page.Total != nil {
+		data["total"] = *page.Total
+	}
+	return data
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/password"
+	"questionnaire-system/backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceErrors 业务错误对应的HTTP状态码、错误码和提示信息
+var serviceErrors = []struct {
+	err    error
+	status int
+	code   apierror.Code
+	key    string
+}{
+	{service.ErrUserNotFound, http.StatusNotFound, apierror.CodeUserNotFound, "user.not_found"},
+	{service.ErrQuestionnaireNotFound, http.StatusNotFound, apierror.CodeQuestionnaireNotFound, "questionnaire.not_found"},
+	{service.ErrSSODisabled, http.StatusNotFound, apierror.CodeNotFound, "sso.disabled"},
+	{service.ErrWebhookNotFound, http.StatusNotFound, apierror.CodeNotFound, "webhook.not_found"},
+	{service.ErrDeliveryNotFound, http.StatusNotFound, apierror.CodeNotFound, "webhook.delivery_missing"},
+	{service.ErrInvalidCreator, http.StatusBadRequest, apierror.CodeValidationFailed, "questionnaire.creator"},
+	{service.ErrNoQuestions, http.StatusBadRequest, apierror.CodeValidationFailed, "questionnaire.no_questions"},
+	{service.ErrPublishedReadOnly, http.StatusBadRequest, apierror.CodeQuestionnaireReadOnly, "questionnaire.read_only"},
+	{service.ErrInvalidLocale, http.StatusBadRequest, apierror.CodeValidationFailed, "questionnaire.translation_locale"},
+	{service.ErrTranslationMismatch, http.StatusBadRequest, apierror.CodeValidationFailed, "questionnaire.translation_mismatch"},
+	{service.ErrInvalidSubmission, http.StatusBadRequest, apierror.CodeValidationFailed, "submission.invalid"},
+	{service.ErrInvalidResetToken, http.StatusBadRequest, apierror.CodeValidationFailed, "user.reset_token"},
+	{service.ErrInvalidVerificationToken, http.StatusBadRequest, apierror.CodeValidationFailed, "user.verification_token"},
+	{service.ErrInvalidMFACode, http.StatusBadRequest, apierror.CodeValidationFailed, "mfa.invalid_code"},
+	{service.ErrInvalidSSOState, http.StatusBadRequest, apierror.CodeValidationFailed, "sso.state"},
+	{password.ErrWeak, http.StatusBadRequest, apierror.CodeValidationFailed, "password.weak"},
+	{password.ErrTooLong, http.StatusBadRequest, apierror.CodeValidationFailed, "password.weak"},
+	{service.ErrInvalidCredentials, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "auth.credentials"},
+	{service.ErrInvalidMFAChallenge, http.StatusUnauthorized, apierror.CodeUnauthorized, "mfa.challenge"},
+	{service.ErrSSOFailed, http.StatusUnauthorized, apierror.CodeUnauthorized, "sso.failed"},
+	{service.ErrAccountLocked, http.StatusLocked, apierror.CodeAccountLocked, "auth.locked"},
+	{service.ErrEditForbidden, http.StatusForbidden, apierror.CodeForbidden, "questionnaire.edit_forbidden"},
+	{service.ErrResultsForbidden, http.StatusForbidden, apierror.CodeForbidden, "questionnaire.results_denied"},
+	{service.ErrCannotDeleteAdmin, http.StatusForbidden, apierror.CodeForbidden, "user.delete_admin"},
+	{service.ErrEmailUnverified, http.StatusForbidden, apierror.CodeEmailUnverified, "submission.email_unverified"},
+	{service.ErrPasswordLoginDisabled, http.StatusForbidden, apierror.CodeForbidden, "auth.password_login_disabled"},
+	{service.ErrWebhookForbidden, http.StatusForbidden, apierror.CodeForbidden, "webhook.forbidden"},
+	{service.ErrUsernameTaken, http.StatusConflict, apierror.CodeUsernameTaken, "user.username"},
+	{service.ErrEmailTaken, http.StatusConflict, apierror.CodeEmailTaken, "user.email"},
+	{service.ErrQuestionnaireClosed, http.StatusConflict, apierror.CodeQuestionnaireClosed, "questionnaire.closed"},
+	{service.ErrAlreadySubmitted, http.StatusConflict, apierror.CodeAlreadySubmitted, "submission.duplicate"},
+	{service.ErrMFAAlreadyEnabled, http.StatusConflict, apierror.CodeConflict, "mfa.already_enabled"},
+	{service.ErrMFANotEnabled, http.StatusConflict, apierror.CodeConflict, "mfa.not_enabled"},
+	{service.ErrMFANotEnrolled, http.StatusConflict, apierror.CodeConflict, "mfa.not_enrolled"},
+	{service.ErrDeliveryInProgress, http.StatusConflict, apierror.CodeConflict, "webhook.delivering"},
+	{service.ErrTooFrequent, http.StatusTooManyRequests, apierror.CodeRateLimited, "user.verification_throttled"},
+}
+
+// serviceError 将服务层错误转换为接口错误，非业务错误按服务器内部错误处理并返回fallback提示
+func serviceError(err error, fallback string) *apierror.Error {
+	for _, e := range serviceErrors {
+		if errors.Is(err, e.err) {
+			apiErr := apierror.New(e.status, e.code, e.key)
+			switch e.err {
+			case service.ErrInvalidCreator:
+				apiErr.WithField("created_by", apierror.ReasonInvalid)
+			case service.ErrNoQuestions:
+				apiErr.WithField("questions", apierror.ReasonRequired)
+			case service.ErrInvalidLocale:
+				apiErr.WithField("locale", apierror.ReasonUnsupported)
+			case service.ErrTranslationMismatch:
+				apiErr.WithField("translations", apierror.ReasonInvalid)
+			case service.ErrInvalidResetToken, service.ErrInvalidVerificationToken:
+				apiErr.WithField("token", apierror.ReasonInvalid)
+			case service.ErrInvalidMFACode:
+				apiErr.WithField("code", apierror.ReasonInvalid)
+			case service.ErrInvalidSSOState:
+				apiErr.WithField("state", apierror.ReasonInvalid)
+			case service.ErrAccountLocked:
+				var locked *service.LockedError
+				if errors.As(err, &locked) {
+					apiErr.WithArgs(locked.Until.UTC().Format(time.RFC3339))
+				}
+			}
+			return apiErr
+		}
+	}
+	return apierror.Internal(fallback, err)
+}
+
+// passwordError 与serviceError相同，密码不符合策略时在field字段上列出违反的每条规则
+func passwordError(err error, field, fallback string) *apierror.Error {
+	apiErr := serviceError(err, fallback)
+	var weak *password.PolicyError
+	if errors.As(err, &weak) {
+		for _, rule := range weak.Rules {
+			apiErr.WithField(field, rule)
+		}
+	} else if errors.Is(err, password.ErrTooLong) {
+		apiErr.WithField(field, password.RuleTooLong)
+	}
+	return apiErr
+}
+
+// respondServiceError 将服务层错误交给错误处理中间件写入响应
+func respondServiceError(c *gin.Context, err error, fallback string) {
+	fail(c, serviceError(err, fallback))
+}
+
+// fail 记录接口错误并中止处理，响应由middleware.ErrorHandler统一写入
+func fail(c *gin.Context, err *apierror.Error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// requiredFields 检查必填字段（字段名和取值依次成对传入），返回列出全部空字段的校验错误，没有缺失时返回nil
+func requiredFields(pairs ...string) *apierror.Error {
+	var err *apierror.Error
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if strings.TrimSpace(pairs[i+1]) != "" {
+			continue
+		}
+		if err == nil {
+			err = apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "request.params")
+		}
+		err.WithField(pairs[i], apierror.ReasonRequired)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSOHandler 处理OpenID Connect单点登录
+type SSOHandler struct {
+	SSO   *service.SSOService
+	Users *service.UserService
+}
+
+// NewSSOHandler 创建单点登录处理器
+func NewSSOHandler(sso *service.SSOService, users *service.UserService) *SSOHandler {
+	return &SSOHandler{SSO: sso, Users: users}
+}
+
+// LoginOptions 登录页可用的登录方式
+func (h *SSOHandler) LoginOptions(c *gin.Context) {
+	data := gin.H{
+		"password_login": !h.Users.DisablePasswordLogin,
+		"oidc":           h.SSO.Enabled(),
+	}
+	if h.SSO.Enabled() {
+		data["oidc_name"] = h.SSO.Name
+	}
+	c.JSON(200, gin.H{"success": true, "data": data})
+}
+
+// Start 发起单点登录，返回身份提供方的授权地址
+// 前端保存state后跳转到authorization_url，身份提供方回调时核对state再调用Complete
+func (h *SSOHandler) Start(c *gin.Context) {
+	authorization, err := h.SSO.Begin(c.Request.Context())
+	if err != nil {
+		middleware.Logger(c).Error("发起单点登录失败", "error", err)
+		respondServiceError(c, err, "sso.start_failed")
+		return
+	}
+	c.JSON(201, gin.H{
+		"success":           true,
+		"message":           middleware.T(c, "sso.started"),
+		"authorization_url": authorization.URL,
+		"state":             authorization.State,
+		"expires_at":        authorization.ExpiresAt,
+	})
+}
+
+// Complete 身份提供方回调后提交授权码和state，响应与密码登录相同
+func (h *SSOHandler) Complete(c *gin.Context) {
+	var request struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
+	if err := requiredFields("code", request.Code, "state", request.State); err != nil {
+		fail(c, err)
+		return
+	}
+
+	result, err := h.SSO.Complete(c.Request.Context(), request.State, request.Code)
+	if err != nil {
+		middleware.Logger(c).Warn("单点登录失败", "error", err)
+		respondServiceError(c, err, "sso.failed")
+		return
+	}
+	sessionStarted(c, result)
+}
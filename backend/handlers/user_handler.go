@@ -1,283 +1,287 @@
 package handlers
 
 import (
-	"log"
-	"questionnaire-system/backend/database"
-	"questionnaire-system/backend/models"
+	"errors"
 	"time"
 
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/service"
+
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // UserHandler 处理用户相关请求
 type UserHandler struct {
-	DB *database.Database
+	Users         *service.UserService
+	Resets        *service.PasswordResetService
+	Verifications *service.EmailVerificationService
 }
 
 // NewUserHandler 创建用户处理器
-func NewUserHandler(db *database.Database) *UserHandler {
-	return &UserHandler{DB: db}
+func NewUserHandler(users *service.UserService, resets *service.PasswordResetService, verifications *service.EmailVerificationService) *UserHandler {
+	return &UserHandler{Users: users, Resets: resets, Verifications: verifications}
 }
 
-// Register 注册用户
+// Register 注册用户，并向邮箱发送验证邮件
 func (h *UserHandler) Register(c *gin.Context) {
-	log.Println("收到注册请求")
-
-	var user models.User
-	err := c.ShouldBindJSON(&user)
-	if err != nil {
-		log.Printf("解析请求数据失败: %v", err)
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   "无效的请求数据",
-		})
-		return
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+		Phone    string `json:"phone"`
 	}
-
-	log.Printf("注册信息: 用户名=%s, 邮箱=%s", user.Username, user.Email)
-
-	// 检查用户名是否已存在
-	var existingUser models.User
-	result := h.DB.Where("username = ?", user.Username).First(&existingUser)
-	if result.RowsAffected > 0 {
-		log.Printf("用户名已存在: %s", user.Username)
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   "用户名已存在",
-		})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
 		return
 	}
 
-	// 检查邮箱是否已存在
-	result = h.DB.Where("email = ?", user.Email).First(&existingUser)
-	if result.RowsAffected > 0 {
-		log.Printf("邮箱已存在: %s", user.Email)
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   "邮箱已存在",
-		})
+	if err := requiredFields("username", request.Username, "password", request.Password, "email", request.Email); err != nil {
+		fail(c, err)
 		return
 	}
 
-	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	user, err := h.Users.Register(c.Request.Context(), service.RegisterInput{
+		Username: request.Username,
+		Email:    request.Email,
+		Phone:    request.Phone,
+		Password: request.Password,
+	})
 	if err != nil {
-		log.Printf("密码加密失败: %v", err)
-		c.JSON(500, gin.H{
-			"success": false,
-			"error":   "密码加密失败",
-		})
-		return
-	}
-	user.Password = string(hashedPassword)
-	log.Printf("密码加密结果: %s", user.Password)
-
-	// 设置创建时间
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
-
-	// 保存用户
-	result = h.DB.Create(&user)
-	if result.Error != nil {
-		log.Printf("创建用户失败: %v", result.Error)
-		c.JSON(500, gin.H{
-			"success": false,
-			"error":   "创建用户失败: " + result.Error.Error(),
-		})
+		fail(c, passwordError(err, "password", "user.create_failed"))
 		return
 	}
 
-	log.Printf("用户注册成功: ID=%d, 用户名=%s", user.ID, user.Username)
+	middleware.Logger(c).Info("用户注册成功", "user_id", user.ID, "username", user.Username)
+	// 验证邮件发送失败不影响注册，用户可以重新发送
+	if err := h.Verifications.Send(c.Request.Context(), user, middleware.Locale(c)); err != nil {
+		middleware.Logger(c).Error("生成邮箱验证令牌失败", "user_id", user.ID, "error", err)
+	}
 
-	// 返回用户信息（不包含密码）
-	user.Password = ""
 	c.JSON(201, gin.H{
 		"success": true,
-		"message": "注册成功",
+		"message": middleware.T(c, "user.registered"),
 		"user": map[string]interface{}{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
+			"id":             user.ID,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": false,
 		},
 	})
 }
 
 // Login 用户登录
+// 用户启用了两步验证时不返回会话令牌，而是返回mfa_token，由CompleteLogin提交验证码后完成登录
 func (h *UserHandler) Login(c *gin.Context) {
-	log.Println("收到登录请求")
-
-	// 解析登录信息
 	var loginRequest struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
+	if err := c.ShouldBindJSON(&loginRequest); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
 
-	err := c.ShouldBindJSON(&loginRequest)
+	result, err := h.Users.Login(c.Request.Context(), loginRequest.Username, loginRequest.Password)
 	if err != nil {
-		log.Printf("解析请求数据失败: %v", err)
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   "无效的请求数据",
-		})
+		middleware.Logger(c).Warn("登录失败", "username", loginRequest.Username, "error", err)
+		h.loginFailed(c, err)
 		return
 	}
 
-	log.Printf("登录尝试: 用户名=%s, 密码长度=%d", loginRequest.Username, len(loginRequest.Password))
+	sessionStarted(c, result)
+}
+
+// sessionStarted 身份校验通过后的响应（密码登录和单点登录相同）：返回会话令牌，用户启用了两步验证时返回mfa_token
+func sessionStarted(c *gin.Context, result *service.LoginResult) {
+	user := result.User
+	if result.Challenge != "" {
+		middleware.Logger(c).Info("身份校验通过，等待两步验证", "user_id", user.ID, "username", user.Username)
+		c.JSON(200, gin.H{
+			"success":  true,
+			"message":  middleware.T(c, "user.mfa_required"),
+			"user_id":  user.ID,
+			"username": user.Username,
+			"is_admin": user.IsAdmin,
 
-	// 查询用户
-	var user models.User
-	result := h.DB.Where("username = ?", loginRequest.Username).First(&user)
-	if result.Error != nil {
-		log.Printf("用户不存在: %s", loginRequest.Username)
-		c.JSON(401, gin.H{
-			"success": false,
-			"error":   "用户名或密码错误",
+			"email_verified": user.EmailVerifiedAt != nil,
+			"mfa_required":   true,
+			"mfa_token":      result.Challenge,
+			"mfa_expires_at": result.ChallengeExpiresAt,
 		})
 		return
 	}
 
-	// 验证密码
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password))
+	middleware.Logger(c).Info("用户登录成功", "user_id", user.ID, "username", user.Username)
+	loggedIn(c, user, result.Token)
+}
+
+// CompleteLogin 登录的第二步：提交登录挑战令牌和验证器App中的验证码（或恢复码），成功后返回会话令牌
+func (h *UserHandler) CompleteLogin(c *gin.Context) {
+	var request struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
+	if err := requiredFields("mfa_token", request.MFAToken, "code", request.Code); err != nil {
+		fail(c, err)
+		return
+	}
+
+	user, token, err := h.Users.CompleteLogin(c.Request.Context(), request.MFAToken, request.Code)
 	if err != nil {
-		log.Printf("密码错误: 用户=%s, 错误=%v", loginRequest.Username, err)
-		c.JSON(401, gin.H{
-			"success": false,
-			"error":   "用户名或密码错误",
-		})
+		middleware.Logger(c).Warn("两步验证失败", "error", err)
+		h.loginFailed(c, err)
 		return
 	}
 
-	log.Printf("用户登录成功: ID=%d, 用户名=%s", user.ID, user.Username)
+	middleware.Logger(c).Info("用户登录成功（两步验证）", "user_id", user.ID, "username", user.Username)
+	loggedIn(c, user, token)
+}
 
-	// 生成简单的会话令牌（实际项目中应使用JWT或其他认证机制）
-	token := "token_" + user.Username + "_" + time.Now().Format("20060102150405")
+// loginFailed 返回登录失败的错误，账户被锁定时设置Retry-After
+func (h *UserHandler) loginFailed(c *gin.Context, err error) {
+	var locked *service.LockedError
+	if errors.As(err, &locked) {
+		middleware.SetRetryAfter(c, time.Until(locked.Until))
+	}
+	respondServiceError(c, err, "auth.login_failed")
+}
 
+// loggedIn 登录成功的响应
+func loggedIn(c *gin.Context, user *models.User, token string) {
 	c.JSON(200, gin.H{
 		"success":  true,
-		"message":  "登录成功",
+		"message":  middleware.T(c, "user.logged_in"),
 		"user_id":  user.ID,
 		"username": user.Username,
 		"email":    user.Email,
 		"is_admin": user.IsAdmin,
 		"token":    token,
+
+		"email_verified": user.EmailVerifiedAt != nil,
+		"mfa_required":   false,
 	})
 }
 
-// ResetPassword 重置密码（用于测试）
-func (h *UserHandler) ResetPassword(c *gin.Context) {
-	log.Println("收到重置密码请求")
+// RequestPasswordReset 申请重置密码，向邮箱发送一次性的重置令牌
+// 无论邮箱是否已注册都返回相同的结果
+func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
+	if err := requiredFields("email", request.Email); err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.Resets.Request(c.Request.Context(), request.Email, middleware.Locale(c)); err != nil {
+		respondServiceError(c, err, "user.reset_request_failed")
+		return
+	}
 
-	// 解析请求
-	var resetRequest struct {
-		Username    string `json:"username"`
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "user.reset_requested"),
+	})
+}
+
+// ConfirmPasswordReset 使用邮件中的令牌设置新密码
+func (h *UserHandler) ConfirmPasswordReset(c *gin.Context) {
+	var request struct {
+		Token       string `json:"token"`
 		NewPassword string `json:"new_password"`
 	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
+	if err := requiredFields("token", request.Token, "new_password", request.NewPassword); err != nil {
+		fail(c, err)
+		return
+	}
 
-	err := c.ShouldBindJSON(&resetRequest)
-	if err != nil {
-		log.Printf("解析请求数据失败: %v", err)
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   "无效的请求数据",
-		})
+	if err := h.Resets.Confirm(c.Request.Context(), request.Token, request.NewPassword); err != nil {
+		fail(c, passwordError(err, "new_password", "user.reset_failed"))
 		return
 	}
 
-	log.Printf("重置密码: 用户名=%s, 新密码长度=%d", resetRequest.Username, len(resetRequest.NewPassword))
-
-	// 查询用户
-	var user models.User
-	result := h.DB.Where("username = ?", resetRequest.Username).First(&user)
-	if result.Error != nil {
-		log.Printf("用户不存在: %s", resetRequest.Username)
-
-		// 如果用户不存在，创建一个新用户（特殊情况，仅用于测试）
-		if resetRequest.Username == "testuser" {
-			// 创建测试用户
-			newUser := models.User{
-				Username:  resetRequest.Username,
-				Email:     "test@example.com",
-				IsAdmin:   false,
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
-
-			// 加密密码
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(resetRequest.NewPassword), bcrypt.DefaultCost)
-			if err != nil {
-				log.Printf("密码加密失败: %v", err)
-				c.JSON(500, gin.H{
-					"success": false,
-					"error":   "密码加密失败",
-				})
-				return
-			}
-			newUser.Password = string(hashedPassword)
-
-			// 保存用户
-			result = h.DB.Create(&newUser)
-			if result.Error != nil {
-				log.Printf("创建测试用户失败: %v", result.Error)
-				c.JSON(500, gin.H{
-					"success": false,
-					"error":   "创建测试用户失败",
-				})
-				return
-			}
-
-			log.Printf("创建测试用户成功: ID=%d, 用户名=%s", newUser.ID, newUser.Username)
-
-			c.JSON(200, gin.H{
-				"success": true,
-				"message": "测试用户创建成功",
-			})
-			return
-		}
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "user.password_reset"),
+	})
+}
 
-		c.JSON(404, gin.H{
-			"success": false,
-			"error":   "用户不存在",
-		})
+// ResendVerification 重新发送邮箱验证邮件
+// 邮箱未注册或已验证时同样返回成功；距上次发送时间太短时返回429和Retry-After
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
 		return
 	}
-
-	// 加密新密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(resetRequest.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
-		log.Printf("密码加密失败: %v", err)
-		c.JSON(500, gin.H{
-			"success": false,
-			"error":   "密码加密失败",
-		})
+	if err := requiredFields("email", request.Email); err != nil {
+		fail(c, err)
 		return
 	}
 
-	// 更新密码
-	user.Password = string(hashedPassword)
-	user.UpdatedAt = time.Now()
-	result = h.DB.Save(&user)
-	if result.Error != nil {
-		log.Printf("更新密码失败: %v", result.Error)
-		c.JSON(500, gin.H{
-			"success": false,
-			"error":   "更新密码失败",
-		})
+	if err := h.Verifications.Resend(c.Request.Context(), request.Email, middleware.Locale(c)); err != nil {
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			middleware.SetRetryAfter(c, throttled.RetryAfter)
+		}
+		respondServiceError(c, err, "user.verification_failed")
 		return
 	}
 
-	log.Printf("密码重置成功: 用户=%s, 新密码哈希=%s", user.Username, user.Password)
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "user.verification_sent"),
+	})
+}
 
-	// 测试密码验证
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(resetRequest.NewPassword))
+// ConfirmEmail 使用邮件中的令牌验证邮箱
+func (h *UserHandler) ConfirmEmail(c *gin.Context) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
+	if err := requiredFields("token", request.Token); err != nil {
+		fail(c, err)
+		return
+	}
+
+	user, err := h.Verifications.Confirm(c.Request.Context(), request.Token)
 	if err != nil {
-		log.Printf("警告：密码验证测试失败: %v", err)
-	} else {
-		log.Printf("密码验证测试成功")
+		respondServiceError(c, err, "user.verification_failed")
+		return
 	}
 
 	c.JSON(200, gin.H{
-		"success": true,
-		"message": "密码重置成功",
+		"success":  true,
+		"message":  middleware.T(c, "user.email_verified"),
+		"user_id":  user.ID,
+		"username": user.Username,
+		"email":    user.Email,
 	})
 }
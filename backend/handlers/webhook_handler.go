@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/pagination"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler 处理Webhook订阅相关请求
+type WebhookHandler struct {
+	Webhooks *service.WebhookService
+	// AllowPrivateTargets 允许订阅本机和内网的目标地址，仅用于开发和测试（见webhook.CheckTarget）
+	AllowPrivateTargets bool
+}
+
+// NewWebhookHandler 创建Webhook处理器
+func NewWebhookHandler(webhooks *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{Webhooks: webhooks}
+}
+
+// CreateWebhook 创建Webhook订阅
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var request struct {
+		QuestionnaireID uint     `json:"questionnaire_id"` // 为0时创建全局订阅
+		TargetURL       string   `json:"target_url"`
+		Secret          string   `json:"secret"`
+		Events          []string `json:"events"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.Logger(c).Debug("解析请求数据失败", "error", err)
+		fail(c, apierror.InvalidRequest(err))
+		return
+	}
+
+	// 校验目标地址，不允许指向本机、内网或元数据服务
+	if err := webhook.CheckTarget(c.Request.Context(), request.TargetURL, h.AllowPrivateTargets); err != nil {
+		key := "webhook.target_url"
+		if errors.Is(err, webhook.ErrForbiddenTarget) {
+			key = "webhook.target_forbidden"
+		}
+		middleware.Logger(c).Warn("Webhook目标地址无效", "target_url", request.TargetURL, "error", err)
+		fail(c, apierror.Validation(key, "target_url", apierror.ReasonInvalid))
+		return
+	}
+
+	// 校验事件类型
+	if len(request.Events) == 0 {
+		fail(c, apierror.Validation("webhook.events_required", "events", apierror.ReasonRequired))
+		return
+	}
+	for _, event := range request.Events {
+		if !webhook.IsValidEvent(event) {
+			fail(c, apierror.Validation("webhook.event_unsupported", "events", apierror.ReasonUnsupported).WithArgs(event))
+			return
+		}
+	}
+
+	subscription, secret, err := h.Webhooks.Create(c.Request.Context(), service.WebhookInput{
+		QuestionnaireID: request.QuestionnaireID,
+		TargetURL:       request.TargetURL,
+		Secret:          request.Secret,
+		Events:          request.Events,
+	}, c.GetUint("user_id"), c.GetBool("is_admin"))
+	if err != nil {
+		respondServiceError(c, err, "webhook.create_failed")
+		return
+	}
+
+	middleware.Logger(c).Info("Webhook订阅创建成功", "subscription_id", subscription.ID, "questionnaire_id", request.QuestionnaireID, "events", subscription.Events)
+
+	// 密钥仅在创建时返回一次
+	c.JSON(201, gin.H{
+		"success": true,
+		"message": middleware.T(c, "webhook.created"),
+		"data": gin.H{
+			"subscription": subscription,
+			"secret":       secret,
+		},
+	})
+}
+
+// GetWebhooks 获取Webhook订阅列表
+// 指定questionnaire_id时返回该问卷的订阅，否则返回全局订阅（仅管理员）
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	var questionnaireID *uint
+	if idStr := c.Query("questionnaire_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			fail(c, apierror.Validation("questionnaire.id_invalid", "questionnaire_id", apierror.ReasonInvalid))
+			return
+		}
+		qid := uint(id)
+		questionnaireID = &qid
+	}
+
+	params, ok := queryPage(c, pagination.ListLimits)
+	if !ok {
+		return
+	}
+
+	subscriptions, page, err := h.Webhooks.List(c.Request.Context(), questionnaireID, c.GetUint("user_id"), c.GetBool("is_admin"), params)
+	if err != nil {
+		respondServiceError(c, err, "webhook.list_failed")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data": withPage(gin.H{
+			"subscriptions": subscriptions,
+			"events":        webhook.Events,
+		}, params, page),
+	})
+}
+
+// DeleteWebhook 删除Webhook订阅
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := resourceID(c, "id", "webhook.id_invalid", "webhook.id_invalid")
+	if !ok {
+		return
+	}
+
+	// 停用而不是物理删除，保留投递记录
+	if err := h.Webhooks.Deactivate(c.Request.Context(), id, c.GetUint("user_id"), c.GetBool("is_admin")); err != nil {
+		respondServiceError(c, err, "webhook.delete_failed")
+		return
+	}
+
+	middleware.Logger(c).Info("Webhook订阅已停用", "subscription_id", id)
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "webhook.deleted"),
+	})
+}
+
+// deliveryLimits 投递记录每页默认20条，最多100条
+var deliveryLimits = pagination.Limits{Default: 20, Max: 100}
+
+// GetWebhookDeliveries 获取订阅的投递记录及每次尝试的结果
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	subscriptionID, ok := resourceID(c, "subscription_id", "webhook.id_invalid", "webhook.id_invalid")
+	if !ok {
+		return
+	}
+
+	params, ok := queryPage(c, deliveryLimits)
+	if !ok {
+		return
+	}
+
+	result, page, err := h.Webhooks.Deliveries(c.Request.Context(), subscriptionID, c.GetUint("user_id"), c.GetBool("is_admin"), params)
+	if err != nil {
+		respondServiceError(c, err, "webhook.deliveries_failed")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"data":    withPage(gin.H{"deliveries": result}, params, page),
+	})
+}
+
+// RedeliverWebhook 重新投递一条发件箱记录
+func (h *WebhookHandler) RedeliverWebhook(c *gin.Context) {
+	var request struct {
+		ID uint `json:"id"` // 发件箱记录ID
+	}
+
+	// 新接口的ID在路径中，请求体可以为空
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			fail(c, apierror.InvalidRequest(err))
+			return
+		}
+	}
+	if !pathID(c, &request.ID, "webhook.id_invalid") {
+		return
+	}
+
+	if err := h.Webhooks.Redeliver(c.Request.Context(), request.ID, c.GetUint("user_id"), c.GetBool("is_admin")); err != nil {
+		respondServiceError(c, err, "webhook.redeliver_failed")
+		return
+	}
+
+	middleware.Logger(c).Info("Webhook已重新排队", "outbox_id", request.ID)
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": middleware.T(c, "webhook.requeued"),
+	})
+}
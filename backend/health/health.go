@@ -0,0 +1,180 @@
+// Package health 存活检查和就绪检查
+//
+// 存活检查只说明进程仍在响应；就绪检查依次探测数据库、表结构版本等依赖，
+// 关键依赖不可用或服务正在关闭时返回不可用，负载均衡据此摘除实例。
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 检查结果状态
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"    // 非关键依赖异常，仍可提供服务
+	StatusUnavailable = "unavailable" // 关键依赖异常或服务正在关闭
+)
+
+// Probe 一项依赖检查
+type Probe struct {
+	Name     string
+	Critical bool // 失败时整个服务不可用
+	// Check 执行检查，details为附带的状态信息（如连接池、版本号），可以为nil
+	Check func(ctx context.Context) (details interface{}, err error)
+}
+
+// Result 单项检查的结果
+type Result struct {
+	Status    string      `json:"status"`
+	Critical  bool        `json:"critical"`
+	LatencyMs int64       `json:"latency_ms"`
+	Details   interface{} `json:"details,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// Report 就绪检查报告
+type Report struct {
+	Status   string            `json:"status"`
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]Result `json:"checks"`
+}
+
+// Ready 是否可以接收请求
+func (r Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// Checker 执行全部检查，可在多个goroutine中并发使用
+type Checker struct {
+	Timeout time.Duration // 单项检查的超时时间
+
+	mu       sync.RWMutex
+	probes   []Probe
+	draining atomic.Bool
+}
+
+// NewChecker 创建检查器，单项检查默认2秒超时
+func NewChecker(probes ...Probe) *Checker {
+	return &Checker{Timeout: 2 * time.Second, probes: probes}
+}
+
+// Add 添加检查项，同名的检查项会被替换
+func (c *Checker) Add(p Probe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.probes {
+		if c.probes[i].Name == p.Name {
+			c.probes[i] = p
+			return
+		}
+	}
+	c.probes = append(c.probes, p)
+}
+
+// Names 按名称排序的检查项
+func (c *Checker) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.probes))
+	for _, p := range c.probes {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDraining 标记服务正在关闭，之后的就绪检查都返回不可用
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Draining 服务是否正在关闭
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check 并发执行全部检查
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	probes := append([]Probe(nil), c.probes...)
+	c.mu.RUnlock()
+
+	results := make([]Result, len(probes))
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, p Probe) {
+			defer wg.Done()
+			results[i] = c.run(ctx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(probes))}
+	for i, p := range probes {
+		r := results[i]
+		report.Checks[p.Name] = r
+		if r.Status == StatusOK {
+			continue
+		}
+		if p.Critical {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	if c.Draining() {
+		report.Status = StatusUnavailable
+		report.Draining = true
+	}
+	return report
+}
+
+// run 执行单项检查，超时或panic都视为失败
+func (c *Checker) run(ctx context.Context, p Probe) (result Result) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result = Result{Status: StatusOK, Critical: p.Critical}
+	defer func() { result.LatencyMs = time.Since(start).Milliseconds() }()
+
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	// 检查在单独的goroutine中执行，不响应ctx的检查也不会阻塞整个报告
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- outcome{err: fmt.Errorf("检查异常: %v", v)}
+			}
+		}()
+		details, err := p.Check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	select {
+	case o := <-done:
+		result.Details = o.details
+		if o.err != nil {
+			result.Status = StatusUnavailable
+			result.Error = o.err.Error()
+		}
+	case <-ctx.Done():
+		result.Status = StatusUnavailable
+		result.Error = "检查超时: " + ctx.Err().Error()
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"questionnaire-system/backend/database/migrations"
+
+	"gorm.io/gorm"
+)
+
+// Database 数据库连接检查（关键依赖），附带连接池状态
+func Database(db *gorm.DB) Probe {
+	return Probe{
+		Name:     "database",
+		Critical: true,
+		Check: func(ctx context.Context) (interface{}, error) {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return nil, err
+			}
+			if err := sqlDB.PingContext(ctx); err != nil {
+				return nil, fmt.Errorf("数据库连接失败: %w", err)
+			}
+			stats := sqlDB.Stats()
+			return map[string]int{
+				"open_connections": stats.OpenConnections,
+				"in_use":           stats.InUse,
+				"idle":             stats.Idle,
+				"max_open":         stats.MaxOpenConnections,
+			}, nil
+		},
+	}
+}
+
+// Migrations 表结构版本检查（关键依赖）：数据库版本与程序支持的版本不一致时不可用
+func Migrations(db *gorm.DB) Probe {
+	return Probe{
+		Name:     "migrations",
+		Critical: true,
+		Check: func(ctx context.Context) (interface{}, error) {
+			migrator := migrations.New(db.WithContext(ctx))
+			current, err := migrator.Current()
+			if err != nil {
+				return nil, fmt.Errorf("读取迁移版本失败: %w", err)
+			}
+			details := map[string]uint{"current": current, "latest": migrator.Latest()}
+			return details, migrator.Check()
+		},
+	}
+}
@@ -0,0 +1,137 @@
+// Package i18n 接口提示信息的多语言目录及语言协商
+//
+// 提示信息按键保存在locales/<语言>.json中，新增提示时需同时补充全部语言。
+// 问卷内容的翻译由问卷作者提供，可以使用任意语言，见 Match。
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// 提供提示信息的语言
+const (
+	ZhCN    = "zh-CN"
+	En      = "en"
+	Default = ZhCN
+)
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs 各语言的提示信息
+var catalogs = load()
+
+var supported = []language.Tag{language.SimplifiedChinese, language.English}
+
+var matcher = language.NewMatcher(supported)
+
+func load() map[string]map[string]string {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("解析提示信息%s失败: %v", entry.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return catalogs
+}
+
+// Locales 提供提示信息的语言
+func Locales() []string {
+	return []string{ZhCN, En}
+}
+
+// Keys 语言目录中的全部键，按字母顺序排列
+func Keys(locale string) []string {
+	keys := make([]string, 0, len(catalogs[locale]))
+	for key := range catalogs[locale] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Negotiate 根据Accept-Language选择提示信息的语言，无法匹配时使用中文
+func Negotiate(acceptLanguage string) string {
+	tags := parse(acceptLanguage)
+	if len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	if supported[index] == language.English {
+		return En
+	}
+	return ZhCN
+}
+
+// T 返回键对应的提示信息，缺少翻译时依次回退到中文和键本身
+func T(locale, key string, args ...interface{}) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		msg, ok = catalogs[Default][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Canonical 规范化语言代码（如zh-cn、zh_CN均为zh-CN），无效时返回false
+func Canonical(locale string) (string, bool) {
+	tag, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if err != nil || tag == language.Und {
+		return "", false
+	}
+	return tag.String(), true
+}
+
+// Match 从available中选择最符合Accept-Language（或单个语言代码）的语言：
+// 精确匹配优先，其次是同一语言的其他地区（如en-GB匹配en），都不匹配时返回fallback
+func Match(acceptLanguage string, available []string, fallback string) string {
+	tags := parse(acceptLanguage)
+	if len(tags) == 0 || len(available) == 0 {
+		return fallback
+	}
+	var candidates []language.Tag
+	for _, locale := range available {
+		candidates = append(candidates, language.Make(locale))
+	}
+	_, index, confidence := language.NewMatcher(candidates).Match(tags...)
+	if confidence < language.High {
+		return fallback
+	}
+	return available[index]
+}
+
+func parse(acceptLanguage string) []language.Tag {
+	if strings.TrimSpace(acceptLanguage) == "" {
+		return nil
+	}
+	tags, _, err := language.ParseAcceptLanguage(strings.ReplaceAll(acceptLanguage, "_", "-"))
+	if err != nil {
+		return nil
+	}
+	return tags
+}
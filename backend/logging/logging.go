@@ -0,0 +1,96 @@
+// Package logging 基于log/slog的结构化日志
+//
+// 提供按配置创建的日志记录器（级别、JSON或文本格式、控制台及可轮转的日志文件）、
+// 凭据和个人信息的脱敏、请求上下文中的日志记录器以及访问日志的按路由采样。
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"questionnaire-system/backend/config"
+)
+
+// ParseLevel 解析日志级别，无法识别时使用info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewHandler 创建输出到w的日志处理器，所有记录都经过脱敏
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: Redact}
+	if format == "text" {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// New 按配置创建日志记录器：输出到控制台，设置了文件时同时写入按大小轮转的日志文件
+// 返回的io.Closer用于关闭日志文件，未使用文件时为空操作
+func New(cfg config.LogConfig, console io.Writer) (*slog.Logger, io.Closer, error) {
+	w := console
+	var closer io.Closer = nopCloser{}
+	if cfg.File != "" {
+		file, err := OpenRotatingFile(cfg.File, cfg.MaxSizeMB, cfg.MaxBackups, cfg.MaxAgeDays)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = io.MultiWriter(console, file), file
+	}
+	return slog.New(NewHandler(w, cfg.Format, ParseLevel(cfg.Level))), closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+type contextKey struct{}
+
+// NewContext 返回携带日志记录器的context
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext 返回context中的日志记录器（带请求ID），没有时返回slog.Default()
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+var (
+	mu          sync.Mutex
+	defaultFile io.Closer = nopCloser{}
+)
+
+// SetDefault 设置slog的默认日志记录器，closer为New返回的日志文件，Fatal退出前关闭
+func SetDefault(logger *slog.Logger, closer io.Closer) {
+	mu.Lock()
+	defer mu.Unlock()
+	slog.SetDefault(logger)
+	defaultFile = closer
+}
+
+// Fatal 记录错误并退出程序
+// os.Exit不执行defer，退出前先关闭日志文件，确保这条错误已同步到磁盘
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	mu.Lock()
+	defaultFile.Close()
+	mu.Unlock()
+	os.Exit(1)
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Redacted 替换凭据的占位符
+const Redacted = "[REDACTED]"
+
+// secretKeys 键名包含这些词的字段视为凭据，值整体替换
+var secretKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "api_key", "apikey", "dsn", "otp"}
+
+// isSecretKey 字段是否为凭据
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact 作为slog.HandlerOptions.ReplaceAttr使用：凭据替换为占位符，邮箱和手机号部分遮盖；
+// 值形如"Bearer xxx"的字段无论键名都视为凭据
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	key := strings.ToLower(a.Key)
+	switch {
+	case isSecretKey(key):
+		return slog.String(a.Key, Redacted)
+	case key == "email" || strings.HasSuffix(key, "_email"):
+		return slog.String(a.Key, MaskEmail(a.Value.String()))
+	case key == "phone" || strings.HasSuffix(key, "_phone") || key == "mobile":
+		return slog.String(a.Key, MaskPhone(a.Value.String()))
+	}
+	if a.Value.Kind() == slog.KindString && strings.HasPrefix(strings.ToLower(a.Value.String()), "bearer ") {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// MaskEmail 只保留邮箱用户名的首字母和域名，如 a***@example.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return maskAll(email)
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// MaskPhone 只保留手机号的前3位和后4位，如 138****8000
+func MaskPhone(phone string) string {
+	if len(phone) < 8 {
+		return maskAll(phone)
+	}
+	return phone[:3] + "****" + phone[len(phone)-4:]
+}
+
+func maskAll(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "***"
+}
+
+// RedactQuery 替换查询字符串中凭据参数的值
+func RedactQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	changed := false
+	for key := range values {
+		if isSecretKey(key) {
+			values[key] = []string{Redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return rawQuery
+	}
+	return values.Encode()
+}
@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat 轮转后的文件名中的时间，按字典序即按时间排序
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile 按大小轮转的日志文件，可在多个goroutine中并发写入
+// 当前文件超过MaxSize时重命名为 名称-时间.扩展名，并按MaxBackups和MaxAge清理旧文件
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64         // 单个文件的最大字节数，0表示不轮转
+	MaxBackups int           // 保留的旧文件数量，0表示不限
+	MaxAge     time.Duration // 旧文件的保留时间，0表示不限
+
+	// now 返回当前时间，测试时可替换
+	now func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile 打开（追加写入）日志文件，大小以MB、保留时间以天为单位
+func OpenRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		Path:       path,
+		MaxSize:    int64(maxSizeMB) * 1024 * 1024,
+		MaxBackups: maxBackups,
+		MaxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write 写入日志，写入后超过大小上限的文件会先轮转
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close 将日志同步到磁盘并关闭文件，重复调用时为空操作
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	f.file.Sync()
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	if dir := filepath.Dir(f.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建日志目录失败: %w", err)
+		}
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate 重命名当前文件并重新打开，调用方持有锁
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.Path)
+	base := fmt.Sprintf("%s-%s", strings.TrimSuffix(f.Path, ext), f.now().Format(backupTimeFormat))
+	backup := base + ext
+	// 同一毫秒内多次轮转时加序号，避免覆盖
+	for i := 1; fileExists(backup); i++ {
+		backup = fmt.Sprintf("%s.%d%s", base, i, ext)
+	}
+	if err := os.Rename(f.Path, backup); err != nil {
+		return fmt.Errorf("轮转日志文件失败: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.cleanup()
+	return nil
+}
+
+// Backups 按时间从新到旧排列的旧日志文件
+func (f *RotatingFile) Backups() ([]string, error) {
+	ext := filepath.Ext(f.Path)
+	matches, err := filepath.Glob(strings.TrimSuffix(f.Path, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return matches, nil
+}
+
+// cleanup 删除超出数量或保留时间的旧文件，失败时忽略，下次轮转时重试
+func (f *RotatingFile) cleanup() {
+	backups, err := f.Backups()
+	if err != nil {
+		return
+	}
+	for i, path := range backups {
+		expired := false
+		if f.MaxAge > 0 {
+			if info, err := os.Stat(path); err == nil && f.now().Sub(info.ModTime()) > f.MaxAge {
+				expired = true
+			}
+		}
+		if expired || (f.MaxBackups > 0 && i >= f.MaxBackups) {
+			os.Remove(path)
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Sampler 按路由采样访问日志，只作用于成功的请求，错误请求总是记录
+// 规则的键为Gin的路由模板（如 /api/v1/questionnaires/:id），以*结尾时按前缀匹配，
+// 值为记录的比例（0表示不记录，1表示全部记录）；没有匹配规则的路由全部记录
+type Sampler struct {
+	exact    map[string]float64
+	prefixes []prefixRule // 按前缀长度从长到短排序
+}
+
+type prefixRule struct {
+	prefix string
+	rate   float64
+}
+
+// NewSampler 创建采样器，rules为空时返回nil（全部记录）
+func NewSampler(rules map[string]float64) *Sampler {
+	if len(rules) == 0 {
+		return nil
+	}
+	s := &Sampler{exact: make(map[string]float64)}
+	for route, rate := range rules {
+		if prefix, ok := strings.CutSuffix(route, "*"); ok {
+			s.prefixes = append(s.prefixes, prefixRule{prefix, rate})
+		} else {
+			s.exact[route] = rate
+		}
+	}
+	sort.Slice(s.prefixes, func(i, j int) bool { return len(s.prefixes[i].prefix) > len(s.prefixes[j].prefix) })
+	return s
+}
+
+// Rate 路由的采样比例
+func (s *Sampler) Rate(route string) float64 {
+	if s == nil {
+		return 1
+	}
+	if rate, ok := s.exact[route]; ok {
+		return rate
+	}
+	for _, p := range s.prefixes {
+		if strings.HasPrefix(route, p.prefix) {
+			return p.rate
+		}
+	}
+	return 1
+}
+
+// Keep 是否记录该请求，按请求ID的哈希决定，同一请求在多个实例上的结果一致
+func (s *Sampler) Keep(route, requestID string) bool {
+	rate := s.Rate(route)
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(requestID))
+	return float64(h.Sum32()%10000) < rate*10000
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File 将邮件写入目录，每封一个.eml文件（可用邮件客户端打开），用于开发和离线测试
+// 邮件中可能包含重置密码的令牌，文件只对当前用户可读
+type File struct {
+	Dir  string
+	From string
+}
+
+// Send 实现Mailer
+func (m *File) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	_, _, data, err := compose(m.From, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	// 文件名按时间排序
+	name := filepath.Join(m.Dir, fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), randomHex(4)))
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "邮件已写入文件", "to", msg.To, "subject", msg.Subject, "file", name)
+	return nil
+}
+
+// Log 只把邮件记录到日志，未配置SMTP和OutboxDir时使用
+// 正文可能包含令牌，只在debug级别记录
+type Log struct{}
+
+// Send 实现Mailer
+func (Log) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "未配置邮件发送，邮件只记录到日志", "to", msg.To, "subject", msg.Subject)
+	slog.DebugContext(ctx, "邮件正文", "to", msg.To, "body", msg.Body)
+	return nil
+}
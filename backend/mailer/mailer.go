@@ -0,0 +1,112 @@
+// Package mailer 发送通知邮件（如重置密码）
+//
+// 生产环境通过SMTP发送；开发和离线测试时可以写入目录（.eml文件）或只记录到日志。
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/config"
+)
+
+// defaultFrom 未配置发件人时写入文件和日志使用的地址
+const defaultFrom = "noreply@localhost"
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string // 收件人地址
+	Subject string
+	Body    string
+}
+
+// Mailer 发送邮件
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New 按配置创建Mailer：设置了Host时使用SMTP，否则设置了OutboxDir时写入文件，都未设置时只记录到日志
+func New(cfg config.SMTPConfig) Mailer {
+	switch {
+	case cfg.Host != "":
+		return &SMTP{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.Username,
+			Password: cfg.Password.Value(),
+			From:     cfg.From,
+		}
+	case cfg.OutboxDir != "":
+		return &File{Dir: cfg.OutboxDir, From: cfg.From}
+	default:
+		return Log{}
+	}
+}
+
+// ErrInvalidMessage 收件人、发件人或标题无效（如标题中包含换行，可能导致邮件头注入）
+var ErrInvalidMessage = errors.New("无效的邮件")
+
+// compose 生成RFC 5322格式的邮件，正文使用UTF-8和quoted-printable编码
+func compose(from string, msg Message, now time.Time) (sender, recipient *mail.Address, data []byte, err error) {
+	if from == "" {
+		from = defaultFrom
+	}
+	if sender, err = mail.ParseAddress(from); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: 发件人 %q: %v", ErrInvalidMessage, from, err)
+	}
+	if recipient, err = mail.ParseAddress(msg.To); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: 收件人 %q: %v", ErrInvalidMessage, msg.To, err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, nil, nil, fmt.Errorf("%w: 标题中不能包含换行", ErrInvalidMessage)
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", sender.String())
+	header("To", recipient.String())
+	header("Subject", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(sender.Address))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(msg.Body)); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, nil, nil, err
+	}
+	return sender, recipient, buf.Bytes(), nil
+}
+
+// messageID 使用发件人的域名生成Message-ID
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	return "<" + randomHex(16) + "@" + domain + ">"
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
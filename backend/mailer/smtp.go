@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// defaultTimeout ctx没有截止时间时，一次发送（连接、认证、传输）的最长时间
+const defaultTimeout = 30 * time.Second
+
+// SMTP 通过SMTP服务器发送邮件
+// 端口465使用隐式TLS；其他端口在服务器支持时升级为STARTTLS。
+// 设置了Username时使用PLAIN认证，net/smtp只允许在TLS连接或本机上发送密码
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration // 为0时使用defaultTimeout
+
+	// TLSConfig 为空时按Host校验服务器证书
+	TLSConfig *tls.Config
+}
+
+// Send 实现Mailer，每封邮件使用一个新连接
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	from, to, data, err := compose(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if m.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tlsConfig()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器 %s 失败: %w", addr, err)
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP握手失败: %w", err)
+	}
+	defer client.Close()
+
+	if m.Port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(m.tlsConfig()); err != nil {
+				return fmt.Errorf("SMTP STARTTLS失败: %w", err)
+			}
+		}
+	}
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM失败: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("SMTP RCPT TO失败: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA失败: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	return client.Quit()
+}
+
+func (m *SMTP) tlsConfig() *tls.Config {
+	if m.TLSConfig != nil {
+		return m.TLSConfig
+	}
+	return &tls.Config{ServerName: m.Host, MinVersion: tls.VersionTLS12}
+}
@@ -1,25 +1,56 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
 	"questionnaire-system/backend/config"
 	"questionnaire-system/backend/database"
-	"questionnaire-system/backend/handlers"
+	"questionnaire-system/backend/health"
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/mailer"
+	"questionnaire-system/backend/metrics"
 	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/oidc"
+	"questionnaire-system/backend/password"
+	"questionnaire-system/backend/ratelimit"
+	"questionnaire-system/backend/realtime"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/server"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/session"
+	"questionnaire-system/backend/tracing"
+	"questionnaire-system/backend/webhook"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CORS中间件
-func CORSMiddleware() gin.HandlerFunc {
+// CORS中间件，allowedOrigins包含"*"时允许所有来源
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool)
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if allowAll {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowed[origin] {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Request-ID, Accept-Language, traceparent, tracestate, baggage")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Content-Language, Deprecation, Link, Retry-After")
 		c.Writer.Header().Set("Access-Control-Max-Age", "3600")
 
 		if c.Request.Method == "OPTIONS" {
@@ -31,145 +62,192 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// 日志中间件
-func LoggingMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 开始时间
-		startTime := time.Now()
-
-		// 处理请求
-		c.Next()
-
-		// 结束时间
-		endTime := time.Now()
-
-		// 执行时间
-		latencyTime := endTime.Sub(startTime)
-
-		// 请求方式
-		reqMethod := c.Request.Method
-
-		// 请求路由
-		reqUri := c.Request.RequestURI
-
-		// 状态码
-		statusCode := c.Writer.Status()
-
-		// 请求IP
-		clientIP := c.ClientIP()
-
-		// 使用Gin的日志系统而不是标准输出
-		gin.DefaultWriter.Write([]byte(
-			"| " +
-				time.Now().Format("2006/01/02 - 15:04:05") +
-				" | " +
-				reqMethod +
-				" | " +
-				reqUri +
-				" | " +
-				clientIP +
-				" | " +
-				time.Duration(latencyTime).String() +
-				" | " +
-				" Status: " +
-				string(statusCode) +
-				" |\n",
-		))
-	}
-}
-
 func main() {
-	// 设置日志输出到文件和控制台
-	logFile, err := os.OpenFile("backend.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	// 加载配置（默认值 → 配置文件 → 环境变量 → 命令行参数）
+	config := config.LoadConfig()
+
+	// 结构化日志：输出到控制台，设置了log.file时同时写入按大小轮转的日志文件
+	logger, logCloser, err := logging.New(config.Log, os.Stdout)
 	if err != nil {
-		log.Fatalf("无法打开日志文件: %v", err)
+		fmt.Fprintf(os.Stderr, "初始化日志失败: %v\n", err)
+		os.Exit(1)
 	}
-	defer logFile.Close()
-
-	// 设置日志同时输出到文件和控制台
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	gin.DefaultWriter = multiWriter
-	log.SetOutput(multiWriter)
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	defer logCloser.Close()
+	logging.SetDefault(logger, logCloser)
 
 	// 设置Gin模式 - 开发环境使用Debug模式以显示更多日志
-	gin.SetMode(gin.DebugMode)
+	if config.Env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	} else {
+		gin.SetMode(gin.DebugMode)
+	}
 
-	// 输出启动信息到控制台
-	fmt.Println("=== 问卷系统后端服务 ===")
-	fmt.Println("正在初始化...")
+	slog.Info("问卷系统后端服务正在初始化", "config", config.Summary())
 
-	// 加载配置
-	config := config.LoadConfig()
+	// 子命令
+	if len(config.Args) > 0 {
+		if config.Args[0] != "migrate" {
+			logging.Fatal("未知的子命令", "command", config.Args[0])
+		}
+		if err := runMigrate(config, config.Args[1:]); err != nil {
+			logging.Fatal("数据库迁移失败", "error", err)
+		}
+		return
+	}
 
-	// 初始化数据库
+	// 链路追踪：导出方式为none时不导出span，只传递请求头中的追踪上下文
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing)
+	if err != nil {
+		logging.Fatal("初始化链路追踪失败", "error", err)
+	}
+
+	// 初始化数据库（表结构版本落后时拒绝启动），每次查询在请求的span下创建子span
 	db, err := database.InitDB(config)
 	if err != nil {
-		log.Fatalf("数据库初始化失败: %v", err)
+		logging.Fatal("数据库初始化失败", "error", err)
+	}
+	if err := db.DB.Use(tracing.NewGormPlugin(nil)); err != nil {
+		logging.Fatal("注册数据库追踪插件失败", "error", err)
 	}
 
-	// 创建Gin路由
-	router := gin.Default()
+	// 后台任务：Webhook投递器
+	bg := newWorkers()
+	dispatcher := webhook.NewDispatcher(db.DB, config.Webhook.AllowPrivateTargets)
+	if config.Webhook.AllowPrivateTargets {
+		slog.Warn("已允许Webhook投递到本机和内网地址，仅用于开发和测试")
+	}
+	bg.Go(dispatcher.Run)
+
+	// 就绪检查：数据库和表结构版本为关键依赖，投递器异常时只标记为降级
+	checker := health.NewChecker(health.Database(db.DB), health.Migrations(db.DB), dispatcher.Probe())
+
+	// 监控指标：Webhook发件箱积压的事件数即后台任务队列长度
+	var appMetrics *metrics.Metrics
+	var metricsAccess gin.HandlerFunc
+	if config.Metrics.Enabled {
+		appMetrics = metrics.New()
+		appMetrics.Registry.GaugeFunc("webhook_outbox_pending", "等待投递的Webhook事件数", func(ctx context.Context) (float64, error) {
+			n, err := dispatcher.Pending(ctx)
+			return float64(n), err
+		})
+		if metricsAccess, err = middleware.MetricsAccess(config.Metrics.Token.Value(), config.Metrics.AllowedNetworks); err != nil {
+			logging.Fatal("监控指标配置无效", "error", err)
+		}
+	}
 
-	// 应用中间件
-	router.Use(CORSMiddleware())
-	router.Use(LoggingMiddleware())
+	// 登录、重置密码和重新发送验证邮件接口按IP、用户名和邮箱限流（邮箱限制同一地址收到的邮件数）；
+	// 多实例部署时令牌桶保存在数据库中，限制对所有实例生效
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+	if config.RateLimit.Backend == "database" {
+		limiter = ratelimit.NewGormLimiter(db.DB)
+	}
+	authLimit := middleware.RateLimit(limiter,
+		middleware.RateRule{
+			Key:   middleware.ByClientIP("auth"),
+			Limit: ratelimit.Limit{Burst: config.RateLimit.IP.Burst, Period: config.RateLimit.IP.Period},
+		},
+		middleware.RateRule{
+			Key:   middleware.ByJSONField("auth", "username"),
+			Limit: ratelimit.Limit{Burst: config.RateLimit.Username.Burst, Period: config.RateLimit.Username.Period},
+		},
+		middleware.RateRule{
+			Key:   middleware.ByJSONField("auth", "email"),
+			Limit: ratelimit.Limit{Burst: config.RateLimit.Username.Burst, Period: config.RateLimit.Username.Period},
+		},
+	)
+
+	// 单点登录：第一次登录时才请求身份提供方的发现文档，身份提供方暂时不可用不影响启动
+	var sso *oidc.Provider
+	if config.Auth.OIDC.Issuer != "" {
+		sso = oidc.New(oidc.Config{
+			Issuer:       config.Auth.OIDC.Issuer,
+			ClientID:     config.Auth.OIDC.ClientID,
+			ClientSecret: config.Auth.OIDC.ClientSecret.Value(),
+			RedirectURL:  config.Auth.OIDC.RedirectURL,
+			Scopes:       config.Auth.OIDC.Scopes,
+		})
+	}
 
-	// 创建处理器
-	userHandler := handlers.NewUserHandler(db)
-	questionnaireHandler := handlers.NewQuestionnaireHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
+	// 会话令牌的签名密钥：多个实例需配置相同的密钥；未配置时（仅开发和测试环境允许）随机生成，重启后需重新登录
+	tokenSecret := []byte(config.Auth.TokenSecret.Value())
+	if len(tokenSecret) == 0 {
+		slog.Warn("未设置auth.token_secret，使用随机密钥签名会话令牌，重启后需重新登录")
+		tokenSecret = session.RandomKey()
+	}
 
-	// 健康检查路由
-	router.GET("/api/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"message": "服务运行正常",
-		})
+	mail := mailer.New(config.SMTP)
+	if _, ok := mail.(mailer.Log); ok {
+		slog.Warn("未设置smtp.host和smtp.outbox_dir，重置密码和验证邮件只记录到日志")
+	}
+
+	// 创建Gin路由并注册接口，实时结果与接口使用同一个仓储计算汇总
+	store := repository.NewGormStore(db.DB)
+	hub := realtime.NewHub(service.NewResultsService(store).Summary)
+	router := server.New(server.Options{
+		DB:            db,
+		Store:         store,
+		Hub:           hub,
+		Health:        checker,
+		Logger:        logger,
+		LogSampler:    logging.NewSampler(config.Log.Sample),
+		Metrics:       appMetrics,
+		MetricsPath:   config.Metrics.Path,
+		MetricsAccess: metricsAccess,
+		TokenSecret:   tokenSecret,
+		TokenTTL:      config.Auth.TokenTTL.Duration,
+		Lockout: service.LockoutPolicy{
+			Threshold:   config.Auth.Lockout.Threshold,
+			Cooldown:    config.Auth.Lockout.Cooldown.Duration,
+			MaxCooldown: config.Auth.Lockout.MaxCooldown.Duration,
+		},
+		AuthLimit:      authLimit,
+		Passwords:      password.NewHasher(config.Auth.Password),
+		PasswordPolicy: password.NewPolicy(config.Auth.Password),
+		Mailer:         mail,
+		ResetTTL:       config.Auth.PasswordReset.TTL.Duration,
+		ResetURL:       config.Auth.PasswordReset.URL,
+
+		VerificationTTL:    config.Auth.EmailVerification.TTL.Duration,
+		VerificationResend: config.Auth.EmailVerification.ResendInterval.Duration,
+		VerificationURL:    config.Auth.EmailVerification.URL,
+		MFA: service.MFAPolicy{
+			ChallengeTTL: config.Auth.MFA.ChallengeTTL.Duration,
+			MaxFailures:  config.Auth.MFA.MaxFailures,
+			RequireAdmin: config.Auth.MFA.RequireAdmin,
+		},
+		MFAIssuer: config.Auth.MFA.Issuer,
+
+		OIDC:                 sso,
+		OIDCName:             config.Auth.OIDC.Name,
+		OIDCGroupsClaim:      config.Auth.OIDC.GroupsClaim,
+		OIDCRoleMapping:      config.Auth.OIDC.RoleMapping,
+		OIDCLoginTTL:         config.Auth.OIDC.LoginTTL.Duration,
+		DisablePasswordLogin: !config.Auth.PasswordLogin,
+
+		AllowPrivateWebhookTargets: config.Webhook.AllowPrivateTargets,
+		Middleware: []gin.HandlerFunc{
+			CORSMiddleware(config.CORS.AllowedOrigins),
+		},
 	})
+	// 只信任来自反向代理的X-Forwarded-For，否则客户端可以伪造IP绕过限流
+	if err := router.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		logging.Fatal("受信任的代理配置无效", "error", err)
+	}
 
-	// 用户相关路由
-	router.POST("/api/user/register", userHandler.Register)
-	router.POST("/api/user/login", userHandler.Login)
-	router.POST("/api/user/reset-password", userHandler.ResetPassword)
-
-	// 问卷相关路由
-	router.POST("/api/questionnaire/create", questionnaireHandler.CreateQuestionnaire)
-	router.GET("/api/questionnaire/list", questionnaireHandler.GetQuestionnaires)
-	router.GET("/api/questionnaire/detail", questionnaireHandler.GetQuestionnaireDetail)
-	router.POST("/api/questionnaire/submit", questionnaireHandler.SubmitQuestionnaire)
-	router.PUT("/api/questionnaire/update", questionnaireHandler.UpdateQuestionnaire)
-	router.PUT("/api/questionnaire/update-status", questionnaireHandler.UpdateQuestionnaireStatus)
-	router.DELETE("/api/questionnaire/delete", questionnaireHandler.DeleteQuestionnaire)
-	router.GET("/api/questionnaire/results", questionnaireHandler.GetQuestionnaireResults)
-	router.GET("/api/questionnaire/check-submission", questionnaireHandler.CheckSubmission)
-	router.GET("/api/questionnaire/stats", questionnaireHandler.GetSystemStats)
-
-	// 管理员路由组 - 使用管理员权限中间件
-	adminGroup := router.Group("/api/admin")
-	adminGroup.Use(middleware.AdminAuthMiddleware(db))
-	{
-		// 用户管理
-		adminGroup.GET("/users", adminHandler.GetAllUsers)
-		adminGroup.GET("/user/detail", adminHandler.GetUserDetail)
-		adminGroup.PUT("/user/update", adminHandler.UpdateUser)
-		adminGroup.DELETE("/user/delete", adminHandler.DeleteUser)
-
-		// 问卷管理
-		adminGroup.GET("/questionnaires", adminHandler.GetAllQuestionnaires)
-		adminGroup.GET("/questionnaire/submissions", adminHandler.GetQuestionnaireSubmissions)
-
-		// 系统统计
-		adminGroup.GET("/statistics", adminHandler.GetSystemStatistics)
-	}
-
-	// 启动服务器
-	serverAddr := ":" + config.Server.Port
-	log.Printf("Gin服务器启动在 %s", serverAddr)
-	log.Printf("按Ctrl+C停止服务器")
-
-	// 使用标准的方式监听本地端口，避免Windows防火墙问题
-	router.Run(serverAddr)
-	// 明确指定监听所有网络接口，确保Windows防火墙可以正确识别
-	//router.Run("0.0.0.0" + serverAddr) // 明确监听所有IP地址
+	// 启动服务器，关闭时先结束实时结果订阅，否则SSE长连接会一直占用到关闭超时
+	srv := newHTTPServer(config.Server, router)
+	srv.RegisterOnShutdown(hub.Close)
+	slog.Info("服务器启动", "addr", srv.Addr)
+
+	if err := serve(config.Server, srv, checker, bg, db); err != nil {
+		logging.Fatal("服务器异常退出", "error", err)
+	}
+
+	// 导出尚未发送的span
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("导出剩余的追踪数据失败", "error", err)
+	}
+	slog.Info("服务器已关闭")
 }
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// 登录失败的原因（login_failures_total的reason标签）
+const (
+	ReasonInvalidCredentials = "invalid_credentials"
+	ReasonAccountLocked      = "account_locked"
+	ReasonInvalidMFACode     = "invalid_mfa_code"
+	ReasonSSOFailed          = "sso_failed"
+)
+
+// Metrics 应用指标：HTTP请求、数据库连接池和业务事件
+// 方法在Metrics为nil时不做任何事，业务层不需要判断是否启用了指标
+type Metrics struct {
+	Registry *Registry
+
+	requests      *Counter
+	duration      *Histogram
+	submissions   *Counter
+	published     *Counter
+	loginFailures *Counter
+}
+
+// New 创建注册了全部应用指标的Metrics
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry: r,
+		requests: r.NewCounter("http_requests_total",
+			"HTTP请求数", "method", "route", "status"),
+		duration: r.NewHistogram("http_request_duration_seconds",
+			"HTTP请求耗时（秒）", DefaultBuckets, "method", "route", "status"),
+		submissions: r.NewCounter("submissions_created_total",
+			"创建的答卷数"),
+		published: r.NewCounter("questionnaires_published_total",
+			"发布问卷的次数（创建时直接发布或由未发布改为发布）"),
+		loginFailures: r.NewCounter("login_failures_total",
+			"登录失败次数", "reason"),
+	}
+}
+
+// ObserveRequest 记录一次HTTP请求，route为路由模板，未匹配任何路由时应传入固定值避免标签过多
+func (m *Metrics) ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.requests.Inc(method, route, code)
+	m.duration.Observe(elapsed.Seconds(), method, route, code)
+}
+
+// SubmissionCreated 记录一份新答卷
+func (m *Metrics) SubmissionCreated() {
+	if m == nil {
+		return
+	}
+	m.submissions.Inc()
+}
+
+// QuestionnairePublished 记录一次问卷发布
+func (m *Metrics) QuestionnairePublished() {
+	if m == nil {
+		return
+	}
+	m.published.Inc()
+}
+
+// LoginFailed 记录一次登录失败
+func (m *Metrics) LoginFailed(reason string) {
+	if m == nil {
+		return
+	}
+	m.loginFailures.Inc(reason)
+}
+
+// DBStats 注册数据库连接池指标，抓取时读取sql.DB.Stats()
+func (m *Metrics) DBStats(db *sql.DB) {
+	stats := []struct {
+		name, help string
+		counter    bool
+		value      func(s sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "最大打开连接数", false, func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "当前打开的连接数", false, func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "正在使用的连接数", false, func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "空闲连接数", false, func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"db_wait_count_total", "等待空闲连接的次数", true, func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "等待空闲连接的总时间（秒）", true, func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "因超过最大空闲连接数关闭的连接数", true, func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_idle_time_closed_total", "因超过最大空闲时间关闭的连接数", true, func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_max_lifetime_closed_total", "因超过最大存活时间关闭的连接数", true, func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, st := range stats {
+		fn := func(context.Context) (float64, error) { return st.value(db.Stats()), nil }
+		if st.counter {
+			m.Registry.CounterFunc(st.name, st.help, fn)
+		} else {
+			m.Registry.GaugeFunc(st.name, st.help, fn)
+		}
+	}
+}
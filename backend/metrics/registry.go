@@ -0,0 +1,343 @@
+// Package metrics 以Prometheus文本格式输出监控指标
+//
+// 只实现本项目用到的计数器、直方图和抓取时计算的仪表盘，不依赖Prometheus客户端库。
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType Prometheus文本格式（0.0.4）
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets 请求耗时直方图的默认分桶（秒）
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// sample 一条时间序列的当前值
+type sample struct {
+	suffix string   // 如直方图的 _bucket、_sum、_count
+	labels []string // 标签名和值交替排列
+	value  float64
+}
+
+// family 一组同名指标
+type family struct {
+	help    string
+	typ     string // counter、gauge、histogram
+	collect func(ctx context.Context) ([]sample, error)
+}
+
+// Registry 指标注册表，可在多个goroutine中并发使用
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]family
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register 注册指标，同名的指标会被替换
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families[name] = f
+}
+
+// NewCounter 注册计数器，labels为标签名
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{labels: labels, series: make(map[string]*counterSeries)}
+	r.register(name, family{help: help, typ: "counter", collect: c.collect})
+	return c
+}
+
+// NewHistogram 注册直方图，buckets为升序的分桶上限
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(name, family{help: help, typ: "histogram", collect: h.collect})
+	return h
+}
+
+// GaugeFunc 注册抓取时计算的仪表盘，如连接数、队列长度
+func (r *Registry) GaugeFunc(name, help string, fn func(ctx context.Context) (float64, error)) {
+	r.register(name, family{help: help, typ: "gauge", collect: valueFunc(fn)})
+}
+
+// CounterFunc 注册抓取时读取的计数器，fn返回的值必须单调递增
+func (r *Registry) CounterFunc(name, help string, fn func(ctx context.Context) (float64, error)) {
+	r.register(name, family{help: help, typ: "counter", collect: valueFunc(fn)})
+}
+
+func valueFunc(fn func(ctx context.Context) (float64, error)) func(ctx context.Context) ([]sample, error) {
+	return func(ctx context.Context) ([]sample, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []sample{{value: v}}, nil
+	}
+}
+
+// Names 按名称排序的指标
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write 按名称顺序以文本格式输出全部指标
+// 某项指标计算失败（如数据库不可用）时跳过该指标并记录日志，不影响其余指标
+func (r *Registry) Write(ctx context.Context, w io.Writer) error {
+	r.mu.RLock()
+	families := make(map[string]family, len(r.families))
+	for name, f := range r.families {
+		families[name] = f
+	}
+	r.mu.RUnlock()
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		samples, err := f.collect(ctx)
+		if err != nil {
+			slog.Warn("采集指标失败", "metric", name, "error", err)
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.typ)
+		for _, s := range samples {
+			bw.WriteString(name + s.suffix)
+			writeLabels(bw, s.labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 输出全部指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := r.Write(req.Context(), w); err != nil {
+		slog.Warn("输出指标失败", "error", err)
+	}
+}
+
+func writeLabels(w *bufio.Writer, labels []string) {
+	if len(labels) == 0 {
+		return
+	}
+	w.WriteByte('{')
+	for i := 0; i < len(labels); i += 2 {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(labels[i])
+		w.WriteString(`="`)
+		w.WriteString(labelEscaper.Replace(labels[i+1]))
+		w.WriteByte('"')
+	}
+	w.WriteByte('}')
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// seriesKey 标签值组合的键
+func seriesKey(labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: 需要%d个标签值（%s），实际为%d个", len(labels), strings.Join(labels, ","), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// pairs 将标签名和值交替排列
+func pairs(labels, values []string) []string {
+	result := make([]string, 0, 2*len(labels))
+	for i, name := range labels {
+		result = append(result, name, values[i])
+	}
+	return result
+}
+
+// sortedKeys 按键排序，保证输出顺序稳定
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	labels []string
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// Inc 计数加1，labelValues与注册时的标签名一一对应
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 计数增加v（v不能为负数）
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: 计数器不能减少")
+	}
+	key := seriesKey(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// Value 当前计数
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := seriesKey(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) collect(context.Context) ([]sample, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	samples := make([]sample, 0, len(c.series))
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		samples = append(samples, sample{labels: pairs(c.labels, s.values), value: s.value})
+	}
+	return samples, nil
+}
+
+// Histogram 按分桶统计观测值的分布
+type Histogram struct {
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // 每个分桶（不累计）的观测次数
+	sum    float64
+	count  uint64
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := seriesKey(h.labels, labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count 观测次数
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := seriesKey(h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) collect(context.Context) ([]sample, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var samples []sample
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		labels := pairs(h.labels, s.values)
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			samples = append(samples, sample{
+				suffix: "_bucket",
+				labels: append(append([]string(nil), labels...), "le", formatValue(upper)),
+				value:  float64(cumulative),
+			})
+		}
+		samples = append(samples,
+			sample{suffix: "_bucket", labels: append(append([]string(nil), labels...), "le", "+Inf"), value: float64(s.count)},
+			sample{suffix: "_sum", labels: labels, value: s.sum},
+			sample{suffix: "_count", labels: labels, value: float64(s.count)},
+		)
+	}
+	return samples, nil
+}
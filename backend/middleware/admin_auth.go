@@ -1,90 +1,137 @@
 package middleware
 
 import (
-	"log"
-	"questionnaire-system/backend/database"
-	"questionnaire-system/backend/models"
+	"errors"
+	"net/http"
+	"strconv"
 	"strings"
 
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/service"
+
 	"github.com/gin-gonic/gin"
 )
 
-// AdminAuthMiddleware 管理员权限验证中间件
-func AdminAuthMiddleware(db *database.Database) gin.HandlerFunc {
+// AuthMiddleware 登录验证中间件，用于用户管理自己账户的接口（如两步验证）
+func AuthMiddleware(users *service.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Println("执行管理员权限验证中间件")
-
-		// 获取Authorization头
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			log.Println("缺少Authorization头")
-			c.JSON(401, gin.H{
-				"success": false,
-				"message": "未授权访问",
-			})
-			c.Abort()
+		user := authenticate(c, users, "用户验证失败")
+		if user == nil {
 			return
 		}
+		setUser(c, user)
+		c.Next()
+	}
+}
 
-		// 解析token
-		// 格式: Bearer token_username_timestamp
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			log.Println("无效的Authorization头格式")
-			c.JSON(401, gin.H{
-				"success": false,
-				"message": "无效的授权格式",
-			})
-			c.Abort()
+// StreamAuthMiddleware 实时结果接口的登录验证：浏览器的EventSource不能设置Authorization头，
+// 查询参数带有token时校验实时结果令牌（只能用于签发时的问卷，见service.UserService.IssueStreamToken），否则与AuthMiddleware相同
+// 问卷ID取自路径参数id，旧接口取查询参数id
+func StreamAuthMiddleware(users *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			AuthMiddleware(users)(c)
 			return
 		}
 
-		token := parts[1]
-		// 从token中提取用户名 (格式: token_username_timestamp)
-		tokenParts := strings.Split(token, "_")
-		if len(tokenParts) < 2 {
-			log.Println("无效的token格式")
-			c.JSON(401, gin.H{
-				"success": false,
-				"message": "无效的令牌",
-			})
-			c.Abort()
+		idStr, ok := c.Params.Get("id")
+		if !ok {
+			idStr = c.Query("id")
+		}
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		var user *models.User
+		if err != nil {
+			err = service.ErrInvalidToken
+		} else {
+			user, err = users.AuthenticateStream(c.Request.Context(), token, uint(id))
+		}
+		if user = checkAuthentication(c, user, err, "实时结果令牌验证失败"); user == nil {
 			return
 		}
+		setUser(c, user)
+		c.Next()
+	}
+}
 
-		username := tokenParts[1]
-		log.Printf("从token中提取的用户名: %s", username)
-
-		// 查询用户
-		var user models.User
-		result := db.Where("username = ?", username).First(&user)
-		if result.Error != nil {
-			log.Printf("用户不存在: %s", username)
-			c.JSON(401, gin.H{
-				"success": false,
-				"message": "无效的用户",
-			})
-			c.Abort()
+// AdminAuthMiddleware 管理员权限验证中间件
+// 策略要求管理员启用两步验证（users.MFA.RequireAdmin）时，未启用的管理员返回403 MFA_REQUIRED
+func AdminAuthMiddleware(users *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := authenticate(c, users, "管理员验证失败")
+		if user == nil {
 			return
 		}
-
 		// 验证管理员权限
 		if !user.IsAdmin {
-			log.Printf("用户不是管理员: %s", username)
-			c.JSON(403, gin.H{
-				"success": false,
-				"message": "需要管理员权限",
-			})
+			Logger(c).Warn("管理员验证失败: 用户不是管理员", "username", user.Username)
+			c.Error(apierror.Forbidden("auth.admin"))
+			c.Abort()
+			return
+		}
+		if users.MFA.RequireAdmin && user.TOTPEnabledAt == nil {
+			Logger(c).Warn("管理员验证失败: 未启用两步验证", "username", user.Username)
+			c.Error(apierror.New(http.StatusForbidden, apierror.CodeMFARequired, "auth.mfa_required"))
 			c.Abort()
 			return
 		}
 
-		// 将用户信息存储到上下文中
-		c.Set("user_id", user.ID)
-		c.Set("username", user.Username)
-		c.Set("is_admin", user.IsAdmin)
-
-		log.Printf("管理员验证通过: %s", username)
+		setUser(c, user)
+		Logger(c).Debug("管理员验证通过", "user_id", user.ID, "username", user.Username)
 		c.Next()
 	}
 }
+
+// authenticate 校验Authorization头中的会话令牌，失败时记录错误、中止请求并返回nil
+func authenticate(c *gin.Context, users *service.UserService, failure string) *models.User {
+	// 获取Authorization头
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.Error(apierror.Unauthorized("auth.unauthorized"))
+		c.Abort()
+		return nil
+	}
+
+	// 格式: Bearer <会话令牌>
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.Error(apierror.Unauthorized("auth.scheme"))
+		c.Abort()
+		return nil
+	}
+
+	// 校验签名和有效期；用户重置密码或启用两步验证之前签发的令牌已失效
+	user, err := users.Authenticate(c.Request.Context(), parts[1])
+	return checkAuthentication(c, user, err, failure)
+}
+
+// checkAuthentication 将令牌校验的错误转换为401响应，失败时记录错误、中止请求并返回nil
+func checkAuthentication(c *gin.Context, user *models.User, err error, failure string) *models.User {
+	switch {
+	case errors.Is(err, service.ErrInvalidToken):
+		Logger(c).Warn(failure + ": 无效的令牌")
+		c.Error(apierror.Unauthorized("auth.token"))
+	case errors.Is(err, service.ErrSessionExpired):
+		c.Error(apierror.Unauthorized("auth.expired"))
+	case errors.Is(err, service.ErrUserNotFound):
+		Logger(c).Warn(failure + ": 用户不存在")
+		c.Error(apierror.Unauthorized("auth.user"))
+	case errors.Is(err, service.ErrSessionRevoked):
+		Logger(c).Warn(failure + ": 会话已失效")
+		c.Error(apierror.Unauthorized("auth.revoked"))
+	case err != nil:
+		c.Error(apierror.Internal("auth.unauthorized", err))
+	default:
+		return user
+	}
+	c.Abort()
+	return nil
+}
+
+// setUser 将用户信息存储到上下文中
+func setUser(c *gin.Context, user *models.User) {
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("is_admin", user.IsAdmin)
+}
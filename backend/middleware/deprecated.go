@@ -0,0 +1,13 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// Deprecated 标记已废弃的旧接口：响应头Deprecation为true，Link指向替代的接口
+func Deprecated(successor string) gin.HandlerFunc {
+	link := "<" + successor + `>; rel="successor-version"`
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", link)
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorHandler 统一的错误响应中间件
+// 处理器通过c.Error记录*apierror.Error后返回，由此中间件按Accept-Language生成错误响应；
+// 其他类型的错误和panic按服务器内部错误处理，原因只写入日志
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				Logger(c).Error("处理请求时发生panic", "panic", r, "stack", string(debug.Stack()))
+				c.Abort()
+				if !c.Writer.Written() {
+					writeError(c, apierror.Internal("internal", nil))
+				}
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		var apiErr *apierror.Error
+		if !errors.As(err, &apiErr) {
+			apiErr = apierror.Internal("internal", err)
+		}
+		if apiErr.Status >= http.StatusInternalServerError {
+			Logger(c).Error("请求失败", "code", apiErr.Code, "error", apiErr)
+			span := trace.SpanFromContext(c.Request.Context())
+			span.RecordError(apiErr)
+			span.SetStatus(codes.Error, apiErr.Error())
+		}
+		writeError(c, apiErr)
+	}
+}
+
+// NotFound 未注册路由的错误响应
+func NotFound(c *gin.Context) {
+	c.Error(apierror.New(http.StatusNotFound, apierror.CodeNotFound, "route.not_found"))
+}
+
+func writeError(c *gin.Context, err *apierror.Error) {
+	envelope := err.Envelope(Locale(c), GetRequestID(c))
+	envelope.Error.TraceID, _ = tracing.IDs(c.Request.Context())
+	c.JSON(err.Status, envelope)
+}
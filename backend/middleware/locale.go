@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"questionnaire-system/backend/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+const localeKey = "locale"
+
+// Localize 根据Accept-Language选择提示信息的语言，保存到上下文并写入Content-Language响应头
+func Localize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(localeKey, locale)
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
+
+// Locale 当前请求的提示信息语言，未经过Localize中间件时按请求头协商
+func Locale(c *gin.Context) string {
+	if locale := c.GetString(localeKey); locale != "" {
+		return locale
+	}
+	return i18n.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// T 按当前请求的语言返回提示信息
+func T(c *gin.Context, key string, args ...interface{}) string {
+	return i18n.T(Locale(c), key, args...)
+}
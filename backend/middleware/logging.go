@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const loggerKey = "logger"
+
+// RequestLogger 为每个请求创建带request_id（有追踪上下文时还有trace_id、span_id）的日志记录器（处理器通过Logger获取，
+// 业务层通过logging.FromContext获取），请求结束后输出一条访问日志
+// 成功的请求按sampler采样（nil表示全部记录），4xx按warn、5xx按error级别记录且不采样
+func RequestLogger(base *slog.Logger, sampler *logging.Sampler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := GetRequestID(c)
+		logger := base.With("request_id", requestID)
+		if traceID, spanID := tracing.IDs(c.Request.Context()); traceID != "" {
+			logger = logger.With("trace_id", traceID, "span_id", spanID)
+		}
+		c.Set(loggerKey, logger)
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		route := c.FullPath()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		default:
+			if !sampler.Keep(route, requestID) {
+				return
+			}
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if query := c.Request.URL.RawQuery; query != "" {
+			attrs = append(attrs, slog.String("query", logging.RedactQuery(query)))
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "请求完成", attrs...)
+	}
+}
+
+// Logger 返回当前请求的日志记录器，未经过RequestLogger中间件时返回slog.Default()
+func Logger(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Get(loggerKey); ok {
+		return logger.(*slog.Logger)
+	}
+	return slog.Default()
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute 未匹配任何路由的请求使用的route标签，避免任意路径产生大量时间序列
+const unmatchedRoute = "unmatched"
+
+// RequestMetrics 按方法、路由模板和状态码记录请求数和耗时
+func RequestMetrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsAccess 限制/metrics的访问
+// token不为空时要求 Authorization: Bearer <token>；networks不为空时只允许来自这些网段（CIDR或IP）的连接，
+// 按TCP连接的对端地址判断，不信任X-Forwarded-For
+func MetricsAccess(token string, networks []string) (gin.HandlerFunc, error) {
+	allowed, err := ParseNetworks(networks)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		if len(allowed) > 0 {
+			ip := net.ParseIP(c.RemoteIP())
+			if ip == nil || !containsIP(allowed, ip) {
+				Logger(c).Warn("拒绝访问监控指标", "remote_ip", c.RemoteIP())
+				c.Error(apierror.Forbidden("metrics.forbidden"))
+				c.Abort()
+				return
+			}
+		}
+		if token != "" {
+			provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				c.Error(apierror.Unauthorized("auth.unauthorized"))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}, nil
+}
+
+// ParseNetworks 解析CIDR或IP列表，单个IP视为只包含该地址的网段
+func ParseNetworks(networks []string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, n := range networks {
+		n = strings.TrimSpace(n)
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的IP或网段 %q", n)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, fmt.Errorf("无效的IP或网段 %q", n)
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"questionnaire-system/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 为每个请求创建服务端span，请求头中带有traceparent时作为其子span
+// span保存在c.Request的ctx中，处理器和业务层传递该ctx即可让数据库查询挂在请求的span下
+// provider为nil时使用全局的TracerProvider
+func Tracing(provider trace.TracerProvider) gin.HandlerFunc {
+	tracer := tracing.Tracer(provider)
+
+	return func(c *gin.Context) {
+		ctx := tracing.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("user_agent.original", c.Request.UserAgent()),
+				attribute.String("request.id", GetRequestID(c)),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if userID, ok := c.Get("user_id"); ok {
+			if id, ok := userID.(uint); ok {
+				span.SetAttributes(attribute.Int64("user.id", int64(id)))
+			}
+		}
+		// 只有服务端错误标记为失败，4xx是客户端的问题
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
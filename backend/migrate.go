@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"questionnaire-system/backend/config"
+	"questionnaire-system/backend/database"
+	"questionnaire-system/backend/database/migrations"
+)
+
+const migrateUsage = `用法: migrate <命令>
+
+命令:
+  up            执行所有未执行的迁移
+  down [n]      回滚最近的n个迁移（默认1个）
+  status        显示每个迁移的执行状态
+  to <版本>     迁移到指定版本（可向上或向下，0表示回滚全部）`
+
+// runMigrate 执行 migrate 子命令
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+
+	migrator := migrations.New(db.DB)
+	migrator.Logf = func(format string, args ...interface{}) {
+		fmt.Printf(format+"\n", args...)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("无效的回滚数量: %s", args[1])
+			}
+		}
+		if err := migrator.Down(steps); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("缺少目标版本\n%s", migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的版本号: %s", args[1])
+		}
+		if err := migrator.To(uint(version)); err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "版本\t名称\t状态\t执行时间")
+		for _, s := range statuses {
+			state, appliedAt := "未执行", ""
+			if s.Applied {
+				state, appliedAt = "已执行", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		w.Flush()
+	default:
+		return fmt.Errorf("未知的迁移命令: %s\n%s", args[0], migrateUsage)
+	}
+
+	current, err := migrator.Current()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("当前数据库版本: %d，程序最新版本: %d\n", current, migrator.Latest())
+	return nil
+}
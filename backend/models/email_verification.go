@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// EmailVerificationToken 邮箱验证令牌
+// 只保存令牌的SHA-256哈希；Email为发送令牌时的邮箱，用户之后修改了邮箱时令牌失效
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Email     string     `json:"email" gorm:"size:100;not null"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
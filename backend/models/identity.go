@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Identity 用户在外部身份提供方（OpenID Connect）的账号，按发行方和subject唯一
+// 单点登录时按subject查找用户，第一次登录时创建用户或关联到邮箱相同的已验证用户
+type Identity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Issuer      string     `json:"issuer" gorm:"size:255;not null;uniqueIndex:idx_identities_subject"`
+	Subject     string     `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_identities_subject"`
+	Email       string     `json:"email" gorm:"size:100"` // 最近一次登录时身份提供方返回的邮箱
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// SSOLogin 已发起、等待身份提供方回调的单点登录
+// state只保存SHA-256哈希；回调时需要PKCE的code_verifier和nonce，取出后即删除，只能使用一次
+type SSOLogin struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Nonce        string    `json:"-" gorm:"size:64;not null"`
+	CodeVerifier string    `json:"-" gorm:"size:128;not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证的恢复码，手机丢失时代替验证码使用，每个只能使用一次
+// 只保存恢复码的SHA-256哈希，原文在生成时展示给用户一次
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null;index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// MFAChallenge 密码校验通过、等待输入验证码的登录
+// 令牌在登录响应中返回给客户端，只保存其SHA-256哈希；输错验证码的次数达到上限或完成登录后失效
+type MFAChallenge struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	Failures  int        `json:"failures" gorm:"not null;default:0"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
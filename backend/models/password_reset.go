@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PasswordResetToken 密码重置令牌
+// 只保存令牌的SHA-256哈希，原文只出现在发给用户的邮件中；使用一次或过期后失效
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
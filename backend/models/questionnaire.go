@@ -1,6 +1,18 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// 问题类型（与前端题型保持一致）
+const (
+	QuestionTypeSingleChoice   = "单选题"
+	QuestionTypeMultipleChoice = "多选题"
+	QuestionTypeText           = "填空题"
+	QuestionTypeRating         = "评分题"
+)
 
 // Questionnaire 问卷模型
 type Questionnaire struct {
@@ -11,14 +23,18 @@ type Questionnaire struct {
 	StartTime   time.Time `json:"start_time"`
 	EndTime     time.Time `json:"end_time"`
 	IsPublished bool      `json:"is_published" gorm:"default:false"`
-	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// DefaultLocale 编写问卷使用的语言，标题、说明和选项的原文为该语言；翻译见QuestionnaireTranslation
+	DefaultLocale string `json:"default_locale" gorm:"size:20;not null;default:zh-CN"`
+	// RequireVerifiedEmail 只有已验证邮箱的用户可以填写
+	RequireVerifiedEmail bool      `json:"require_verified_email" gorm:"not null;default:false"`
+	CreatedAt            time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt            time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 // Question 问题模型
 type Question struct {
 	ID              uint      `json:"id" gorm:"primaryKey"`
-	QuestionnaireID uint      `json:"questionnaire_id" gorm:"not null"`
+	QuestionnaireID uint      `json:"questionnaire_id" gorm:"not null;index"`
 	Title           string    `json:"title" gorm:"size:255;not null"`
 	Type            string    `json:"type" gorm:"size:50;not null"` // 单选、多选、填空、评分等
 	Required        bool      `json:"required" gorm:"default:false"`
@@ -28,32 +44,86 @@ type Question struct {
 	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+// OptionList 解析问题选项
+// 选项可能是JSON数组，也可能是逗号分隔的字符串（与前端解析逻辑一致）
+func (q Question) OptionList() []string {
+	if strings.TrimSpace(q.Options) == "" {
+		return nil
+	}
+
+	var options []string
+	if err := json.Unmarshal([]byte(q.Options), &options); err == nil {
+		return options
+	}
+
+	for _, opt := range strings.Split(q.Options, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// IsChoice 是否为选择题（单选或多选）
+func (q Question) IsChoice() bool {
+	return q.Type == QuestionTypeSingleChoice || q.Type == QuestionTypeMultipleChoice
+}
+
 // Answer 答案模型
 type Answer struct {
 	ID         uint      `json:"id" gorm:"primaryKey"`
-	QuestionID uint      `json:"question_id" gorm:"not null"`
+	QuestionID uint      `json:"question_id" gorm:"not null;index"`
 	UserID     uint      `json:"user_id" gorm:"not null"`
 	Content    string    `json:"content" gorm:"type:text"` // 答案内容
 	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
+// AnswerValues 解析答案内容
+// 多选题答案以JSON数组保存，其余题型为单个字符串
+func AnswerValues(content string) []string {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(content, "[") {
+		var values []string
+		if err := json.Unmarshal([]byte(content), &values); err == nil {
+			return values
+		}
+	}
+	return []string{content}
+}
+
 // Submission 提交记录
 type Submission struct {
 	ID              uint      `json:"id" gorm:"primaryKey"`
-	QuestionnaireID uint      `json:"questionnaire_id" gorm:"not null"`
-	UserID          uint      `json:"user_id" gorm:"not null"`
+	QuestionnaireID uint      `json:"questionnaire_id" gorm:"not null;index"`
+	UserID          uint      `json:"user_id" gorm:"not null;index"`
 	SubmittedAt     time.Time `json:"submitted_at" gorm:"autoCreateTime"`
 	IPAddress       string    `json:"ip_address" gorm:"size:50"`
+	Locale          string    `json:"locale" gorm:"size:20"` // 填写时使用的问卷语言
 }
 
 // User 用户模型
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"size:50;not null;uniqueIndex"`
-	Password  string    `json:"password,omitempty" gorm:"size:255;not null"` // 在API响应中省略密码
-	Email     string    `json:"email" gorm:"size:100;uniqueIndex"`
-	Phone     string    `json:"phone" gorm:"size:20"`
-	IsAdmin   bool      `json:"is_admin" gorm:"default:false"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Username     string     `json:"username" gorm:"size:50;not null;uniqueIndex"`
+	Password     string     `json:"password,omitempty" gorm:"size:255;not null"` // 在API响应中省略密码
+	Email        string     `json:"email" gorm:"size:100;uniqueIndex"`
+	Phone        string     `json:"phone" gorm:"size:20"`
+	IsAdmin      bool       `json:"is_admin" gorm:"default:false"`
+	FailedLogins int        `json:"failed_logins" gorm:"not null;default:0"` // 连续登录失败次数，登录成功或管理员解锁时清零
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`                  // 在此之前不允许登录
+	// SessionsRevokedAt 在此之前签发的会话令牌失效（重置密码时设置）
+	SessionsRevokedAt *time.Time `json:"-"`
+	// EmailVerifiedAt 验证邮箱的时间，为nil表示尚未验证；修改邮箱后需要重新验证
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TOTPSecret 两步验证的密钥（Base32），TOTPEnabledAt为nil时表示正在绑定、尚未启用
+	TOTPSecret    string     `json:"-" gorm:"size:64"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at,omitempty"`
+	// TOTPLastCounter 最近一次使用的验证码的时间步序号，同一验证码不能使用两次
+	TOTPLastCounter int64     `json:"-" gorm:"not null;default:0"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
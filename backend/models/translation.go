@@ -0,0 +1,67 @@
+package models
+
+import "encoding/json"
+
+// QuestionnaireTranslation 问卷标题和说明的翻译，每种语言一条
+type QuestionnaireTranslation struct {
+	ID              uint   `json:"-" gorm:"primaryKey"`
+	QuestionnaireID uint   `json:"-" gorm:"not null;uniqueIndex:idx_questionnaire_translations_locale"`
+	Locale          string `json:"locale" gorm:"size:20;not null;uniqueIndex:idx_questionnaire_translations_locale"`
+	Title           string `json:"title" gorm:"size:255"`
+	Description     string `json:"description" gorm:"type:text"`
+}
+
+// QuestionTranslation 问题标题和选项的翻译，每种语言一条
+// 选项与原问题的选项按位置一一对应，位置（从1开始）即选项ID，统计时不同语言的答案按选项ID合并
+type QuestionTranslation struct {
+	ID              uint   `json:"-" gorm:"primaryKey"`
+	QuestionnaireID uint   `json:"-" gorm:"not null;index"`
+	QuestionID      uint   `json:"-" gorm:"not null;uniqueIndex:idx_question_translations_locale"`
+	Locale          string `json:"locale" gorm:"size:20;not null;uniqueIndex:idx_question_translations_locale"`
+	Title           string `json:"title" gorm:"size:255"`
+	Options         string `json:"options" gorm:"type:text"` // JSON数组
+}
+
+// OptionList 解析翻译后的选项
+func (t QuestionTranslation) OptionList() []string {
+	var options []string
+	if err := json.Unmarshal([]byte(t.Options), &options); err != nil {
+		return nil
+	}
+	return options
+}
+
+// OptionAliases 选择题各语言的选项到原文选项的对应关系（按问题ID），
+// 用于将不同语言的答案换算为原文选项
+func OptionAliases(questions []Question, translations []QuestionTranslation) map[uint]map[string]string {
+	declared := make(map[uint][]string)
+	for _, q := range questions {
+		if q.IsChoice() {
+			declared[q.ID] = q.OptionList()
+		}
+	}
+
+	aliases := make(map[uint]map[string]string)
+	for _, t := range translations {
+		options, ok := declared[t.QuestionID]
+		if !ok {
+			continue
+		}
+		for i, label := range t.OptionList() {
+			if i >= len(options) || label == options[i] {
+				continue
+			}
+			if aliases[t.QuestionID] == nil {
+				aliases[t.QuestionID] = make(map[string]string)
+			}
+			aliases[t.QuestionID][label] = options[i]
+		}
+	}
+	// 原文选项优先，避免某种语言的译文恰好与另一个原文选项相同
+	for id, options := range declared {
+		for _, label := range options {
+			delete(aliases[id], label)
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 投递状态
+const (
+	WebhookStatusPending    = "pending"
+	WebhookStatusProcessing = "processing"
+	WebhookStatusDelivered  = "delivered"
+	WebhookStatusFailed     = "failed"
+)
+
+// WebhookSubscription Webhook订阅
+// QuestionnaireID为空表示全局订阅（仅管理员可创建）
+type WebhookSubscription struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	QuestionnaireID *uint     `json:"questionnaire_id" gorm:"index"`
+	TargetURL       string    `json:"target_url" gorm:"size:500;not null"`
+	Secret          string    `json:"-" gorm:"size:100;not null"`      // 用于HMAC签名，不在列表中返回
+	Events          string    `json:"events" gorm:"size:255;not null"` // 逗号分隔的事件类型
+	IsActive        bool      `json:"is_active" gorm:"default:true"`
+	CreatedBy       uint      `json:"created_by" gorm:"not null"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// EventList 订阅的事件类型列表
+func (s WebhookSubscription) EventList() []string {
+	var events []string
+	for _, e := range strings.Split(s.Events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Subscribes 是否订阅了指定事件
+func (s WebhookSubscription) Subscribes(event string) bool {
+	for _, e := range s.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookOutbox 待投递的Webhook事件（发件箱）
+// 与业务数据在同一事务中写入，由后台投递器异步发送
+type WebhookOutbox struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"not null;index"`
+	EventType      string     `json:"event_type" gorm:"size:50;not null"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"size:20;not null;index"`
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	LockedUntil    *time.Time `json:"-"`
+	LastError      string     `json:"last_error" gorm:"type:text"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 发件箱表名
+func (WebhookOutbox) TableName() string {
+	return "webhook_outbox"
+}
+
+// WebhookDeliveryAttempt 每次投递尝试的记录
+type WebhookDeliveryAttempt struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	OutboxID     uint      `json:"outbox_id" gorm:"not null;index"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody string    `json:"response_body" gorm:"type:text"`
+	Error        string    `json:"error" gorm:"type:text"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
@@ -0,0 +1,279 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Claims ID令牌中的声明
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	// Raw 全部声明，用于读取组等身份提供方自定义的声明
+	Raw map[string]interface{}
+}
+
+// Strings 字符串或字符串数组类型的声明，如groups；不存在或类型不符时返回nil
+func (c *Claims) Strings(name string) []string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// Verify 校验ID令牌的签名、issuer、audience、有效期和nonce，返回其中的声明
+// 校验失败时返回包装了ErrInvalidIDToken的错误
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, invalid("格式错误")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalid("无法解析头部")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("无法解析签名")
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var raws map[string]interface{}
+	if err := decodeSegment(parts[1], &raws); err != nil {
+		return nil, invalid("无法解析声明")
+	}
+	var claims struct {
+		Issuer            string          `json:"iss"`
+		Subject           string          `json:"sub"`
+		Audience          audience        `json:"aud"`
+		AuthorizedParty   string          `json:"azp"`
+		Expiry            float64         `json:"exp"`
+		IssuedAt          float64         `json:"iat"`
+		Nonce             string          `json:"nonce"`
+		Email             string          `json:"email"`
+		EmailVerified     json.RawMessage `json:"email_verified"`
+		Name              string          `json:"name"`
+		PreferredUsername string          `json:"preferred_username"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalid("无法解析声明")
+	}
+
+	now := p.Now()
+	switch {
+	case claims.Issuer != p.Config.Issuer:
+		return nil, invalid("issuer不一致: " + claims.Issuer)
+	case claims.Subject == "":
+		return nil, invalid("缺少sub")
+	case !claims.Audience.contains(p.Config.ClientID):
+		return nil, invalid("audience不包含客户端ID")
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.Config.ClientID:
+		return nil, invalid("azp与客户端ID不一致")
+	case claims.Expiry == 0 || now.After(time.Unix(int64(claims.Expiry), 0).Add(clockSkew)):
+		return nil, invalid("已过期")
+	case claims.IssuedAt != 0 && time.Unix(int64(claims.IssuedAt), 0).After(now.Add(clockSkew)):
+		return nil, invalid("签发时间晚于当前时间")
+	case claims.Nonce != nonce:
+		return nil, invalid("nonce不一致")
+	}
+
+	return &Claims{
+		Subject: claims.Subject,
+		Email:   claims.Email,
+		// 部分身份提供方以字符串"true"返回
+		EmailVerified:     string(claims.EmailVerified) == "true" || string(claims.EmailVerified) == `"true"`,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+		Raw:               raws,
+	}, nil
+}
+
+// key 按kid查找公钥，找不到时重新获取JWKS（身份提供方可能已轮换密钥）
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	key, ok := lookup(p.keys, kid)
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, md.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: 获取公钥失败: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// 不支持的密钥类型忽略，只在实际用到时报错
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	if key, ok := lookup(keys, kid); ok {
+		return key, nil
+	}
+	return nil, invalid("找不到签名公钥 " + kid)
+}
+
+// lookup 按kid查找公钥；令牌没有kid时只有一个公钥才能确定
+func lookup(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+func verifySignature(alg string, key interface{}, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return invalid("签名无效")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return invalid("签名无效")
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return invalid("签名无效")
+		}
+	default:
+		// 包括none，不接受未签名的令牌
+		return invalid("不支持的签名算法 " + alg)
+	}
+	return nil
+}
+
+// jwk JSON Web Key中RSA和P-256公钥使用的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("无效的RSA指数")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("不支持的曲线 %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("公钥不在曲线上")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型 %s", k.Kty)
+}
+
+// audience aud声明可以是字符串或字符串数组
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidIDToken, reason)
+}
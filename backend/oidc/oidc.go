@@ -0,0 +1,207 @@
+// Package oidc OpenID Connect授权码流程（带PKCE）的客户端
+//
+// 只实现单点登录需要的部分：发现文档、生成授权地址、用授权码换取令牌，以及按JWKS校验ID令牌（RS256、ES256）。
+// 发现文档和公钥在第一次使用时获取并缓存，遇到未知的kid时重新获取公钥以支持密钥轮换。
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config 身份提供方和客户端的配置
+type Config struct {
+	Issuer       string   // 身份提供方的issuer，发现文档位于 Issuer + "/.well-known/openid-configuration"
+	ClientID     string   // 客户端ID
+	ClientSecret string   // 公共客户端为空，仅使用PKCE
+	RedirectURL  string   // 回调地址，需在身份提供方登记
+	Scopes       []string // 需包含openid
+}
+
+// Metadata 发现文档中使用的字段
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Tokens 令牌端点返回的令牌
+type Tokens struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Error 身份提供方返回的OAuth错误，如授权码无效时的invalid_grant
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return "oidc: " + e.Code
+	}
+	return "oidc: " + e.Code + ": " + e.Description
+}
+
+// ErrInvalidIDToken ID令牌的格式、签名或声明无效
+var ErrInvalidIDToken = errors.New("oidc: 无效的ID令牌")
+
+// clockSkew 校验exp和iat时允许的时钟偏差
+const clockSkew = time.Minute
+
+// Provider 身份提供方，可以并发使用
+type Provider struct {
+	Config     Config
+	HTTPClient *http.Client
+	Now        func() time.Time
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     map[string]interface{} // kid → *rsa.PublicKey 或 *ecdsa.PublicKey
+}
+
+// New 创建身份提供方，不立即请求发现文档
+func New(cfg Config) *Provider {
+	return &Provider{Config: cfg, HTTPClient: &http.Client{Timeout: 10 * time.Second}, Now: time.Now}
+}
+
+// Metadata 获取并缓存发现文档，文档中的issuer必须与配置一致
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md Metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Config.Issuer, "/")+"/.well-known/openid-configuration", &md); err != nil {
+		return nil, fmt.Errorf("oidc: 获取发现文档失败: %w", err)
+	}
+	if md.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("oidc: 发现文档的issuer %q 与配置的 %q 不一致", md.Issuer, p.Config.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("oidc: 发现文档缺少authorization_endpoint、token_endpoint或jwks_uri")
+	}
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// AuthCodeURL 身份提供方的授权地址，verifier为PKCE的code_verifier（只发送其S256摘要）
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: 无效的authorization_endpoint: %w", err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.Config.ClientID)
+	query.Set("redirect_uri", p.Config.RedirectURL)
+	query.Set("scope", strings.Join(p.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange 使用授权码和PKCE的code_verifier换取令牌，身份提供方拒绝时返回*Error
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Tokens, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.Config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		// client_secret_basic
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: 请求令牌端点失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr Error
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return nil, &oauthErr
+		}
+		return nil, fmt.Errorf("oidc: 令牌端点返回 %s", resp.Status)
+	}
+	var tokens Tokens
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("oidc: 解析令牌响应失败: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc: 令牌响应中没有id_token")
+	}
+	return &tokens, nil
+}
+
+// NewVerifier 生成PKCE的code_verifier（43个字符）
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge code_verifier的S256摘要
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", target, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
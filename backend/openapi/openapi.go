@@ -0,0 +1,314 @@
+// Package openapi 生成和校验OpenAPI 3.0接口文档
+//
+// 只实现本项目用到的部分：文档结构、由Go类型生成的JSON Schema，
+// 以及在测试中用文档校验实际请求和响应的校验器。
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Version 生成的文档使用的OpenAPI版本
+const Version = "3.0.3"
+
+// Document OpenAPI文档
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	types map[reflect.Type]string // 已注册为组件的Go类型
+}
+
+// Info 文档信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server 服务地址
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag 接口分组
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 一个路径下的接口，键为小写的HTTP方法
+type PathItem map[string]*Operation
+
+// Operation 一个接口
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter 路径、查询或请求头参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType 某种内容类型的请求体或响应
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Components 可复用的组件
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 认证方式
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityRequirement 接口要求的认证方式
+type SecurityRequirement map[string][]string
+
+// New 创建空文档
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+		types: make(map[reflect.Type]string),
+	}
+}
+
+// AddOperation 添加接口，path使用{name}形式的路径参数
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// 参数位置
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// PathParam 路径参数
+func PathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: InPath, Description: description, Required: true, Schema: Integer()}
+}
+
+// QueryParam 查询参数
+func QueryParam(name, description string, schema *Schema, required bool) Parameter {
+	return Parameter{Name: name, In: InQuery, Description: description, Required: required, Schema: schema}
+}
+
+// JSONBody 必填的JSON请求体
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// JSON JSON响应
+func JSON(description string, schema *Schema) Response {
+	return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// Binary 文件或流响应
+func Binary(description, contentType string) Response {
+	return Response{Description: description, Content: map[string]MediaType{contentType: {Schema: &Schema{Type: "string", Format: "binary"}}}}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// PathParams 路径模板中的参数名
+func PathParams(path string) []string {
+	var names []string
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// Methods 按字母顺序返回路径下的HTTP方法
+func (p PathItem) Methods() []string {
+	methods := make([]string, 0, len(p))
+	for m := range p {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Validate 检查文档本身：每个接口有唯一的operationId和至少一个响应，
+// 路径参数与路径模板一致，引用的组件都存在
+func (d *Document) Validate() error {
+	if d.OpenAPI == "" || d.Info.Title == "" || d.Info.Version == "" {
+		return fmt.Errorf("缺少openapi版本或文档信息")
+	}
+
+	var errs []string
+	operationIDs := make(map[string]string)
+	for path, item := range d.Paths {
+		if !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Sprintf("%s: 路径必须以/开头", path))
+		}
+		for _, method := range item.Methods() {
+			op := item[method]
+			where := strings.ToUpper(method) + " " + path
+			if op.OperationID == "" {
+				errs = append(errs, where+": 缺少operationId")
+			} else if other, ok := operationIDs[op.OperationID]; ok {
+				errs = append(errs, fmt.Sprintf("%s: operationId %s 与 %s 重复", where, op.OperationID, other))
+			}
+			operationIDs[op.OperationID] = where
+			if len(op.Responses) == 0 {
+				errs = append(errs, where+": 没有声明响应")
+			}
+
+			declared := make(map[string]bool)
+			for _, p := range op.Parameters {
+				if p.In == InPath {
+					declared[p.Name] = true
+				}
+				if p.Schema == nil {
+					errs = append(errs, fmt.Sprintf("%s: 参数%s缺少schema", where, p.Name))
+				}
+			}
+			for _, name := range PathParams(path) {
+				if !declared[name] {
+					errs = append(errs, fmt.Sprintf("%s: 路径参数%s未声明", where, name))
+				}
+				delete(declared, name)
+			}
+			for name := range declared {
+				errs = append(errs, fmt.Sprintf("%s: 声明的路径参数%s不在路径中", where, name))
+			}
+
+			var schemas []*Schema
+			if op.RequestBody != nil {
+				for _, mt := range op.RequestBody.Content {
+					schemas = append(schemas, mt.Schema)
+				}
+			}
+			for _, r := range op.Responses {
+				for _, mt := range r.Content {
+					schemas = append(schemas, mt.Schema)
+				}
+			}
+			for _, s := range schemas {
+				if err := d.checkRefs(s); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+				}
+			}
+		}
+	}
+	for name, s := range d.Components.Schemas {
+		if err := d.checkRefs(s); err != nil {
+			errs = append(errs, fmt.Sprintf("组件%s: %v", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("OpenAPI文档无效:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// checkRefs 检查schema中引用的组件是否存在
+func (d *Document) checkRefs(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		if _, err := d.resolve(s); err != nil {
+			return err
+		}
+		return nil
+	}
+	children := append([]*Schema{s.Items, s.AdditionalProperties}, s.AllOf...)
+	for _, p := range s.Properties {
+		children = append(children, p)
+	}
+	for _, c := range children {
+		if err := d.checkRefs(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithParams 添加参数
+func (o *Operation) WithParams(params ...Parameter) *Operation {
+	o.Parameters = append(o.Parameters, params...)
+	return o
+}
+
+// WithBody 设置必填的JSON请求体
+func (o *Operation) WithBody(schema *Schema) *Operation {
+	o.RequestBody = JSONBody(schema)
+	return o
+}
+
+// WithSecurity 要求使用指定的认证方式
+func (o *Operation) WithSecurity(scheme string) *Operation {
+	o.Security = append(o.Security, SecurityRequirement{scheme: {}})
+	return o
+}
+
+// WithResponse 设置状态码对应的响应，status为"default"时用于其他状态码
+func (o *Operation) WithResponse(status string, r Response) *Operation {
+	if o.Responses == nil {
+		o.Responses = make(map[string]Response)
+	}
+	o.Responses[status] = r
+	return o
+}
+
+// Describe 设置接口说明
+func (o *Operation) Describe(description string) *Operation {
+	o.Description = description
+	return o
+}
@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema JSON Schema（OpenAPI 3.0的子集）
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+// String 字符串
+func String() *Schema { return &Schema{Type: "string"} }
+
+// Integer 整数
+func Integer() *Schema { return &Schema{Type: "integer"} }
+
+// Number 数字
+func Number() *Schema { return &Schema{Type: "number"} }
+
+// Boolean 布尔值
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+
+// DateTime RFC 3339时间
+func DateTime() *Schema { return &Schema{Type: "string", Format: "date-time"} }
+
+// Any 任意值
+func Any() *Schema { return &Schema{} }
+
+// Array 数组，Go的nil切片编码为null，因此默认可为null
+func Array(items *Schema) *Schema { return &Schema{Type: "array", Items: items, Nullable: true} }
+
+// Object 对象，required列出必须出现的属性
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// Ref 引用组件
+func Ref(name string) *Schema { return &Schema{Ref: refPrefix + name} }
+
+// Enum 限定取值
+func Enum(values ...string) *Schema {
+	s := String()
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+	return s
+}
+
+// Describe 设置说明
+func (s *Schema) Describe(description string) *Schema {
+	s.Description = description
+	return s
+}
+
+// OrNull 允许为null，引用组件时使用allOf包装
+func (s *Schema) OrNull() *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	s.Nullable = true
+	return s
+}
+
+// Register 将Go类型注册为组件，之后SchemaOf遇到该类型时生成引用
+// 同名组件已存在时覆盖
+func (d *Document) Register(name string, v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	schema := d.schemaOf(t)
+	d.Components.Schemas[name] = schema
+	d.types[t] = name
+	return schema
+}
+
+// SchemaOf 根据Go类型及其json标签生成schema：没有omitempty的字段为必需属性，
+// 指针可为null，已注册的类型生成引用
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schemaOfType(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (d *Document) schemaOfType(t reflect.Type) *Schema {
+	if name, ok := d.types[t]; ok {
+		return Ref(name)
+	}
+	return d.schemaOf(t)
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == nil:
+		return Any()
+	case t == timeType:
+		return DateTime()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return d.schemaOfType(t.Elem()).OrNull()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return Number()
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		return Array(d.schemaOfType(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOfType(t.Elem())}
+	case reflect.Struct:
+		s := Object(make(map[string]*Schema))
+		d.addFields(s, t)
+		return s
+	default:
+		return Any()
+	}
+}
+
+// addFields 按encoding/json的规则添加结构体字段，匿名嵌入的结构体字段提升到外层
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			d.addFields(s, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schemaOfType(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Match 查找与请求方法和实际路径匹配的接口，返回接口和路径模板
+// 静态路径优先于带参数的路径
+func (d *Document) Match(method, path string) (*Operation, string, bool) {
+	templates := make([]string, 0, len(d.Paths))
+	for template := range d.Paths {
+		templates = append(templates, template)
+	}
+	// 参数少的模板在前，使静态路径优先匹配
+	sort.Slice(templates, func(i, j int) bool {
+		pi, pj := len(PathParams(templates[i])), len(PathParams(templates[j]))
+		if pi != pj {
+			return pi < pj
+		}
+		return templates[i] < templates[j]
+	})
+
+	for _, template := range templates {
+		if !matchPath(template, path) {
+			continue
+		}
+		if op, ok := d.Paths[template][strings.ToLower(method)]; ok {
+			return op, template, true
+		}
+	}
+	return nil, "", false
+}
+
+func matchPath(template, path string) bool {
+	ts := strings.Split(strings.Trim(template, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ts) != len(ps) {
+		return false
+	}
+	for i := range ts {
+		if strings.HasPrefix(ts[i], "{") && strings.HasSuffix(ts[i], "}") {
+			if ps[i] == "" {
+				return false
+			}
+			continue
+		}
+		if ts[i] != ps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRequest 校验请求：必填的查询参数存在，JSON请求体符合文档
+func (d *Document) ValidateRequest(method, rawURL string, body []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	op, template, ok := d.Match(method, u.Path)
+	if !ok {
+		return fmt.Errorf("文档中没有接口 %s %s", method, u.Path)
+	}
+	where := method + " " + template
+
+	query := u.Query()
+	for _, p := range op.Parameters {
+		if p.In == InQuery && p.Required && query.Get(p.Name) == "" {
+			return fmt.Errorf("%s: 缺少必填的查询参数%s", where, p.Name)
+		}
+	}
+
+	if op.RequestBody == nil {
+		if len(body) > 0 {
+			return fmt.Errorf("%s: 文档中没有声明请求体", where)
+		}
+		return nil
+	}
+	mt, ok := op.RequestBody.Content["application/json"]
+	if !ok || len(body) == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("%s: 缺少请求体", where)
+		}
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s: 请求体不是JSON: %v", where, err)
+	}
+	if err := d.ValidateValue(mt.Schema, value); err != nil {
+		return fmt.Errorf("%s 请求体%v", where, err)
+	}
+	return nil
+}
+
+// ValidateResponse 校验响应：状态码已在文档中声明（或有default响应），
+// 内容类型与文档一致，JSON响应体符合schema
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	op, template, ok := d.Match(method, path)
+	if !ok {
+		return fmt.Errorf("文档中没有接口 %s %s", method, path)
+	}
+	where := fmt.Sprintf("%s %s -> %d", method, template, status)
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok {
+			return fmt.Errorf("%s: 未声明的状态码", where)
+		}
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("%s: 文档中没有声明响应内容", where)
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mt, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s: 文档中没有声明内容类型%q", where, contentType)
+	}
+	if mediaType != "application/json" || mt.Schema == nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s: 响应不是JSON: %v", where, err)
+	}
+	if err := d.ValidateValue(mt.Schema, value); err != nil {
+		return fmt.Errorf("%s 响应%v", where, err)
+	}
+	return nil
+}
+
+// ValidateValue 校验JSON解码后的值是否符合schema
+func (d *Document) ValidateValue(s *Schema, value interface{}) error {
+	return d.validate(s, value, "$")
+}
+
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, refPrefix)
+		target, ok := d.Components.Schemas[name]
+		if !ok || name == s.Ref {
+			return nil, fmt.Errorf("引用的组件%s不存在", s.Ref)
+		}
+		s = target
+	}
+	return s, nil
+}
+
+func (d *Document) validate(s *Schema, value interface{}, at string) error {
+	if s == nil {
+		return nil
+	}
+	s, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: 不能为null", at)
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, value, at); err != nil {
+			return err
+		}
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if e == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v 不在可选值%v中", at, value, s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: 应为对象，实际为%T", at, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: 缺少属性%s", at, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if err := d.validate(prop, v, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: 应为数组，实际为%T", at, value)
+		}
+		for i, v := range items {
+			if err := d.validate(s.Items, v, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: 应为字符串，实际为%T", at, value)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %q 不是RFC 3339时间", at, str)
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: 应为整数，实际为%v", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: 应为数字，实际为%T", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: 应为布尔值，实际为%T", at, value)
+		}
+	default:
+		return fmt.Errorf("%s: 不支持的类型%s", at, s.Type)
+	}
+	return nil
+}
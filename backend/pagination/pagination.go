@@ -0,0 +1,129 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// 列表接口统一的分页参数：
+//   - cursor：上一页返回的next_cursor，从该位置之后继续（键集分页，翻页深度不影响性能）
+//   - page：页码（从1开始），按偏移量分页并返回总数，兼容按页码跳转的分页组件
+//   - limit（或page_size）：每页数量，超过上限时按上限处理
+//   - include_total：为true时返回符合条件的总数（COUNT(*)在大表上开销较大，默认不返回）
+//
+// 同时指定cursor和page时以cursor为准。
+
+// ErrInvalidCursor 游标无法解析
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+// Limits 每页数量的默认值和上限
+type Limits struct {
+	Default int
+	Max     int
+}
+
+var (
+	// ListLimits 问卷、用户等列表
+	ListLimits = Limits{Default: 10, Max: 100}
+	// ResultsLimits 答卷、投递记录等明细
+	ResultsLimits = Limits{Default: 100, Max: 500}
+)
+
+// Cursor 游标：上一页最后一条记录的排序键
+// 编码后对客户端不透明，以便将来调整排序键而不影响客户端
+type Cursor struct {
+	ID uint `json:"id"`
+}
+
+// Encode 编码为URL安全的字符串
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode 解析Encode生成的游标
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Params 解析后的分页参数
+type Params struct {
+	Limit        int
+	Page         int     // 大于0时按页码分页
+	After        *Cursor // 游标分页的起点，第一页为nil
+	IncludeTotal bool
+}
+
+// Parse 从查询参数解析分页参数，无效的页码和数量使用默认值，只有游标无效时返回错误
+func Parse(query url.Values, limits Limits) (Params, error) {
+	p := Params{Limit: limits.Default}
+
+	limit := query.Get("limit")
+	if limit == "" {
+		limit = query.Get("page_size")
+	}
+	if n, err := strconv.Atoi(limit); err == nil && n > 0 {
+		p.Limit = n
+	}
+	if p.Limit > limits.Max {
+		p.Limit = limits.Max
+	}
+
+	p.IncludeTotal, _ = strconv.ParseBool(query.Get("include_total"))
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := Decode(cursor)
+		if err != nil {
+			return p, err
+		}
+		p.After = after
+		return p, nil
+	}
+
+	if page := query.Get("page"); page != "" {
+		p.Page = 1
+		if n, err := strconv.Atoi(page); err == nil && n > 0 {
+			p.Page = n
+		}
+	}
+	return p, nil
+}
+
+// Offset 页码分页时跳过的记录数
+func (p Params) Offset() int {
+	if p.Page > 1 && p.After == nil {
+		return (p.Page - 1) * p.Limit
+	}
+	return 0
+}
+
+// WithTotal 是否需要统计总数，页码分页需要总数计算页数
+func (p Params) WithTotal() bool {
+	return p.IncludeTotal || p.Page > 0
+}
+
+// Page 一页结果的分页信息
+type Page struct {
+	NextCursor string // 没有下一页时为空
+	Total      *int64 // 未要求总数时为nil
+}
+
+// Trim 截取按Limit+1条查询的结果：多出的一条表示还有下一页，下一页从本页最后一条之后开始
+func Trim[T any](items []T, p Params, key func(T) uint) ([]T, Page) {
+	if len(items) <= p.Limit {
+		return items, Page{}
+	}
+	items = items[:p.Limit]
+	return items, Page{NextCursor: Cursor{ID: key(items[len(items)-1])}.Encode()}
+}
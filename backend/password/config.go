@@ -0,0 +1,24 @@
+package password
+
+import "questionnaire-system/backend/config"
+
+// NewHasher 按配置创建Hasher
+func NewHasher(cfg config.PasswordConfig) *Hasher {
+	h := Default()
+	h.Algorithm = cfg.Algorithm
+	h.BcryptCost = cfg.BcryptCost
+	h.Argon2.Memory = uint32(cfg.Argon2.Memory)
+	h.Argon2.Iterations = uint32(cfg.Argon2.Iterations)
+	h.Argon2.Parallelism = uint8(cfg.Argon2.Parallelism)
+	return h
+}
+
+// NewPolicy 按配置创建密码策略
+func NewPolicy(cfg config.PasswordConfig) Policy {
+	return Policy{
+		MinLength:      cfg.MinLength,
+		MaxLength:      cfg.MaxLength,
+		MinClasses:     cfg.MinClasses,
+		RejectUsername: cfg.RejectUsername,
+	}
+}
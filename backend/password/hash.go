@@ -0,0 +1,164 @@
+// Package password 密码哈希及密码强度策略
+//
+// 能够识别并校验MD5（早期版本写入的十六进制摘要）、bcrypt和argon2id三种哈希；
+// 新密码总是使用当前配置的算法，Verify在旧算法或旧参数的哈希校验通过时提示调用方重新哈希。
+package password
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 哈希算法
+const (
+	MD5      = "md5" // 只用于校验旧数据，不能用于生成新哈希
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// bcryptMaxLength bcrypt只使用密码的前72个字节
+const bcryptMaxLength = 72
+
+var (
+	// ErrUnknownHash 无法识别的哈希格式
+	ErrUnknownHash = errors.New("无法识别的密码哈希格式")
+	// ErrTooLong 密码超过bcrypt支持的72个字节
+	ErrTooLong = errors.New("密码超过72个字节")
+)
+
+// Argon2Params argon2id的参数
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2 RFC 9106推荐的第二种参数（64 MiB内存、3次迭代）
+var DefaultArgon2 = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+// Hasher 使用Algorithm生成新哈希，并校验所有支持格式的哈希
+type Hasher struct {
+	Algorithm  string // Bcrypt或Argon2id
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Default 默认使用bcrypt（默认cost），与早期版本写入的哈希一致
+func Default() *Hasher {
+	return &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.DefaultCost, Argon2: DefaultArgon2}
+}
+
+// Identify 识别哈希使用的算法，无法识别时返回空字符串
+func Identify(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return Bcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return Argon2id
+	case len(hash) == md5.Size*2 && isHex(hash):
+		return MD5
+	default:
+		return ""
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Hash 使用当前算法生成密码哈希
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case Bcrypt:
+		if len(password) > bcryptMaxLength {
+			return "", ErrTooLong
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	case Argon2id:
+		salt := make([]byte, h.Argon2.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		p := h.Argon2
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", fmt.Errorf("不支持的密码哈希算法 %q", h.Algorithm)
+	}
+}
+
+// Verify 校验密码与哈希是否匹配
+// 匹配且哈希不是当前算法和参数生成的（如MD5、cost较低的bcrypt）时rehash为true，调用方应使用Hash重新生成并保存；
+// 哈希格式无法识别时返回ErrUnknownHash
+func (h *Hasher) Verify(hash, password string) (ok, rehash bool, err error) {
+	switch Identify(hash) {
+	case MD5:
+		sum := md5.Sum([]byte(password))
+		ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hash))) == 1
+		return ok, ok, nil
+	case Bcrypt:
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, err != nil || h.Algorithm != Bcrypt || cost != h.BcryptCost, nil
+	case Argon2id:
+		params, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return false, false, err
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false, nil
+		}
+		current := h.Argon2
+		stale := params.Memory != current.Memory || params.Iterations != current.Iterations ||
+			params.Parallelism != current.Parallelism || uint32(len(key)) != current.KeyLength
+		return true, h.Algorithm != Argon2id || stale, nil
+	default:
+		return false, false, ErrUnknownHash
+	}
+}
+
+// parseArgon2 解析 $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key> 格式的哈希
+func parseArgon2(hash string) (params Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: 不支持的argon2版本 %q", ErrUnknownHash, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: 无效的argon2哈希", ErrUnknownHash)
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	return params, salt, key, nil
+}
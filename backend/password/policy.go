@@ -0,0 +1,86 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 违反的密码规则，用作字段错误的原因
+const (
+	RuleTooShort         = "too_short"         // 少于MinLength个字符
+	RuleTooLong          = "too_long"          // 多于MaxLength个字节
+	RuleTooSimple        = "too_simple"        // 字符类别少于MinClasses
+	RuleContainsUsername = "contains_username" // 包含用户名
+)
+
+// ErrWeak 密码不符合策略，errors.Is(*PolicyError, ErrWeak)为true
+var ErrWeak = errors.New("密码不符合要求")
+
+// Policy 密码强度策略，零值不做任何限制
+type Policy struct {
+	MinLength int // 最少字符数
+	MaxLength int // 最多字节数，0表示不限；bcrypt只使用前72个字节
+	// MinClasses 至少包含几类字符（小写字母、大写字母、数字、其他符号），0或1表示不限
+	MinClasses int
+	// RejectUsername 不允许密码中包含用户名（不区分大小写）
+	RejectUsername bool
+}
+
+// PolicyError 违反的全部规则
+type PolicyError struct {
+	Policy Policy
+	Rules  []string
+}
+
+func (e *PolicyError) Error() string {
+	return ErrWeak.Error() + ": " + strings.Join(e.Rules, ", ")
+}
+
+func (e *PolicyError) Unwrap() error { return ErrWeak }
+
+// Check 检查密码是否符合策略，不符合时返回*PolicyError
+func (p Policy) Check(password, username string) error {
+	var rules []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		rules = append(rules, RuleTooShort)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		rules = append(rules, RuleTooLong)
+	}
+	if p.MinClasses > 1 && classes(password) < p.MinClasses {
+		rules = append(rules, RuleTooSimple)
+	}
+	if p.RejectUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		rules = append(rules, RuleContainsUsername)
+	}
+	if len(rules) > 0 {
+		return &PolicyError{Policy: p, Rules: rules}
+	}
+	return nil
+}
+
+// classes 密码中包含的字符类别数
+func classes(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			n++
+		}
+	}
+	return n
+}
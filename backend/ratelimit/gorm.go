@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxAttempts 令牌桶被其他请求同时修改时重试的次数
+const maxAttempts = 5
+
+// bucketRow 数据库中的令牌桶，表由迁移0005创建
+type bucketRow struct {
+	Key        string    `gorm:"column:bucket_key;primaryKey;size:191"`
+	Tokens     float64   `gorm:"not null"`
+	RefilledAt int64     `gorm:"not null"` // 上次计算令牌数的时间（Unix纳秒）
+	ExpiresAt  time.Time `gorm:"not null;index"`
+	Version    int64     `gorm:"not null"`
+}
+
+func (bucketRow) TableName() string { return "rate_limit_buckets" }
+
+// GormLimiter 令牌桶保存在数据库中的限流器，多个实例共享限制
+// 使用版本号做乐观并发控制，不依赖行锁，SQLite、MySQL和PostgreSQL行为一致
+type GormLimiter struct {
+	DB  *gorm.DB
+	Now func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewGormLimiter 创建基于数据库的限流器
+func NewGormLimiter(db *gorm.DB) *GormLimiter {
+	return &GormLimiter{DB: db, Now: time.Now}
+}
+
+// Allow 实现Limiter
+// 同一个key被大量并发请求争用、重试后仍无法更新时视为超过限制
+func (l *GormLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	db := l.DB.WithContext(ctx)
+	if err := l.sweep(db); err != nil {
+		return Result{}, err
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		now := l.Now()
+
+		var row bucketRow
+		err := db.Where("bucket_key = ?", key).Take(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tokens, result := take(float64(limit.Burst), now, now, limit)
+			row = bucketRow{Key: key, Tokens: tokens, RefilledAt: now.UnixNano(), ExpiresAt: fullAt(tokens, now, limit), Version: 1}
+			created := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if created.Error != nil {
+				return Result{}, created.Error
+			}
+			if created.RowsAffected == 1 {
+				return result, nil
+			}
+			continue // 其他请求先创建了令牌桶
+		}
+		if err != nil {
+			return Result{}, err
+		}
+
+		tokens, result := take(row.Tokens, time.Unix(0, row.RefilledAt), now, limit)
+		updated := db.Model(&bucketRow{}).
+			Where("bucket_key = ? AND version = ?", key, row.Version).
+			Updates(map[string]interface{}{
+				"tokens":      tokens,
+				"refilled_at": now.UnixNano(),
+				"expires_at":  fullAt(tokens, now, limit),
+				"version":     row.Version + 1,
+			})
+		if updated.Error != nil {
+			return Result{}, updated.Error
+		}
+		if updated.RowsAffected == 1 {
+			return result, nil
+		}
+	}
+	return Result{RetryAfter: time.Second}, nil
+}
+
+// sweep 定期删除已补满的令牌桶
+func (l *GormLimiter) sweep(db *gorm.DB) error {
+	now := l.Now()
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) < sweepInterval {
+		l.mu.Unlock()
+		return nil
+	}
+	l.lastSweep = now
+	l.mu.Unlock()
+
+	return db.Where("expires_at < ?", now).Delete(&bucketRow{}).Error
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval 清理已补满的令牌桶的间隔
+const sweepInterval = time.Minute
+
+// MemoryLimiter 进程内的限流器，多个实例部署时各自计数
+type MemoryLimiter struct {
+	Now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 到该时间令牌桶补满
+}
+
+// NewMemoryLimiter 创建进程内限流器
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{Now: time.Now, buckets: make(map[string]*memoryBucket)}
+}
+
+// Allow 实现Limiter
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	tokens, result := take(b.tokens, b.last, now, limit)
+	b.tokens, b.last, b.full = tokens, now, fullAt(tokens, now, limit)
+	return result, nil
+}
+
+// sweep 定期删除已补满的令牌桶，避免大量不同的key占用内存，调用方需持有锁
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Package ratelimit 令牌桶限流
+//
+// 每个key对应一个容量为Burst的令牌桶，每次请求消耗一个令牌，令牌按Burst/Period的速度补充。
+// MemoryLimiter只在单个实例内生效；GormLimiter把令牌桶保存在数据库中，多个实例共享限制。
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit 令牌桶参数：最多连续Burst次请求，之后每Period/Burst补充一次
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Unlimited Burst或Period不大于0时不限流
+func (l Limit) Unlimited() bool {
+	return l.Burst <= 0 || l.Period <= 0
+}
+
+// rate 每纳秒补充的令牌数
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / float64(l.Period)
+}
+
+// Result 一次请求的限流结果
+type Result struct {
+	Allowed    bool
+	Remaining  int           // 本次请求之后剩余的令牌数
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌的时间
+}
+
+// Limiter 限流器
+type Limiter interface {
+	// Allow 为key消耗一个令牌，令牌不足时Allowed为false
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// take 按经过的时间补充令牌并尝试消耗一个，返回剩余令牌数
+// tokens为上次计算时的令牌数，新的令牌桶传入limit.Burst
+func take(tokens float64, last, now time.Time, limit Limit) (float64, Result) {
+	if elapsed := now.Sub(last); elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+float64(elapsed)*limit.rate())
+	}
+	if tokens >= 1 {
+		tokens--
+		return tokens, Result{Allowed: true, Remaining: int(tokens)}
+	}
+	wait := time.Duration(math.Ceil((1 - tokens) / limit.rate()))
+	return tokens, Result{RetryAfter: wait}
+}
+
+// fullAt 令牌桶补满的时间，此后的状态与新建的令牌桶相同，可以删除
+func fullAt(tokens float64, now time.Time, limit Limit) time.Time {
+	return now.Add(time.Duration(math.Ceil((float64(limit.Burst) - tokens) / limit.rate())))
+}
@@ -0,0 +1,204 @@
+package realtime
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"questionnaire-system/backend/stats"
+)
+
+// Subscription 一个实时结果订阅（对应一个SSE连接）
+// Updates只保留最新的一份汇总：客户端处理较慢时旧的汇总会被新的覆盖，而不会阻塞发布方
+type Subscription struct {
+	QuestionnaireID uint
+	updates         chan *stats.Summary
+
+	mu      sync.Mutex
+	dropped int64
+}
+
+// Updates 接收汇总更新的通道
+func (s *Subscription) Updates() <-chan *stats.Summary {
+	return s.updates
+}
+
+// Dropped 因客户端处理不及时而被覆盖的更新数量
+func (s *Subscription) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// offer 非阻塞地投递汇总，通道已满时丢弃未读取的旧汇总
+func (s *Subscription) offer(summary *stats.Summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case s.updates <- summary:
+		return
+	default:
+	}
+
+	select {
+	case <-s.updates:
+		s.dropped++
+	default:
+	}
+	s.updates <- summary
+}
+
+// topic 同一问卷的订阅者及刷新状态
+type topic struct {
+	subscribers map[*Subscription]struct{}
+	refreshing  bool // 是否正在计算汇总
+	dirty       bool // 计算期间是否有新的提交
+}
+
+// Hub 按问卷分发实时结果，可在多个goroutine中并发使用
+// 每次发布只计算一次汇总并分发给所有订阅者；计算期间的多次发布会合并为一次
+type Hub struct {
+	// Load 计算问卷的汇总统计，测试时可替换
+	Load func(ctx context.Context, questionnaireID uint) (*stats.Summary, error)
+
+	mu     sync.Mutex
+	topics map[uint]*topic
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewHub 创建Hub，load计算问卷的汇总统计（如service.ResultsService.Summary）
+func NewHub(load func(ctx context.Context, questionnaireID uint) (*stats.Summary, error)) *Hub {
+	return &Hub{
+		Load:   load,
+		topics: make(map[uint]*topic),
+		closed: make(chan struct{}),
+	}
+}
+
+// Close 通知所有订阅者结束（服务关闭时调用），SSE连接据此主动断开，客户端会重连到其他实例
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// Done 在Close之后关闭的通道；Hub为nil时返回nil（永远不会关闭）
+func (h *Hub) Done() <-chan struct{} {
+	if h == nil {
+		return nil
+	}
+	return h.closed
+}
+
+// Subscribe 订阅问卷的汇总更新，使用完毕后必须调用Unsubscribe
+// Hub为nil（未启用实时推送）时返回的订阅不会收到更新
+func (h *Hub) Subscribe(questionnaireID uint) *Subscription {
+	sub := &Subscription{
+		QuestionnaireID: questionnaireID,
+		updates:         make(chan *stats.Summary, 1),
+	}
+	if h == nil {
+		return sub
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t := h.topics[questionnaireID]
+	if t == nil {
+		t = &topic{subscribers: make(map[*Subscription]struct{})}
+		h.topics[questionnaireID] = t
+	}
+	t.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe 取消订阅
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	if h == nil || sub == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t := h.topics[sub.QuestionnaireID]
+	if t == nil {
+		return
+	}
+	delete(t.subscribers, sub)
+	if len(t.subscribers) == 0 && !t.refreshing {
+		delete(h.topics, sub.QuestionnaireID)
+	}
+}
+
+// Subscribers 问卷当前的订阅者数量
+func (h *Hub) Subscribers(questionnaireID uint) int {
+	if h == nil {
+		return 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if t := h.topics[questionnaireID]; t != nil {
+		return len(t.subscribers)
+	}
+	return 0
+}
+
+// Publish 通知问卷有新的提交；没有订阅者时不做任何事
+// 汇总在后台计算，不会阻塞调用方
+func (h *Hub) Publish(questionnaireID uint) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t := h.topics[questionnaireID]
+	if t == nil || len(t.subscribers) == 0 {
+		return
+	}
+	if t.refreshing {
+		t.dirty = true
+		return
+	}
+	t.refreshing = true
+
+	go h.refresh(questionnaireID, t)
+}
+
+// refresh 计算汇总并分发，直到计算期间没有新的发布
+func (h *Hub) refresh(questionnaireID uint, t *topic) {
+	for {
+		summary, err := h.Load(context.Background(), questionnaireID)
+
+		h.mu.Lock()
+		if err != nil {
+			slog.Error("计算实时结果失败", "questionnaire_id", questionnaireID, "error", err)
+		} else {
+			for sub := range t.subscribers {
+				sub.offer(summary)
+			}
+		}
+
+		if !t.dirty || len(t.subscribers) == 0 {
+			t.refreshing = false
+			t.dirty = false
+			if len(t.subscribers) == 0 {
+				delete(h.topics, questionnaireID)
+			}
+			h.mu.Unlock()
+			return
+		}
+		t.dirty = false
+		h.mu.Unlock()
+	}
+}
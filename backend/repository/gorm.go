@@ -0,0 +1,782 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/webhook"
+
+	"gorm.io/gorm"
+)
+
+// GormStore 基于GORM的仓储实现
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建GORM仓储
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Users 用户仓储
+func (s *GormStore) Users() UserRepository { return gormUsers{s.db} }
+
+// Questionnaires 问卷仓储
+func (s *GormStore) Questionnaires() QuestionnaireRepository { return gormQuestionnaires{s.db} }
+
+// Submissions 提交记录仓储
+func (s *GormStore) Submissions() SubmissionRepository { return gormSubmissions{s.db} }
+
+// Events 事件仓储
+func (s *GormStore) Events() EventRepository { return gormEvents{s.db} }
+
+// Webhooks Webhook订阅和投递记录仓储
+func (s *GormStore) Webhooks() WebhookRepository { return gormWebhooks{s.db} }
+
+// PasswordResets 密码重置令牌仓储
+func (s *GormStore) PasswordResets() PasswordResetRepository { return gormPasswordResets{s.db} }
+
+// EmailVerifications 邮箱验证令牌仓储
+func (s *GormStore) EmailVerifications() EmailVerificationRepository {
+	return gormEmailVerifications{s.db}
+}
+
+// MFA 两步验证仓储
+func (s *GormStore) MFA() MFARepository { return gormMFA{s.db} }
+
+// SSO 单点登录仓储
+func (s *GormStore) SSO() SSORepository { return gormSSO{s.db} }
+
+// Transaction 在数据库事务中执行fn
+func (s *GormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&GormStore{db: tx})
+	})
+}
+
+// translate 将GORM错误转换为仓储错误
+func translate(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey), isUniqueViolation(err):
+		return ErrDuplicate
+	default:
+		return err
+	}
+}
+
+// isUniqueViolation 识别各驱动的唯一约束错误（未开启TranslateError时）
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") || // MySQL
+		strings.Contains(msg, "UNIQUE constraint failed") || // SQLite
+		strings.Contains(msg, "duplicate key value") // PostgreSQL
+}
+
+// paginate 应用分页参数，desc表示列表按ID倒序
+func paginate(query *gorm.DB, page Page, desc bool) *gorm.DB {
+	if page.After != nil {
+		if desc {
+			query = query.Where("id < ?", page.After.ID)
+		} else {
+			query = query.Where("id > ?", page.After.ID)
+		}
+	} else if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+	return query
+}
+
+// 用户
+
+type gormUsers struct{ db *gorm.DB }
+
+// userColumns 返回给调用方的用户字段（不含密码）
+const userColumns = "id, username, email, phone, is_admin, failed_logins, locked_until, email_verified_at, totp_enabled_at, created_at, updated_at"
+
+func (r gormUsers) Get(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) GetMany(ctx context.Context, ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Select(userColumns).Where("id IN ?", ids).Order("id").Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) List(ctx context.Context, page Page) ([]models.User, error) {
+	var users []models.User
+	err := paginate(r.db.WithContext(ctx).Select(userColumns).Order("id desc"), page, true).Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) Count(ctx context.Context, adminsOnly bool) (int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.User{})
+	if adminsOnly {
+		query = query.Where("is_admin = ?", true)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	return translate(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r gormUsers) Save(ctx context.Context, user *models.User) error {
+	return translate(r.db.WithContext(ctx).Save(user).Error)
+}
+
+func (r gormUsers) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
+}
+
+// 登录失败计数只修改这两列，不更新updated_at
+
+func (r gormUsers) RecordLoginFailure(ctx context.Context, id uint) (int, error) {
+	var failures int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", id).
+			UpdateColumn("failed_logins", gorm.Expr("failed_logins + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Model(&models.User{}).Where("id = ?", id).Pluck("failed_logins", &failures).Error
+	})
+	return failures, err
+}
+
+func (r gormUsers) Lock(ctx context.Context, id uint, until time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumn("locked_until", until).Error
+}
+
+func (r gormUsers) ResetLoginFailures(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"failed_logins": 0, "locked_until": nil}).Error
+}
+
+func (r gormUsers) SetPassword(ctx context.Context, id uint, hash string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumn("password", hash).Error
+}
+
+func (r gormUsers) SetTOTP(ctx context.Context, id uint, secret string, enabledAt *time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": enabledAt, "totp_last_counter": 0}).Error
+}
+
+func (r gormUsers) UseTOTPCounter(ctx context.Context, id uint, counter int64) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_counter < ?", id, counter).UpdateColumn("totp_last_counter", counter)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// 问卷
+
+type gormQuestionnaires struct{ db *gorm.DB }
+
+func (r gormQuestionnaires) Get(ctx context.Context, id uint) (*models.Questionnaire, error) {
+	var questionnaire models.Questionnaire
+	if err := r.db.WithContext(ctx).First(&questionnaire, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &questionnaire, nil
+}
+
+func (r gormQuestionnaires) filtered(ctx context.Context, filter QuestionnaireFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.Questionnaire{})
+	if filter.VisibleTo > 0 {
+		query = query.Where("is_published = ? OR created_by = ?", true, filter.VisibleTo)
+	}
+	if filter.CreatedBy > 0 {
+		query = query.Where("created_by = ?", filter.CreatedBy)
+	}
+	if filter.Published != nil {
+		query = query.Where("is_published = ?", *filter.Published)
+	}
+	if !filter.OpenAt.IsZero() {
+		query = query.Where("is_published = ?", true).
+			Where("start_time IS NULL OR start_time <= ?", filter.OpenAt).
+			Where("end_time IS NULL OR end_time = ? OR end_time >= ?", time.Time{}, filter.OpenAt)
+	}
+	return query
+}
+
+func (r gormQuestionnaires) List(ctx context.Context, filter QuestionnaireFilter, page Page) ([]models.Questionnaire, error) {
+	var questionnaires []models.Questionnaire
+	err := paginate(r.filtered(ctx, filter).Order("id DESC"), page, true).Find(&questionnaires).Error
+	return questionnaires, err
+}
+
+func (r gormQuestionnaires) Count(ctx context.Context, filter QuestionnaireFilter) (int64, error) {
+	var count int64
+	err := r.filtered(ctx, filter).Count(&count).Error
+	return count, err
+}
+
+func (r gormQuestionnaires) Create(ctx context.Context, questionnaire *models.Questionnaire) error {
+	return r.db.WithContext(ctx).Create(questionnaire).Error
+}
+
+func (r gormQuestionnaires) Save(ctx context.Context, questionnaire *models.Questionnaire) error {
+	return r.db.WithContext(ctx).Save(questionnaire).Error
+}
+
+func (r gormQuestionnaires) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Questionnaire{}, id).Error
+}
+
+func (r gormQuestionnaires) DeleteByCreator(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("created_by = ?", userID).Delete(&models.Questionnaire{}).Error
+}
+
+func (r gormQuestionnaires) Questions(ctx context.Context, questionnaireID uint) ([]models.Question, error) {
+	var questions []models.Question
+	err := r.db.WithContext(ctx).Where("questionnaire_id = ?", questionnaireID).Order("sort, id").Find(&questions).Error
+	return questions, err
+}
+
+func (r gormQuestionnaires) CreateQuestion(ctx context.Context, question *models.Question) error {
+	return r.db.WithContext(ctx).Create(question).Error
+}
+
+func (r gormQuestionnaires) DeleteQuestions(ctx context.Context, questionnaireID uint) error {
+	return r.db.WithContext(ctx).Where("questionnaire_id = ?", questionnaireID).Delete(&models.Question{}).Error
+}
+
+func (r gormQuestionnaires) CountQuestions(ctx context.Context, questionnaireID uint) (int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Question{})
+	if questionnaireID > 0 {
+		query = query.Where("questionnaire_id = ?", questionnaireID)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// groupCount GROUP BY查询的一行
+type groupCount struct {
+	ID    uint
+	Count int64
+}
+
+// countBy 按column统计model中属于ids的记录数
+func countBy(db *gorm.DB, model interface{}, column string, ids []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	var rows []groupCount
+	err := db.Model(model).
+		Select(column+" AS id, COUNT(*) AS count").
+		Where(column+" IN ?", ids).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r gormQuestionnaires) CountQuestionsByQuestionnaire(ctx context.Context, questionnaireIDs []uint) (map[uint]int64, error) {
+	return countBy(r.db.WithContext(ctx), &models.Question{}, "questionnaire_id", questionnaireIDs)
+}
+
+func (r gormQuestionnaires) Translations(ctx context.Context, questionnaireID uint) ([]models.QuestionnaireTranslation, error) {
+	var translations []models.QuestionnaireTranslation
+	err := r.db.WithContext(ctx).Where("questionnaire_id = ?", questionnaireID).Order("locale").Find(&translations).Error
+	return translations, err
+}
+
+func (r gormQuestionnaires) QuestionTranslations(ctx context.Context, questionnaireID uint) ([]models.QuestionTranslation, error) {
+	var translations []models.QuestionTranslation
+	err := r.db.WithContext(ctx).Where("questionnaire_id = ?", questionnaireID).Order("question_id, locale").Find(&translations).Error
+	return translations, err
+}
+
+func (r gormQuestionnaires) CreateTranslation(ctx context.Context, translation *models.QuestionnaireTranslation) error {
+	return translate(r.db.WithContext(ctx).Create(translation).Error)
+}
+
+func (r gormQuestionnaires) CreateQuestionTranslation(ctx context.Context, translation *models.QuestionTranslation) error {
+	return translate(r.db.WithContext(ctx).Create(translation).Error)
+}
+
+func (r gormQuestionnaires) DeleteTranslations(ctx context.Context, questionnaireID uint) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("questionnaire_id = ?", questionnaireID).Delete(&models.QuestionTranslation{}).Error; err != nil {
+		return err
+	}
+	return db.Where("questionnaire_id = ?", questionnaireID).Delete(&models.QuestionnaireTranslation{}).Error
+}
+
+// 提交记录
+
+type gormSubmissions struct{ db *gorm.DB }
+
+func (r gormSubmissions) Find(ctx context.Context, questionnaireID, userID uint) (*models.Submission, error) {
+	var submission models.Submission
+	err := r.db.WithContext(ctx).Where("questionnaire_id = ? AND user_id = ?", questionnaireID, userID).First(&submission).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &submission, nil
+}
+
+func (r gormSubmissions) filtered(ctx context.Context, filter SubmissionFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.Submission{})
+	if filter.QuestionnaireID > 0 {
+		query = query.Where("questionnaire_id = ?", filter.QuestionnaireID)
+	}
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("submitted_at > ?", filter.Since)
+	}
+	return query
+}
+
+func (r gormSubmissions) List(ctx context.Context, filter SubmissionFilter, page Page) ([]models.Submission, error) {
+	order := "id"
+	if filter.NewestFirst {
+		order = "id desc"
+	}
+	var submissions []models.Submission
+	err := paginate(r.filtered(ctx, filter).Order(order), page, filter.NewestFirst).Find(&submissions).Error
+	return submissions, err
+}
+
+func (r gormSubmissions) Count(ctx context.Context, filter SubmissionFilter) (int64, error) {
+	var count int64
+	err := r.filtered(ctx, filter).Count(&count).Error
+	return count, err
+}
+
+func (r gormSubmissions) CountByQuestionnaire(ctx context.Context, questionnaireIDs []uint) (map[uint]int64, error) {
+	return countBy(r.db.WithContext(ctx), &models.Submission{}, "questionnaire_id", questionnaireIDs)
+}
+
+func (r gormSubmissions) Create(ctx context.Context, submission *models.Submission) error {
+	return translate(r.db.WithContext(ctx).Create(submission).Error)
+}
+
+func (r gormSubmissions) DeleteByQuestionnaire(ctx context.Context, questionnaireID uint) error {
+	return r.db.WithContext(ctx).Where("questionnaire_id = ?", questionnaireID).Delete(&models.Submission{}).Error
+}
+
+func (r gormSubmissions) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.Submission{}).Error
+}
+
+func (r gormSubmissions) CreateAnswer(ctx context.Context, answer *models.Answer) error {
+	return r.db.WithContext(ctx).Create(answer).Error
+}
+
+func (r gormSubmissions) questionIDs(ctx context.Context, questionnaireID uint) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&models.Question{}).Select("id").Where("questionnaire_id = ?", questionnaireID)
+}
+
+func (r gormSubmissions) Answers(ctx context.Context, questionnaireID, userID uint) ([]models.Answer, error) {
+	var answers []models.Answer
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND question_id IN (?)", userID, r.questionIDs(ctx, questionnaireID)).
+		Order("id").
+		Find(&answers).Error
+	return answers, err
+}
+
+func (r gormSubmissions) AnswersByUsers(ctx context.Context, questionnaireID uint, userIDs []uint) ([]models.Answer, error) {
+	var answers []models.Answer
+	if len(userIDs) == 0 {
+		return answers, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("user_id IN ? AND question_id IN (?)", userIDs, r.questionIDs(ctx, questionnaireID)).
+		Order("id").
+		Find(&answers).Error
+	return answers, err
+}
+
+func (r gormSubmissions) CountAnswers(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Answer{}).Count(&count).Error
+	return count, err
+}
+
+func (r gormSubmissions) CountAnswersByContent(ctx context.Context, questionnaireID uint) ([]AnswerCount, error) {
+	var counts []AnswerCount
+	submitted := r.filtered(ctx, SubmissionFilter{QuestionnaireID: questionnaireID}).Select("user_id")
+	err := r.db.WithContext(ctx).Model(&models.Answer{}).
+		Select("question_id, content, COUNT(*) AS total").
+		Where("question_id IN (?) AND user_id IN (?)", r.questionIDs(ctx, questionnaireID), submitted).
+		Group("question_id, content").
+		Order("question_id, content").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r gormSubmissions) DeleteAnswersByQuestionnaire(ctx context.Context, questionnaireID uint) error {
+	return r.db.WithContext(ctx).Where("question_id IN (?)", r.questionIDs(ctx, questionnaireID)).Delete(&models.Answer{}).Error
+}
+
+func (r gormSubmissions) DeleteAnswersByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.Answer{}).Error
+}
+
+// 事件
+
+type gormEvents struct{ db *gorm.DB }
+
+func (r gormEvents) Enqueue(ctx context.Context, questionnaireID uint, event string, data interface{}) error {
+	return webhook.Enqueue(r.db.WithContext(ctx), questionnaireID, event, data)
+}
+
+func (r gormEvents) DeactivateSubscriptions(ctx context.Context, questionnaireID uint) error {
+	return r.db.WithContext(ctx).Model(&models.WebhookSubscription{}).
+		Where("questionnaire_id = ?", questionnaireID).
+		Update("is_active", false).Error
+}
+
+// Webhook
+
+type gormWebhooks struct{ db *gorm.DB }
+
+func (r gormWebhooks) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	return r.db.WithContext(ctx).Create(subscription).Error
+}
+
+func (r gormWebhooks) GetSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := r.db.WithContext(ctx).First(&subscription, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &subscription, nil
+}
+
+func (r gormWebhooks) subscriptions(ctx context.Context, questionnaireID *uint) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.WebhookSubscription{})
+	if questionnaireID != nil {
+		return query.Where("questionnaire_id = ?", *questionnaireID)
+	}
+	return query.Where("questionnaire_id IS NULL")
+}
+
+func (r gormWebhooks) ListSubscriptions(ctx context.Context, questionnaireID *uint, page Page) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := paginate(r.subscriptions(ctx, questionnaireID).Order("id desc"), page, true).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r gormWebhooks) CountSubscriptions(ctx context.Context, questionnaireID *uint) (int64, error) {
+	var count int64
+	err := r.subscriptions(ctx, questionnaireID).Count(&count).Error
+	return count, err
+}
+
+func (r gormWebhooks) DeactivateSubscription(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&models.WebhookSubscription{}).Where("id = ?", id).Update("is_active", false).Error
+}
+
+func (r gormWebhooks) GetDelivery(ctx context.Context, id uint) (*models.WebhookOutbox, error) {
+	var delivery models.WebhookOutbox
+	if err := r.db.WithContext(ctx).First(&delivery, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &delivery, nil
+}
+
+func (r gormWebhooks) ListDeliveries(ctx context.Context, subscriptionID uint, page Page) ([]models.WebhookOutbox, error) {
+	var deliveries []models.WebhookOutbox
+	query := r.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Order("id desc")
+	err := paginate(query, page, true).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r gormWebhooks) CountDeliveries(ctx context.Context, subscriptionID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.WebhookOutbox{}).Where("subscription_id = ?", subscriptionID).Count(&count).Error
+	return count, err
+}
+
+func (r gormWebhooks) Attempts(ctx context.Context, outboxIDs []uint) ([]models.WebhookDeliveryAttempt, error) {
+	var attempts []models.WebhookDeliveryAttempt
+	if len(outboxIDs) == 0 {
+		return attempts, nil
+	}
+	err := r.db.WithContext(ctx).Where("outbox_id IN ?", outboxIDs).Order("outbox_id, attempt").Find(&attempts).Error
+	return attempts, err
+}
+
+func (r gormWebhooks) Redeliver(ctx context.Context, outboxID uint, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.WebhookOutbox{}).
+		Where("id = ? AND status <> ?", outboxID, models.WebhookStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":          models.WebhookStatusPending,
+			"attempts":        0,
+			"next_attempt_at": at,
+			"locked_until":    nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// 密码重置令牌
+
+type gormPasswordResets struct{ db *gorm.DB }
+
+func (r gormPasswordResets) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return translate(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r gormPasswordResets) GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r gormPasswordResets) MarkUsed(ctx context.Context, id uint, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).UpdateColumn("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormPasswordResets) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.PasswordResetToken{}).Error
+}
+
+// 邮箱验证令牌
+
+type gormEmailVerifications struct{ db *gorm.DB }
+
+func (r gormEmailVerifications) Create(ctx context.Context, token *models.EmailVerificationToken) error {
+	return translate(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r gormEmailVerifications) GetByHash(ctx context.Context, tokenHash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r gormEmailVerifications) Latest(ctx context.Context, userID uint) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r gormEmailVerifications) MarkUsed(ctx context.Context, id uint, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).UpdateColumn("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormEmailVerifications) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.EmailVerificationToken{}).Error
+}
+
+// 两步验证
+
+type gormMFA struct{ db *gorm.DB }
+
+func (r gormMFA) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []models.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r gormMFA) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).UpdateColumn("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormMFA) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (r gormMFA) CreateChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	return translate(r.db.WithContext(ctx).Create(challenge).Error)
+}
+
+func (r gormMFA) GetChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&challenge).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &challenge, nil
+}
+
+func (r gormMFA) RecordChallengeFailure(ctx context.Context, id uint) (int, error) {
+	var failures int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MFAChallenge{}).Where("id = ?", id).
+			UpdateColumn("failures", gorm.Expr("failures + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Model(&models.MFAChallenge{}).Where("id = ?", id).Pluck("failures", &failures).Error
+	})
+	return failures, err
+}
+
+func (r gormMFA) MarkChallengeUsed(ctx context.Context, id uint, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).UpdateColumn("used_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormMFA) DeleteByUser(ctx context.Context, userID uint) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userID).Delete(&models.MFAChallenge{}).Error
+}
+
+// 单点登录
+
+type gormSSO struct{ db *gorm.DB }
+
+func (r gormSSO) CreateLogin(ctx context.Context, login *models.SSOLogin) error {
+	return translate(r.db.WithContext(ctx).Create(login).Error)
+}
+
+func (r gormSSO) TakeLogin(ctx context.Context, stateHash string) (*models.SSOLogin, error) {
+	var login models.SSOLogin
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ?", stateHash).First(&login).Error; err != nil {
+			return translate(err)
+		}
+		// 并发回调时只有删除成功的请求能继续
+		result := tx.Delete(&models.SSOLogin{}, login.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+func (r gormSSO) DeleteExpiredLogins(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.SSOLogin{}).Error
+}
+
+func (r gormSSO) GetIdentity(ctx context.Context, issuer, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	if err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (r gormSSO) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	return translate(r.db.WithContext(ctx).Create(identity).Error)
+}
+
+func (r gormSSO) RecordIdentityLogin(ctx context.Context, id uint, email string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Identity{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"email": email, "last_login_at": at}).Error
+}
+
+func (r gormSSO) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.Identity{}).Error
+}
@@ -0,0 +1,1344 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/webhook"
+)
+
+// RecordedEvent 内存仓储记录的事件
+type RecordedEvent struct {
+	QuestionnaireID uint
+	Event           string
+	Data            interface{}
+}
+
+// memoryData 内存仓储保存的全部数据
+type memoryData struct {
+	users          map[uint]models.User
+	questionnaires map[uint]models.Questionnaire
+	questions      map[uint]models.Question
+	translations   map[uint]models.QuestionnaireTranslation
+	questionTrans  map[uint]models.QuestionTranslation
+	submissions    map[uint]models.Submission
+	answers        map[uint]models.Answer
+	resets         map[uint]models.PasswordResetToken
+	verifications  map[uint]models.EmailVerificationToken
+	recoveryCodes  map[uint]models.RecoveryCode
+	challenges     map[uint]models.MFAChallenge
+	identities     map[uint]models.Identity
+	ssoLogins      map[uint]models.SSOLogin
+	webhooks       map[uint]models.WebhookSubscription
+	outbox         map[uint]models.WebhookOutbox
+	events         []RecordedEvent
+	deactivated    map[uint]bool
+	lastID         map[string]uint // 每张表的自增ID
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		users:          make(map[uint]models.User, len(d.users)),
+		questionnaires: make(map[uint]models.Questionnaire, len(d.questionnaires)),
+		questions:      make(map[uint]models.Question, len(d.questions)),
+		translations:   make(map[uint]models.QuestionnaireTranslation, len(d.translations)),
+		questionTrans:  make(map[uint]models.QuestionTranslation, len(d.questionTrans)),
+		submissions:    make(map[uint]models.Submission, len(d.submissions)),
+		answers:        make(map[uint]models.Answer, len(d.answers)),
+		resets:         make(map[uint]models.PasswordResetToken, len(d.resets)),
+		verifications:  make(map[uint]models.EmailVerificationToken, len(d.verifications)),
+		recoveryCodes:  make(map[uint]models.RecoveryCode, len(d.recoveryCodes)),
+		challenges:     make(map[uint]models.MFAChallenge, len(d.challenges)),
+		identities:     make(map[uint]models.Identity, len(d.identities)),
+		ssoLogins:      make(map[uint]models.SSOLogin, len(d.ssoLogins)),
+		webhooks:       make(map[uint]models.WebhookSubscription, len(d.webhooks)),
+		outbox:         make(map[uint]models.WebhookOutbox, len(d.outbox)),
+		events:         append([]RecordedEvent(nil), d.events...),
+		deactivated:    make(map[uint]bool, len(d.deactivated)),
+		lastID:         make(map[string]uint, len(d.lastID)),
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.questionnaires {
+		c.questionnaires[k] = v
+	}
+	for k, v := range d.questions {
+		c.questions[k] = v
+	}
+	for k, v := range d.translations {
+		c.translations[k] = v
+	}
+	for k, v := range d.questionTrans {
+		c.questionTrans[k] = v
+	}
+	for k, v := range d.submissions {
+		c.submissions[k] = v
+	}
+	for k, v := range d.answers {
+		c.answers[k] = v
+	}
+	for k, v := range d.resets {
+		c.resets[k] = v
+	}
+	for k, v := range d.verifications {
+		c.verifications[k] = v
+	}
+	for k, v := range d.recoveryCodes {
+		c.recoveryCodes[k] = v
+	}
+	for k, v := range d.challenges {
+		c.challenges[k] = v
+	}
+	for k, v := range d.identities {
+		c.identities[k] = v
+	}
+	for k, v := range d.ssoLogins {
+		c.ssoLogins[k] = v
+	}
+	for k, v := range d.webhooks {
+		c.webhooks[k] = v
+	}
+	for k, v := range d.outbox {
+		c.outbox[k] = v
+	}
+	for k, v := range d.deactivated {
+		c.deactivated[k] = v
+	}
+	for k, v := range d.lastID {
+		c.lastID[k] = v
+	}
+	return c
+}
+
+// MemoryStore 内存中的仓储实现，用于测试和不需要数据库的工具
+// 事务串行执行，失败时恢复到事务开始前的数据
+type MemoryStore struct {
+	mu   sync.Mutex
+	txMu sync.Mutex
+	data *memoryData
+
+	// Now 返回当前时间，测试时可替换
+	Now func() time.Time
+}
+
+// NewMemoryStore 创建空的内存仓储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: &memoryData{
+			users:          make(map[uint]models.User),
+			questionnaires: make(map[uint]models.Questionnaire),
+			questions:      make(map[uint]models.Question),
+			translations:   make(map[uint]models.QuestionnaireTranslation),
+			questionTrans:  make(map[uint]models.QuestionTranslation),
+			submissions:    make(map[uint]models.Submission),
+			answers:        make(map[uint]models.Answer),
+			resets:         make(map[uint]models.PasswordResetToken),
+			verifications:  make(map[uint]models.EmailVerificationToken),
+			recoveryCodes:  make(map[uint]models.RecoveryCode),
+			challenges:     make(map[uint]models.MFAChallenge),
+			identities:     make(map[uint]models.Identity),
+			ssoLogins:      make(map[uint]models.SSOLogin),
+			webhooks:       make(map[uint]models.WebhookSubscription),
+			outbox:         make(map[uint]models.WebhookOutbox),
+			deactivated:    make(map[uint]bool),
+			lastID:         make(map[string]uint),
+		},
+		Now: time.Now,
+	}
+}
+
+// Users 用户仓储
+func (s *MemoryStore) Users() UserRepository { return memoryUsers{s} }
+
+// Questionnaires 问卷仓储
+func (s *MemoryStore) Questionnaires() QuestionnaireRepository { return memoryQuestionnaires{s} }
+
+// Submissions 提交记录仓储
+func (s *MemoryStore) Submissions() SubmissionRepository { return memorySubmissions{s} }
+
+// Events 事件仓储
+func (s *MemoryStore) Events() EventRepository { return memoryEvents{s} }
+
+// Webhooks Webhook订阅和投递记录仓储
+func (s *MemoryStore) Webhooks() WebhookRepository { return memoryWebhooks{s} }
+
+// PasswordResets 密码重置令牌仓储
+func (s *MemoryStore) PasswordResets() PasswordResetRepository { return memoryPasswordResets{s} }
+
+// EmailVerifications 邮箱验证令牌仓储
+func (s *MemoryStore) EmailVerifications() EmailVerificationRepository {
+	return memoryEmailVerifications{s}
+}
+
+// MFA 两步验证仓储
+func (s *MemoryStore) MFA() MFARepository { return memoryMFA{s} }
+
+// SSO 单点登录仓储
+func (s *MemoryStore) SSO() SSORepository { return memorySSO{s} }
+
+// Transaction 串行执行fn，返回错误时回滚
+func (s *MemoryStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	snapshot := s.data.clone()
+	s.mu.Unlock()
+
+	if err := fn(s); err != nil {
+		s.mu.Lock()
+		s.data = snapshot
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RecordedEvents 已写入的事件（按写入顺序）
+func (s *MemoryStore) RecordedEvents() []RecordedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedEvent(nil), s.data.events...)
+}
+
+// SubscriptionsDeactivated 问卷的Webhook订阅是否已被停用
+func (s *MemoryStore) SubscriptionsDeactivated(questionnaireID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.deactivated[questionnaireID]
+}
+
+// nextID 按表分配自增ID（与数据库一致），调用方需持有锁
+func (s *MemoryStore) nextID(table string) uint {
+	s.data.lastID[table]++
+	return s.data.lastID[table]
+}
+
+func (s *MemoryStore) stamp(created, updated *time.Time) {
+	now := s.Now()
+	if created != nil && created.IsZero() {
+		*created = now
+	}
+	if updated != nil {
+		*updated = now
+	}
+}
+
+// window 对已排序的结果分页，id返回记录的ID，desc表示按ID倒序
+func window[T any](items []T, page Page, id func(T) uint, desc bool) []T {
+	if page.After != nil {
+		start := len(items)
+		for i, item := range items {
+			if (desc && id(item) < page.After.ID) || (!desc && id(item) > page.After.ID) {
+				start = i
+				break
+			}
+		}
+		items = items[start:]
+	} else if page.Offset >= len(items) {
+		return nil
+	} else {
+		items = items[page.Offset:]
+	}
+	if page.Limit > 0 && page.Limit < len(items) {
+		items = items[:page.Limit]
+	}
+	return items
+}
+
+// 用户
+
+type memoryUsers struct{ s *MemoryStore }
+
+func (r memoryUsers) Get(ctx context.Context, id uint) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	user, ok := r.s.data.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r memoryUsers) find(match func(models.User) bool) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.data.users {
+		if match(user) {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(func(u models.User) bool { return u.Username == username })
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(func(u models.User) bool { return u.Email == email })
+}
+
+func (r memoryUsers) GetMany(ctx context.Context, ids []uint) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var users []models.User
+	for _, id := range ids {
+		if user, ok := r.s.data.users[id]; ok {
+			user.Password = ""
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r memoryUsers) List(ctx context.Context, page Page) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	users := make([]models.User, 0, len(r.s.data.users))
+	for _, user := range r.s.data.users {
+		user.Password = ""
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID > users[j].ID })
+	return window(users, page, func(u models.User) uint { return u.ID }, true), nil
+}
+
+func (r memoryUsers) Count(ctx context.Context, adminsOnly bool) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, user := range r.s.data.users {
+		if !adminsOnly || user.IsAdmin {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// conflicts 检查用户名和邮箱的唯一约束，调用方需持有锁
+func (r memoryUsers) conflicts(user *models.User) bool {
+	for id, existing := range r.s.data.users {
+		if id != user.ID && (existing.Username == user.Username || existing.Email == user.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.conflicts(user) {
+		return ErrDuplicate
+	}
+	user.ID = r.s.nextID("users")
+	r.s.stamp(&user.CreatedAt, &user.UpdatedAt)
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUsers) Save(ctx context.Context, user *models.User) error {
+	if user.ID == 0 {
+		return r.Create(ctx, user)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.conflicts(user) {
+		return ErrDuplicate
+	}
+	r.s.stamp(&user.CreatedAt, &user.UpdatedAt)
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUsers) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.users, id)
+	return nil
+}
+
+// update 修改用户的登录失败状态或密码，调用方需持有锁
+func (r memoryUsers) update(id uint, fn func(user *models.User)) error {
+	user, ok := r.s.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&user)
+	r.s.data.users[id] = user
+	return nil
+}
+
+func (r memoryUsers) RecordLoginFailure(ctx context.Context, id uint) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var failures int
+	err := r.update(id, func(user *models.User) {
+		user.FailedLogins++
+		failures = user.FailedLogins
+	})
+	return failures, err
+}
+
+func (r memoryUsers) Lock(ctx context.Context, id uint, until time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.update(id, func(user *models.User) { user.LockedUntil = &until })
+}
+
+func (r memoryUsers) ResetLoginFailures(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.update(id, func(user *models.User) {
+		user.FailedLogins = 0
+		user.LockedUntil = nil
+	})
+}
+
+func (r memoryUsers) SetPassword(ctx context.Context, id uint, hash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.update(id, func(user *models.User) { user.Password = hash })
+}
+
+func (r memoryUsers) SetTOTP(ctx context.Context, id uint, secret string, enabledAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.update(id, func(user *models.User) {
+		user.TOTPSecret = secret
+		user.TOTPEnabledAt = enabledAt
+		user.TOTPLastCounter = 0
+	})
+}
+
+func (r memoryUsers) UseTOTPCounter(ctx context.Context, id uint, counter int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	user, ok := r.s.data.users[id]
+	if !ok || user.TOTPLastCounter >= counter {
+		return ErrNotFound
+	}
+	user.TOTPLastCounter = counter
+	r.s.data.users[id] = user
+	return nil
+}
+
+// 问卷
+
+type memoryQuestionnaires struct{ s *MemoryStore }
+
+func (r memoryQuestionnaires) Get(ctx context.Context, id uint) (*models.Questionnaire, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	questionnaire, ok := r.s.data.questionnaires[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &questionnaire, nil
+}
+
+func (r memoryQuestionnaires) matching(filter QuestionnaireFilter) []models.Questionnaire {
+	var result []models.Questionnaire
+	for _, q := range r.s.data.questionnaires {
+		if filter.VisibleTo > 0 && !q.IsPublished && q.CreatedBy != filter.VisibleTo {
+			continue
+		}
+		if filter.CreatedBy > 0 && q.CreatedBy != filter.CreatedBy {
+			continue
+		}
+		if filter.Published != nil && q.IsPublished != *filter.Published {
+			continue
+		}
+		if !filter.OpenAt.IsZero() && (!q.IsPublished || filter.OpenAt.Before(q.StartTime) ||
+			(!q.EndTime.IsZero() && filter.OpenAt.After(q.EndTime))) {
+			continue
+		}
+		result = append(result, q)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+	return result
+}
+
+func (r memoryQuestionnaires) List(ctx context.Context, filter QuestionnaireFilter, page Page) ([]models.Questionnaire, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return window(r.matching(filter), page, func(q models.Questionnaire) uint { return q.ID }, true), nil
+}
+
+func (r memoryQuestionnaires) Count(ctx context.Context, filter QuestionnaireFilter) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return int64(len(r.matching(filter))), nil
+}
+
+func (r memoryQuestionnaires) Create(ctx context.Context, questionnaire *models.Questionnaire) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	questionnaire.ID = r.s.nextID("questionnaires")
+	r.s.stamp(&questionnaire.CreatedAt, &questionnaire.UpdatedAt)
+	r.s.data.questionnaires[questionnaire.ID] = *questionnaire
+	return nil
+}
+
+func (r memoryQuestionnaires) Save(ctx context.Context, questionnaire *models.Questionnaire) error {
+	if questionnaire.ID == 0 {
+		return r.Create(ctx, questionnaire)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.stamp(&questionnaire.CreatedAt, &questionnaire.UpdatedAt)
+	r.s.data.questionnaires[questionnaire.ID] = *questionnaire
+	return nil
+}
+
+func (r memoryQuestionnaires) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.questionnaires, id)
+	return nil
+}
+
+func (r memoryQuestionnaires) DeleteByCreator(ctx context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, q := range r.s.data.questionnaires {
+		if q.CreatedBy == userID {
+			delete(r.s.data.questionnaires, id)
+		}
+	}
+	return nil
+}
+
+func (r memoryQuestionnaires) Questions(ctx context.Context, questionnaireID uint) ([]models.Question, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var questions []models.Question
+	for _, q := range r.s.data.questions {
+		if q.QuestionnaireID == questionnaireID {
+			questions = append(questions, q)
+		}
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		if questions[i].Sort != questions[j].Sort {
+			return questions[i].Sort < questions[j].Sort
+		}
+		return questions[i].ID < questions[j].ID
+	})
+	return questions, nil
+}
+
+func (r memoryQuestionnaires) CreateQuestion(ctx context.Context, question *models.Question) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	question.ID = r.s.nextID("questions")
+	r.s.stamp(&question.CreatedAt, &question.UpdatedAt)
+	r.s.data.questions[question.ID] = *question
+	return nil
+}
+
+func (r memoryQuestionnaires) DeleteQuestions(ctx context.Context, questionnaireID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, q := range r.s.data.questions {
+		if q.QuestionnaireID == questionnaireID {
+			delete(r.s.data.questions, id)
+		}
+	}
+	return nil
+}
+
+func (r memoryQuestionnaires) CountQuestions(ctx context.Context, questionnaireID uint) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, q := range r.s.data.questions {
+		if questionnaireID == 0 || q.QuestionnaireID == questionnaireID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryQuestionnaires) CountQuestionsByQuestionnaire(ctx context.Context, questionnaireIDs []uint) (map[uint]int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	counts := make(map[uint]int64, len(questionnaireIDs))
+	wanted := idSet(questionnaireIDs)
+	for _, q := range r.s.data.questions {
+		if wanted[q.QuestionnaireID] {
+			counts[q.QuestionnaireID]++
+		}
+	}
+	return counts, nil
+}
+
+func (r memoryQuestionnaires) Translations(ctx context.Context, questionnaireID uint) ([]models.QuestionnaireTranslation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var translations []models.QuestionnaireTranslation
+	for _, t := range r.s.data.translations {
+		if t.QuestionnaireID == questionnaireID {
+			translations = append(translations, t)
+		}
+	}
+	sort.Slice(translations, func(i, j int) bool { return translations[i].Locale < translations[j].Locale })
+	return translations, nil
+}
+
+func (r memoryQuestionnaires) QuestionTranslations(ctx context.Context, questionnaireID uint) ([]models.QuestionTranslation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var translations []models.QuestionTranslation
+	for _, t := range r.s.data.questionTrans {
+		if t.QuestionnaireID == questionnaireID {
+			translations = append(translations, t)
+		}
+	}
+	sort.Slice(translations, func(i, j int) bool {
+		if translations[i].QuestionID != translations[j].QuestionID {
+			return translations[i].QuestionID < translations[j].QuestionID
+		}
+		return translations[i].Locale < translations[j].Locale
+	})
+	return translations, nil
+}
+
+func (r memoryQuestionnaires) CreateTranslation(ctx context.Context, translation *models.QuestionnaireTranslation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.data.translations {
+		if t.QuestionnaireID == translation.QuestionnaireID && t.Locale == translation.Locale {
+			return ErrDuplicate
+		}
+	}
+	translation.ID = r.s.nextID("questionnaire_translations")
+	r.s.data.translations[translation.ID] = *translation
+	return nil
+}
+
+func (r memoryQuestionnaires) CreateQuestionTranslation(ctx context.Context, translation *models.QuestionTranslation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.data.questionTrans {
+		if t.QuestionID == translation.QuestionID && t.Locale == translation.Locale {
+			return ErrDuplicate
+		}
+	}
+	translation.ID = r.s.nextID("question_translations")
+	r.s.data.questionTrans[translation.ID] = *translation
+	return nil
+}
+
+func (r memoryQuestionnaires) DeleteTranslations(ctx context.Context, questionnaireID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, t := range r.s.data.translations {
+		if t.QuestionnaireID == questionnaireID {
+			delete(r.s.data.translations, id)
+		}
+	}
+	for id, t := range r.s.data.questionTrans {
+		if t.QuestionnaireID == questionnaireID {
+			delete(r.s.data.questionTrans, id)
+		}
+	}
+	return nil
+}
+
+func idSet(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// 提交记录
+
+type memorySubmissions struct{ s *MemoryStore }
+
+func (r memorySubmissions) Find(ctx context.Context, questionnaireID, userID uint) (*models.Submission, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, sub := range r.s.data.submissions {
+		if sub.QuestionnaireID == questionnaireID && sub.UserID == userID {
+			return &sub, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memorySubmissions) matching(filter SubmissionFilter) []models.Submission {
+	var result []models.Submission
+	for _, sub := range r.s.data.submissions {
+		if filter.QuestionnaireID > 0 && sub.QuestionnaireID != filter.QuestionnaireID {
+			continue
+		}
+		if filter.UserID > 0 && sub.UserID != filter.UserID {
+			continue
+		}
+		if !filter.Since.IsZero() && !sub.SubmittedAt.After(filter.Since) {
+			continue
+		}
+		result = append(result, sub)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if filter.NewestFirst {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (r memorySubmissions) List(ctx context.Context, filter SubmissionFilter, page Page) ([]models.Submission, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return window(r.matching(filter), page, func(s models.Submission) uint { return s.ID }, filter.NewestFirst), nil
+}
+
+func (r memorySubmissions) Count(ctx context.Context, filter SubmissionFilter) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return int64(len(r.matching(filter))), nil
+}
+
+func (r memorySubmissions) CountByQuestionnaire(ctx context.Context, questionnaireIDs []uint) (map[uint]int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	counts := make(map[uint]int64, len(questionnaireIDs))
+	wanted := idSet(questionnaireIDs)
+	for _, sub := range r.s.data.submissions {
+		if wanted[sub.QuestionnaireID] {
+			counts[sub.QuestionnaireID]++
+		}
+	}
+	return counts, nil
+}
+
+func (r memorySubmissions) Create(ctx context.Context, submission *models.Submission) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	submission.ID = r.s.nextID("submissions")
+	r.s.stamp(&submission.SubmittedAt, nil)
+	r.s.data.submissions[submission.ID] = *submission
+	return nil
+}
+
+func (r memorySubmissions) deleteWhere(match func(models.Submission) bool) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, sub := range r.s.data.submissions {
+		if match(sub) {
+			delete(r.s.data.submissions, id)
+		}
+	}
+}
+
+func (r memorySubmissions) DeleteByQuestionnaire(ctx context.Context, questionnaireID uint) error {
+	r.deleteWhere(func(s models.Submission) bool { return s.QuestionnaireID == questionnaireID })
+	return nil
+}
+
+func (r memorySubmissions) DeleteByUser(ctx context.Context, userID uint) error {
+	r.deleteWhere(func(s models.Submission) bool { return s.UserID == userID })
+	return nil
+}
+
+func (r memorySubmissions) CreateAnswer(ctx context.Context, answer *models.Answer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	answer.ID = r.s.nextID("answers")
+	r.s.stamp(&answer.CreatedAt, nil)
+	r.s.data.answers[answer.ID] = *answer
+	return nil
+}
+
+// belongsTo 答案的问题是否属于问卷，调用方需持有锁
+func (r memorySubmissions) belongsTo(answer models.Answer, questionnaireID uint) bool {
+	question, ok := r.s.data.questions[answer.QuestionID]
+	return ok && question.QuestionnaireID == questionnaireID
+}
+
+func (r memorySubmissions) Answers(ctx context.Context, questionnaireID, userID uint) ([]models.Answer, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var answers []models.Answer
+	for _, answer := range r.s.data.answers {
+		if answer.UserID == userID && r.belongsTo(answer, questionnaireID) {
+			answers = append(answers, answer)
+		}
+	}
+	sort.Slice(answers, func(i, j int) bool { return answers[i].ID < answers[j].ID })
+	return answers, nil
+}
+
+func (r memorySubmissions) AnswersByUsers(ctx context.Context, questionnaireID uint, userIDs []uint) ([]models.Answer, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	users := idSet(userIDs)
+	var answers []models.Answer
+	for _, answer := range r.s.data.answers {
+		if users[answer.UserID] && r.belongsTo(answer, questionnaireID) {
+			answers = append(answers, answer)
+		}
+	}
+	sort.Slice(answers, func(i, j int) bool { return answers[i].ID < answers[j].ID })
+	return answers, nil
+}
+
+func (r memorySubmissions) CountAnswers(ctx context.Context) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return int64(len(r.s.data.answers)), nil
+}
+
+func (r memorySubmissions) CountAnswersByContent(ctx context.Context, questionnaireID uint) ([]AnswerCount, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	submitted := make(map[uint]bool)
+	for _, sub := range r.s.data.submissions {
+		if sub.QuestionnaireID == questionnaireID {
+			submitted[sub.UserID] = true
+		}
+	}
+
+	type key struct {
+		questionID uint
+		content    string
+	}
+	totals := make(map[key]int64)
+	for _, answer := range r.s.data.answers {
+		if submitted[answer.UserID] && r.belongsTo(answer, questionnaireID) {
+			totals[key{answer.QuestionID, answer.Content}]++
+		}
+	}
+	counts := make([]AnswerCount, 0, len(totals))
+	for k, total := range totals {
+		counts = append(counts, AnswerCount{QuestionID: k.questionID, Content: k.content, Total: total})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].QuestionID != counts[j].QuestionID {
+			return counts[i].QuestionID < counts[j].QuestionID
+		}
+		return counts[i].Content < counts[j].Content
+	})
+	return counts, nil
+}
+
+func (r memorySubmissions) DeleteAnswersByQuestionnaire(ctx context.Context, questionnaireID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, answer := range r.s.data.answers {
+		if r.belongsTo(answer, questionnaireID) {
+			delete(r.s.data.answers, id)
+		}
+	}
+	return nil
+}
+
+func (r memorySubmissions) DeleteAnswersByUser(ctx context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, answer := range r.s.data.answers {
+		if answer.UserID == userID {
+			delete(r.s.data.answers, id)
+		}
+	}
+	return nil
+}
+
+// 事件
+
+type memoryEvents struct{ s *MemoryStore }
+
+// Enqueue 记录事件，并像GORM仓储一样为匹配的订阅写入发件箱记录
+func (r memoryEvents) Enqueue(ctx context.Context, questionnaireID uint, event string, data interface{}) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.data.events = append(r.s.data.events, RecordedEvent{
+		QuestionnaireID: questionnaireID,
+		Event:           event,
+		Data:            data,
+	})
+
+	var body []byte
+	now := r.s.Now()
+	for _, sub := range r.s.data.webhooks {
+		if !sub.IsActive || (sub.QuestionnaireID != nil && *sub.QuestionnaireID != questionnaireID) || !sub.Subscribes(event) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = webhook.MarshalPayload(questionnaireID, event, data, now); err != nil {
+				return err
+			}
+		}
+		entry := models.WebhookOutbox{
+			ID:             r.s.nextID("webhook_outbox"),
+			SubscriptionID: sub.ID,
+			EventType:      event,
+			Payload:        string(body),
+			Status:         models.WebhookStatusPending,
+			NextAttemptAt:  now,
+		}
+		r.s.stamp(&entry.CreatedAt, &entry.UpdatedAt)
+		r.s.data.outbox[entry.ID] = entry
+	}
+	return nil
+}
+
+func (r memoryEvents) DeactivateSubscriptions(ctx context.Context, questionnaireID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.data.deactivated[questionnaireID] = true
+	for id, sub := range r.s.data.webhooks {
+		if sub.QuestionnaireID != nil && *sub.QuestionnaireID == questionnaireID {
+			sub.IsActive = false
+			r.s.stamp(nil, &sub.UpdatedAt)
+			r.s.data.webhooks[id] = sub
+		}
+	}
+	return nil
+}
+
+// Webhook
+
+type memoryWebhooks struct{ s *MemoryStore }
+
+func (r memoryWebhooks) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	subscription.ID = r.s.nextID("webhook_subscriptions")
+	r.s.stamp(&subscription.CreatedAt, &subscription.UpdatedAt)
+	r.s.data.webhooks[subscription.ID] = *subscription
+	return nil
+}
+
+func (r memoryWebhooks) GetSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	subscription, ok := r.s.data.webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &subscription, nil
+}
+
+// subscriptions 按ID倒序返回问卷（nil为全局）的订阅，调用方需持有锁
+func (r memoryWebhooks) subscriptions(questionnaireID *uint) []models.WebhookSubscription {
+	var result []models.WebhookSubscription
+	for _, sub := range r.s.data.webhooks {
+		if questionnaireID == nil && sub.QuestionnaireID == nil ||
+			questionnaireID != nil && sub.QuestionnaireID != nil && *sub.QuestionnaireID == *questionnaireID {
+			result = append(result, sub)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+	return result
+}
+
+func (r memoryWebhooks) ListSubscriptions(ctx context.Context, questionnaireID *uint, page Page) ([]models.WebhookSubscription, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return window(r.subscriptions(questionnaireID), page, func(s models.WebhookSubscription) uint { return s.ID }, true), nil
+}
+
+func (r memoryWebhooks) CountSubscriptions(ctx context.Context, questionnaireID *uint) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return int64(len(r.subscriptions(questionnaireID))), nil
+}
+
+func (r memoryWebhooks) DeactivateSubscription(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sub, ok := r.s.data.webhooks[id]; ok {
+		sub.IsActive = false
+		r.s.stamp(nil, &sub.UpdatedAt)
+		r.s.data.webhooks[id] = sub
+	}
+	return nil
+}
+
+func (r memoryWebhooks) GetDelivery(ctx context.Context, id uint) (*models.WebhookOutbox, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delivery, ok := r.s.data.outbox[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &delivery, nil
+}
+
+// deliveries 按ID倒序返回订阅的发件箱记录，调用方需持有锁
+func (r memoryWebhooks) deliveries(subscriptionID uint) []models.WebhookOutbox {
+	var result []models.WebhookOutbox
+	for _, entry := range r.s.data.outbox {
+		if entry.SubscriptionID == subscriptionID {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+	return result
+}
+
+func (r memoryWebhooks) ListDeliveries(ctx context.Context, subscriptionID uint, page Page) ([]models.WebhookOutbox, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return window(r.deliveries(subscriptionID), page, func(e models.WebhookOutbox) uint { return e.ID }, true), nil
+}
+
+func (r memoryWebhooks) CountDeliveries(ctx context.Context, subscriptionID uint) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return int64(len(r.deliveries(subscriptionID))), nil
+}
+
+// Attempts 内存仓储不运行投递器，没有投递尝试
+func (r memoryWebhooks) Attempts(ctx context.Context, outboxIDs []uint) ([]models.WebhookDeliveryAttempt, error) {
+	return []models.WebhookDeliveryAttempt{}, nil
+}
+
+func (r memoryWebhooks) Redeliver(ctx context.Context, outboxID uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	entry, ok := r.s.data.outbox[outboxID]
+	if !ok || entry.Status == models.WebhookStatusProcessing {
+		return ErrNotFound
+	}
+	entry.Status = models.WebhookStatusPending
+	entry.Attempts = 0
+	entry.NextAttemptAt = at
+	entry.LockedUntil = nil
+	r.s.stamp(nil, &entry.UpdatedAt)
+	r.s.data.outbox[outboxID] = entry
+	return nil
+}
+
+// 密码重置令牌
+
+type memoryPasswordResets struct{ s *MemoryStore }
+
+func (r memoryPasswordResets) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.data.resets {
+		if existing.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	token.ID = r.s.nextID("password_reset_tokens")
+	r.s.stamp(&token.CreatedAt, nil)
+	r.s.data.resets[token.ID] = *token
+	return nil
+}
+
+func (r memoryPasswordResets) GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, token := range r.s.data.resets {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryPasswordResets) MarkUsed(ctx context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	token, ok := r.s.data.resets[id]
+	if !ok || token.UsedAt != nil {
+		return ErrNotFound
+	}
+	token.UsedAt = &at
+	r.s.data.resets[id] = token
+	return nil
+}
+
+func (r memoryPasswordResets) DeleteByUser(ctx context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, token := range r.s.data.resets {
+		if token.UserID == userID {
+			delete(r.s.data.resets, id)
+		}
+	}
+	return nil
+}
+
+// 邮箱验证令牌
+
+type memoryEmailVerifications struct{ s *MemoryStore }
+
+func (r memoryEmailVerifications) Create(ctx context.Context, token *models.EmailVerificationToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.data.verifications {
+		if existing.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	token.ID = r.s.nextID("email_verification_tokens")
+	r.s.stamp(&token.CreatedAt, nil)
+	r.s.data.verifications[token.ID] = *token
+	return nil
+}
+
+func (r memoryEmailVerifications) GetByHash(ctx context.Context, tokenHash string) (*models.EmailVerificationToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, token := range r.s.data.verifications {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryEmailVerifications) Latest(ctx context.Context, userID uint) (*models.EmailVerificationToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var latest *models.EmailVerificationToken
+	for _, token := range r.s.data.verifications {
+		if token.UserID == userID && (latest == nil || token.ID > latest.ID) {
+			token := token
+			latest = &token
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+func (r memoryEmailVerifications) MarkUsed(ctx context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	token, ok := r.s.data.verifications[id]
+	if !ok || token.UsedAt != nil {
+		return ErrNotFound
+	}
+	token.UsedAt = &at
+	r.s.data.verifications[id] = token
+	return nil
+}
+
+func (r memoryEmailVerifications) DeleteByUser(ctx context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, token := range r.s.data.verifications {
+		if token.UserID == userID {
+			delete(r.s.data.verifications, id)
+		}
+	}
+	return nil
+}
+
+// 两步验证
+
+type memoryMFA struct{ s *MemoryStore }
+
+func (r memoryMFA) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []models.RecoveryCode) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, code := range r.s.data.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.s.data.recoveryCodes, id)
+		}
+	}
+	for i := range codes {
+		codes[i].ID = r.s.nextID("recovery_codes")
+		r.s.stamp(&codes[i].CreatedAt, nil)
+		r.s.data.recoveryCodes[codes[i].ID] = codes[i]
+	}
+	return nil
+}
+
+func (r memoryMFA) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, code := range r.s.data.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			code.UsedAt = &at
+			r.s.data.recoveryCodes[id] = code
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r memoryMFA) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, code := range r.s.data.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryMFA) CreateChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.data.challenges {
+		if existing.TokenHash == challenge.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	challenge.ID = r.s.nextID("mfa_challenges")
+	r.s.stamp(&challenge.CreatedAt, nil)
+	r.s.data.challenges[challenge.ID] = *challenge
+	return nil
+}
+
+func (r memoryMFA) GetChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, challenge := range r.s.data.challenges {
+		if challenge.TokenHash == tokenHash {
+			return &challenge, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryMFA) RecordChallengeFailure(ctx context.Context, id uint) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	challenge, ok := r.s.data.challenges[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	challenge.Failures++
+	r.s.data.challenges[id] = challenge
+	return challenge.Failures, nil
+}
+
+func (r memoryMFA) MarkChallengeUsed(ctx context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	challenge, ok := r.s.data.challenges[id]
+	if !ok || challenge.UsedAt != nil {
+		return ErrNotFound
+	}
+	challenge.UsedAt = &at
+	r.s.data.challenges[id] = challenge
+	return nil
+}
+
+func (r memoryMFA) DeleteByUser(ctx context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, code := range r.s.data.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.s.data.recoveryCodes, id)
+		}
+	}
+	for id, challenge := range r.s.data.challenges {
+		if challenge.UserID == userID {
+			delete(r.s.data.challenges, id)
+		}
+	}
+	return nil
+}
+
+// 单点登录
+
+type memorySSO struct{ s *MemoryStore }
+
+func (r memorySSO) CreateLogin(ctx context.Context, login *models.SSOLogin) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.data.ssoLogins {
+		if existing.StateHash == login.StateHash {
+			return ErrDuplicate
+		}
+	}
+	login.ID = r.s.nextID("sso_logins")
+	r.s.stamp(&login.CreatedAt, nil)
+	r.s.data.ssoLogins[login.ID] = *login
+	return nil
+}
+
+func (r memorySSO) TakeLogin(ctx context.Context, stateHash string) (*models.SSOLogin, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, login := range r.s.data.ssoLogins {
+		if login.StateHash == stateHash {
+			delete(r.s.data.ssoLogins, id)
+			return &login, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memorySSO) DeleteExpiredLogins(ctx context.Context, before time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, login := range r.s.data.ssoLogins {
+		if login.ExpiresAt.Before(before) {
+			delete(r.s.data.ssoLogins, id)
+		}
+	}
+	return nil
+}
+
+func (r memorySSO) GetIdentity(ctx context.Context, issuer, subject string) (*models.Identity, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, identity := range r.s.data.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memorySSO) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.data.identities {
+		if existing.Issuer == identity.Issuer && existing.Subject == identity.Subject {
+			return ErrDuplicate
+		}
+	}
+	identity.ID = r.s.nextID("identities")
+	r.s.stamp(&identity.CreatedAt, &identity.UpdatedAt)
+	r.s.data.identities[identity.ID] = *identity
+	return nil
+}
+
+func (r memorySSO) RecordIdentityLogin(ctx context.Context, id uint, email string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	identity, ok := r.s.data.identities[id]
+	if !ok {
+		return nil
+	}
+	identity.Email, identity.LastLoginAt = email, &at
+	r.s.stamp(nil, &identity.UpdatedAt)
+	r.s.data.identities[id] = identity
+	return nil
+}
+
+func (r memorySSO) DeleteByUser(ctx context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, identity := range r.s.data.identities {
+		if identity.UserID == userID {
+			delete(r.s.data.identities, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/pagination"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("记录不存在")
+
+// ErrDuplicate 违反唯一约束
+var ErrDuplicate = errors.New("记录已存在")
+
+// Page 分页参数，Limit为0表示不限制
+// 列表均按ID排序，After不为nil时从该ID之后继续（键集分页）并忽略Offset
+type Page struct {
+	Offset int
+	Limit  int
+	After  *pagination.Cursor
+}
+
+// PageFor 将接口的分页参数转换为查询参数，多查询一条用于判断是否还有下一页
+func PageFor(p pagination.Params) Page {
+	return Page{Offset: p.Offset(), Limit: p.Limit + 1, After: p.After}
+}
+
+// QuestionnaireFilter 问卷查询条件
+type QuestionnaireFilter struct {
+	VisibleTo uint  // 大于0时只返回已发布或由该用户创建的问卷
+	CreatedBy uint  // 大于0时只返回该用户创建的问卷
+	Published *bool // 不为nil时按发布状态过滤
+	// OpenAt 不为零时只返回该时刻可以填写的问卷：已发布，且在开始和结束时间之间（未设置的一端不限制）
+	OpenAt time.Time
+}
+
+// SubmissionFilter 提交记录查询条件
+type SubmissionFilter struct {
+	QuestionnaireID uint
+	UserID          uint
+	Since           time.Time // 不为零时只统计该时间之后的提交
+	NewestFirst     bool      // 按提交时间（ID）倒序
+}
+
+// AnswerCount 问题的一种答案内容及其作答次数
+type AnswerCount struct {
+	QuestionID uint
+	Content    string
+	Total      int64
+}
+
+// UserRepository 用户数据访问
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetMany 批量获取用户（不含密码），不存在的ID被忽略
+	GetMany(ctx context.Context, ids []uint) ([]models.User, error)
+	// List 按ID倒序返回用户（不含密码）
+	List(ctx context.Context, page Page) ([]models.User, error)
+	Count(ctx context.Context, adminsOnly bool) (int64, error)
+	Create(ctx context.Context, user *models.User) error
+	Save(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id uint) error
+
+	// RecordLoginFailure 原子地增加用户的连续登录失败次数，返回增加后的次数
+	RecordLoginFailure(ctx context.Context, id uint) (int, error)
+	// Lock 锁定用户到until之前
+	Lock(ctx context.Context, id uint, until time.Time) error
+	// ResetLoginFailures 清零连续登录失败次数并解除锁定
+	ResetLoginFailures(ctx context.Context, id uint) error
+	// SetPassword 只修改密码哈希（登录时升级旧哈希），不更新updated_at
+	SetPassword(ctx context.Context, id uint, hash string) error
+	// SetTOTP 设置两步验证的密钥和启用时间，并清零已使用的时间步序号；secret为空时关闭两步验证
+	SetTOTP(ctx context.Context, id uint, secret string, enabledAt *time.Time) error
+	// UseTOTPCounter 记录已使用的验证码的时间步序号，序号不大于上次记录的序号时返回ErrNotFound，保证同一验证码只能使用一次
+	UseTOTPCounter(ctx context.Context, id uint, counter int64) error
+}
+
+// QuestionnaireRepository 问卷及问题数据访问
+type QuestionnaireRepository interface {
+	Get(ctx context.Context, id uint) (*models.Questionnaire, error)
+	// List 按创建时间（ID）倒序返回问卷
+	List(ctx context.Context, filter QuestionnaireFilter, page Page) ([]models.Questionnaire, error)
+	Count(ctx context.Context, filter QuestionnaireFilter) (int64, error)
+	Create(ctx context.Context, questionnaire *models.Questionnaire) error
+	Save(ctx context.Context, questionnaire *models.Questionnaire) error
+	Delete(ctx context.Context, id uint) error
+	DeleteByCreator(ctx context.Context, userID uint) error
+
+	// Questions 按排序返回问卷的问题
+	Questions(ctx context.Context, questionnaireID uint) ([]models.Question, error)
+	CreateQuestion(ctx context.Context, question *models.Question) error
+	DeleteQuestions(ctx context.Context, questionnaireID uint) error
+	// CountQuestions 统计问卷的问题数，questionnaireID为0时统计全部问题
+	CountQuestions(ctx context.Context, questionnaireID uint) (int64, error)
+	// CountQuestionsByQuestionnaire 批量统计问卷的问题数，没有问题的问卷不在结果中
+	CountQuestionsByQuestionnaire(ctx context.Context, questionnaireIDs []uint) (map[uint]int64, error)
+
+	// Translations 按语言顺序返回问卷的翻译
+	Translations(ctx context.Context, questionnaireID uint) ([]models.QuestionnaireTranslation, error)
+	// QuestionTranslations 按问题、语言顺序返回问卷中全部问题的翻译
+	QuestionTranslations(ctx context.Context, questionnaireID uint) ([]models.QuestionTranslation, error)
+	CreateTranslation(ctx context.Context, translation *models.QuestionnaireTranslation) error
+	CreateQuestionTranslation(ctx context.Context, translation *models.QuestionTranslation) error
+	// DeleteTranslations 删除问卷及其问题的全部翻译
+	DeleteTranslations(ctx context.Context, questionnaireID uint) error
+}
+
+// SubmissionRepository 提交记录及答案数据访问
+type SubmissionRepository interface {
+	Find(ctx context.Context, questionnaireID, userID uint) (*models.Submission, error)
+	// List 按ID顺序返回提交记录，NewestFirst时倒序
+	List(ctx context.Context, filter SubmissionFilter, page Page) ([]models.Submission, error)
+	Count(ctx context.Context, filter SubmissionFilter) (int64, error)
+	// CountByQuestionnaire 批量统计问卷的提交数，没有提交的问卷不在结果中
+	CountByQuestionnaire(ctx context.Context, questionnaireIDs []uint) (map[uint]int64, error)
+	Create(ctx context.Context, submission *models.Submission) error
+	DeleteByQuestionnaire(ctx context.Context, questionnaireID uint) error
+	DeleteByUser(ctx context.Context, userID uint) error
+
+	CreateAnswer(ctx context.Context, answer *models.Answer) error
+	// Answers 返回用户对问卷中问题的答案
+	Answers(ctx context.Context, questionnaireID, userID uint) ([]models.Answer, error)
+	// AnswersByUsers 按ID顺序返回一批用户对问卷中问题的答案
+	AnswersByUsers(ctx context.Context, questionnaireID uint, userIDs []uint) ([]models.Answer, error)
+	CountAnswers(ctx context.Context) (int64, error)
+	// CountAnswersByContent 按问题、答案内容分组统计已提交用户对问卷的答案
+	CountAnswersByContent(ctx context.Context, questionnaireID uint) ([]AnswerCount, error)
+	// DeleteAnswersByQuestionnaire 删除问卷所有问题的答案，需在删除问题之前调用
+	DeleteAnswersByQuestionnaire(ctx context.Context, questionnaireID uint) error
+	DeleteAnswersByUser(ctx context.Context, userID uint) error
+}
+
+// PasswordResetRepository 密码重置令牌数据访问
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	// MarkUsed 将未使用的令牌标记为已使用，令牌已被使用时返回ErrNotFound，保证只能使用一次
+	MarkUsed(ctx context.Context, id uint, at time.Time) error
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// EmailVerificationRepository 邮箱验证令牌数据访问
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, token *models.EmailVerificationToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.EmailVerificationToken, error)
+	// Latest 用户最近生成的令牌，没有时返回ErrNotFound
+	Latest(ctx context.Context, userID uint) (*models.EmailVerificationToken, error)
+	// MarkUsed 将未使用的令牌标记为已使用，令牌已被使用时返回ErrNotFound，保证只能使用一次
+	MarkUsed(ctx context.Context, id uint, at time.Time) error
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// MFARepository 两步验证的恢复码和登录挑战数据访问
+type MFARepository interface {
+	// ReplaceRecoveryCodes 删除用户原有的恢复码，保存新生成的恢复码
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []models.RecoveryCode) error
+	// UseRecoveryCode 将用户未使用的恢复码标记为已使用，不存在或已被使用时返回ErrNotFound
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string, at time.Time) error
+	// CountRecoveryCodes 统计用户未使用的恢复码
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+
+	CreateChallenge(ctx context.Context, challenge *models.MFAChallenge) error
+	GetChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error)
+	// RecordChallengeFailure 原子地增加登录挑战输错验证码的次数，返回增加后的次数
+	RecordChallengeFailure(ctx context.Context, id uint) (int, error)
+	// MarkChallengeUsed 将未使用的登录挑战标记为已使用，已被使用时返回ErrNotFound，保证只能使用一次
+	MarkChallengeUsed(ctx context.Context, id uint, at time.Time) error
+
+	// DeleteByUser 删除用户的全部恢复码和登录挑战
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// SSORepository 单点登录的外部身份和等待回调的登录
+type SSORepository interface {
+	CreateLogin(ctx context.Context, login *models.SSOLogin) error
+	// TakeLogin 取出并删除state对应的登录，不存在或已被取出时返回ErrNotFound，保证只能使用一次
+	TakeLogin(ctx context.Context, stateHash string) (*models.SSOLogin, error)
+	// DeleteExpiredLogins 删除在before之前过期、没有完成的登录
+	DeleteExpiredLogins(ctx context.Context, before time.Time) error
+
+	// GetIdentity 按发行方和subject查找外部身份，不存在时返回ErrNotFound
+	GetIdentity(ctx context.Context, issuer, subject string) (*models.Identity, error)
+	// CreateIdentity 发行方和subject重复时返回ErrDuplicate
+	CreateIdentity(ctx context.Context, identity *models.Identity) error
+	// RecordIdentityLogin 记录登录时间和身份提供方返回的邮箱
+	RecordIdentityLogin(ctx context.Context, id uint, email string, at time.Time) error
+
+	// DeleteByUser 删除用户的全部外部身份
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// EventRepository 领域事件（Webhook发件箱）
+type EventRepository interface {
+	// Enqueue 为订阅了事件的Webhook写入发件箱记录
+	Enqueue(ctx context.Context, questionnaireID uint, event string, data interface{}) error
+	// DeactivateSubscriptions 停用问卷的全部Webhook订阅
+	DeactivateSubscriptions(ctx context.Context, questionnaireID uint) error
+}
+
+// WebhookRepository Webhook订阅、发件箱记录及投递尝试
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error)
+	// ListSubscriptions 按ID倒序返回问卷的订阅，questionnaireID为nil时返回全局订阅
+	ListSubscriptions(ctx context.Context, questionnaireID *uint, page Page) ([]models.WebhookSubscription, error)
+	CountSubscriptions(ctx context.Context, questionnaireID *uint) (int64, error)
+	// DeactivateSubscription 停用订阅，保留投递记录
+	DeactivateSubscription(ctx context.Context, id uint) error
+
+	GetDelivery(ctx context.Context, id uint) (*models.WebhookOutbox, error)
+	// ListDeliveries 按ID倒序返回订阅的发件箱记录
+	ListDeliveries(ctx context.Context, subscriptionID uint, page Page) ([]models.WebhookOutbox, error)
+	CountDeliveries(ctx context.Context, subscriptionID uint) (int64, error)
+	// Attempts 按记录、尝试次数顺序返回一批发件箱记录的投递尝试
+	Attempts(ctx context.Context, outboxIDs []uint) ([]models.WebhookDeliveryAttempt, error)
+	// Redeliver 重置尝试次数并在at时重新排队，记录正在投递中时返回ErrNotFound
+	Redeliver(ctx context.Context, outboxID uint, at time.Time) error
+}
+
+// Store 聚合所有仓储，并提供事务
+type Store interface {
+	Users() UserRepository
+	Questionnaires() QuestionnaireRepository
+	Submissions() SubmissionRepository
+	Events() EventRepository
+	Webhooks() WebhookRepository
+	PasswordResets() PasswordResetRepository
+	EmailVerifications() EmailVerificationRepository
+	MFA() MFARepository
+	SSO() SSORepository
+
+	// Transaction 在事务中执行fn，fn返回错误时回滚
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
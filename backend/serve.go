@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"questionnaire-system/backend/config"
+	"questionnaire-system/backend/database"
+	"questionnaire-system/backend/health"
+)
+
+// workers 随服务启动的后台任务，关闭时统一停止并等待退出
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+// Go 在后台运行fn，fn应在ctx取消后尽快返回
+func (w *workers) Go(fn func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.ctx)
+	}()
+}
+
+// Stop 通知所有后台任务停止并等待退出，超过ctx的期限时返回错误
+func (w *workers) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newHTTPServer 按配置创建带超时的HTTP服务器
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+	}
+}
+
+// serve 启动HTTP服务器，收到SIGINT或SIGTERM后优雅关闭：
+// 就绪检查先返回503，等待drain_delay让负载均衡摘除实例，然后停止接收新连接并等待处理中的请求完成，
+// 最后停止后台任务并关闭数据库连接。整个关闭过程不超过shutdown_timeout
+func serve(cfg config.ServerConfig, srv *http.Server, checker *health.Checker, bg *workers, db *database.Database) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		bg.Stop(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+	// 关闭期间再次收到信号时按默认行为直接退出
+	stop()
+
+	slog.Info("收到关闭信号，开始优雅关闭", "timeout", cfg.ShutdownTimeout.String())
+	checker.SetDraining()
+	if cfg.DrainDelay.Duration > 0 {
+		slog.Info("等待负载均衡摘除实例", "drain_delay", cfg.DrainDelay.String())
+		time.Sleep(cfg.DrainDelay.Duration)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("等待处理中的请求超时，强制关闭连接", "error", err)
+		srv.Close()
+		errs = append(errs, err)
+	} else {
+		slog.Info("HTTP服务器已停止")
+	}
+	if err := bg.Stop(shutdownCtx); err != nil {
+		slog.Warn("等待后台任务退出超时", "error", err)
+		errs = append(errs, err)
+	} else {
+		slog.Info("后台任务已停止")
+	}
+	if sqlDB, err := db.DB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		user := env.createUser(userOpts{Username: "plain"})
+
+		env.get("/api/admin/users").expectError(http.StatusUnauthorized, "未授权访问")
+		env.get("/api/admin/users", func(r *http.Request) { r.Header.Set("Authorization", "Basic abc") }).
+			expectError(http.StatusUnauthorized, "无效的授权格式")
+		env.get("/api/admin/users", bearer("token_root_20060102150405")).expectError(http.StatusUnauthorized, "无效的令牌")
+
+		// 令牌签发之后用户被删除
+		ghost := env.createUser(userOpts{Username: "ghost"})
+		asGhost := env.asUser(ghost.Username)
+		if err := env.store.Users().Delete(env.ctx(), ghost.ID); err != nil {
+			t.Fatal(err)
+		}
+		env.get("/api/admin/users", asGhost).expectError(http.StatusUnauthorized, "无效的用户")
+		env.get("/api/admin/users", env.asUser(user.Username)).expectError(http.StatusForbidden, "需要管理员权限")
+	})
+}
+
+func TestAdminUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createUser(userOpts{Username: "root", Admin: true})
+		user := env.createUser(userOpts{Username: "carol"})
+		q := env.createQuestionnaire(user, true)
+		env.submit(q, user)
+		as := env.asUser(admin.Username)
+
+		env.get("/api/admin/users?page=1", as).expect(http.StatusOK).assertGolden("admin_users")
+		env.get(fmt.Sprintf("/api/admin/user/detail?id=%d", user.ID), as).
+			expect(http.StatusOK).assertGolden("admin_user_detail")
+		env.get("/api/admin/user/detail?id=999", as).expectError(http.StatusNotFound, "用户不存在")
+
+		env.put("/api/admin/user/update", map[string]interface{}{
+			"id": user.ID, "email": "carol@new.example.com", "phone": "123", "is_admin": false,
+		}, as).expect(http.StatusOK).assertGolden("admin_user_update")
+
+		detail := env.get(fmt.Sprintf("/api/admin/user/detail?id=%d", user.ID), as)
+		if detail.path("data.user.email") != "carol@new.example.com" {
+			t.Fatalf("用户信息未更新: %s", detail.Body)
+		}
+
+		env.put("/api/admin/user/update", map[string]interface{}{"id": 999}, as).
+			expectError(http.StatusNotFound, "用户不存在")
+	})
+}
+
+func TestAdminDeleteUserCascades(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createAdmin()
+		doomed := env.createUser(userOpts{})
+		other := env.createUser(userOpts{})
+		as := env.asUser(admin.Username)
+
+		// doomed创建的问卷，以及doomed对other问卷的答卷
+		env.createQuestionnaire(doomed, true)
+		otherQ := env.createQuestionnaire(other, true)
+		env.submit(otherQ, doomed)
+		env.submit(otherQ, other)
+
+		env.delete(fmt.Sprintf("/api/admin/user/delete?id=%d", admin.ID), as).
+			expectError(http.StatusForbidden, "不允许删除管理员账户")
+
+		env.delete(fmt.Sprintf("/api/admin/user/delete?id=%d", doomed.ID), as).
+			expect(http.StatusOK).assertGolden("admin_user_delete")
+		env.delete(fmt.Sprintf("/api/admin/user/delete?id=%d", doomed.ID), as).
+			expectError(http.StatusNotFound, "用户不存在")
+
+		got := env.counts()
+		if got.Users != 2 || got.Questionnaires != 1 || got.Submissions != 1 || got.Answers != int64(len(otherQ.Questions)) {
+			t.Fatalf("删除用户后记录数不正确: %+v", got)
+		}
+	})
+}
+
+func TestAdminQuestionnaires(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createUser(userOpts{Username: "root", Admin: true})
+		owner := env.createUser(userOpts{Username: "dave"})
+		respondent := env.createUser(userOpts{Username: "erin"})
+		q := env.createQuestionnaire(owner, true)
+		env.createQuestionnaire(owner, false)
+		env.submit(q, respondent)
+		as := env.asUser(admin.Username)
+
+		env.get("/api/admin/questionnaires?page=1&page_size=10", as).expect(http.StatusOK).assertGolden("admin_questionnaires")
+		env.get(fmt.Sprintf("/api/admin/questionnaire/submissions?id=%d", q.ID), as).
+			expect(http.StatusOK).assertGolden("admin_questionnaire_submissions")
+		env.get("/api/admin/questionnaire/submissions?id=999", as).
+			expectError(http.StatusNotFound, "问卷不存在")
+	})
+}
+
+func TestAdminStatistics(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createAdmin()
+		as := env.asUser(admin.Username)
+
+		// 没有提交时平均答案数为0而不是NaN
+		env.get("/api/admin/statistics", as).expect(http.StatusOK).assertGolden("admin_statistics_empty")
+
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		env.createQuestionnaire(owner, false)
+		env.submit(q, owner)
+		env.submit(q, admin)
+
+		env.get("/api/admin/statistics", as).expect(http.StatusOK).assertGolden("admin_statistics")
+	})
+}
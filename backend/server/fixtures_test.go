@@ -0,0 +1,174 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/service"
+)
+
+// 测试数据工厂：直接通过业务服务写入数据，返回可在请求中使用的对象
+
+// defaultPassword 工厂创建的用户的密码
+const defaultPassword = "secret123"
+
+// next 生成唯一的序号
+func (e *testEnv) next() int {
+	e.seq++
+	return e.seq
+}
+
+// userOpts 创建用户的可选项
+type userOpts struct {
+	Username string
+	Email    string
+	Admin    bool
+	Verified bool // 邮箱已验证
+}
+
+// createUser 创建用户，未指定的字段自动生成
+func (e *testEnv) createUser(opts userOpts) *models.User {
+	e.t.Helper()
+
+	n := e.next()
+	if opts.Username == "" {
+		opts.Username = fmt.Sprintf("user%d", n)
+	}
+	if opts.Email == "" {
+		opts.Email = opts.Username + "@example.com"
+	}
+
+	user, err := e.services.Users.Register(e.ctx(), service.RegisterInput{
+		Username: opts.Username,
+		Email:    opts.Email,
+		Password: defaultPassword,
+	})
+	if err != nil {
+		e.t.Fatalf("创建用户失败: %v", err)
+	}
+
+	if opts.Admin {
+		err := e.services.Users.Update(e.ctx(), service.UserUpdate{ID: user.ID, Email: user.Email, IsAdmin: true})
+		if err != nil {
+			e.t.Fatalf("设置管理员失败: %v", err)
+		}
+		user.IsAdmin = true
+	}
+
+	if opts.Verified {
+		stored, err := e.store.Users().Get(e.ctx(), user.ID)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		now := time.Now()
+		stored.EmailVerifiedAt = &now
+		if err := e.store.Users().Save(e.ctx(), stored); err != nil {
+			e.t.Fatalf("验证邮箱失败: %v", err)
+		}
+		user.EmailVerifiedAt = &now
+	}
+	return user
+}
+
+// createAdmin 创建管理员
+func (e *testEnv) createAdmin() *models.User {
+	e.t.Helper()
+	return e.createUser(userOpts{Username: fmt.Sprintf("admin%d", e.next()), Admin: true})
+}
+
+// sampleQuestions 一份包含单选、多选、填空、评分题的问卷
+func sampleQuestions() []service.QuestionInput {
+	return []service.QuestionInput{
+		{Title: "您的性别", Type: models.QuestionTypeSingleChoice, Required: true, Options: `["男","女"]`},
+		{Title: "您喜欢的水果", Type: models.QuestionTypeMultipleChoice, Options: `["苹果","香蕉","橙子"]`},
+		{Title: "您的建议", Type: models.QuestionTypeText},
+		{Title: "满意度", Type: models.QuestionTypeRating},
+	}
+}
+
+// questionnaireFixture 创建的问卷及其问题
+type questionnaireFixture struct {
+	*models.Questionnaire
+	Questions []models.Question
+}
+
+// createQuestionnaire 由creator创建问卷（包含sampleQuestions），published为true时发布
+func (e *testEnv) createQuestionnaire(creator *models.User, published bool) *questionnaireFixture {
+	e.t.Helper()
+
+	questionnaire, questions, err := e.services.Questionnaires.Create(e.ctx(), service.QuestionnaireInput{
+		Title:       fmt.Sprintf("问卷%d", e.next()),
+		Description: "测试问卷",
+		CreatedBy:   creator.ID,
+		IsPublished: published,
+		Questions:   sampleQuestions(),
+	})
+	if err != nil {
+		e.t.Fatalf("创建问卷失败: %v", err)
+	}
+	return &questionnaireFixture{Questionnaire: questionnaire, Questions: questions}
+}
+
+// sampleAnswers 对sampleQuestions的一组答案
+func (q *questionnaireFixture) sampleAnswers() []models.Answer {
+	contents := []string{"女", `["苹果","橙子"]`, "很好", "5"}
+	var answers []models.Answer
+	for i, question := range q.Questions {
+		answers = append(answers, models.Answer{QuestionID: question.ID, Content: contents[i%len(contents)]})
+	}
+	return answers
+}
+
+// submit 以user身份提交问卷的示例答案
+func (e *testEnv) submit(q *questionnaireFixture, user *models.User) *models.Submission {
+	e.t.Helper()
+
+	submission, err := e.services.Submissions.Submit(e.ctx(), service.SubmitInput{
+		QuestionnaireID: q.ID,
+		UserID:          user.ID,
+		IPAddress:       "192.0.2.1",
+		Answers:         q.sampleAnswers(),
+	})
+	if err != nil {
+		e.t.Fatalf("提交问卷失败: %v", err)
+	}
+	return submission
+}
+
+// counts 数据库中各类记录的数量，用于检查级联删除
+type counts struct {
+	Users, Questionnaires, Questions, Submissions, Answers int64
+}
+
+func (e *testEnv) counts() counts {
+	e.t.Helper()
+
+	totals, err := e.services.Statistics.System(e.ctx())
+	if err != nil {
+		e.t.Fatalf("统计失败: %v", err)
+	}
+	return counts{
+		Users:          totals.Users,
+		Questionnaires: totals.Questionnaires,
+		Questions:      totals.Questions,
+		Submissions:    totals.Submissions,
+		Answers:        totals.Answers,
+	}
+}
+
+// TestFixtures 确保工厂在两种仓储上的行为一致
+func TestFixtures(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createAdmin()
+		user := env.createUser(userOpts{})
+		q := env.createQuestionnaire(admin, true)
+		env.submit(q, user)
+
+		want := counts{Users: 2, Questionnaires: 1, Questions: 4, Submissions: 1, Answers: 4}
+		if got := env.counts(); got != want {
+			t.Fatalf("记录数为%+v，期望%+v", got, want)
+		}
+	})
+}
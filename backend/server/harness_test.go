@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/config"
+	"questionnaire-system/backend/database"
+	"questionnaire-system/backend/database/migrations"
+	"questionnaire-system/backend/realtime"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 使用 go test ./server -update 重新生成golden文件
+var update = flag.Bool("update", false, "更新testdata/golden下的响应快照")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	gin.SetMode(gin.TestMode)
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// 测试使用的仓储实现
+const (
+	backendSQLite = "sqlite" // GORM仓储 + SQLite内存数据库
+	backendMemory = "memory" // 内存仓储（健康检查仍使用SQLite）
+)
+
+// testEnv 一个独立的测试服务：空数据库、路由和业务服务
+type testEnv struct {
+	t        testing.TB
+	backend  string
+	db       *database.Database
+	store    repository.Store
+	services *Services
+	hub      *realtime.Hub
+	router   *gin.Engine
+	seq      int // 工厂生成唯一名称使用的序号
+
+	secret   []byte            // 会话令牌的签名密钥，重新创建路由后之前登录得到的令牌仍然有效
+	sessions map[string]string // 用户名 → asUser登录得到的会话令牌
+}
+
+// newTestEnv 创建使用指定仓储的测试服务
+func newTestEnv(t testing.TB, backend string) *testEnv {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Env = config.EnvTest
+	cfg.Database.Driver = database.DriverSQLite
+	cfg.Database.DSN = ":memory:"
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := migrations.New(db.DB).Up(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	var store repository.Store
+	switch backend {
+	case backendSQLite:
+		store = repository.NewGormStore(db.DB)
+	case backendMemory:
+		store = repository.NewMemoryStore()
+	default:
+		t.Fatalf("未知的测试仓储: %s", backend)
+	}
+
+	env := &testEnv{
+		t:        t,
+		backend:  backend,
+		db:       db,
+		store:    store,
+		hub:      realtime.NewHub(service.NewResultsService(store).Summary),
+		secret:   []byte("test-token-secret"),
+		sessions: make(map[string]string),
+	}
+	env.services = NewServices(store, env.hub, nil, nil)
+	env.router = New(env.options())
+	return env
+}
+
+// options 测试服务的基本选项（DB、Store、Hub和令牌密钥），重新创建路由时在此基础上修改
+func (e *testEnv) options() Options {
+	return Options{DB: e.db, Store: e.store, Hub: e.hub, TokenSecret: e.secret}
+}
+
+// forEachBackend 在每种仓储实现上分别运行fn
+func forEachBackend(t *testing.T, fn func(t *testing.T, env *testEnv)) {
+	for _, backend := range []string{backendSQLite, backendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			fn(t, newTestEnv(t, backend))
+		})
+	}
+}
+
+// response 接口响应
+type response struct {
+	t      testing.TB
+	Code   int
+	Header http.Header
+	Body   []byte
+}
+
+// requestOption 修改测试请求
+type requestOption func(*http.Request)
+
+// asUser 携带用户的认证头：第一次使用时以工厂的默认密码登录，之后复用同一个会话令牌
+// 用户需未启用两步验证；重置密码等操作使令牌失效后，请求同样会被拒绝
+func (e *testEnv) asUser(username string) requestOption {
+	e.t.Helper()
+	token, ok := e.sessions[username]
+	if !ok {
+		resp := e.post("/api/v1/sessions", map[string]string{"username": username, "password": defaultPassword}).expect(http.StatusOK)
+		token, _ = resp.path("token").(string)
+		if token == "" {
+			e.t.Fatalf("登录没有返回会话令牌: %s", resp.Body)
+		}
+		e.sessions[username] = token
+	}
+	return withHeader("Authorization", "Bearer "+token)
+}
+
+// withHeader 设置请求头
+func withHeader(key, value string) requestOption {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// do 发送请求，body不为nil时编码为JSON
+func (e *testEnv) do(method, path string, body interface{}, opts ...requestOption) *response {
+	e.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatalf("编码请求失败: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return &response{t: e.t, Code: rec.Code, Header: rec.Header(), Body: rec.Body.Bytes()}
+}
+
+func (e *testEnv) get(path string, opts ...requestOption) *response {
+	e.t.Helper()
+	return e.do(http.MethodGet, path, nil, opts...)
+}
+
+func (e *testEnv) post(path string, body interface{}, opts ...requestOption) *response {
+	e.t.Helper()
+	return e.do(http.MethodPost, path, body, opts...)
+}
+
+func (e *testEnv) put(path string, body interface{}, opts ...requestOption) *response {
+	e.t.Helper()
+	return e.do(http.MethodPut, path, body, opts...)
+}
+
+func (e *testEnv) delete(path string, opts ...requestOption) *response {
+	e.t.Helper()
+	return e.do(http.MethodDelete, path, nil, opts...)
+}
+
+// expect 断言状态码
+func (r *response) expect(code int) *response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Fatalf("状态码为%d，期望%d，响应: %s", r.Code, code, r.Body)
+	}
+	return r
+}
+
+// json 解码响应
+func (r *response) json() map[string]interface{} {
+	r.t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal(r.Body, &v); err != nil {
+		r.t.Fatalf("解析响应失败: %v，响应: %s", err, r.Body)
+	}
+	return v
+}
+
+// message 响应中的提示信息，错误响应取error.message
+func (r *response) message() string {
+	r.t.Helper()
+	v := r.json()
+	if e, ok := v["error"].(map[string]interface{}); ok {
+		msg, _ := e["message"].(string)
+		return msg
+	}
+	msg, _ := v["message"].(string)
+	return msg
+}
+
+// expectError 断言错误状态码和提示信息
+func (r *response) expectError(code int, message string) *response {
+	r.t.Helper()
+	r.expect(code)
+	if got := r.message(); got != message {
+		r.t.Fatalf("错误信息为%q，期望%q", got, message)
+	}
+	return r
+}
+
+// expectCode 断言错误状态码和错误码
+func (r *response) expectCode(status int, code string) *response {
+	r.t.Helper()
+	r.expect(status)
+	if got := r.path("error.code"); got != code {
+		r.t.Fatalf("错误码为%v，期望%s，响应: %s", got, code, r.Body)
+	}
+	return r
+}
+
+// path 按"data.questionnaire.id"形式取响应中的值
+func (r *response) path(p string) interface{} {
+	r.t.Helper()
+	var v interface{} = r.json()
+	for _, key := range strings.Split(p, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			r.t.Fatalf("响应中不存在%s: %s", p, r.Body)
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// 快照中需要归一化的动态值
+var (
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`)
+	tokenPattern     = regexp.MustCompile(`^v1\.\d+\.\d+\.\d+\.[A-Za-z0-9_-]+$`)
+)
+
+// normalize 将时间戳、令牌、请求ID等每次运行都不同的值替换为占位符
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, item := range x {
+			if k == "request_id" {
+				x[k] = "<request_id>"
+				continue
+			}
+			x[k] = normalize(item)
+		}
+		return x
+	case []interface{}:
+		for i, item := range x {
+			x[i] = normalize(item)
+		}
+		return x
+	case string:
+		if timestampPattern.MatchString(x) {
+			if _, err := time.Parse(time.RFC3339Nano, x); err == nil {
+				return "<time>"
+			}
+		}
+		if tokenPattern.MatchString(x) {
+			return "<token>"
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+// assertGolden 将响应与testdata/golden/<name>.json比较，保证前端依赖的响应结构不被意外修改
+func (r *response) assertGolden(name string) {
+	r.t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal(r.Body, &v); err != nil {
+		r.t.Fatalf("解析响应失败: %v，响应: %s", err, r.Body)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(map[string]interface{}{"status": r.Code, "body": normalize(v)}); err != nil {
+		r.t.Fatal(err)
+	}
+	got := buf.Bytes()
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		r.t.Fatalf("读取golden文件失败（使用 -update 生成）: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		r.t.Errorf("响应与 %s 不一致（确认是预期修改后使用 -update 更新）\n实际:\n%s\n期望:\n%s", path, got, want)
+	}
+}
+
+// ctx 测试中调用服务使用的上下文
+func (e *testEnv) ctx() context.Context {
+	return context.Background()
+}
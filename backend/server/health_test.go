@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/database/migrations"
+	"questionnaire-system/backend/health"
+	"questionnaire-system/backend/webhook"
+)
+
+func TestReadiness(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+
+	env.get("/api/v1/health/live").expect(http.StatusOK)
+	resp := env.get("/api/v1/health/ready").expect(http.StatusOK)
+	if resp.path("status") != health.StatusOK ||
+		resp.path("checks.database.status") != health.StatusOK ||
+		resp.path("checks.migrations.status") != health.StatusOK {
+		t.Fatalf("就绪检查结果不正确: %s", resp.Body)
+	}
+	latest := float64(migrations.New(env.db.DB).Latest())
+	if resp.path("checks.migrations.details.current") != latest {
+		t.Fatalf("迁移版本不正确: %s", resp.Body)
+	}
+}
+
+func TestReadinessFailsWhenSchemaBehind(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	if err := migrations.New(env.db.DB).Down(1); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := env.get("/api/v1/health/ready").expect(http.StatusServiceUnavailable)
+	if resp.path("status") != health.StatusUnavailable || resp.path("checks.migrations.status") != health.StatusUnavailable {
+		t.Fatalf("表结构落后时应不可用: %s", resp.Body)
+	}
+	if resp.path("checks.database.status") != health.StatusOK {
+		t.Fatalf("数据库连接应正常: %s", resp.Body)
+	}
+
+	// 旧的健康检查同样反映依赖状态，存活检查不受影响
+	legacy := env.get("/api/health").expect(http.StatusServiceUnavailable)
+	if legacy.path("status") != health.StatusUnavailable || legacy.path("message") != "服务不可用" {
+		t.Fatalf("健康检查结果不正确: %s", legacy.Body)
+	}
+	env.get("/api/v1/health/live").expect(http.StatusOK)
+}
+
+func TestReadinessFailsWhenDatabaseDown(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	sqlDB, err := env.db.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+
+	resp := env.get("/api/v1/health/ready").expect(http.StatusServiceUnavailable)
+	if resp.path("checks.database.status") != health.StatusUnavailable || resp.path("checks.database.error") == nil {
+		t.Fatalf("数据库不可用时应返回错误: %s", resp.Body)
+	}
+	env.get("/api/v1/health/live").expect(http.StatusOK)
+}
+
+func TestReadinessDegradedAndDraining(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	checker := health.NewChecker(
+		health.Database(env.db.DB),
+		health.Probe{Name: "mail", Check: func(context.Context) (interface{}, error) {
+			return nil, errors.New("连接被拒绝")
+		}},
+		health.Probe{Name: "slow", Check: func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}},
+	)
+	checker.Timeout = 50 * time.Millisecond
+	opts := env.options()
+	opts.Health = checker
+	env.router = New(opts)
+
+	// 非关键依赖异常时降级，仍然就绪
+	resp := env.get("/api/v1/health/ready").expect(http.StatusOK)
+	if resp.path("status") != health.StatusDegraded || resp.path("checks.mail.error") != "连接被拒绝" ||
+		resp.path("checks.slow.status") != health.StatusUnavailable {
+		t.Fatalf("就绪检查结果不正确: %s", resp.Body)
+	}
+	env.get("/api/v1/health").expect(http.StatusOK)
+
+	// 开始关闭后不再就绪，存活检查仍然正常
+	checker.SetDraining()
+	resp = env.get("/api/v1/health/ready").expect(http.StatusServiceUnavailable)
+	if resp.path("draining") != true {
+		t.Fatalf("关闭中的实例应标记draining: %s", resp.Body)
+	}
+	env.get("/api/v1/health/live").expect(http.StatusOK)
+}
+
+func TestDispatcherProbe(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	dispatcher := webhook.NewDispatcher(env.db.DB, false)
+	dispatcher.PollInterval = 10 * time.Millisecond
+	checker := health.NewChecker(dispatcher.Probe())
+
+	if report := checker.Check(context.Background()); report.Status != health.StatusDegraded {
+		t.Fatalf("投递器未运行时应为降级: %+v", report)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		report := checker.Check(context.Background())
+		if report.Status == health.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("投递器运行后检查仍未通过: %+v", report)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHubCloseEndsResultStreams(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+
+	req := httptest.NewRequest(http.MethodGet,
+		fmt.Sprintf("/api/v1/questionnaires/%d/results/stream", q.ID), nil)
+	env.asUser(owner.Username)(req)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		env.router.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	// 等待订阅建立后关闭
+	deadline := time.Now().Add(2 * time.Second)
+	for env.hub.Subscribers(q.ID) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("实时结果订阅没有建立")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	env.hub.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("关闭Hub后实时结果连接没有结束")
+	}
+}
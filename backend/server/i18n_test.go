@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"questionnaire-system/backend/i18n"
+	"questionnaire-system/backend/models"
+)
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	want := strings.Join(i18n.Keys(i18n.Default), "\n")
+	for _, locale := range i18n.Locales() {
+		if got := strings.Join(i18n.Keys(locale), "\n"); got != want {
+			t.Errorf("%s的消息目录与%s的键不一致", locale, i18n.Default)
+		}
+	}
+}
+
+func TestLocalizedMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		resp := env.get("/api/health", withHeader("Accept-Language", "en-GB")).expect(http.StatusOK)
+		if resp.path("message") != "Service is running" || resp.Header.Get("Content-Language") != "en" {
+			t.Fatalf("英文响应不正确: %v %s", resp.Header, resp.Body)
+		}
+
+		resp = env.get("/api/health").expect(http.StatusOK)
+		if resp.path("message") != "服务运行正常" || resp.Header.Get("Content-Language") != "zh-CN" {
+			t.Fatalf("默认应为中文: %v %s", resp.Header, resp.Body)
+		}
+	})
+}
+
+// translatedPayload 带英文翻译的问卷
+func translatedPayload(createdBy uint) map[string]interface{} {
+	payload := questionnairePayload(createdBy, "满意度调查")
+	payload["is_published"] = true
+	payload["translations"] = []map[string]interface{}{{
+		"locale":      "en",
+		"title":       "Satisfaction survey",
+		"description": "Description",
+		"questions": []map[string]interface{}{
+			{"title": "Your gender", "options": []string{"Male", "Female"}},
+			{"title": "Your suggestions"},
+		},
+	}}
+	return payload
+}
+
+func TestMultilingualQuestionnaire(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		created := env.post("/api/questionnaire/create", translatedPayload(owner.ID)).expect(http.StatusCreated)
+		id := uint(created.path("data.questionnaire.id").(float64))
+		detail := fmt.Sprintf("/api/questionnaire/detail?id=%d", id)
+
+		cases := []struct {
+			query, acceptLanguage string
+			locale, title         string
+		}{
+			{"", "", "zh-CN", "满意度调查"},
+			{"", "en-US,en;q=0.9", "en", "Satisfaction survey"},
+			{"", "en-GB", "en", "Satisfaction survey"},
+			{"", "fr-FR", "zh-CN", "满意度调查"},
+			{"&locale=en", "zh-CN", "en", "Satisfaction survey"},
+		}
+		for _, tc := range cases {
+			resp := env.get(detail+tc.query, withHeader("Accept-Language", tc.acceptLanguage)).expect(http.StatusOK)
+			if resp.path("data.locale") != tc.locale || resp.path("data.questionnaire.title") != tc.title {
+				t.Fatalf("%q/%q: 返回了%v %v", tc.query, tc.acceptLanguage, resp.path("data.locale"), resp.path("data.questionnaire.title"))
+			}
+		}
+
+		resp := env.get(detail + "&locale=en").expect(http.StatusOK)
+		question := resp.path("data.questions").([]interface{})[0].(map[string]interface{})
+		if question["title"] != "Your gender" || question["options"] != `["Male","Female"]` {
+			t.Fatalf("问题未翻译: %v", question)
+		}
+		if fmt.Sprint(resp.path("data.locales")) != "[zh-CN en]" {
+			t.Fatalf("可用语言为%v", resp.path("data.locales"))
+		}
+		if _, ok := resp.path("data").(map[string]interface{})["translations"]; ok {
+			t.Fatalf("未要求时不应返回翻译: %s", resp.Body)
+		}
+
+		resp = env.get(detail + "&include_translations=true").expect(http.StatusOK)
+		translation := resp.path("data.translations").([]interface{})[0].(map[string]interface{})
+		if translation["locale"] != "en" || len(translation["questions"].([]interface{})) != 2 {
+			t.Fatalf("翻译不完整: %v", translation)
+		}
+	})
+}
+
+func TestTranslationValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+
+		payload := translatedPayload(owner.ID)
+		payload["translations"].([]map[string]interface{})[0]["locale"] = "xx-invalid-locale"
+		env.post("/api/questionnaire/create", payload).
+			expectCode(http.StatusBadRequest, "VALIDATION_FAILED").
+			expectError(http.StatusBadRequest, "无效的翻译语言代码")
+
+		// 与原文语言相同
+		payload = translatedPayload(owner.ID)
+		payload["default_locale"] = "en"
+		env.post("/api/questionnaire/create", payload).
+			expectError(http.StatusBadRequest, "无效的翻译语言代码")
+
+		// 选项数量不一致
+		payload = translatedPayload(owner.ID)
+		questions := payload["translations"].([]map[string]interface{})[0]["questions"].([]map[string]interface{})
+		questions[0]["options"] = []string{"Male"}
+		resp := env.post("/api/questionnaire/create", payload).
+			expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		if resp.path("error.details").([]interface{})[0].(map[string]interface{})["field"] != "translations" {
+			t.Fatalf("字段错误不正确: %s", resp.Body)
+		}
+	})
+}
+
+func TestSubmitTranslatedAnswers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		created := env.post("/api/questionnaire/create", translatedPayload(owner.ID)).expect(http.StatusCreated)
+		id := uint(created.path("data.questionnaire.id").(float64))
+		questions := created.path("data.questions").([]interface{})
+		choiceID := questions[0].(map[string]interface{})["id"]
+
+		submit := func(user *models.User, answer string, opts ...requestOption) {
+			env.post("/api/questionnaire/submit", map[string]interface{}{
+				"questionnaire_id": id,
+				"answers":          []map[string]interface{}{{"question_id": choiceID, "content": answer}},
+			}, append(opts, env.asUser(user.Username))...).expect(http.StatusCreated)
+		}
+		english := env.createUser(userOpts{})
+		chinese := env.createUser(userOpts{})
+		submit(english, "Female", withHeader("Accept-Language", "en-US"))
+		submit(chinese, "女")
+
+		// 答案统一保存为原文选项，答卷记录填写语言
+		resp := env.get(fmt.Sprintf("/api/questionnaire/results?id=%d", id), env.asUser(owner.Username)).
+			expect(http.StatusOK)
+		var locales []string
+		for _, s := range resp.path("data.submissions").([]interface{}) {
+			item := s.(map[string]interface{})
+			locales = append(locales, item["submission"].(map[string]interface{})["locale"].(string))
+			if content := item["answers"].([]interface{})[0].(map[string]interface{})["content"]; content != "女" {
+				t.Fatalf("答案未换算为原文选项: %v", content)
+			}
+		}
+		if fmt.Sprint(locales) != "[en zh-CN]" {
+			t.Fatalf("答卷语言为%v", locales)
+		}
+
+		// 汇总统计按选项ID合并
+		summary, err := env.services.Results.Summary(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		options := summary.Questions[0].Options
+		if len(options) != 2 || options[1].OptionID != 2 || options[1].Option != "女" || options[1].Count != 2 {
+			t.Fatalf("选项统计不正确: %+v", options)
+		}
+	})
+}
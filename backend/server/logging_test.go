@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"questionnaire-system/backend/config"
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/middleware"
+)
+
+// logRecorder 收集测试服务输出的JSON日志
+type logRecorder struct {
+	t   testing.TB
+	buf bytes.Buffer
+}
+
+// withLogs 使用记录到内存的日志记录器重新创建路由
+func (e *testEnv) withLogs(sampler *logging.Sampler) *logRecorder {
+	rec := &logRecorder{t: e.t}
+	logger := slog.New(logging.NewHandler(&rec.buf, "json", slog.LevelDebug))
+	opts := e.options()
+	opts.Logger, opts.LogSampler = logger, sampler
+	e.router = New(opts)
+	return rec
+}
+
+// entries 解析全部日志，每行必须是合法的JSON
+func (r *logRecorder) entries() []map[string]interface{} {
+	r.t.Helper()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(r.buf.Bytes()))
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			r.t.Fatalf("日志不是JSON: %s", scanner.Text())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// find 查找消息为msg且请求ID为requestID的日志
+func (r *logRecorder) find(msg, requestID string) map[string]interface{} {
+	r.t.Helper()
+	for _, entry := range r.entries() {
+		if entry["msg"] == msg && entry["request_id"] == requestID {
+			return entry
+		}
+	}
+	r.t.Fatalf("没有找到日志 %q（request_id=%s）:\n%s", msg, requestID, r.buf.String())
+	return nil
+}
+
+// accessLogs 访问日志的数量
+func (r *logRecorder) accessLogs(route string) int {
+	n := 0
+	for _, entry := range r.entries() {
+		if entry["msg"] == "请求完成" && entry["route"] == route {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRequestLogging(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	logs := env.withLogs(nil)
+
+	resp := env.post("/api/v1/users", map[string]string{
+		"username": "alice", "password": "secret123", "email": "alice@example.com", "phone": "13800138000",
+	}).expect(http.StatusCreated)
+	requestID := resp.Header.Get(middleware.RequestIDHeader)
+
+	// 访问日志：状态码为数字，带路由模板和请求ID
+	access := logs.find("请求完成", requestID)
+	if access["status"] != float64(http.StatusCreated) || access["route"] != "/api/v1/users" ||
+		access["method"] != http.MethodPost || access["level"] != "INFO" {
+		t.Fatalf("访问日志不正确: %v", access)
+	}
+	if _, ok := access["latency_ms"].(float64); !ok {
+		t.Fatalf("访问日志缺少耗时: %v", access)
+	}
+	// 处理器的日志使用同一请求ID
+	if entry := logs.find("用户注册成功", requestID); entry["username"] != "alice" {
+		t.Fatalf("处理器日志不正确: %v", entry)
+	}
+
+	// 调用方传入的请求ID贯穿访问日志和处理器日志
+	env.post("/api/v1/sessions", map[string]string{"username": "alice", "password": "wrong"},
+		withHeader(middleware.RequestIDHeader, "gateway-42")).expect(http.StatusUnauthorized)
+	if entry := logs.find("请求完成", "gateway-42"); entry["level"] != "WARN" || entry["status"] != float64(401) {
+		t.Fatalf("4xx应记录为WARN: %v", entry)
+	}
+	logs.find("登录失败", "gateway-42")
+
+	env.get("/api/v1/questionnaires?limit=5&token=abc123").expect(http.StatusOK)
+
+	output := logs.buf.String()
+	for _, secret := range []string{"secret123", "wrong", "alice@example.com", "13800138000", "abc123"} {
+		if strings.Contains(output, secret) {
+			t.Fatalf("日志中不应出现 %q:\n%s", secret, output)
+		}
+	}
+	if !strings.Contains(output, "token="+queryEscape(logging.Redacted)) {
+		t.Fatalf("查询参数中的凭据应被替换:\n%s", output)
+	}
+}
+
+// queryEscape 对占位符做查询字符串编码
+func queryEscape(s string) string {
+	return strings.NewReplacer("[", "%5B", "]", "%5D").Replace(s)
+}
+
+func TestLogRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(&buf, "json", slog.LevelInfo))
+	logger.Info("测试",
+		"password", "p@ss", "new_password", "p2", "token_secret", "s", "authorization", "Bearer abc",
+		"header", "Bearer xyz", "email", "alice@example.com", "owner_email", "bob@example.com",
+		"phone", "13800138000", "username", "alice",
+		slog.Group("request", "password", "p3"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"password":      logging.Redacted,
+		"new_password":  logging.Redacted,
+		"token_secret":  logging.Redacted,
+		"authorization": logging.Redacted,
+		"header":        logging.Redacted,
+		"email":         "a***@example.com",
+		"owner_email":   "b***@example.com",
+		"phone":         "138****8000",
+		"username":      "alice",
+		"request":       map[string]interface{}{"password": logging.Redacted},
+	}
+	for key, want := range expected {
+		if fmt.Sprint(entry[key]) != fmt.Sprint(want) {
+			t.Errorf("%s: 期望 %v，实际 %v", key, want, entry[key])
+		}
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	logs := env.withLogs(logging.NewSampler(map[string]float64{
+		"/api/v1/health/*":           0,
+		"/api/v1/questionnaires/:id": 0,
+	}))
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+
+	env.get("/api/v1/health/live").expect(http.StatusOK)
+	env.get(fmt.Sprintf("/api/v1/questionnaires/%d", q.ID)).expect(http.StatusOK)
+	env.get("/api/v1/stats").expect(http.StatusOK)
+	if logs.accessLogs("/api/v1/health/live") != 0 || logs.accessLogs("/api/v1/questionnaires/:id") != 0 {
+		t.Fatalf("采样比例为0的成功请求不应记录:\n%s", logs.buf.String())
+	}
+	if logs.accessLogs("/api/v1/stats") != 1 {
+		t.Fatalf("没有规则的路由应全部记录:\n%s", logs.buf.String())
+	}
+
+	// 错误请求不受采样影响
+	env.get("/api/v1/questionnaires/999").expect(http.StatusNotFound)
+	if logs.accessLogs("/api/v1/questionnaires/:id") != 1 {
+		t.Fatalf("错误请求应始终记录:\n%s", logs.buf.String())
+	}
+
+	// 按请求ID哈希采样，同一请求的结果稳定
+	sampler := logging.NewSampler(map[string]float64{"/api/v1/questionnaires": 0.25})
+	kept := 0
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		keep := sampler.Keep("/api/v1/questionnaires", id)
+		if keep != sampler.Keep("/api/v1/questionnaires", id) {
+			t.Fatal("同一请求的采样结果应一致")
+		}
+		if keep {
+			kept++
+		}
+	}
+	if kept < 400 || kept > 600 {
+		t.Fatalf("采样比例0.25，2000个请求记录了%d个", kept)
+	}
+}
+
+func TestLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	file, err := logging.OpenRotatingFile(path, 0, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.MaxSize = 100
+
+	line := strings.Repeat("x", 59) + "\n"
+	for i := 0; i < 10; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := file.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("应保留2个旧文件，实际: %v", backups)
+	}
+	// 每个文件都只包含完整的行且不超过上限
+	for _, p := range append(backups, path) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == 0 || len(data) > 100 || len(data)%len(line) != 0 {
+			t.Fatalf("%s 的大小不正确: %d", p, len(data))
+		}
+	}
+}
+
+func TestFatalWritesLogFile(t *testing.T) {
+	// 子进程中调用Fatal
+	if path := os.Getenv("TEST_FATAL_LOG_FILE"); path != "" {
+		logger, closer, err := logging.New(config.LogConfig{File: path, Format: "json"}, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logging.SetDefault(logger, closer)
+		logging.Fatal("启动失败", "error", "boom")
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalWritesLogFile$")
+	cmd.Env = append(os.Environ(), "TEST_FATAL_LOG_FILE="+path)
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Fatal应以状态码1退出: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"msg":"启动失败"`)) || !bytes.Contains(data, []byte(`"error":"boom"`)) {
+		t.Fatalf("日志文件中缺少退出前的错误: %s", data)
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/metrics"
+	"questionnaire-system/backend/middleware"
+)
+
+// withMetrics 使用新的指标注册表重新创建路由
+func (e *testEnv) withMetrics(access ...string) *metrics.Metrics {
+	e.t.Helper()
+
+	m := metrics.New()
+	opts := e.options()
+	opts.Metrics = m
+	if len(access) > 0 {
+		guard, err := middleware.MetricsAccess(access[0], access[1:])
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		opts.MetricsAccess = guard
+	}
+	e.router = New(opts)
+	return m
+}
+
+// scrape 抓取/metrics并按序列名（含标签）返回全部值
+func (e *testEnv) scrape() map[string]float64 {
+	e.t.Helper()
+
+	resp := e.get("/metrics").expect(http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != metrics.ContentType {
+		e.t.Fatalf("Content-Type不正确: %s", ct)
+	}
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(resp.Body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			e.t.Fatalf("无效的指标行: %s", line)
+		}
+		values[line[:i]] = v
+	}
+	return values
+}
+
+func TestMetrics(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.withMetrics()
+		owner := env.createUser(userOpts{Username: "owner"})
+		respondent := env.createUser(userOpts{})
+
+		env.post("/api/v1/sessions", map[string]string{"username": "owner", "password": defaultPassword}).expect(http.StatusOK)
+		for i := 0; i < 2; i++ {
+			env.post("/api/v1/sessions", map[string]string{"username": "owner", "password": "wrong"}).
+				expect(http.StatusUnauthorized)
+		}
+		env.post("/api/v1/sessions", map[string]string{"username": "nobody", "password": "wrong"}).
+			expect(http.StatusUnauthorized)
+
+		// 创建时直接发布、草稿发布各计一次，重复发布不计
+		payload := questionnairePayload(owner.ID, "直接发布")
+		payload["is_published"] = true
+		published := env.post("/api/v1/questionnaires", payload).expect(http.StatusCreated)
+		id := uint(published.path("data.questionnaire.id").(float64))
+		draft := env.post("/api/v1/questionnaires", questionnairePayload(owner.ID, "草稿")).expect(http.StatusCreated)
+		draftID := uint(draft.path("data.questionnaire.id").(float64))
+		for i := 0; i < 2; i++ {
+			env.do(http.MethodPut, fmt.Sprintf("/api/v1/questionnaires/%d/status", draftID),
+				map[string]bool{"is_published": true}).expect(http.StatusOK)
+		}
+
+		questions := published.path("data.questions").([]interface{})
+		answer := map[string]interface{}{
+			"answers": []map[string]interface{}{{"question_id": questions[0].(map[string]interface{})["id"], "content": "男"}},
+		}
+		asRespondent := env.asUser(respondent.Username)
+		env.post(fmt.Sprintf("/api/v1/questionnaires/%d/submissions", id), answer, asRespondent).expect(http.StatusCreated)
+		env.post(fmt.Sprintf("/api/v1/questionnaires/%d/submissions", id), answer, asRespondent).expect(http.StatusConflict)
+
+		// 未发布和已结束的问卷不计入可填写问卷
+		env.createQuestionnaire(owner, false)
+		ended := env.createQuestionnaire(owner, true)
+		ended.EndTime = time.Now().Add(-time.Hour)
+		if err := env.store.Questionnaires().Save(env.ctx(), ended.Questionnaire); err != nil {
+			t.Fatal(err)
+		}
+		env.get("/api/v1/questionnaires/999").expect(http.StatusNotFound)
+		env.get("/no/such/path").expect(http.StatusNotFound)
+
+		values := env.scrape()
+		expected := map[string]float64{
+			`http_requests_total{method="POST",route="/api/v1/sessions",status="200"}`:                            2, // 含答题用户登录
+			`http_requests_total{method="POST",route="/api/v1/sessions",status="401"}`:                            3,
+			`http_requests_total{method="GET",route="/api/v1/questionnaires/:id",status="404"}`:                   1,
+			`http_requests_total{method="GET",route="unmatched",status="404"}`:                                    1,
+			`http_request_duration_seconds_count{method="POST",route="/api/v1/sessions",status="401"}`:            3,
+			`http_request_duration_seconds_bucket{method="POST",route="/api/v1/sessions",status="401",le="+Inf"}`: 3,
+			`login_failures_total{reason="invalid_credentials"}`:                                                  3,
+			`questionnaires_published_total`:                                                                      2,
+			`submissions_created_total`:                                                                           1,
+			`questionnaires_open`:                                                                                 2,
+		}
+		for series, want := range expected {
+			if got, ok := values[series]; !ok || got != want {
+				t.Errorf("%s: 期望 %v，实际 %v（存在: %t）", series, want, got, ok)
+			}
+		}
+		for _, name := range []string{"db_max_open_connections", "db_open_connections", "db_in_use_connections", "db_idle_connections", "db_wait_count_total"} {
+			if _, ok := values[name]; !ok {
+				t.Errorf("缺少连接池指标 %s", name)
+			}
+		}
+	})
+}
+
+func TestMetricsAccess(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	env.get("/metrics").expect(http.StatusNotFound) // 未启用指标
+
+	// httptest请求的对端地址为192.0.2.1，X-Forwarded-For不影响判断
+	env.withMetrics("", "10.0.0.0/8", "127.0.0.1")
+	env.get("/metrics", withHeader("X-Forwarded-For", "10.0.0.1")).expectCode(http.StatusForbidden, "FORBIDDEN")
+	env.withMetrics("", "192.0.2.0/24")
+	env.get("/metrics").expect(http.StatusOK)
+
+	env.withMetrics("scrape-token", "192.0.2.1")
+	env.get("/metrics").expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+	env.get("/metrics", withHeader("Authorization", "Bearer wrong")).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+	env.get("/metrics", withHeader("Authorization", "Bearer scrape-token")).expect(http.StatusOK)
+
+	if _, err := middleware.MetricsAccess("", []string{"not-a-network"}); err == nil {
+		t.Fatal("无效的网段应返回错误")
+	}
+}
+
+func TestMetricsFormat(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.NewCounter("jobs_total", "处理的任务数", "queue")
+	c.Inc(`a"b`)
+	c.Add(2, "default")
+	h := r.NewHistogram("job_seconds", "任务耗时", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+	r.GaugeFunc("queue_depth", "队列长度\n（抓取时计算）", func(context.Context) (float64, error) { return 7, nil })
+	r.GaugeFunc("broken", "计算失败的指标不输出", func(context.Context) (float64, error) {
+		return 0, errors.New("数据库不可用")
+	})
+
+	var buf bytes.Buffer
+	if err := r.Write(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP job_seconds 任务耗时
+# TYPE job_seconds histogram
+job_seconds_bucket{le="0.1"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.55
+job_seconds_count 3
+# HELP jobs_total 处理的任务数
+# TYPE jobs_total counter
+jobs_total{queue="a\"b"} 1
+jobs_total{queue="default"} 2
+# HELP queue_depth 队列长度\n（抓取时计算）
+# TYPE queue_depth gauge
+queue_depth 7
+`
+	if buf.String() != expected {
+		t.Fatalf("输出格式不正确:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/totp"
+)
+
+// withMFA 使用两步验证策略和可拨动的时钟重新创建路由
+func (e *testEnv) withMFA(policy service.MFAPolicy, clock *fakeClock) {
+	e.t.Helper()
+	opts := e.options()
+	opts.MFA, opts.MFAIssuer, opts.Now = policy, "Example", clock.Now
+	e.router = New(opts)
+}
+
+// passcode 验证器App在at时刻显示的验证码
+func passcode(t testing.TB, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Counter(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// recoveryCodes 响应中的恢复码
+func recoveryCodes(resp *response) []string {
+	var codes []string
+	for _, code := range resp.path("recovery_codes").([]interface{}) {
+		codes = append(codes, code.(string))
+	}
+	return codes
+}
+
+// bearer 携带会话令牌的认证头
+func bearer(token string) requestOption {
+	return withHeader("Authorization", "Bearer "+token)
+}
+
+// enableMFA 以as的身份绑定并启用两步验证，返回密钥、恢复码和新的会话令牌
+func enableMFA(env *testEnv, clock *fakeClock, as requestOption) (secret string, codes []string, token string) {
+	env.t.Helper()
+	secret = env.post("/api/v1/mfa/totp", nil, as).expect(http.StatusOK).path("secret").(string)
+	resp := env.post("/api/v1/mfa/totp/confirm", map[string]string{"code": passcode(env.t, secret, clock.now)}, as).
+		expect(http.StatusOK)
+	return secret, recoveryCodes(resp), resp.path("token").(string)
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238附录B的测试向量（取后6位）
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for at, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		if got, err := totp.Code(secret, totp.Counter(time.Unix(at, 0))); err != nil || got != want {
+			t.Errorf("时间%d的验证码应为%s: %s %v", at, want, got, err)
+		}
+	}
+
+	// 允许前后各一个时间步的偏差，返回验证码所在的时间步
+	now := time.Unix(1234567890, 0)
+	early := passcode(t, secret, now.Add(-totp.Period))
+	if counter, ok, err := totp.Validate(secret, early, now, 1); err != nil || !ok || counter != totp.Counter(now)-1 {
+		t.Fatalf("上一时间步的验证码应有效: %d %v %v", counter, ok, err)
+	}
+	if _, ok, _ := totp.Validate(secret, early, now.Add(totp.Period), 1); ok {
+		t.Fatal("超出偏差的验证码不应有效")
+	}
+	if _, _, err := totp.Validate("不是Base32", early, now, 1); err != totp.ErrInvalidSecret {
+		t.Fatalf("密钥无效时应返回ErrInvalidSecret: %v", err)
+	}
+
+	uri := totp.URI("Example", "alice", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Example:alice?") || !strings.Contains(uri, "secret="+secret) ||
+		!strings.Contains(uri, "issuer=Example") {
+		t.Fatalf("otpauth URI不正确: %s", uri)
+	}
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)}
+		env.withMFA(service.DefaultMFAPolicy(), clock)
+		env.createUser(userOpts{Username: "alice"})
+		as := env.asUser("alice")
+
+		if env.get("/api/v1/mfa", as).expect(http.StatusOK).path("data.enabled") != false {
+			t.Fatal("新用户不应启用两步验证")
+		}
+		env.get("/api/v1/mfa").expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.post("/api/v1/mfa/totp/confirm", map[string]string{"code": "123456"}, as).expectCode(http.StatusConflict, "CONFLICT")
+		env.post("/api/v1/mfa/totp/disable", map[string]string{"code": "123456"}, as).expectCode(http.StatusConflict, "CONFLICT")
+
+		// 重新生成密钥后，之前的密钥失效
+		stale := env.post("/api/v1/mfa/totp", nil, as).expect(http.StatusOK).path("secret").(string)
+		enrolled := env.post("/api/v1/mfa/totp", nil, as).expect(http.StatusOK)
+		secret := enrolled.path("secret").(string)
+		if secret == stale || enrolled.path("otpauth_uri") != totp.URI("Example", "alice", secret) {
+			t.Fatalf("绑定信息不正确: %s", enrolled.Body)
+		}
+		env.post("/api/v1/mfa/totp/confirm", map[string]string{"code": passcode(t, stale, clock.now)}, as).
+			expectError(http.StatusBadRequest, "验证码错误")
+		env.post("/api/v1/mfa/totp/confirm", map[string]string{}, as).expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+
+		// 启用后返回恢复码和新的会话令牌，之前签发的令牌失效
+		clock.now = clock.now.Add(time.Second)
+		activated := env.post("/api/v1/mfa/totp/confirm", map[string]string{"code": passcode(t, secret, clock.now)}, as).
+			expect(http.StatusOK)
+		if codes := recoveryCodes(activated); len(codes) != 10 {
+			t.Fatalf("应生成10个恢复码: %v", codes)
+		}
+		env.get("/api/v1/mfa", as).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		status := env.get("/api/v1/mfa", bearer(activated.path("token").(string))).expect(http.StatusOK)
+		if status.path("data.enabled") != true || status.path("data.recovery_codes_remaining") != float64(10) {
+			t.Fatalf("两步验证状态不正确: %s", status.Body)
+		}
+		env.post("/api/v1/mfa/totp", nil, bearer(activated.path("token").(string))).expectCode(http.StatusConflict, "CONFLICT")
+	})
+}
+
+func TestMFALogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)}
+		env.withMFA(service.MFAPolicy{ChallengeTTL: 5 * time.Minute, MaxFailures: 2}, clock)
+		env.createUser(userOpts{Username: "alice"})
+		secret, codes, token := enableMFA(env, clock, env.asUser("alice"))
+
+		challenge := func() string {
+			t.Helper()
+			resp := login(env, "alice", defaultPassword).expect(http.StatusOK)
+			if resp.path("mfa_required") != true || resp.path("token") != nil {
+				t.Fatalf("启用两步验证后密码登录不应直接返回令牌: %s", resp.Body)
+			}
+			return resp.path("mfa_token").(string)
+		}
+		complete := func(mfaToken, code string) *response {
+			t.Helper()
+			return env.post("/api/v1/sessions/mfa", map[string]string{"mfa_token": mfaToken, "code": code})
+		}
+
+		// 启用时使用过的验证码不能再次使用
+		mfaToken := challenge()
+		complete(mfaToken, passcode(t, secret, clock.now)).expectError(http.StatusBadRequest, "验证码错误")
+		clock.now = clock.now.Add(totp.Period)
+		resp := complete(mfaToken, passcode(t, secret, clock.now)).expect(http.StatusOK)
+		if resp.path("username") != "alice" || resp.path("mfa_required") != false {
+			t.Fatalf("登录响应不正确: %s", resp.Body)
+		}
+		env.get("/api/v1/mfa", bearer(resp.path("token").(string))).expect(http.StatusOK)
+		env.get("/api/v1/mfa", bearer(token)).expect(http.StatusOK)
+		// 登录验证只能使用一次
+		clock.now = clock.now.Add(totp.Period)
+		complete(mfaToken, passcode(t, secret, clock.now)).expectError(http.StatusUnauthorized, "登录验证已失效，请重新登录")
+
+		// 恢复码只能使用一次，忽略大小写和连字符
+		complete(challenge(), strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))).expect(http.StatusOK)
+		complete(challenge(), codes[0]).expectError(http.StatusBadRequest, "验证码错误")
+
+		// 输错达到上限后需重新输入密码
+		mfaToken = challenge()
+		complete(mfaToken, passcode(t, secret, clock.now.Add(time.Hour))).expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		complete(mfaToken, codes[9]+"x").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		complete(mfaToken, codes[1]).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+
+		// 登录验证过期
+		mfaToken = challenge()
+		clock.now = clock.now.Add(5*time.Minute + time.Second)
+		complete(mfaToken, codes[1]).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		complete("forged", codes[1]).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+
+		// 重新生成恢复码后之前的恢复码失效；关闭后密码登录直接返回令牌
+		regenerated := env.post("/api/v1/mfa/recovery-codes", map[string]string{"code": codes[1]}, bearer(token)).
+			expect(http.StatusOK)
+		env.post("/api/v1/mfa/totp/disable", map[string]string{"code": codes[2]}, bearer(token)).
+			expectError(http.StatusBadRequest, "验证码错误")
+		status := env.get("/api/v1/mfa", bearer(token)).expect(http.StatusOK)
+		if status.path("data.recovery_codes_remaining") != float64(len(recoveryCodes(regenerated))) {
+			t.Fatalf("恢复码数量不正确: %s", status.Body)
+		}
+		clock.now = clock.now.Add(totp.Period)
+		env.post("/api/v1/mfa/totp/disable", map[string]string{"code": passcode(t, secret, clock.now)}, bearer(token)).
+			expect(http.StatusOK)
+		if login(env, "alice", defaultPassword).expect(http.StatusOK).path("token") == nil {
+			t.Fatal("关闭两步验证后应直接返回令牌")
+		}
+		if n, err := env.store.MFA().CountRecoveryCodes(env.ctx(), uint(resp.path("user_id").(float64))); err != nil || n != 0 {
+			t.Fatalf("关闭后应删除恢复码: %d %v", n, err)
+		}
+	})
+}
+
+func TestAdminMFAPolicy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)}
+		policy := service.DefaultMFAPolicy()
+		policy.RequireAdmin = true
+		env.withMFA(policy, clock)
+		admin := env.createAdmin()
+
+		// 未启用两步验证的管理员不能访问管理接口，但可以完成绑定
+		env.get("/api/v1/users", env.asUser(admin.Username)).expectCode(http.StatusForbidden, "MFA_REQUIRED")
+		_, _, token := enableMFA(env, clock, env.asUser(admin.Username))
+		env.get("/api/v1/users", bearer(token)).expect(http.StatusOK)
+
+		// 重置管理员账号同时关闭两步验证，用于找回丢失的验证器
+		if _, err := env.services.Users.EnsureAdmin(env.ctx(), admin.Username, admin.Email, "newsecret123"); err != nil {
+			t.Fatal(err)
+		}
+		env.get("/api/v1/users", bearer(token)).expectCode(http.StatusForbidden, "MFA_REQUIRED")
+		if login(env, admin.Username, "newsecret123").expect(http.StatusOK).path("mfa_required") != false {
+			t.Fatal("重置后应关闭两步验证")
+		}
+	})
+}
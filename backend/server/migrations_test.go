@@ -0,0 +1,83 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"questionnaire-system/backend/config"
+	"questionnaire-system/backend/database"
+	"questionnaire-system/backend/database/migrations"
+)
+
+// openInstance 模拟一个实例：各自打开同一个SQLite文件数据库
+func openInstance(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Env = config.EnvTest
+	cfg.Database.Driver = database.DriverSQLite
+	cfg.Database.DSN = config.Secret(path)
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db.DB
+}
+
+func TestMigrationLockIsNotTakenOverWhileHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.db")
+	first := migrations.New(openInstance(t, path))
+	second := migrations.New(openInstance(t, path))
+
+	// 锁在300毫秒未刷新后视为失效，第一个实例的迁移执行远超这个时间
+	started := make(chan struct{})
+	release := make(chan struct{})
+	noop := func(*gorm.DB) error { return nil }
+	first.StaleLock = 300 * time.Millisecond
+	first.Migrations = []migrations.Migration{{
+		Version: 1,
+		Name:    "slow",
+		Up: func(*gorm.DB) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}}
+	second.StaleLock = 300 * time.Millisecond
+	second.LockTimeout = 1500 * time.Millisecond
+	second.Migrations = []migrations.Migration{{Version: 1, Name: "slow", Up: noop, Down: noop}}
+
+	firstDone := make(chan error, 1)
+	go func() { firstDone <- first.Up() }()
+	<-started
+
+	// 第二个实例等待超过数倍StaleLock后超时，而不是清除仍在刷新的锁
+	err := second.Up()
+	if err == nil || !strings.Contains(err.Error(), "等待迁移锁超时") {
+		t.Fatalf("持有中的迁移锁被接管: %v", err)
+	}
+
+	close(release)
+	if err := <-firstDone; err != nil {
+		t.Fatalf("第一个实例迁移失败: %v", err)
+	}
+	if current, _ := second.Current(); current != 1 {
+		t.Fatalf("迁移版本为%d，期望1", current)
+	}
+
+	// 锁释放后另一个实例可以立即加锁
+	second.LockTimeout = 0
+	if err := second.Down(1); err != nil {
+		t.Fatalf("锁释放后加锁失败: %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+
+	"questionnaire-system/backend/apierror"
+	"questionnaire-system/backend/health"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/openapi"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/webhook"
+)
+
+// 接口分组
+const (
+	tagSystem         = "系统"
+	tagUsers          = "用户"
+	tagQuestionnaires = "问卷"
+	tagSubmissions    = "答卷"
+	tagWebhooks       = "Webhook"
+	tagAdmin          = "管理后台"
+)
+
+// securityBearer 文档中的Bearer认证方式
+const securityBearer = "bearerAuth"
+
+// newSpec 根据/api/v1的路由表生成OpenAPI文档
+// 管理后台接口和用户管理自己账户的接口要求Bearer认证；所有接口的错误响应都使用统一的错误结构
+func newSpec(routes []route) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "问卷系统API",
+		Version:     "1.0.0",
+		Description: "旧的/api/*接口仍然可用，但已废弃，响应头Link指向对应的/api/v1接口。",
+	})
+	doc.Servers = []openapi.Server{{URL: apiV1}}
+	doc.Components.SecuritySchemes[securityBearer] = openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "登录接口返回的会话令牌，由服务端签名；过期、用户重置密码或启用两步验证后失效",
+	}
+	for _, tag := range []string{tagSystem, tagUsers, tagQuestionnaires, tagSubmissions, tagWebhooks, tagAdmin} {
+		doc.Tags = append(doc.Tags, openapi.Tag{Name: tag})
+	}
+	registerSchemas(doc)
+
+	for _, r := range routes {
+		if (r.admin || r.requiresLogin()) && len(r.doc.Security) == 0 {
+			r.doc.WithSecurity(securityBearer)
+		}
+		doc.AddOperation(r.method, r.path, r.doc)
+	}
+	return doc
+}
+
+// registerSchemas 注册文档中引用的组件，响应中的模型由Go类型生成
+func registerSchemas(doc *openapi.Document) {
+	doc.Register("Error", apierror.Envelope{})
+
+	user := doc.Register("User", models.User{})
+	delete(user.Properties, "password")
+	doc.Register("Questionnaire", models.Questionnaire{})
+	doc.Register("Question", models.Question{})
+	doc.Register("Submission", models.Submission{})
+	doc.Register("Translation", service.Translation{})
+	doc.Register("QuestionnaireSummary", service.QuestionnaireSummary{})
+	doc.Register("QuestionnaireOverview", service.QuestionnaireOverview{})
+	doc.Register("UserDetail", service.UserDetail{})
+	doc.Register("MFAStatus", service.MFAStatus{})
+	doc.Register("WebhookSubscription", models.WebhookSubscription{})
+	doc.Register("WebhookDelivery", models.WebhookOutbox{})
+	doc.Register("WebhookDeliveryAttempt", models.WebhookDeliveryAttempt{})
+	report := doc.Register("HealthReport", health.Report{})
+	report.Properties["status"] = healthStatus()
+
+	// 请求体
+	doc.Components.Schemas["RegisterRequest"] = openapi.Object(map[string]*openapi.Schema{
+		"username": openapi.String(),
+		"password": openapi.String(),
+		"email":    openapi.String(),
+		"phone":    openapi.String(),
+	}, "username", "password", "email")
+
+	doc.Components.Schemas["QuestionnaireInput"] = openapi.Object(map[string]*openapi.Schema{
+		"title":          openapi.String(),
+		"description":    openapi.String(),
+		"created_by":     openapi.Integer(),
+		"start_time":     openapi.DateTime(),
+		"end_time":       openapi.DateTime(),
+		"is_published":   openapi.Boolean().Describe("仅创建时有效"),
+		"default_locale": openapi.String().Describe("原文语言，默认zh-CN"),
+		"require_verified_email": openapi.Boolean().
+			Describe("只允许已验证邮箱的用户填写，未验证的用户提交时返回403 EMAIL_UNVERIFIED"),
+		"questions": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+			"title": openapi.String(),
+			"type": openapi.Enum(models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice,
+				models.QuestionTypeText, models.QuestionTypeRating),
+			"required": openapi.Boolean(),
+			"options":  openapi.String().Describe("JSON数组或逗号分隔的选项"),
+		}, "title", "type")),
+		"translations": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+			"locale":      openapi.String(),
+			"title":       openapi.String(),
+			"description": openapi.String(),
+			"questions": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+				"title":   openapi.String(),
+				"options": openapi.Array(openapi.String()),
+			})).Describe("与问卷的问题按位置对应"),
+		}, "locale")),
+	}, "title", "created_by", "questions")
+
+	doc.Components.Schemas["WebhookRequest"] = openapi.Object(map[string]*openapi.Schema{
+		"questionnaire_id": openapi.Integer().Describe("为空时创建全局订阅（仅管理员）"),
+		"target_url":       openapi.String(),
+		"secret":           openapi.String().Describe("为空时自动生成"),
+		"events":           openapi.Array(openapi.Enum(webhook.Events...)),
+	}, "target_url", "events")
+}
+
+// operation 创建接口文档：status为成功响应，其他状态码为统一的错误响应
+func operation(id, tag, summary string, status int, body *openapi.Schema) *openapi.Operation {
+	op := &openapi.Operation{OperationID: id, Summary: summary, Tags: []string{tag}}
+	return op.
+		WithResponse(strconv.Itoa(status), openapi.JSON("成功", body)).
+		WithResponse("default", openapi.JSON("错误", openapi.Ref("Error")))
+}
+
+// probe 健康检查接口：成功和不可用时返回相同结构，状态码分别为200和503
+func probe(id, summary string, body *openapi.Schema) *openapi.Operation {
+	op := &openapi.Operation{OperationID: id, Summary: summary, Tags: []string{tagSystem}}
+	return op.
+		WithResponse("200", openapi.JSON("可用", body)).
+		WithResponse("503", openapi.JSON("不可用", body))
+}
+
+// healthStatus 健康检查状态
+func healthStatus() *openapi.Schema {
+	return openapi.Enum(health.StatusOK, health.StatusDegraded, health.StatusUnavailable)
+}
+
+// download 返回文件或事件流的接口，需要问卷结果的查看权限
+func download(id, summary, contentType string) *openapi.Operation {
+	op := &openapi.Operation{OperationID: id, Summary: summary, Tags: []string{tagSubmissions}}
+	return op.
+		WithResponse("200", openapi.Binary("成功", contentType)).
+		WithResponse("default", openapi.JSON("错误", openapi.Ref("Error"))).
+		WithSecurity(securityBearer)
+}
+
+// codeBody 提交验证码（或恢复码）的请求体
+func codeBody() *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"code": openapi.String().Describe("验证器App中的6位验证码或一个恢复码"),
+	}, "code")
+}
+
+// loginSchema 登录成功的响应（密码登录和单点登录相同）
+func loginSchema() *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"success":  openapi.Boolean(),
+		"message":  openapi.String(),
+		"user_id":  openapi.Integer(),
+		"username": openapi.String(),
+		"email":    openapi.String(),
+		"is_admin": openapi.Boolean(),
+		"token":    openapi.String().Describe("会话令牌，mfa_required为true时没有该字段"),
+
+		"email_verified": openapi.Boolean(),
+		"mfa_required":   openapi.Boolean(),
+		"mfa_token":      openapi.String().Describe("登录挑战令牌，提交到 POST /sessions/mfa"),
+		"mfa_expires_at": openapi.DateTime(),
+	}, "success", "user_id", "username", "is_admin", "email_verified", "mfa_required")
+}
+
+// envelope 成功响应：{success, message, data}
+func envelope(data *openapi.Schema) *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"success": openapi.Boolean(),
+		"message": openapi.String(),
+		"data":    data,
+	}, "success", "data")
+}
+
+// messageSchema 只有提示信息的成功响应
+func messageSchema() *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"success": openapi.Boolean(),
+		"message": openapi.String(),
+	}, "success", "message")
+}
+
+// paged 分页列表：列表字段及page_size、next_cursor，页码分页时的page和要求统计时的total
+func paged(listKey string, item *openapi.Schema, extra map[string]*openapi.Schema) *openapi.Schema {
+	properties := map[string]*openapi.Schema{
+		listKey:       openapi.Array(item),
+		"page_size":   openapi.Integer(),
+		"next_cursor": openapi.String().Describe("没有下一页时为空"),
+		"page":        openapi.Integer(),
+		"total":       openapi.Integer(),
+	}
+	required := []string{listKey, "page_size", "next_cursor"}
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		properties[name] = extra[name]
+		required = append(required, name)
+	}
+	return openapi.Object(properties, required...)
+}
+
+// pageParams 分页参数
+func pageParams() []openapi.Parameter {
+	return []openapi.Parameter{
+		openapi.QueryParam("cursor", "上一页返回的next_cursor", openapi.String(), false),
+		openapi.QueryParam("limit", "每页数量（超过上限时按上限处理）", openapi.Integer(), false),
+		openapi.QueryParam("page_size", "limit的别名", openapi.Integer(), false),
+		openapi.QueryParam("page", "页码（兼容旧客户端，建议使用cursor）", openapi.Integer(), false),
+		openapi.QueryParam("include_total", "为true时返回total", openapi.Boolean(), false),
+	}
+}
+
+// questionnaireWithQuestions 问卷及其问题
+func questionnaireWithQuestions() *openapi.Schema {
+	return openapi.Object(map[string]*openapi.Schema{
+		"questionnaire": openapi.Ref("Questionnaire"),
+		"questions":     openapi.Array(openapi.Ref("Question")),
+	}, "questionnaire", "questions")
+}
+
+// counters 统计数字
+func counters(names ...string) *openapi.Schema {
+	properties := make(map[string]*openapi.Schema, len(names))
+	for _, name := range names {
+		properties[name] = openapi.Number()
+	}
+	return openapi.Object(properties, names...)
+}
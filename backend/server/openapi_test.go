@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/openapi"
+)
+
+// contract 用服务提供的OpenAPI文档校验/api/v1的每个请求和响应，并记录调用过的接口
+type contract struct {
+	env     *testEnv
+	doc     *openapi.Document
+	covered map[string]bool // operationId
+}
+
+// contract 获取并校验OpenAPI文档
+func (e *testEnv) contract() *contract {
+	e.t.Helper()
+
+	resp := e.get(apiV1 + "/openapi.json").expect(http.StatusOK)
+	var doc openapi.Document
+	if err := json.Unmarshal(resp.Body, &doc); err != nil {
+		e.t.Fatalf("解析OpenAPI文档失败: %v", err)
+	}
+	if err := doc.Validate(); err != nil {
+		e.t.Fatal(err)
+	}
+	return &contract{env: e, doc: &doc, covered: map[string]bool{"getOpenAPI": true}}
+}
+
+// do 发送/api/v1请求（path不含前缀），请求和响应不符合文档时测试失败
+func (c *contract) do(method, path string, body interface{}, opts ...requestOption) *response {
+	t := c.env.t
+	t.Helper()
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.doc.ValidateRequest(method, path, data); err != nil {
+		t.Errorf("请求不符合文档: %v", err)
+	}
+
+	resp := c.env.do(method, apiV1+path, body, opts...)
+	u, _ := url.Parse(path)
+	if err := c.doc.ValidateResponse(method, u.Path, resp.Code, resp.Header.Get("Content-Type"), resp.Body); err != nil {
+		t.Errorf("响应不符合文档: %v\n%s", err, resp.Body)
+	}
+	if op, _, ok := c.doc.Match(method, u.Path); ok {
+		c.covered[op.OperationID] = true
+	}
+	return resp
+}
+
+func (c *contract) get(path string, opts ...requestOption) *response {
+	c.env.t.Helper()
+	return c.do(http.MethodGet, path, nil, opts...)
+}
+
+var ginParamPattern = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	c := env.contract()
+
+	registered := make(map[string]bool)
+	for _, r := range env.router.Routes() {
+		if !strings.HasPrefix(r.Path, apiV1+"/") {
+			continue
+		}
+		path := ginParamPattern.ReplaceAllString(strings.TrimPrefix(r.Path, apiV1), "{$1}")
+		registered[r.Method+" "+path] = true
+		if _, ok := c.doc.Paths[path][strings.ToLower(r.Method)]; !ok {
+			t.Errorf("接口 %s %s 没有写入OpenAPI文档", r.Method, path)
+		}
+	}
+	for path, item := range c.doc.Paths {
+		for _, method := range item.Methods() {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				t.Errorf("文档中的接口 %s %s 没有注册", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	// 文档即接口契约，修改时在评审中可以看到差异
+	env.get(apiV1 + "/openapi.json").expect(http.StatusOK).assertGolden("openapi")
+}
+
+func TestAPIV1Contract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		c := env.contract()
+		admin := env.createAdmin()
+		asAdmin := env.asUser(admin.Username)
+
+		c.get("/health").expect(http.StatusOK)
+		c.get("/health/live").expect(http.StatusOK)
+		c.get("/health/ready").expect(http.StatusOK)
+
+		// 用户
+		c.do(http.MethodPost, "/users", map[string]string{
+			"username": "owner", "password": "secret123", "email": "owner@example.com",
+		}).expect(http.StatusCreated)
+		login := c.do(http.MethodPost, "/sessions", map[string]string{"username": "owner", "password": "secret123"}).
+			expect(http.StatusOK)
+		ownerID := uint(login.path("user_id").(float64))
+		asOwner := env.asUser("owner")
+		c.do(http.MethodPost, "/sessions", map[string]string{"username": "owner", "password": "wrong"}).
+			expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		c.do(http.MethodPost, "/password-resets", map[string]string{"email": "owner@example.com"}).
+			expect(http.StatusOK)
+		c.do(http.MethodPost, "/password-resets/confirm", map[string]string{"token": "forged", "new_password": "secret456"}).
+			expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		c.do(http.MethodPost, "/email-verifications", map[string]string{"email": "nobody@example.com"}).
+			expect(http.StatusOK)
+		c.do(http.MethodPost, "/email-verifications/confirm", map[string]string{"token": "forged"}).
+			expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		// 未启用单点登录
+		c.get("/login-options").expect(http.StatusOK)
+		c.do(http.MethodPost, "/sso/oidc/authorizations", nil).expectCode(http.StatusNotFound, "NOT_FOUND")
+		c.do(http.MethodPost, "/sessions/oidc", map[string]string{"code": "forged", "state": "forged"}).
+			expectCode(http.StatusNotFound, "NOT_FOUND")
+
+		// 问卷
+		payload := translatedPayload(ownerID)
+		created := c.do(http.MethodPost, "/questionnaires", payload).expect(http.StatusCreated)
+		id := uint(created.path("data.questionnaire.id").(float64))
+		choiceID := created.path("data.questions").([]interface{})[0].(map[string]interface{})["id"]
+
+		c.get(fmt.Sprintf("/questionnaires?user_id=%d&limit=1&include_total=true", ownerID)).expect(http.StatusOK)
+		c.get(fmt.Sprintf("/questionnaires/%d?include_translations=true", id)).expect(http.StatusOK)
+		c.get("/questionnaires/abc").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		c.get("/questionnaires/999").expectCode(http.StatusNotFound, "QUESTIONNAIRE_NOT_FOUND")
+
+		draft := c.do(http.MethodPost, "/questionnaires", questionnairePayload(ownerID, "草稿")).expect(http.StatusCreated)
+		draftID := uint(draft.path("data.questionnaire.id").(float64))
+		update := questionnairePayload(ownerID, "草稿（修订）")
+		update["id"] = 12345 // 以路径中的ID为准
+		c.do(http.MethodPut, fmt.Sprintf("/questionnaires/%d", draftID), update).expect(http.StatusOK)
+		c.do(http.MethodPut, fmt.Sprintf("/questionnaires/%d/status", draftID), map[string]bool{"is_published": true}).
+			expect(http.StatusOK)
+		c.do(http.MethodPut, fmt.Sprintf("/questionnaires/%d", draftID), update).
+			expectCode(http.StatusBadRequest, "QUESTIONNAIRE_READ_ONLY")
+
+		// 答卷
+		respondent := env.createUser(userOpts{})
+		answer := map[string]interface{}{
+			"answers": []map[string]interface{}{{"question_id": choiceID, "content": "Male"}},
+			"locale":  "en",
+		}
+		asRespondent := env.asUser(respondent.Username)
+		c.do(http.MethodPost, fmt.Sprintf("/questionnaires/%d/submissions", id), answer).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		c.do(http.MethodPost, fmt.Sprintf("/questionnaires/%d/submissions", id), answer, asRespondent).expect(http.StatusCreated)
+		c.do(http.MethodPost, fmt.Sprintf("/questionnaires/%d/submissions", id), answer, asRespondent).
+			expectCode(http.StatusConflict, "ALREADY_SUBMITTED")
+		status := c.get(fmt.Sprintf("/questionnaires/%d/submission-status?user_id=%d", id, respondent.ID)).expect(http.StatusOK)
+		if status.path("has_submitted") != true {
+			t.Fatalf("提交状态不正确: %s", status.Body)
+		}
+		c.get(fmt.Sprintf("/questionnaires/%d/submission-status?user_id=%d", id, ownerID)).expect(http.StatusOK)
+		c.get(fmt.Sprintf("/questionnaires/%d/submissions", id), asOwner).expect(http.StatusOK)
+		c.get(fmt.Sprintf("/questionnaires/%d/submissions", id), env.asUser(respondent.Username)).
+			expectCode(http.StatusForbidden, "FORBIDDEN")
+		c.get("/stats").expect(http.StatusOK)
+
+		// 管理后台
+		c.get("/users?limit=10", asAdmin).expect(http.StatusOK)
+		c.get("/users").expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		c.get(fmt.Sprintf("/users/%d", ownerID), asAdmin).expect(http.StatusOK)
+		c.do(http.MethodPut, fmt.Sprintf("/users/%d", ownerID), map[string]interface{}{"email": "new@example.com"}, asAdmin).
+			expect(http.StatusOK)
+		c.do(http.MethodPost, fmt.Sprintf("/users/%d/unlock", ownerID), nil, asAdmin).expect(http.StatusOK)
+		c.get("/admin/questionnaires?include_total=true", asAdmin).expect(http.StatusOK)
+		c.get(fmt.Sprintf("/admin/questionnaires/%d/submissions", id), asAdmin).expect(http.StatusOK)
+		c.get("/admin/statistics", asAdmin).expect(http.StatusOK)
+
+		for _, format := range []string{"xlsx", "sav", "csv-bundle", "pdf"} {
+			c.get(fmt.Sprintf("/questionnaires/%d/exports/%s", id, format), asOwner).expect(http.StatusOK)
+		}
+		c.do(http.MethodPost, fmt.Sprintf("/questionnaires/%d/results/stream-tokens", id), nil, asOwner).expect(http.StatusCreated)
+
+		hook := c.do(http.MethodPost, "/webhooks", map[string]interface{}{
+			"questionnaire_id": id,
+			"target_url":       "https://example.com/hook",
+			"events":           []string{"submission.created"},
+		}, asOwner).expect(http.StatusCreated)
+		hookID := uint(hook.path("data.subscription.id").(float64))
+		c.get(fmt.Sprintf("/webhooks?questionnaire_id=%d", id), asOwner).expect(http.StatusOK)
+		c.get(fmt.Sprintf("/webhooks/%d/deliveries", hookID), asOwner).expect(http.StatusOK)
+		c.do(http.MethodPost, "/webhook-deliveries/999/redeliver", nil, asOwner).
+			expectCode(http.StatusNotFound, "NOT_FOUND")
+		c.do(http.MethodDelete, fmt.Sprintf("/webhooks/%d", hookID), nil, asOwner).expect(http.StatusOK)
+
+		c.do(http.MethodDelete, fmt.Sprintf("/questionnaires/%d", draftID), nil).expect(http.StatusOK)
+		c.do(http.MethodDelete, fmt.Sprintf("/users/%d", respondent.ID), nil, asAdmin).expect(http.StatusOK)
+
+		// 两步验证：启用后之前的会话令牌失效，放在最后
+		c.get("/mfa", asOwner).expect(http.StatusOK)
+		secret := c.do(http.MethodPost, "/mfa/totp", nil, asOwner).expect(http.StatusOK).path("secret").(string)
+		activated := c.do(http.MethodPost, "/mfa/totp/confirm", map[string]string{"code": passcode(t, secret, time.Now())}, asOwner).
+			expect(http.StatusOK)
+		codes := recoveryCodes(activated)
+		asOwner = bearer(activated.path("token").(string))
+		challenge := c.do(http.MethodPost, "/sessions", map[string]string{"username": "owner", "password": "secret123"}).
+			expect(http.StatusOK)
+		c.do(http.MethodPost, "/sessions/mfa", map[string]string{"mfa_token": challenge.path("mfa_token").(string), "code": codes[0]}).
+			expect(http.StatusOK)
+		c.do(http.MethodPost, "/sessions/mfa", map[string]string{"mfa_token": "forged", "code": codes[1]}).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		codes = recoveryCodes(c.do(http.MethodPost, "/mfa/recovery-codes", map[string]string{"code": codes[1]}, asOwner).
+			expect(http.StatusOK))
+		c.do(http.MethodPost, "/mfa/totp/disable", map[string]string{"code": "abcde-fghij"}, asOwner).
+			expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		c.do(http.MethodPost, "/mfa/totp/disable", map[string]string{"code": codes[0]}, asOwner).expect(http.StatusOK)
+
+		// 除事件流外每个接口都经过了文档校验
+		var missing []string
+		for _, item := range c.doc.Paths {
+			for _, op := range item {
+				if !c.covered[op.OperationID] && op.OperationID != "streamResults" {
+					missing = append(missing, op.OperationID)
+				}
+			}
+		}
+		sort.Strings(missing)
+		if len(missing) > 0 {
+			t.Errorf("以下接口没有经过契约测试: %v", missing)
+		}
+	})
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+
+		resp := env.get(fmt.Sprintf("/api/questionnaire/detail?id=%d", q.ID)).expect(http.StatusOK)
+		if resp.Header.Get("Deprecation") != "true" ||
+			resp.Header.Get("Link") != `</api/v1/questionnaires/{id}>; rel="successor-version"` {
+			t.Fatalf("旧接口缺少废弃标记: %v", resp.Header)
+		}
+
+		// 新旧接口返回相同的数据
+		legacy := resp.path("data.questionnaire")
+		current := env.get(fmt.Sprintf("/api/v1/questionnaires/%d", q.ID)).expect(http.StatusOK)
+		if fmt.Sprint(current.path("data.questionnaire")) != fmt.Sprint(legacy) {
+			t.Fatalf("新旧接口的数据不一致: %s", current.Body)
+		}
+		if current.Header.Get("Deprecation") != "" {
+			t.Fatalf("新接口不应标记为废弃")
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// walk 沿next_cursor取完列表的全部页，返回每条记录（或其idPath字段）的id
+func (e *testEnv) walk(path, listKey, idPath string, opts ...requestOption) (ids []uint, pages int) {
+	e.t.Helper()
+
+	cursor := ""
+	for {
+		p := path
+		if cursor != "" {
+			p += "&cursor=" + url.QueryEscape(cursor)
+		}
+		resp := e.get(p, opts...).expect(http.StatusOK)
+		pages++
+		items, _ := resp.path("data." + listKey).([]interface{})
+		for _, item := range items {
+			v := item.(map[string]interface{})
+			if idPath != "" {
+				v = v[idPath].(map[string]interface{})
+			}
+			ids = append(ids, uint(v["id"].(float64)))
+		}
+		cursor, _ = resp.path("data.next_cursor").(string)
+		if cursor == "" {
+			return ids, pages
+		}
+		if pages > 100 {
+			e.t.Fatalf("分页没有结束: %s", path)
+		}
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createAdmin()
+		owner := env.createUser(userOpts{})
+		var want []uint
+		for i := 0; i < 5; i++ {
+			want = append([]uint{env.createQuestionnaire(owner, true).ID}, want...)
+		}
+
+		ids, pages := env.walk("/api/questionnaire/list?limit=2", "questionnaires", "questionnaire")
+		if fmt.Sprint(ids) != fmt.Sprint(want) || pages != 3 {
+			t.Fatalf("游标分页结果为%v（%d页），期望%v（3页）", ids, pages, want)
+		}
+
+		ids, _ = env.walk("/api/admin/questionnaires?page_size=3", "questionnaires", "questionnaire", env.asUser(admin.Username))
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Fatalf("管理后台游标分页结果为%v，期望%v", ids, want)
+		}
+
+		ids, _ = env.walk("/api/admin/users?limit=1", "users", "", env.asUser(admin.Username))
+		if fmt.Sprint(ids) != fmt.Sprint([]uint{owner.ID, admin.ID}) {
+			t.Fatalf("用户游标分页结果为%v", ids)
+		}
+	})
+}
+
+func TestPaginationTotalsAndLimits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		for i := 0; i < 3; i++ {
+			env.createQuestionnaire(owner, true)
+		}
+
+		// 默认不统计总数
+		resp := env.get("/api/questionnaire/list?limit=2").expect(http.StatusOK)
+		if _, ok := resp.json()["data"].(map[string]interface{})["total"]; ok {
+			t.Fatalf("未要求时不应返回总数: %s", resp.Body)
+		}
+
+		resp = env.get("/api/questionnaire/list?limit=2&include_total=true").expect(http.StatusOK)
+		if resp.path("data.total") != float64(3) || resp.path("data.next_cursor") == "" {
+			t.Fatalf("总数或游标不正确: %s", resp.Body)
+		}
+
+		// 超过上限时按上限处理
+		resp = env.get("/api/questionnaire/list?page_size=100000").expect(http.StatusOK)
+		if resp.path("data.page_size") != float64(100) {
+			t.Fatalf("每页数量未限制: %s", resp.Body)
+		}
+
+		env.get("/api/questionnaire/list?cursor=not-a-cursor").expectError(http.StatusBadRequest, "无效的分页游标")
+	})
+}
+
+func TestResultsPagination(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		admin := env.createAdmin()
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		var submissions []uint
+		for i := 0; i < 3; i++ {
+			submissions = append(submissions, env.submit(q, env.createUser(userOpts{})).ID)
+		}
+
+		path := fmt.Sprintf("/api/questionnaire/results?id=%d&limit=2", q.ID)
+		first := env.get(path, env.asUser(owner.Username)).expect(http.StatusOK)
+		if first.path("data.total_submissions") != float64(3) {
+			t.Fatalf("total_submissions应为全部答卷数: %s", first.Body)
+		}
+
+		ids, pages := env.walk(path, "submissions", "submission", env.asUser(owner.Username))
+		if fmt.Sprint(ids) != fmt.Sprint(submissions) || pages != 2 {
+			t.Fatalf("结果分页为%v（%d页），期望%v（2页）", ids, pages, submissions)
+		}
+
+		// 管理后台按提交时间倒序
+		ids, _ = env.walk(fmt.Sprintf("/api/admin/questionnaire/submissions?id=%d&limit=1", q.ID),
+			"submission_details", "submission", env.asUser(admin.Username))
+		if fmt.Sprint(ids) != fmt.Sprint([]uint{submissions[2], submissions[1], submissions[0]}) {
+			t.Fatalf("提交详情分页为%v", ids)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/mailer"
+)
+
+// outbox 将邮件写入临时目录（mailer.File），并通知测试已发送
+type outbox struct {
+	mailer.File
+	sent chan struct{}
+	read int // 已读取的邮件数
+}
+
+func (o *outbox) Send(ctx context.Context, msg mailer.Message) error {
+	err := o.File.Send(ctx, msg)
+	o.sent <- struct{}{}
+	return err
+}
+
+// withOutbox 使用写入临时目录的Mailer重新创建路由
+func (e *testEnv) withOutbox(ttl time.Duration) *outbox {
+	e.t.Helper()
+	return e.withMailOptions(Options{ResetTTL: ttl})
+}
+
+// withMailOptions 在opts（只需设置e.options()之外的选项）的基础上使用写入临时目录的Mailer重新创建路由
+func (e *testEnv) withMailOptions(opts Options) *outbox {
+	e.t.Helper()
+	box := &outbox{File: mailer.File{Dir: e.t.TempDir(), From: "noreply@example.com"}, sent: make(chan struct{}, 10)}
+	opts.DB, opts.Store, opts.Hub, opts.TokenSecret = e.db, e.store, e.hub, e.secret
+	opts.Mailer, opts.ResetURL, opts.VerificationURL = box, "https://example.com/reset?from=mail", "https://example.com/verify?from=mail"
+	e.router = New(opts)
+	return box
+}
+
+var (
+	resetLinkPattern  = regexp.MustCompile(`https://example\.com/reset\?from=mail&token=([A-Za-z0-9_-]+)`)
+	verifyLinkPattern = regexp.MustCompile(`https://example\.com/verify\?from=mail&token=([A-Za-z0-9_-]+)`)
+)
+
+// resetMail 等待下一封邮件，返回收件人、标题和重置令牌
+func (o *outbox) resetMail(t *testing.T) (to, subject, token string) {
+	t.Helper()
+	return o.linkMail(t, resetLinkPattern)
+}
+
+// verifyMail 等待下一封邮件，返回收件人、标题和邮箱验证令牌
+func (o *outbox) verifyMail(t *testing.T) (to, subject, token string) {
+	t.Helper()
+	return o.linkMail(t, verifyLinkPattern)
+}
+
+// linkMail 等待下一封邮件，返回收件人、标题和邮件中与link匹配的令牌
+func (o *outbox) linkMail(t *testing.T, link *regexp.Regexp) (to, subject, token string) {
+	t.Helper()
+	select {
+	case <-o.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到重置密码邮件")
+	}
+	// 文件名按时间排序
+	files, _ := filepath.Glob(filepath.Join(o.Dir, "*.eml"))
+	if len(files) <= o.read {
+		t.Fatalf("邮件没有写入%s", o.Dir)
+	}
+	name := files[o.read]
+	o.read++
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("邮件格式错误: %v", err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := link.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("邮件中没有链接%s: %s", link, body)
+	}
+	return msg.Header.Get("To"), subject, string(match[1])
+}
+
+func TestResetPassword(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		box := env.withOutbox(time.Hour)
+		admin := env.createAdmin()
+		env.get("/api/v1/users", env.asUser(admin.Username)).expect(http.StatusOK)
+
+		// 未注册的邮箱返回相同的结果，不发送邮件
+		unknown := env.post("/api/v1/password-resets", map[string]string{"email": "nobody@example.com"}).expect(http.StatusOK)
+		known := env.post("/api/v1/password-resets", map[string]string{"email": admin.Email}).expect(http.StatusOK)
+		if unknown.message() != known.message() {
+			t.Fatalf("已注册和未注册的邮箱返回了不同的结果: %q %q", unknown.message(), known.message())
+		}
+		to, subject, first := box.resetMail(t)
+		if to != "<"+admin.Email+">" || subject != "重置密码" {
+			t.Fatalf("收件人或标题错误: %q %q", to, subject)
+		}
+
+		// 再次申请时之前的令牌失效，邮件使用请求的语言
+		env.post("/api/user/reset-password", map[string]string{"email": admin.Email}, withHeader("Accept-Language", "en")).
+			expect(http.StatusOK)
+		_, subject, second := box.resetMail(t)
+		if subject != "Reset your password" {
+			t.Fatalf("邮件标题应为英文: %q", subject)
+		}
+		confirm := func(token, password string) *response {
+			return env.post("/api/v1/password-resets/confirm", map[string]string{"token": token, "new_password": password})
+		}
+		confirm(first, "newpass").expectError(http.StatusBadRequest, "重置链接无效或已过期")
+		confirm("forged", "newpass").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		confirm("", "").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+
+		// 重置成功后解除锁定，旧密码和之前签发的会话令牌失效
+		if err := env.store.Users().Lock(env.ctx(), admin.ID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		confirm(second, "newpass").expect(http.StatusOK)
+		confirm(second, "another").expectError(http.StatusBadRequest, "重置链接无效或已过期")
+
+		env.get("/api/v1/users", env.asUser(admin.Username)).expectError(http.StatusUnauthorized, "会话已失效，请重新登录")
+		// 把旧令牌的签发时间改到重置之后，签名不再匹配
+		parts := strings.Split(env.sessions[admin.Username], ".")
+		parts[2] = strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+		env.get("/api/v1/users", bearer(strings.Join(parts, "."))).expectError(http.StatusUnauthorized, "无效的令牌")
+		login(env, admin.Username, defaultPassword).expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		token := login(env, admin.Username, "newpass").expect(http.StatusOK).path("token").(string)
+		env.get("/api/v1/users", withHeader("Authorization", "Bearer "+token)).expect(http.StatusOK)
+
+		files, _ := filepath.Glob(filepath.Join(box.Dir, "*.eml"))
+		if len(files) != 2 {
+			t.Fatalf("应发送2封邮件，实际%d封", len(files))
+		}
+	})
+}
+
+func TestResetPasswordExpired(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	box := env.withOutbox(time.Millisecond)
+	user := env.createUser(userOpts{})
+
+	env.post("/api/v1/password-resets", map[string]string{"email": user.Email}).expect(http.StatusOK)
+	_, _, token := box.resetMail(t)
+	time.Sleep(10 * time.Millisecond)
+
+	env.post("/api/v1/password-resets/confirm", map[string]string{"token": token, "new_password": "newpass"}).
+		expectError(http.StatusBadRequest, "重置链接无效或已过期")
+	login(env, user.Username, defaultPassword).expect(http.StatusOK)
+
+	// 删除用户时一并删除其重置令牌
+	admin := env.createAdmin()
+	env.delete(fmt.Sprintf("/api/v1/users/%d", user.ID), env.asUser(admin.Username)).expect(http.StatusOK)
+	var count int64
+	env.db.DB.Table("password_reset_tokens").Count(&count)
+	if count != 0 {
+		t.Fatalf("删除用户后仍有%d个重置令牌", count)
+	}
+}
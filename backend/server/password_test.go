@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/password"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// storedHash 读取数据库中保存的密码哈希
+func (e *testEnv) storedHash(id uint) string {
+	e.t.Helper()
+	user, err := e.store.Users().Get(e.ctx(), id)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return user.Password
+}
+
+func TestLegacyPasswordHashes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		current := &password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost, Argon2: password.DefaultArgon2}
+		env.withMailOptions(Options{Passwords: current})
+
+		lowCost := password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost + 1}
+		oldArgon := password.Hasher{Algorithm: password.Argon2id, Argon2: password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+		md5Sum := md5.Sum([]byte("legacy123"))
+
+		legacy := map[string]func() (string, error){
+			"md5":      func() (string, error) { return hex.EncodeToString(md5Sum[:]), nil },
+			"bcrypt":   func() (string, error) { return lowCost.Hash("legacy123") },
+			"argon2id": func() (string, error) { return oldArgon.Hash("legacy123") },
+		}
+		for name, hash := range legacy {
+			user := env.createUser(userOpts{Username: "legacy_" + name})
+			old, err := hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := env.store.Users().SetPassword(env.ctx(), user.ID, old); err != nil {
+				t.Fatal(err)
+			}
+
+			// 密码错误时不修改哈希
+			login(env, user.Username, "wrong").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+			if env.storedHash(user.ID) != old {
+				t.Fatalf("%s: 登录失败后哈希被修改", name)
+			}
+
+			// 登录成功后改用当前算法和参数，之后仍可以登录
+			login(env, user.Username, "legacy123").expect(http.StatusOK)
+			upgraded := env.storedHash(user.ID)
+			if cost, err := bcrypt.Cost([]byte(upgraded)); err != nil || cost != bcrypt.MinCost {
+				t.Fatalf("%s: 哈希没有升级为当前参数的bcrypt: %q", name, upgraded)
+			}
+			login(env, user.Username, "legacy123").expect(http.StatusOK)
+			if env.storedHash(user.ID) != upgraded {
+				t.Fatalf("%s: 哈希已是当前参数时不应重新生成", name)
+			}
+			login(env, user.Username, "wrong").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		}
+
+		// 无法识别的哈希按密码错误处理
+		user := env.createUser(userOpts{Username: "plaintext"})
+		if err := env.store.Users().SetPassword(env.ctx(), user.ID, "legacy123"); err != nil {
+			t.Fatal(err)
+		}
+		login(env, user.Username, "legacy123").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+	})
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		box := env.withMailOptions(Options{
+			ResetTTL:       time.Hour,
+			Passwords:      &password.Hasher{Algorithm: password.Argon2id, Argon2: password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+			PasswordPolicy: password.Policy{MinLength: 8, MaxLength: 64, MinClasses: 3, RejectUsername: true},
+		})
+		register := func(username, pass string) *response {
+			return env.post("/api/v1/users", map[string]string{"username": username, "password": pass, "email": username + "@example.com"})
+		}
+		reasons := func(resp *response, field string) []string {
+			var got []string
+			for _, d := range resp.path("error.details").([]interface{}) {
+				detail := d.(map[string]interface{})
+				if detail["field"] != field {
+					t.Fatalf("字段应为%s: %v", field, detail)
+				}
+				got = append(got, detail["reason"].(string))
+			}
+			return got
+		}
+
+		resp := register("alice", "Alice1").expectError(http.StatusBadRequest, "密码不符合要求")
+		if got := reasons(resp, "password"); len(got) != 2 || got[0] != "too_short" || got[1] != "contains_username" {
+			t.Fatalf("违反的规则错误: %v", got)
+		}
+		resp = register("alice", "alllowercase").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		if got := reasons(resp, "password"); len(got) != 1 || got[0] != "too_simple" {
+			t.Fatalf("违反的规则错误: %v", got)
+		}
+		register("alice", "Str0ng-pass").expect(http.StatusCreated)
+		box.verifyMail(t)
+		login(env, "alice", "Str0ng-pass").expect(http.StatusOK)
+
+		// 重置密码同样检查策略，不符合时令牌仍然有效
+		env.post("/api/v1/password-resets", map[string]string{"email": "alice@example.com"}).expect(http.StatusOK)
+		_, _, token := box.resetMail(t)
+		resp = env.post("/api/v1/password-resets/confirm", map[string]string{"token": token, "new_password": "short"}, withHeader("Accept-Language", "en")).
+			expectError(http.StatusBadRequest, "Password does not meet the requirements")
+		if got := reasons(resp, "new_password"); len(got) != 2 || got[0] != "too_short" || got[1] != "too_simple" {
+			t.Fatalf("违反的规则错误: %v", got)
+		}
+		env.post("/api/v1/password-resets/confirm", map[string]string{"token": token, "new_password": "An0ther-pass"}).expect(http.StatusOK)
+		login(env, "alice", "An0ther-pass").expect(http.StatusOK)
+	})
+}
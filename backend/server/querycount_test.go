@@ -0,0 +1,172 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"questionnaire-system/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 列表和结果接口的查询次数不应随数据量增长（N+1查询）
+
+// queryCounter 统计SQLite仓储执行的SQL语句数
+type queryCounter struct {
+	n atomic.Int64
+}
+
+// countQueries 在env的数据库上注册计数回调
+func (e *testEnv) countQueries() *queryCounter {
+	e.t.Helper()
+
+	counter := &queryCounter{}
+	inc := func(db *gorm.DB) {
+		// 子查询会以DryRun方式生成SQL，并不访问数据库
+		if !db.DryRun {
+			counter.n.Add(1)
+		}
+	}
+	callbacks := e.db.DB.Callback()
+	for name, err := range map[string]error{
+		"query":  callbacks.Query().After("gorm:query").Register("test:count_query", inc),
+		"row":    callbacks.Row().After("gorm:row").Register("test:count_row", inc),
+		"raw":    callbacks.Raw().After("gorm:raw").Register("test:count_raw", inc),
+		"create": callbacks.Create().After("gorm:create").Register("test:count_create", inc),
+		"update": callbacks.Update().After("gorm:update").Register("test:count_update", inc),
+		"delete": callbacks.Delete().After("gorm:delete").Register("test:count_delete", inc),
+	} {
+		if err != nil {
+			e.t.Fatalf("注册%s计数回调失败: %v", name, err)
+		}
+	}
+	return counter
+}
+
+// measure 返回fn执行期间的查询次数
+func (c *queryCounter) measure(fn func()) int64 {
+	before := c.n.Load()
+	fn()
+	return c.n.Load() - before
+}
+
+// seededData 查询次数测试和基准测试使用的数据
+type seededData struct {
+	admin  *models.User
+	target *questionnaireFixture // 收到全部答卷的问卷
+}
+
+// seed 创建questionnaires份由不同用户创建的已发布问卷，以及respondents个答题者对target的答卷
+// 批量用户直接写入仓储，跳过注册时的密码哈希
+func (e *testEnv) seed(questionnaires, respondents int) *seededData {
+	e.t.Helper()
+
+	data := &seededData{admin: e.createAdmin()}
+	for i := 0; i < questionnaires; i++ {
+		q := e.createQuestionnaire(e.insertUser(), true)
+		if data.target == nil {
+			data.target = q
+		}
+	}
+	for i := 0; i < respondents; i++ {
+		e.submit(data.target, e.insertUser())
+	}
+	return data
+}
+
+// insertUser 直接写入一个没有密码的用户
+func (e *testEnv) insertUser() *models.User {
+	e.t.Helper()
+
+	n := e.next()
+	user := &models.User{Username: fmt.Sprintf("seed%d", n), Email: fmt.Sprintf("seed%d@example.com", n)}
+	if err := e.store.Users().Create(e.ctx(), user); err != nil {
+		e.t.Fatalf("写入用户失败: %v", err)
+	}
+	return user
+}
+
+// boundedEndpoints 需要保证查询次数上限的接口
+func boundedEndpoints(env *testEnv, data *seededData) []struct {
+	name  string
+	path  string
+	opts  []requestOption
+	limit int64
+} {
+	as := env.asUser(data.admin.Username)
+	return []struct {
+		name  string
+		path  string
+		opts  []requestOption
+		limit int64
+	}{
+		{"questionnaire_list", "/api/questionnaire/list?page_size=50", nil, 3},
+		{"admin_questionnaires", "/api/admin/questionnaires?page_size=50", []requestOption{as}, 6},
+		{"questionnaire_results", fmt.Sprintf("/api/questionnaire/results?id=%d", data.target.ID), []requestOption{as}, 8},
+		{"admin_questionnaire_submissions", fmt.Sprintf("/api/admin/questionnaire/submissions?id=%d", data.target.ID), []requestOption{as}, 6},
+	}
+}
+
+func TestQueryCountIsBounded(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	counter := env.countQueries()
+
+	small := env.seed(2, 2)
+	smallCounts := map[string]int64{}
+	for _, ep := range boundedEndpoints(env, small) {
+		smallCounts[ep.name] = counter.measure(func() {
+			env.get(ep.path, ep.opts...).expect(http.StatusOK)
+		})
+	}
+
+	// 数据量增加一个数量级后查询次数不变
+	large := env.seed(40, 30)
+	for _, ep := range boundedEndpoints(env, large) {
+		got := counter.measure(func() {
+			env.get(ep.path, ep.opts...).expect(http.StatusOK)
+		})
+		if got > ep.limit {
+			t.Errorf("%s 执行了%d次查询，上限为%d", ep.name, got, ep.limit)
+		}
+		if got != smallCounts[ep.name] {
+			t.Errorf("%s 的查询次数随数据量变化: %d -> %d", ep.name, smallCounts[ep.name], got)
+		}
+	}
+}
+
+// 基准测试：go test ./server -run '^$' -bench . -benchmem
+// 每次请求的查询次数作为queries/op指标输出
+
+func benchmarkEndpoint(b *testing.B, name string) {
+	env := newTestEnv(b, backendSQLite)
+	counter := env.countQueries()
+	data := env.seed(50, 100)
+
+	for _, ep := range boundedEndpoints(env, data) {
+		if ep.name != name {
+			continue
+		}
+		b.ResetTimer()
+		var queries int64
+		for i := 0; i < b.N; i++ {
+			queries += counter.measure(func() {
+				env.get(ep.path, ep.opts...).expect(http.StatusOK)
+			})
+		}
+		b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+		return
+	}
+	b.Fatalf("未知的接口: %s", name)
+}
+
+func BenchmarkQuestionnaireList(b *testing.B) { benchmarkEndpoint(b, "questionnaire_list") }
+
+func BenchmarkAdminQuestionnaires(b *testing.B) { benchmarkEndpoint(b, "admin_questionnaires") }
+
+func BenchmarkQuestionnaireResults(b *testing.B) { benchmarkEndpoint(b, "questionnaire_results") }
+
+func BenchmarkAdminQuestionnaireSubmissions(b *testing.B) {
+	benchmarkEndpoint(b, "admin_questionnaire_submissions")
+}
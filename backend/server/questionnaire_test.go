@@ -0,0 +1,225 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"questionnaire-system/backend/models"
+)
+
+// questionnairePayload 创建/更新问卷的请求体
+func questionnairePayload(createdBy uint, title string) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       title,
+		"description": "描述",
+		"created_by":  createdBy,
+		"start_time":  "2026-01-01T00:00:00Z",
+		"end_time":    "2026-12-31T00:00:00Z",
+		"questions": []map[string]interface{}{
+			{"title": "您的性别", "type": models.QuestionTypeSingleChoice, "required": true, "options": `["男","女"]`},
+			{"title": "您的建议", "type": models.QuestionTypeText},
+		},
+	}
+}
+
+func TestQuestionnaireCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{Username: "owner"})
+
+		created := env.post("/api/questionnaire/create", questionnairePayload(owner.ID, "满意度调查")).
+			expect(http.StatusCreated)
+		created.assertGolden("questionnaire_create")
+		id := uint(created.path("data.questionnaire.id").(float64))
+
+		env.get(fmt.Sprintf("/api/questionnaire/detail?id=%d", id)).
+			expect(http.StatusOK).assertGolden("questionnaire_detail")
+
+		env.get(fmt.Sprintf("/api/questionnaire/list?user_id=%d", owner.ID)).
+			expect(http.StatusOK).assertGolden("questionnaire_list")
+
+		// 更新：替换标题和全部问题
+		update := questionnairePayload(owner.ID, "满意度调查（修订）")
+		update["id"] = id
+		update["questions"] = []map[string]interface{}{
+			{"title": "满意度", "type": models.QuestionTypeRating},
+		}
+		env.put("/api/questionnaire/update", update).
+			expect(http.StatusOK).assertGolden("questionnaire_update")
+
+		// 发布后不能再编辑
+		env.put("/api/questionnaire/update-status", map[string]interface{}{"id": id, "is_published": true}).
+			expect(http.StatusOK).assertGolden("questionnaire_update_status")
+		env.put("/api/questionnaire/update", update).
+			expectError(http.StatusBadRequest, "已发布的问卷不能编辑")
+
+		env.delete(fmt.Sprintf("/api/questionnaire/delete?id=%d", id)).
+			expect(http.StatusOK).assertGolden("questionnaire_delete")
+		env.get(fmt.Sprintf("/api/questionnaire/detail?id=%d", id)).
+			expectError(http.StatusNotFound, "问卷不存在")
+	})
+}
+
+func TestCreateQuestionnaireValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.post("/api/questionnaire/create", questionnairePayload(0, "无创建者")).
+			expectError(http.StatusBadRequest, "无效的创建者ID")
+
+		payload := questionnairePayload(1, "没有问题")
+		payload["questions"] = []interface{}{}
+		env.post("/api/questionnaire/create", payload).
+			expectError(http.StatusBadRequest, "问卷必须包含至少一个问题")
+
+		env.get("/api/questionnaire/detail").expectError(http.StatusBadRequest, "缺少问卷ID")
+		env.get("/api/questionnaire/detail?id=abc").expectError(http.StatusBadRequest, "无效的问卷ID")
+	})
+}
+
+func TestUpdateQuestionnaireRequiresCreator(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		other := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, false)
+
+		payload := questionnairePayload(other.ID, "篡改")
+		payload["id"] = q.ID
+		env.put("/api/questionnaire/update", payload).
+			expectError(http.StatusForbidden, "您没有权限编辑此问卷")
+	})
+}
+
+func TestQuestionnaireListVisibility(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		other := env.createUser(userOpts{})
+		env.createQuestionnaire(owner, true)
+		env.createQuestionnaire(owner, false)
+		env.createQuestionnaire(other, false)
+
+		// 其他用户只能看到已发布的问卷和自己的草稿
+		resp := env.get(fmt.Sprintf("/api/questionnaire/list?user_id=%d&include_total=true", other.ID)).expect(http.StatusOK)
+		if total := resp.path("data.total"); total != float64(2) {
+			t.Fatalf("可见问卷数为%v，期望2", total)
+		}
+
+		resp = env.get("/api/questionnaire/list?page=2&page_size=2").expect(http.StatusOK)
+		items := resp.path("data.questionnaires").([]interface{})
+		if resp.path("data.total") != float64(3) || len(items) != 1 {
+			t.Fatalf("分页结果不正确: %s", resp.Body)
+		}
+	})
+}
+
+func TestSubmitQuestionnaire(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		respondent := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+
+		body := map[string]interface{}{
+			"questionnaire_id": q.ID,
+			"answers":          q.sampleAnswers(),
+		}
+		asRespondent := env.asUser(respondent.Username)
+		env.post("/api/questionnaire/submit", body, asRespondent).
+			expect(http.StatusCreated).assertGolden("questionnaire_submit")
+
+		// 重复提交
+		resp := env.post("/api/questionnaire/submit", body, asRespondent)
+		resp.expectError(http.StatusConflict, "您已经提交过该问卷，不能重复提交")
+		resp.assertGolden("questionnaire_submit_duplicate")
+
+		env.get(fmt.Sprintf("/api/questionnaire/check-submission?questionnaire_id=%d&user_id=%d", q.ID, respondent.ID)).
+			expect(http.StatusOK).assertGolden("questionnaire_check_submitted")
+		env.get(fmt.Sprintf("/api/questionnaire/check-submission?questionnaire_id=%d&user_id=%d", q.ID, owner.ID)).
+			expect(http.StatusOK).assertGolden("questionnaire_check_not_submitted")
+
+		if got := env.counts(); got.Submissions != 1 || got.Answers != int64(len(q.Questions)) {
+			t.Fatalf("重复提交不应写入数据: %+v", got)
+		}
+	})
+}
+
+func TestSubmitValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		user := env.createUser(userOpts{})
+
+		asUser := env.asUser(user.Username)
+		env.post("/api/questionnaire/submit", map[string]interface{}{"questionnaire_id": 0}, asUser).
+			expectError(http.StatusBadRequest, "无效的问卷ID或用户ID")
+		env.post("/api/questionnaire/submit", map[string]interface{}{"questionnaire_id": 999}, asUser).
+			expectError(http.StatusNotFound, "问卷不存在")
+		env.get("/api/questionnaire/check-submission?questionnaire_id=1").
+			expectError(http.StatusBadRequest, "缺少必要参数")
+	})
+}
+
+func TestResultsAuthorization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{Username: "owner"})
+		respondent := env.createUser(userOpts{Username: "respondent"})
+		admin := env.createAdmin()
+		q := env.createQuestionnaire(owner, true)
+		env.submit(q, respondent)
+
+		results := fmt.Sprintf("/api/questionnaire/results?id=%d", q.ID)
+
+		env.get(results).expectError(http.StatusUnauthorized, "未授权访问")
+		env.get(results, func(r *http.Request) { r.Header.Set("Authorization", "token") }).
+			expectError(http.StatusUnauthorized, "无效的授权格式")
+
+		// 答题者既不是创建者也不是管理员，在参数中冒充创建者同样无效
+		env.get(results, env.asUser(respondent.Username)).
+			expectError(http.StatusForbidden, "您没有权限查看此问卷的结果")
+		env.get(fmt.Sprintf("%s&user_id=%d", results, owner.ID), env.asUser(respondent.Username)).
+			expectError(http.StatusForbidden, "您没有权限查看此问卷的结果")
+		env.get(fmt.Sprintf("/api/v1/questionnaires/%d/submissions?user_id=%d", q.ID, admin.ID), env.asUser(respondent.Username)).
+			expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		env.get(results, env.asUser(owner.Username)).
+			expect(http.StatusOK).assertGolden("questionnaire_results")
+		env.get(results, env.asUser(admin.Username)).expect(http.StatusOK)
+
+		env.get("/api/questionnaire/results?id=999", env.asUser(owner.Username)).
+			expectError(http.StatusNotFound, "问卷不存在")
+	})
+}
+
+func TestDeleteQuestionnaireCascades(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		keep := env.createQuestionnaire(owner, true)
+		doomed := env.createQuestionnaire(owner, true)
+		for i := 0; i < 3; i++ {
+			user := env.createUser(userOpts{})
+			env.submit(keep, user)
+			env.submit(doomed, user)
+		}
+
+		env.delete(fmt.Sprintf("/api/questionnaire/delete?id=%d", doomed.ID)).expect(http.StatusOK)
+		env.delete(fmt.Sprintf("/api/questionnaire/delete?id=%d", doomed.ID)).
+			expectError(http.StatusNotFound, "问卷不存在")
+
+		// 只删除该问卷的问题、提交记录和答案
+		want := counts{
+			Users:          4,
+			Questionnaires: 1,
+			Questions:      int64(len(keep.Questions)),
+			Submissions:    3,
+			Answers:        3 * int64(len(keep.Questions)),
+		}
+		if got := env.counts(); got != want {
+			t.Fatalf("删除后记录数为%+v，期望%+v", got, want)
+		}
+	})
+}
+
+func TestSystemStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		env.submit(q, owner)
+
+		env.get("/api/questionnaire/stats").expect(http.StatusOK).assertGolden("questionnaire_stats")
+	})
+}
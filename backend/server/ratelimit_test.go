@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/ratelimit"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/session"
+)
+
+// fakeClock 可以手动拨动的时钟
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// withLoginProtection 使用账户锁定策略和登录限流重新创建路由
+func (e *testEnv) withLoginProtection(lockout service.LockoutPolicy, limiter ratelimit.Limiter, rules ...middleware.RateRule) {
+	e.t.Helper()
+
+	opts := e.options()
+	opts.Lockout = lockout
+	if limiter != nil {
+		opts.AuthLimit = middleware.RateLimit(limiter, rules...)
+	}
+	e.router = New(opts)
+	if err := e.router.SetTrustedProxies(nil); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func login(env *testEnv, username, password string) *response {
+	env.t.Helper()
+	return env.post("/api/v1/sessions", map[string]string{"username": username, "password": password})
+}
+
+func TestAccountLockout(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.withLoginProtection(service.LockoutPolicy{Threshold: 3, Cooldown: time.Minute, MaxCooldown: 3 * time.Minute}, nil)
+		user := env.createUser(userOpts{Username: "victim"})
+		admin := env.createAdmin()
+		signedIn := env.asUser("victim")
+
+		for i := 0; i < 2; i++ {
+			login(env, "victim", "wrong").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		}
+		// 第3次失败时锁定，锁定期间正确的密码也不能登录
+		resp := login(env, "victim", "wrong").expectCode(http.StatusLocked, "ACCOUNT_LOCKED")
+		if resp.Header.Get("Retry-After") != "60" {
+			t.Fatalf("Retry-After应为60秒: %q", resp.Header.Get("Retry-After"))
+		}
+		login(env, "victim", defaultPassword).expectCode(http.StatusLocked, "ACCOUNT_LOCKED")
+		// 锁定只阻止登录，他人猜测密码不会使用户已有的会话失效
+		env.get("/api/v1/mfa", signedIn).expect(http.StatusOK)
+
+		detail := env.get(fmt.Sprintf("/api/v1/users/%d", user.ID), env.asUser(admin.Username)).expect(http.StatusOK)
+		if detail.path("data.user.failed_logins") != float64(3) || detail.path("data.user.locked_until") == nil {
+			t.Fatalf("用户详情中缺少锁定状态: %s", detail.Body)
+		}
+
+		// 锁定到期后继续失败，锁定时间逐次翻倍，不超过上限
+		for _, want := range []string{"120", "180"} {
+			if err := env.store.Users().Lock(env.ctx(), user.ID, time.Now().Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				login(env, "victim", "wrong").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+			}
+			resp := login(env, "victim", "wrong").expectCode(http.StatusLocked, "ACCOUNT_LOCKED")
+			if got := resp.Header.Get("Retry-After"); got != want {
+				t.Fatalf("Retry-After应为%s秒: %q", want, got)
+			}
+		}
+
+		// 管理员解锁后可以登录，失败次数清零
+		env.post(fmt.Sprintf("/api/v1/users/%d/unlock", user.ID), nil, env.asUser(admin.Username)).expect(http.StatusOK)
+		env.post("/api/v1/users/999/unlock", nil, env.asUser(admin.Username)).expectCode(http.StatusNotFound, "USER_NOT_FOUND")
+		env.post(fmt.Sprintf("/api/v1/users/%d/unlock", user.ID), nil, env.asUser("victim")).expectCode(http.StatusForbidden, "FORBIDDEN")
+		login(env, "victim", defaultPassword).expect(http.StatusOK)
+
+		stored, err := env.store.Users().Get(env.ctx(), user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.FailedLogins != 0 || stored.LockedUntil != nil {
+			t.Fatalf("解锁后失败次数和锁定时间应被清除: %d %v", stored.FailedLogins, stored.LockedUntil)
+		}
+
+		// 登录成功同样清零，之后重新计数
+		login(env, "victim", "wrong").expect(http.StatusUnauthorized)
+		login(env, "victim", defaultPassword).expect(http.StatusOK)
+		for i := 0; i < 2; i++ {
+			login(env, "victim", "wrong").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		}
+	})
+}
+
+func TestAuthRateLimit(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	env.createUser(userOpts{Username: "alice"})
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	memory := ratelimit.NewMemoryLimiter()
+	memory.Now = clock.Now
+	shared := ratelimit.NewGormLimiter(env.db.DB)
+	shared.Now = clock.Now
+
+	for name, limiter := range map[string]ratelimit.Limiter{"memory": memory, "database": shared} {
+		t.Run(name, func(t *testing.T) {
+			env.withLoginProtection(service.LockoutPolicy{}, limiter,
+				middleware.RateRule{Key: middleware.ByClientIP(name), Limit: ratelimit.Limit{Burst: 4, Period: time.Minute}},
+				middleware.RateRule{Key: middleware.ByJSONField(name, "username"), Limit: ratelimit.Limit{Burst: 2, Period: time.Minute}},
+			)
+
+			// 同一用户名（不区分大小写）在新旧登录接口和重置密码接口上共用限制，处理器仍能读取请求体
+			login(env, "alice", defaultPassword).expect(http.StatusOK)
+			env.post("/api/user/login", map[string]string{"username": " ALICE ", "password": "wrong"}).
+				expect(http.StatusUnauthorized)
+			resp := env.post("/api/v1/password-resets", map[string]string{"username": "alice", "new_password": "x"}).
+				expectCode(http.StatusTooManyRequests, "RATE_LIMITED")
+			if resp.Header.Get("Retry-After") != "30" {
+				t.Fatalf("Retry-After应为30秒: %q", resp.Header.Get("Retry-After"))
+			}
+
+			// 其他用户名不受影响，但同一IP的总次数也有限制；X-Forwarded-For来自不受信任的地址，不能绕过
+			login(env, "bob", "wrong").expect(http.StatusUnauthorized)
+			env.post("/api/v1/sessions", map[string]string{"username": "carol", "password": "wrong"},
+				withHeader("X-Forwarded-For", "203.0.113.9")).expectCode(http.StatusTooManyRequests, "RATE_LIMITED")
+
+			// 令牌按时间恢复
+			clock.now = clock.now.Add(30 * time.Second)
+			login(env, "alice", defaultPassword).expect(http.StatusOK)
+			login(env, "alice", defaultPassword).expectCode(http.StatusTooManyRequests, "RATE_LIMITED")
+			clock.now = clock.now.Add(time.Hour)
+		})
+	}
+
+	// 数据库中的令牌桶对使用同一数据库的所有实例生效
+	env.withLoginProtection(service.LockoutPolicy{}, ratelimit.NewGormLimiter(env.db.DB),
+		middleware.RateRule{Key: middleware.ByJSONField("database", "username"), Limit: ratelimit.Limit{Burst: 1, Period: time.Hour}},
+	)
+	login(env, "dave", "wrong").expect(http.StatusUnauthorized)
+	env.withLoginProtection(service.LockoutPolicy{}, ratelimit.NewGormLimiter(env.db.DB),
+		middleware.RateRule{Key: middleware.ByJSONField("database", "username"), Limit: ratelimit.Limit{Burst: 1, Period: time.Hour}},
+	)
+	login(env, "dave", "wrong").expectCode(http.StatusTooManyRequests, "RATE_LIMITED")
+}
+
+// failingLimiter 总是返回错误的限流器
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("数据库不可用")
+}
+
+func TestRateLimitLogsOmitKey(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	rec := &logRecorder{t: t}
+	opts := env.options()
+	opts.Logger = slog.New(logging.NewHandler(&rec.buf, "json", slog.LevelDebug))
+
+	// 按邮箱和用户名限流时，日志只记录规则和key的哈希，不记录邮箱、用户名本身
+	rules := []middleware.RateRule{
+		{Key: middleware.ByJSONField("auth", "email"), Limit: ratelimit.Limit{Burst: 1, Period: time.Hour}},
+		{Key: middleware.ByJSONField("auth", "username"), Limit: ratelimit.Limit{Burst: 1, Period: time.Hour}},
+	}
+	opts.AuthLimit = middleware.RateLimit(ratelimit.NewMemoryLimiter(), rules...)
+	env.router = New(opts)
+	reset := map[string]string{"email": "Alice.Secret@example.com"}
+	env.post("/api/v1/password-resets", reset).expect(http.StatusOK)
+	throttled := env.post("/api/v1/password-resets", reset).expectCode(http.StatusTooManyRequests, "RATE_LIMITED")
+
+	throttledLog := rec.find("请求过于频繁", throttled.Header.Get("X-Request-ID"))
+	if throttledLog["rule"] != "auth:email" || throttledLog["key_hash"] == "" {
+		t.Fatalf("限流日志字段不正确: %v", throttledLog)
+	}
+
+	// 限流器出错时同样不记录key
+	opts.AuthLimit = middleware.RateLimit(failingLimiter{}, rules...)
+	env.router = New(opts)
+	failed := login(env, "bob.private", "wrong").expect(http.StatusUnauthorized)
+	failedLog := rec.find("限流检查失败", failed.Header.Get("X-Request-ID"))
+	if failedLog["rule"] != "auth:username" {
+		t.Fatalf("限流错误日志字段不正确: %v", failedLog)
+	}
+
+	for secret, entry := range map[string]map[string]interface{}{"alice.secret": throttledLog, "bob.private": failedLog} {
+		data, _ := json.Marshal(entry)
+		if strings.Contains(strings.ToLower(string(data)), secret) {
+			t.Fatalf("限流日志中出现了 %q: %s", secret, data)
+		}
+	}
+}
+
+func TestForgedSessionToken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		victim := env.createUser(userOpts{Username: "victim"})
+		admin := env.createAdmin()
+		env.get("/api/v1/mfa", env.asUser("victim")).expect(http.StatusOK)
+
+		// 伪造或篡改的令牌都不能通过认证，也不能冒充其他用户
+		now := time.Now()
+		parts := strings.Split(env.sessions["victim"], ".")
+		swapped := append([]string(nil), parts...)
+		swapped[1] = strconv.FormatUint(uint64(admin.ID), 10)
+		other, _ := session.NewSigner([]byte("another-secret"), time.Hour).Issue(admin.ID, now)
+		for name, forged := range map[string]string{
+			"legacy":     fmt.Sprintf("token_%s_%s", admin.Username, now.Format("20060102150405")),
+			"unsigned":   strings.Join(parts[:4], ".") + ".",
+			"swapped":    strings.Join(swapped, "."),
+			"foreignKey": other,
+		} {
+			t.Run(name, func(t *testing.T) {
+				env.get("/api/v1/users", bearer(forged)).expectError(http.StatusUnauthorized, "无效的令牌")
+				env.get("/api/v1/mfa", bearer(forged)).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+			})
+		}
+
+		// 过期的令牌
+		expired, _ := session.NewSigner(env.secret, time.Hour).Issue(victim.ID, now.Add(-2*time.Hour))
+		env.get("/api/v1/mfa", bearer(expired)).expectError(http.StatusUnauthorized, "会话已过期，请重新登录")
+	})
+}
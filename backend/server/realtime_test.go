@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/realtime"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/stats"
+)
+
+// waitFor 等待cond成立，超过2秒时失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// nextUpdate 读取订阅的下一份汇总
+func nextUpdate(t *testing.T, sub *realtime.Subscription) *stats.Summary {
+	t.Helper()
+	select {
+	case summary := <-sub.Updates():
+		return summary
+	case <-time.After(2 * time.Second):
+		t.Fatal("没有收到汇总更新")
+		return nil
+	}
+}
+
+// countingHub Load返回的TotalSubmissions为调用次数
+func countingHub(calls *atomic.Int64) *realtime.Hub {
+	return realtime.NewHub(func(context.Context, uint) (*stats.Summary, error) {
+		return &stats.Summary{TotalSubmissions: calls.Add(1)}, nil
+	})
+}
+
+func TestHubCoalescesPublishes(t *testing.T) {
+	var calls atomic.Int64
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	hub := realtime.NewHub(func(context.Context, uint) (*stats.Summary, error) {
+		n := calls.Add(1)
+		started <- struct{}{}
+		<-release
+		return &stats.Summary{TotalSubmissions: n}, nil
+	})
+	sub := hub.Subscribe(1)
+	defer hub.Unsubscribe(sub)
+
+	// 计算期间的多次发布合并为一次重新计算
+	hub.Publish(1)
+	<-started
+	for i := 0; i < 5; i++ {
+		hub.Publish(1)
+	}
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+
+	waitFor(t, "第二次计算的汇总", func() bool { return sub.Dropped() == 1 })
+	if summary := nextUpdate(t, sub); summary.TotalSubmissions != 2 || calls.Load() != 2 {
+		t.Fatalf("6次发布应只计算2次汇总: 计算%d次，最新汇总%d", calls.Load(), summary.TotalSubmissions)
+	}
+
+	// 没有订阅者的问卷不计算
+	hub.Publish(2)
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 2 {
+		t.Fatalf("没有订阅者时不应计算汇总: %d", calls.Load())
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	var calls atomic.Int64
+	hub := countingHub(&calls)
+	fast, slow := hub.Subscribe(1), hub.Subscribe(1)
+	defer hub.Unsubscribe(fast)
+	defer hub.Unsubscribe(slow)
+
+	// 不读取的订阅者不阻塞发布和其他订阅者，只保留最新的汇总
+	for i := int64(1); i <= 3; i++ {
+		hub.Publish(1)
+		if summary := nextUpdate(t, fast); summary.TotalSubmissions != i {
+			t.Fatalf("第%d次更新不正确: %d", i, summary.TotalSubmissions)
+		}
+	}
+	waitFor(t, "慢订阅者的旧汇总被覆盖", func() bool { return slow.Dropped() == 2 })
+	if summary := nextUpdate(t, slow); summary.TotalSubmissions != 3 || fast.Dropped() != 0 {
+		t.Fatalf("慢订阅者应只收到最新汇总: %d，快订阅者丢弃%d", summary.TotalSubmissions, fast.Dropped())
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	var calls atomic.Int64
+	hub := countingHub(&calls)
+	a, b := hub.Subscribe(1), hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	if hub.Subscribers(1) != 2 || hub.Subscribers(2) != 1 {
+		t.Fatalf("订阅者数量不正确: %d %d", hub.Subscribers(1), hub.Subscribers(2))
+	}
+
+	// 断开后不再收到更新，重复取消订阅不出错
+	hub.Unsubscribe(a)
+	hub.Unsubscribe(a)
+	hub.Publish(1)
+	if summary := nextUpdate(t, b); summary.TotalSubmissions != 1 {
+		t.Fatalf("仍在订阅的连接应收到更新: %d", summary.TotalSubmissions)
+	}
+	select {
+	case <-a.Updates():
+		t.Fatal("已取消的订阅不应收到更新")
+	case <-other.Updates():
+		t.Fatal("其他问卷的订阅不应收到更新")
+	default:
+	}
+
+	hub.Unsubscribe(b)
+	hub.Unsubscribe(other)
+	hub.Publish(1)
+	time.Sleep(20 * time.Millisecond)
+	if hub.Subscribers(1) != 0 || hub.Subscribers(2) != 0 || calls.Load() != 1 {
+		t.Fatalf("全部断开后不应再计算: 订阅者%d，计算%d次", hub.Subscribers(1), calls.Load())
+	}
+}
+
+func TestNilHub(t *testing.T) {
+	// 未启用实时推送时Hub为nil，各方法都可以安全调用
+	var hub *realtime.Hub
+	sub := hub.Subscribe(1)
+	hub.Publish(1)
+	if hub.Subscribers(1) != 0 || hub.Done() != nil {
+		t.Fatal("nil Hub不应有订阅者")
+	}
+	hub.Unsubscribe(sub)
+	hub.Close()
+}
+
+// sseEvent 读取下一个SSE事件（跳过心跳），返回id和data
+func sseEvent(t *testing.T, r *bufio.Reader) (id string, data map[string]interface{}) {
+	t.Helper()
+	var event string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取事件失败: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return id, data
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("事件数据不是JSON: %s", line)
+			}
+		}
+	}
+}
+
+// openStream 连接实时结果接口，ctx取消时断开
+func openStream(ctx context.Context, t *testing.T, url string, opts ...requestOption) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestResultsStream(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		srv := httptest.NewServer(env.router)
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resp := openStream(ctx, t, fmt.Sprintf("%s/api/v1/questionnaires/%d/results/stream", srv.URL, q.ID), env.asUser(owner.Username))
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("响应不正确: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		// 连接后先推送当前汇总，提交后推送新的汇总，事件ID为答卷总数
+		events := bufio.NewReader(resp.Body)
+		if id, data := sseEvent(t, events); id != "0" || data["total_submissions"] != float64(0) {
+			t.Fatalf("初始汇总不正确: %s %v", id, data)
+		}
+		env.submit(q, env.createUser(userOpts{}))
+		if id, data := sseEvent(t, events); id != "1" || data["total_submissions"] != float64(1) {
+			t.Fatalf("提交后的汇总不正确: %s %v", id, data)
+		}
+
+		// 客户端断开后取消订阅
+		if env.hub.Subscribers(q.ID) != 1 {
+			t.Fatalf("应有1个订阅者: %d", env.hub.Subscribers(q.ID))
+		}
+		cancel()
+		waitFor(t, "断开后取消订阅", func() bool { return env.hub.Subscribers(q.ID) == 0 })
+	})
+}
+
+func TestResultsStreamToken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		other := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		another := env.createQuestionnaire(owner, true)
+		srv := httptest.NewServer(env.router)
+		defer srv.Close()
+
+		// 只有能查看结果的用户可以申请令牌
+		tokenPath := func(q *questionnaireFixture) string {
+			return fmt.Sprintf("/api/v1/questionnaires/%d/results/stream-tokens", q.ID)
+		}
+		env.post(tokenPath(q), nil).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.post(tokenPath(q), nil, env.asUser(other.Username)).expectCode(http.StatusForbidden, "FORBIDDEN")
+		issued := env.post(tokenPath(q), nil, env.asUser(owner.Username)).expect(http.StatusCreated)
+		token, _ := issued.path("token").(string)
+		if token == "" || issued.path("expires_at") == nil {
+			t.Fatalf("没有返回令牌: %s", issued.Body)
+		}
+
+		// EventSource只能通过查询参数携带令牌，不需要Authorization头
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resp := openStream(ctx, t, fmt.Sprintf("%s/api/v1/questionnaires/%d/results/stream?token=%s", srv.URL, q.ID, token))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("使用令牌连接失败: %d", resp.StatusCode)
+		}
+		if id, _ := sseEvent(t, bufio.NewReader(resp.Body)); id != "0" {
+			t.Fatalf("初始汇总不正确: %s", id)
+		}
+		resp = openStream(ctx, t, fmt.Sprintf("%s/api/questionnaire/results/stream?id=%d&token=%s", srv.URL, q.ID, token))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("旧接口使用令牌连接失败: %d", resp.StatusCode)
+		}
+
+		// 令牌只能用于签发时的问卷，不能当作会话令牌，会话令牌也不能放在查询参数中
+		env.get(fmt.Sprintf("/api/v1/questionnaires/%d/results/stream?token=%s", another.ID, token)).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.get("/api/v1/mfa", bearer(token)).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.post(tokenPath(q), nil, bearer(token)).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.get(fmt.Sprintf("/api/v1/questionnaires/%d/results/stream?token=%s", q.ID, env.sessions[owner.Username])).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+
+		// 令牌过期后需重新申请
+		opts := env.options()
+		opts.Now = func() time.Time { return time.Now().Add(service.StreamTokenTTL) }
+		env.router = New(opts)
+		env.get(fmt.Sprintf("/api/v1/questionnaires/%d/results/stream?token=%s", q.ID, token)).
+			expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+	})
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+
+	"questionnaire-system/backend/handlers"
+	"questionnaire-system/backend/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiV1 版本化接口的路径前缀
+const apiV1 = "/api/v1"
+
+// route /api/v1下的一个接口及其OpenAPI描述
+type route struct {
+	method  string
+	path    string // 相对apiV1的路径，路径参数写作{id}
+	admin   bool   // 需要管理员权限
+	handler gin.HandlerFunc
+	doc     *openapi.Operation
+}
+
+// routeHandlers 注册接口使用的处理器
+type routeHandlers struct {
+	health         *handlers.HealthHandler
+	spec           gin.HandlerFunc
+	users          *handlers.UserHandler
+	mfa            *handlers.MFAHandler
+	sso            *handlers.SSOHandler
+	questionnaires *handlers.QuestionnaireHandler
+	admin          *handlers.AdminHandler
+	export         *handlers.ExportHandler
+	webhooks       *handlers.WebhookHandler
+	live           *handlers.LiveResultsHandler
+}
+
+// authPaths 登录（含单点登录）、重置密码和重新发送验证邮件接口，注册时在处理器之前加上Options.AuthLimit
+var authPaths = map[string]bool{
+	"/sessions":                true,
+	"/sessions/mfa":            true,
+	"/sessions/oidc":           true,
+	"/sso/oidc/authorizations": true,
+	"/password-resets":         true,
+	"/password-resets/confirm": true,
+	"/email-verifications":     true,
+}
+
+// userRoutes 需要登录的接口（用户管理自己的账户，提交答卷，查看、导出和订阅问卷结果），键为"方法 路径"，
+// 注册时在处理器之前加上登录验证（middleware.AuthMiddleware）
+var userRoutes = map[string]bool{
+	"GET /mfa":                 true,
+	"POST /mfa/totp":           true,
+	"POST /mfa/totp/confirm":   true,
+	"POST /mfa/totp/disable":   true,
+	"POST /mfa/recovery-codes": true,
+
+	"GET /questionnaires/{id}/submissions":            true,
+	"POST /questionnaires/{id}/submissions":           true,
+	"POST /questionnaires/{id}/results/stream-tokens": true,
+	"GET /questionnaires/{id}/exports/xlsx":           true,
+	"GET /questionnaires/{id}/exports/sav":            true,
+	"GET /questionnaires/{id}/exports/csv-bundle":     true,
+	"GET /questionnaires/{id}/exports/pdf":            true,
+
+	"POST /webhooks":                          true,
+	"GET /webhooks":                           true,
+	"DELETE /webhooks/{id}":                   true,
+	"GET /webhooks/{id}/deliveries":           true,
+	"POST /webhook-deliveries/{id}/redeliver": true,
+}
+
+// requiresLogin 接口是否需要登录验证（见userRoutes）
+func (r route) requiresLogin() bool {
+	return userRoutes[r.method+" "+r.path] || r.acceptsStreamToken()
+}
+
+// streamRoutes 浏览器通过EventSource访问的接口：EventSource不能设置Authorization头，
+// 注册时使用middleware.StreamAuthMiddleware，除会话令牌外也接受查询参数token中的实时结果令牌
+var streamRoutes = map[string]bool{
+	"GET /questionnaires/{id}/results/stream": true,
+}
+
+// acceptsStreamToken 接口是否接受实时结果令牌（见streamRoutes）
+func (r route) acceptsStreamToken() bool {
+	return streamRoutes[r.method+" "+r.path]
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// ginPath 将{id}形式的路径参数转换为Gin的:id
+func ginPath(path string) string {
+	return pathParamPattern.ReplaceAllString(path, ":$1")
+}
+
+// v1Routes /api/v1的全部接口
+// 资源ID在路径中，其余参数与旧接口相同；OpenAPI文档由这张表生成
+func v1Routes(h routeHandlers) []route {
+	idOf := func(name string) openapi.Parameter { return openapi.PathParam("id", name+"ID") }
+
+	return []route{
+		// 系统
+		{http.MethodGet, "/health", false, h.health.Health,
+			probe("getHealth", "服务状态", openapi.Object(map[string]*openapi.Schema{
+				"status":  healthStatus(),
+				"message": openapi.String(),
+			}, "status", "message"))},
+		{http.MethodGet, "/health/live", false, h.health.Live,
+			probe("getLiveness", "存活检查", openapi.Object(map[string]*openapi.Schema{
+				"status": healthStatus(),
+			}, "status")).Describe("进程能够处理请求即返回200，不检查任何依赖")},
+		{http.MethodGet, "/health/ready", false, h.health.Ready,
+			probe("getReadiness", "就绪检查", openapi.Ref("HealthReport")).
+				Describe("检查数据库连接、表结构版本及其他依赖；关键依赖不可用或服务正在关闭时返回503")},
+		{http.MethodGet, "/openapi.json", false, h.spec,
+			operation("getOpenAPI", tagSystem, "OpenAPI接口文档", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"openapi": openapi.String(),
+				"paths":   openapi.Any(),
+			}, "openapi", "paths"))},
+		{http.MethodGet, "/stats", false, h.questionnaires.GetSystemStats,
+			operation("getStats", tagSystem, "系统总数统计", http.StatusOK, envelope(openapi.Object(map[string]*openapi.Schema{
+				"user_count":          openapi.Integer(),
+				"questionnaire_count": openapi.Integer(),
+				"submission_count":    openapi.Integer(),
+			}, "user_count", "questionnaire_count", "submission_count")))},
+
+		// 用户
+		{http.MethodPost, "/users", false, h.users.Register,
+			operation("registerUser", tagUsers, "注册用户", http.StatusCreated, openapi.Object(map[string]*openapi.Schema{
+				"success": openapi.Boolean(),
+				"message": openapi.String(),
+				"user": openapi.Object(map[string]*openapi.Schema{
+					"id":             openapi.Integer(),
+					"username":       openapi.String(),
+					"email":          openapi.String(),
+					"email_verified": openapi.Boolean(),
+				}, "id", "username", "email", "email_verified"),
+			}, "success", "message", "user")).
+				Describe("新用户的邮箱未验证，注册后向邮箱发送验证邮件；用户名或邮箱已存在时返回409 USERNAME_TAKEN或EMAIL_TAKEN；关闭了密码登录时返回403 FORBIDDEN").
+				WithBody(openapi.Ref("RegisterRequest"))},
+		{http.MethodPost, "/sessions", false, h.users.Login,
+			operation("login", tagUsers, "登录", http.StatusOK, loginSchema()).
+				Describe("用户启用了两步验证时不返回token，而是返回mfa_token，需在mfa_expires_at之前提交验证码完成登录。" +
+					"连续登录失败达到阈值时账户被临时锁定，返回423 ACCOUNT_LOCKED；按IP和用户名限流，超过限制时返回429 RATE_LIMITED。两者都带有Retry-After响应头。" +
+					"关闭了密码登录时返回403 FORBIDDEN").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"username": openapi.String(),
+					"password": openapi.String(),
+				}, "username", "password"))},
+		{http.MethodPost, "/sessions/mfa", false, h.users.CompleteLogin,
+			operation("completeLogin", tagUsers, "两步验证登录", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"success":  openapi.Boolean(),
+				"message":  openapi.String(),
+				"user_id":  openapi.Integer(),
+				"username": openapi.String(),
+				"email":    openapi.String(),
+				"is_admin": openapi.Boolean(),
+				"token":    openapi.String(),
+
+				"email_verified": openapi.Boolean(),
+				"mfa_required":   openapi.Boolean(),
+			}, "success", "user_id", "username", "is_admin", "token", "email_verified", "mfa_required")).
+				Describe("提交登录接口返回的mfa_token和验证器App中的验证码（或一个恢复码）。验证码错误时返回400 VALIDATION_FAILED（字段 code）；" +
+					"令牌过期、已使用或输错次数达到上限时返回401 UNAUTHORIZED，需重新输入密码").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"mfa_token": openapi.String(),
+					"code":      openapi.String(),
+				}, "mfa_token", "code"))},
+		{http.MethodGet, "/login-options", false, h.sso.LoginOptions,
+			operation("getLoginOptions", tagUsers, "可用的登录方式", http.StatusOK, envelope(openapi.Object(map[string]*openapi.Schema{
+				"password_login": openapi.Boolean().Describe("为false时关闭了密码登录、注册和重置密码"),
+				"oidc":           openapi.Boolean().Describe("是否启用了单点登录"),
+				"oidc_name":      openapi.String().Describe("单点登录按钮显示的名称"),
+			}, "password_login", "oidc")))},
+		{http.MethodPost, "/sso/oidc/authorizations", false, h.sso.Start,
+			operation("startOIDCLogin", tagUsers, "发起单点登录", http.StatusCreated, openapi.Object(map[string]*openapi.Schema{
+				"success":           openapi.Boolean(),
+				"message":           openapi.String(),
+				"authorization_url": openapi.String().Describe("身份提供方的授权地址，浏览器跳转到该地址登录"),
+				"state":             openapi.String().Describe("身份提供方回调时原样带回，前端应核对后再提交"),
+				"expires_at":        openapi.DateTime(),
+			}, "success", "authorization_url", "state", "expires_at")).
+				Describe("OpenID Connect授权码流程（带PKCE），code_verifier和nonce保存在服务端。未启用单点登录时返回404 NOT_FOUND")},
+		{http.MethodPost, "/sessions/oidc", false, h.sso.Complete,
+			operation("completeOIDCLogin", tagUsers, "单点登录", http.StatusOK, loginSchema()).
+				Describe("提交身份提供方回调时带回的code和state，响应与 POST /sessions 相同，用户启用了两步验证时同样返回mfa_token。" +
+					"外部身份第一次登录时自动创建用户，邮箱与已验证邮箱的用户相同时关联到该用户。" +
+					"state无效、已使用或已过期时返回400 VALIDATION_FAILED（字段 state）；身份提供方拒绝授权码或ID令牌无效时返回401 UNAUTHORIZED；" +
+					"邮箱已被其他未验证邮箱的用户使用时返回409 EMAIL_TAKEN；未启用单点登录时返回404 NOT_FOUND").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"code":  openapi.String().Describe("身份提供方回调时的授权码"),
+					"state": openapi.String(),
+				}, "code", "state"))},
+		{http.MethodGet, "/mfa", false, h.mfa.GetStatus,
+			operation("getMFAStatus", tagUsers, "两步验证状态", http.StatusOK, envelope(openapi.Ref("MFAStatus")))},
+		{http.MethodPost, "/mfa/totp", false, h.mfa.Enroll,
+			operation("enrollTOTP", tagUsers, "生成两步验证密钥", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"success":     openapi.Boolean(),
+				"message":     openapi.String(),
+				"secret":      openapi.String().Describe("Base32编码的密钥，可手动输入验证器App"),
+				"otpauth_uri": openapi.String().Describe("可生成二维码供验证器App扫描"),
+			}, "success", "secret", "otpauth_uri")).
+				Describe("之前未完成绑定的密钥随之失效；已启用两步验证时返回409 CONFLICT")},
+		{http.MethodPost, "/mfa/totp/confirm", false, h.mfa.Activate,
+			operation("activateTOTP", tagUsers, "启用两步验证", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"success":        openapi.Boolean(),
+				"message":        openapi.String(),
+				"recovery_codes": openapi.Array(openapi.String()),
+				"token":          openapi.String().Describe("新的会话令牌"),
+			}, "success", "recovery_codes", "token")).
+				Describe("提交验证器App中的第一个验证码。恢复码只返回这一次；之前签发的会话令牌全部失效，客户端应改用响应中的token").
+				WithBody(codeBody())},
+		{http.MethodPost, "/mfa/totp/disable", false, h.mfa.Disable,
+			operation("disableTOTP", tagUsers, "关闭两步验证", http.StatusOK, messageSchema()).
+				Describe("需要提交验证码或一个恢复码；删除密钥和全部恢复码").
+				WithBody(codeBody())},
+		{http.MethodPost, "/mfa/recovery-codes", false, h.mfa.RegenerateRecoveryCodes,
+			operation("regenerateRecoveryCodes", tagUsers, "重新生成恢复码", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"success":        openapi.Boolean(),
+				"message":        openapi.String(),
+				"recovery_codes": openapi.Array(openapi.String()),
+			}, "success", "recovery_codes")).
+				Describe("需要提交验证码或一个恢复码；之前的恢复码全部失效").
+				WithBody(codeBody())},
+		{http.MethodPost, "/password-resets", false, h.users.RequestPasswordReset,
+			operation("requestPasswordReset", tagUsers, "申请重置密码", http.StatusOK, messageSchema()).
+				Describe("向邮箱发送一次性的重置令牌，之前未使用的令牌随之失效；无论邮箱是否已注册都返回相同的结果；关闭了密码登录时返回403 FORBIDDEN").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"email": openapi.String(),
+				}, "email"))},
+		{http.MethodPost, "/password-resets/confirm", false, h.users.ConfirmPasswordReset,
+			operation("confirmPasswordReset", tagUsers, "重置密码", http.StatusOK, messageSchema()).
+				Describe("使用邮件中的令牌设置新密码，令牌只能使用一次；重置后解除账户锁定，之前签发的会话令牌全部失效；关闭了密码登录时返回403 FORBIDDEN").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"token":        openapi.String(),
+					"new_password": openapi.String(),
+				}, "token", "new_password"))},
+		{http.MethodPost, "/email-verifications", false, h.users.ResendVerification,
+			operation("resendVerification", tagUsers, "重新发送验证邮件", http.StatusOK, messageSchema()).
+				Describe("之前的验证令牌随之失效；邮箱未注册或已验证时同样返回成功。距上次发送时间太短时返回429 RATE_LIMITED和Retry-After").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"email": openapi.String(),
+				}, "email"))},
+		{http.MethodPost, "/email-verifications/confirm", false, h.users.ConfirmEmail,
+			operation("confirmEmail", tagUsers, "验证邮箱", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"success":  openapi.Boolean(),
+				"message":  openapi.String(),
+				"user_id":  openapi.Integer(),
+				"username": openapi.String(),
+				"email":    openapi.String(),
+			}, "success", "message", "user_id", "username", "email")).
+				Describe("使用邮件中的令牌验证邮箱，令牌只能使用一次；之后修改了邮箱时令牌失效").
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"token": openapi.String(),
+				}, "token"))},
+		{http.MethodGet, "/users", true, h.admin.GetAllUsers,
+			operation("listUsers", tagUsers, "用户列表", http.StatusOK, envelope(paged("users", openapi.Ref("User"), nil))).
+				WithParams(pageParams()...)},
+		{http.MethodGet, "/users/{id}", true, h.admin.GetUserDetail,
+			operation("getUser", tagUsers, "用户详情", http.StatusOK, envelope(openapi.Ref("UserDetail"))).
+				WithParams(idOf("用户"))},
+		{http.MethodPut, "/users/{id}", true, h.admin.UpdateUser,
+			operation("updateUser", tagUsers, "更新用户", http.StatusOK, messageSchema()).
+				WithParams(idOf("用户")).
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"email":    openapi.String(),
+					"phone":    openapi.String(),
+					"is_admin": openapi.Boolean(),
+				}))},
+		{http.MethodDelete, "/users/{id}", true, h.admin.DeleteUser,
+			operation("deleteUser", tagUsers, "删除用户", http.StatusOK, messageSchema()).WithParams(idOf("用户"))},
+		{http.MethodPost, "/users/{id}/unlock", true, h.admin.UnlockUser,
+			operation("unlockUser", tagUsers, "解除用户锁定", http.StatusOK, messageSchema()).
+				Describe("清零用户的连续登录失败次数并解除锁定").
+				WithParams(idOf("用户"))},
+
+		// 问卷
+		{http.MethodGet, "/questionnaires", false, h.questionnaires.GetQuestionnaires,
+			operation("listQuestionnaires", tagQuestionnaires, "问卷列表", http.StatusOK,
+				envelope(paged("questionnaires", openapi.Ref("QuestionnaireSummary"), nil))).
+				WithParams(openapi.QueryParam("user_id", "只返回该用户创建的问卷和已发布的问卷", openapi.Integer(), false)).
+				WithParams(pageParams()...)},
+		{http.MethodPost, "/questionnaires", false, h.questionnaires.CreateQuestionnaire,
+			operation("createQuestionnaire", tagQuestionnaires, "创建问卷", http.StatusCreated, envelope(questionnaireWithQuestions())).
+				WithBody(openapi.Ref("QuestionnaireInput"))},
+		{http.MethodGet, "/questionnaires/{id}", false, h.questionnaires.GetQuestionnaireDetail,
+			operation("getQuestionnaire", tagQuestionnaires, "问卷详情", http.StatusOK, envelope(openapi.Object(map[string]*openapi.Schema{
+				"questionnaire": openapi.Ref("Questionnaire"),
+				"questions":     openapi.Array(openapi.Ref("Question")),
+				"locale":        openapi.String().Describe("实际返回的语言"),
+				"locales":       openapi.Array(openapi.String()).Describe("问卷提供的全部语言，原文语言在前"),
+				"translations":  openapi.Array(openapi.Ref("Translation")),
+			}, "questionnaire", "questions", "locale", "locales"))).
+				Describe("按locale参数或Accept-Language返回对应语言的翻译，没有该语言时返回原文").
+				WithParams(idOf("问卷"),
+					openapi.QueryParam("locale", "问卷语言，优先于Accept-Language", openapi.String(), false),
+					openapi.QueryParam("include_translations", "为true时返回全部翻译", openapi.Boolean(), false))},
+		{http.MethodPut, "/questionnaires/{id}", false, h.questionnaires.UpdateQuestionnaire,
+			operation("updateQuestionnaire", tagQuestionnaires, "更新未发布的问卷", http.StatusOK, envelope(questionnaireWithQuestions())).
+				WithParams(idOf("问卷")).
+				WithBody(openapi.Ref("QuestionnaireInput"))},
+		{http.MethodDelete, "/questionnaires/{id}", false, h.questionnaires.DeleteQuestionnaire,
+			operation("deleteQuestionnaire", tagQuestionnaires, "删除问卷", http.StatusOK, messageSchema()).WithParams(idOf("问卷"))},
+		{http.MethodPut, "/questionnaires/{id}/status", false, h.questionnaires.UpdateQuestionnaireStatus,
+			operation("updateQuestionnaireStatus", tagQuestionnaires, "发布或取消发布问卷", http.StatusOK, envelope(openapi.Ref("Questionnaire"))).
+				WithParams(idOf("问卷")).
+				WithBody(openapi.Object(map[string]*openapi.Schema{"is_published": openapi.Boolean()}, "is_published"))},
+
+		// 答卷
+		{http.MethodGet, "/questionnaires/{id}/submissions", false, h.questionnaires.GetQuestionnaireResults,
+			operation("listSubmissions", tagSubmissions, "问卷的答卷（创建者或管理员）", http.StatusOK, envelope(openapi.Object(map[string]*openapi.Schema{
+				"questionnaire": openapi.Ref("Questionnaire"),
+				"questions":     openapi.Array(openapi.Ref("Question")),
+				"submissions": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+					"submission": openapi.Ref("Submission"),
+					"answers": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+						"id":          openapi.Integer(),
+						"question_id": openapi.Integer(),
+						"content":     openapi.String(),
+						"created_at":  openapi.DateTime(),
+					}, "id", "question_id", "content", "created_at")),
+					"user_info": openapi.Object(map[string]*openapi.Schema{"username": openapi.String()}, "username"),
+				}, "submission", "answers", "user_info")),
+				"total_submissions": openapi.Integer(),
+				"page_size":         openapi.Integer(),
+				"next_cursor":       openapi.String(),
+			}, "questionnaire", "questions", "submissions", "total_submissions", "page_size", "next_cursor"))).
+				WithParams(idOf("问卷")).
+				WithParams(pageParams()...)},
+		{http.MethodPost, "/questionnaires/{id}/submissions", false, h.questionnaires.SubmitQuestionnaire,
+			operation("submitQuestionnaire", tagSubmissions, "提交答卷", http.StatusCreated, messageSchema()).
+				Describe("以登录用户的身份提交；问卷要求验证邮箱而用户邮箱未验证时返回403 EMAIL_UNVERIFIED").
+				WithParams(idOf("问卷")).
+				WithBody(openapi.Object(map[string]*openapi.Schema{
+					"answers": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+						"question_id": openapi.Integer(),
+						"content":     openapi.String().Describe("多选题为JSON数组"),
+					}, "question_id", "content")),
+					"locale": openapi.String().Describe("填写时使用的语言，为空时按Accept-Language"),
+				}, "answers"))},
+		{http.MethodGet, "/questionnaires/{id}/submission-status", false, h.questionnaires.CheckSubmission,
+			operation("getSubmissionStatus", tagSubmissions, "用户是否已提交问卷", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+				"success":       openapi.Boolean(),
+				"has_submitted": openapi.Boolean(),
+				"submission":    openapi.Ref("Submission").OrNull(),
+			}, "success", "has_submitted", "submission")).
+				WithParams(idOf("问卷"), openapi.QueryParam("user_id", "用户ID", openapi.Integer(), true))},
+		{http.MethodPost, "/questionnaires/{id}/results/stream-tokens", false, h.live.CreateStreamToken,
+			operation("createStreamToken", tagSubmissions, "申请实时结果令牌", http.StatusCreated, openapi.Object(map[string]*openapi.Schema{
+				"success":    openapi.Boolean(),
+				"message":    openapi.String(),
+				"token":      openapi.String().Describe("以 ?token= 传给实时结果接口"),
+				"expires_at": openapi.DateTime(),
+			}, "success", "token", "expires_at")).
+				Describe("浏览器的EventSource不能设置Authorization头，先用会话令牌申请只能订阅该问卷实时结果的短期令牌（5分钟）。" +
+					"令牌只在建立连接时校验，连接断开后需重新申请；权限与实时结果接口相同").
+				WithParams(idOf("问卷"))},
+		{http.MethodGet, "/questionnaires/{id}/results/stream", false, h.live.StreamResults,
+			download("streamResults", "实时结果（Server-Sent Events）", "text/event-stream").
+				Describe("使用Authorization头中的会话令牌，或查询参数token中的实时结果令牌（见 POST /questionnaires/{id}/results/stream-tokens）").
+				WithParams(idOf("问卷"),
+					openapi.QueryParam("token", "实时结果令牌，浏览器的EventSource使用", openapi.String(), false))},
+		{http.MethodGet, "/questionnaires/{id}/exports/xlsx", false, h.export.ExportXLSX,
+			download("exportXLSX", "导出Excel工作簿", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet").
+				WithParams(idOf("问卷"))},
+		{http.MethodGet, "/questionnaires/{id}/exports/sav", false, h.export.ExportSAV,
+			download("exportSAV", "导出SPSS数据文件", "application/x-spss-sav").
+				WithParams(idOf("问卷"))},
+		{http.MethodGet, "/questionnaires/{id}/exports/csv-bundle", false, h.export.ExportCSVBundle,
+			download("exportCSVBundle", "导出CSV数据包", "application/zip").
+				WithParams(idOf("问卷"))},
+		{http.MethodGet, "/questionnaires/{id}/exports/pdf", false, h.export.ExportPDF,
+			download("exportPDF", "导出PDF报告", "application/pdf").
+				WithParams(idOf("问卷"),
+					openapi.QueryParam("include_identifying", "是否包含可识别个人的填空题答案", openapi.Boolean(), false))},
+
+		// Webhook
+		{http.MethodPost, "/webhooks", false, h.webhooks.CreateWebhook,
+			operation("createWebhook", tagWebhooks, "创建Webhook订阅", http.StatusCreated, envelope(openapi.Object(map[string]*openapi.Schema{
+				"subscription": openapi.Ref("WebhookSubscription"),
+				"secret":       openapi.String().Describe("签名密钥，仅在创建时返回"),
+			}, "subscription", "secret"))).
+				WithBody(openapi.Ref("WebhookRequest"))},
+		{http.MethodGet, "/webhooks", false, h.webhooks.GetWebhooks,
+			operation("listWebhooks", tagWebhooks, "Webhook订阅列表", http.StatusOK, envelope(paged("subscriptions", openapi.Ref("WebhookSubscription"),
+				map[string]*openapi.Schema{"events": openapi.Array(openapi.String())}))).
+				Describe("指定questionnaire_id时返回该问卷的订阅，否则返回全局订阅（仅管理员）").
+				WithParams(openapi.QueryParam("questionnaire_id", "问卷ID", openapi.Integer(), false)).
+				WithParams(pageParams()...)},
+		{http.MethodDelete, "/webhooks/{id}", false, h.webhooks.DeleteWebhook,
+			operation("deleteWebhook", tagWebhooks, "停用Webhook订阅", http.StatusOK, messageSchema()).
+				WithParams(idOf("订阅"))},
+		{http.MethodGet, "/webhooks/{id}/deliveries", false, h.webhooks.GetWebhookDeliveries,
+			operation("listWebhookDeliveries", tagWebhooks, "订阅的投递记录", http.StatusOK, envelope(paged("deliveries", openapi.Object(map[string]*openapi.Schema{
+				"delivery": openapi.Ref("WebhookDelivery"),
+				"attempts": openapi.Array(openapi.Ref("WebhookDeliveryAttempt")),
+			}, "delivery", "attempts"), nil))).
+				WithParams(idOf("订阅")).
+				WithParams(pageParams()...)},
+		{http.MethodPost, "/webhook-deliveries/{id}/redeliver", false, h.webhooks.RedeliverWebhook,
+			operation("redeliverWebhook", tagWebhooks, "重新投递", http.StatusOK, messageSchema()).
+				WithParams(idOf("投递记录"))},
+
+		// 管理后台
+		{http.MethodGet, "/admin/questionnaires", true, h.admin.GetAllQuestionnaires,
+			operation("adminListQuestionnaires", tagAdmin, "全部问卷及答卷数", http.StatusOK,
+				envelope(paged("questionnaires", openapi.Ref("QuestionnaireOverview"), nil))).
+				WithParams(pageParams()...)},
+		{http.MethodGet, "/admin/questionnaires/{id}/submissions", true, h.admin.GetQuestionnaireSubmissions,
+			operation("adminListSubmissions", tagAdmin, "问卷的答卷详情", http.StatusOK, envelope(paged("submission_details", openapi.Object(map[string]*openapi.Schema{
+				"submission": openapi.Ref("Submission"),
+				"user": openapi.Object(map[string]*openapi.Schema{
+					"id":       openapi.Integer(),
+					"username": openapi.String(),
+				}, "id", "username"),
+				"answers": openapi.Array(openapi.Object(map[string]*openapi.Schema{
+					"question_id": openapi.Integer(),
+					"content":     openapi.String(),
+				}, "question_id", "content")),
+			}, "submission", "user", "answers"), map[string]*openapi.Schema{
+				"questionnaire": openapi.Ref("Questionnaire"),
+				"questions":     openapi.Array(openapi.Ref("Question")),
+			}))).
+				WithParams(idOf("问卷")).
+				WithParams(pageParams()...)},
+		{http.MethodGet, "/admin/statistics", true, h.admin.GetSystemStatistics,
+			operation("adminStatistics", tagAdmin, "系统统计", http.StatusOK, envelope(openapi.Object(map[string]*openapi.Schema{
+				"user_statistics":          counters("total_users", "admin_users", "normal_users"),
+				"questionnaire_statistics": counters("total_questionnaires", "published_questionnaires", "unpublished_questionnaires", "total_questions"),
+				"submission_statistics":    counters("total_submissions", "total_answers", "recent_submissions", "average_answers_per_submission"),
+			}, "user_statistics", "questionnaire_statistics", "submission_statistics")))},
+	}
+}
@@ -0,0 +1,353 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"questionnaire-system/backend/database"
+	"questionnaire-system/backend/handlers"
+	"questionnaire-system/backend/health"
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/mailer"
+	"questionnaire-system/backend/metrics"
+	"questionnaire-system/backend/middleware"
+	"questionnaire-system/backend/oidc"
+	"questionnaire-system/backend/openapi"
+	"questionnaire-system/backend/password"
+	"questionnaire-system/backend/realtime"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/service"
+	"questionnaire-system/backend/session"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options 构建路由所需的依赖
+type Options struct {
+	DB    *database.Database
+	Store repository.Store // 为nil时使用基于DB的GORM仓储
+	Hub   *realtime.Hub    // 实时结果推送，为nil时不推送
+	// Health 就绪检查，为nil时只检查数据库连接和表结构版本
+	Health *health.Checker
+	// Logger 请求日志使用的记录器，为nil时使用slog.Default()；LogSampler为nil时记录全部请求
+	Logger     *slog.Logger
+	LogSampler *logging.Sampler
+	// TracerProvider 创建请求span使用，为nil时使用全局的TracerProvider（由tracing.Setup设置）
+	TracerProvider trace.TracerProvider
+	// Metrics 为nil时不记录指标，也不注册MetricsPath；MetricsAccess为访问指标接口前执行的权限检查
+	Metrics       *metrics.Metrics
+	MetricsPath   string // 默认为 /metrics
+	MetricsAccess gin.HandlerFunc
+
+	// TokenSecret 会话令牌的签名密钥，为空时随机生成（重启后之前签发的令牌失效）；TokenTTL 令牌的有效期，为0时为24小时
+	TokenSecret []byte
+	TokenTTL    time.Duration
+	// Lockout 连续登录失败后锁定账户的策略，零值时不锁定
+	Lockout service.LockoutPolicy
+	// MFA 两步验证的策略，零值时使用service.DefaultMFAPolicy()；MFAIssuer 验证器App中显示的发行方，为空时为“问卷系统”
+	MFA       service.MFAPolicy
+	MFAIssuer string
+	// AuthLimit 登录、重置密码和重新发送验证邮件接口的限流中间件（见middleware.RateLimit），为nil时不限流
+	AuthLimit gin.HandlerFunc
+	// Passwords 密码哈希算法，为nil时使用password.Default()；PasswordPolicy 注册和重置密码时检查，零值时不限制
+	Passwords      *password.Hasher
+	PasswordPolicy password.Policy
+	// Mailer 发送重置密码、验证邮箱等邮件，为nil时只记录到日志
+	Mailer mailer.Mailer
+	// ResetTTL 重置密码令牌的有效期，为0时为1小时；ResetURL 邮件中的重置页面地址
+	ResetTTL time.Duration
+	ResetURL string
+	// VerificationTTL 邮箱验证令牌的有效期，为0时为24小时；VerificationResend 重新发送验证邮件的最短间隔，为0时为1分钟；
+	// VerificationURL 邮件中的验证页面地址
+	VerificationTTL    time.Duration
+	VerificationResend time.Duration
+	VerificationURL    string
+	// OIDC 单点登录的身份提供方，为nil时不启用单点登录；OIDCName 登录页中按钮的名称，为空时为“企业账号”；
+	// OIDCGroupsClaim ID令牌中组的声明名称，为空时为groups；OIDCRoleMapping 组 → 角色（见service.SSOService）；
+	// OIDCLoginTTL 发起登录后完成回调的时限，为0时为10分钟
+	OIDC            *oidc.Provider
+	OIDCName        string
+	OIDCGroupsClaim string
+	OIDCRoleMapping map[string]string
+	OIDCLoginTTL    time.Duration
+	// DisablePasswordLogin 关闭密码登录、注册和重置密码，只能通过单点登录登录
+	DisablePasswordLogin bool
+	// AllowPrivateWebhookTargets 允许Webhook订阅本机和内网的目标地址，仅用于开发和测试；投递器需使用相同的设置（见webhook.NewDispatcher）
+	AllowPrivateWebhookTargets bool
+
+	// Now 业务服务使用的时钟，为nil时为time.Now，测试时可替换
+	Now func() time.Time
+
+	// Middleware 在注册路由之前应用的中间件（请求ID、链路追踪、请求日志、语言协商和错误处理始终启用）
+	Middleware []gin.HandlerFunc
+}
+
+// Services 处理器和命令行工具共用的业务服务
+type Services struct {
+	Users          *service.UserService
+	Questionnaires *service.QuestionnaireService
+	Submissions    *service.SubmissionService
+	Statistics     *service.StatisticsService
+	PasswordResets *service.PasswordResetService
+	Verifications  *service.EmailVerificationService
+	MFA            *service.MFAService
+	SSO            *service.SSOService // 默认未启用
+	Results        *service.ResultsService
+	Webhooks       *service.WebhookService
+}
+
+// NewServices 基于仓储创建全部业务服务；mail发送重置密码和验证邮件，为nil时只记录到日志；m为nil时不记录业务指标
+func NewServices(store repository.Store, publisher service.ResultsPublisher, mail mailer.Mailer, m *metrics.Metrics) *Services {
+	if mail == nil {
+		mail = mailer.Log{}
+	}
+	users := service.NewUserService(store)
+	services := &Services{
+		Users:          users,
+		Questionnaires: service.NewQuestionnaireService(store),
+		Submissions:    service.NewSubmissionService(store, publisher),
+		Statistics:     service.NewStatisticsService(store),
+		PasswordResets: service.NewPasswordResetService(store, users, mail),
+		Verifications:  service.NewEmailVerificationService(store, mail),
+		MFA:            service.NewMFAService(store, users),
+		SSO:            service.NewSSOService(store, users, nil),
+		Results:        service.NewResultsService(store),
+		Webhooks:       service.NewWebhookService(store),
+	}
+	services.Users.Metrics = m
+	services.Questionnaires.Metrics = m
+	services.Submissions.Metrics = m
+	return services
+}
+
+// New 创建Gin路由并注册全部接口
+func New(opts Options) *gin.Engine {
+	store := opts.Store
+	if store == nil {
+		store = repository.NewGormStore(opts.DB.DB)
+	}
+
+	var publisher service.ResultsPublisher
+	if opts.Hub != nil {
+		publisher = opts.Hub
+	}
+	services := NewServices(store, publisher, opts.Mailer, opts.Metrics)
+	if len(opts.TokenSecret) > 0 || opts.TokenTTL > 0 {
+		secret, ttl := opts.TokenSecret, opts.TokenTTL
+		if len(secret) == 0 {
+			secret = session.RandomKey()
+		}
+		if ttl <= 0 {
+			ttl = service.DefaultTokenTTL
+		}
+		services.Users.Tokens = session.NewSigner(secret, ttl)
+	}
+	services.Users.Lockout = opts.Lockout
+	if opts.MFA != (service.MFAPolicy{}) {
+		services.Users.MFA = opts.MFA
+	}
+	if opts.MFAIssuer != "" {
+		services.MFA.Issuer = opts.MFAIssuer
+	}
+	if opts.Now != nil {
+		services.Users.Now = opts.Now
+		services.PasswordResets.Now = opts.Now
+		services.Verifications.Now = opts.Now
+		services.Submissions.Now = opts.Now
+		services.Statistics.Now = opts.Now
+		services.Results.Now = opts.Now
+		services.Webhooks.Now = opts.Now
+	}
+	if opts.Passwords != nil {
+		services.Users.Passwords = opts.Passwords
+	}
+	services.Users.Policy = opts.PasswordPolicy
+	if opts.ResetTTL > 0 {
+		services.PasswordResets.TTL = opts.ResetTTL
+	}
+	services.PasswordResets.URL = opts.ResetURL
+	if opts.VerificationTTL > 0 {
+		services.Verifications.TTL = opts.VerificationTTL
+	}
+	if opts.VerificationResend > 0 {
+		services.Verifications.ResendInterval = opts.VerificationResend
+	}
+	services.Verifications.URL = opts.VerificationURL
+	services.Users.DisablePasswordLogin = opts.DisablePasswordLogin
+	services.SSO.Provider = opts.OIDC
+	if opts.OIDCName != "" {
+		services.SSO.Name = opts.OIDCName
+	}
+	if opts.OIDCGroupsClaim != "" {
+		services.SSO.GroupsClaim = opts.OIDCGroupsClaim
+	}
+	services.SSO.RoleMapping = opts.OIDCRoleMapping
+	if opts.OIDCLoginTTL > 0 {
+		services.SSO.LoginTTL = opts.OIDCLoginTTL
+	}
+
+	checker := opts.Health
+	if checker == nil {
+		checker = health.NewChecker(health.Database(opts.DB.DB), health.Migrations(opts.DB.DB))
+	}
+
+	router := gin.New()
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	// 请求span在访问日志之前，日志中才有trace_id；
+	// 访问日志和请求指标在错误处理之前，记录的是最终的状态码；
+	// 错误处理放在其余中间件之前，其后的中间件和处理器的错误和panic都统一生成错误响应
+	router.Use(
+		middleware.RequestID(),
+		middleware.Tracing(opts.TracerProvider),
+		middleware.RequestLogger(logger, opts.LogSampler),
+	)
+	if opts.Metrics != nil {
+		router.Use(middleware.RequestMetrics(opts.Metrics))
+	}
+	router.Use(middleware.Localize(), middleware.ErrorHandler())
+	router.Use(opts.Middleware...)
+	router.NoRoute(middleware.NotFound)
+
+	// 创建处理器
+	userHandler := handlers.NewUserHandler(services.Users, services.PasswordResets, services.Verifications)
+	mfaHandler := handlers.NewMFAHandler(services.MFA)
+	ssoHandler := handlers.NewSSOHandler(services.SSO, services.Users)
+	questionnaireHandler := handlers.NewQuestionnaireHandler(services.Questionnaires, services.Submissions, services.Statistics)
+	adminHandler := handlers.NewAdminHandler(services.Users, services.Questionnaires, services.Submissions, services.Statistics)
+	exportHandler := handlers.NewExportHandler(services.Results, services.Questionnaires)
+	webhookHandler := handlers.NewWebhookHandler(services.Webhooks)
+	webhookHandler.AllowPrivateTargets = opts.AllowPrivateWebhookTargets
+	healthHandler := handlers.NewHealthHandler(checker)
+	liveResultsHandler := handlers.NewLiveResultsHandler(opts.Hub, services.Results, services.Questionnaires, services.Users)
+
+	// /api/v1接口及由路由表生成的OpenAPI文档
+	var spec *openapi.Document
+	routes := v1Routes(routeHandlers{
+		health:         healthHandler,
+		spec:           func(c *gin.Context) { c.JSON(200, spec) },
+		users:          userHandler,
+		mfa:            mfaHandler,
+		sso:            ssoHandler,
+		questionnaires: questionnaireHandler,
+		admin:          adminHandler,
+		export:         exportHandler,
+		webhooks:       webhookHandler,
+		live:           liveResultsHandler,
+	})
+	spec = newSpec(routes)
+
+	adminAuth := middleware.AdminAuthMiddleware(services.Users)
+	userAuth := middleware.AuthMiddleware(services.Users)
+	streamAuth := middleware.StreamAuthMiddleware(services.Users)
+	authLimit := opts.AuthLimit
+	if authLimit == nil {
+		authLimit = func(c *gin.Context) { c.Next() }
+	}
+	v1 := router.Group(apiV1)
+	for _, r := range routes {
+		chain := []gin.HandlerFunc{r.handler}
+		if r.admin {
+			chain = []gin.HandlerFunc{adminAuth, r.handler}
+		} else if r.acceptsStreamToken() {
+			chain = []gin.HandlerFunc{streamAuth, r.handler}
+		} else if r.requiresLogin() {
+			chain = []gin.HandlerFunc{userAuth, r.handler}
+		}
+		if authPaths[r.path] {
+			chain = append([]gin.HandlerFunc{authLimit}, chain...)
+		}
+		v1.Handle(r.method, ginPath(r.path), chain...)
+	}
+
+	// 旧接口：已废弃，响应头Link指向替代的/api/v1接口
+	deprecated := func(successor string) gin.HandlerFunc {
+		return middleware.Deprecated(apiV1 + successor)
+	}
+
+	// 监控指标：数据库连接池和当前可以填写的问卷数在抓取时计算
+	if opts.Metrics != nil {
+		if sqlDB, err := opts.DB.DB.DB(); err == nil {
+			opts.Metrics.DBStats(sqlDB)
+		}
+		opts.Metrics.Registry.GaugeFunc("questionnaires_open", "当前可以填写的问卷数", func(ctx context.Context) (float64, error) {
+			n, err := services.Statistics.OpenQuestionnaires(ctx)
+			return float64(n), err
+		})
+
+		path := opts.MetricsPath
+		if path == "" {
+			path = "/metrics"
+		}
+		chain := []gin.HandlerFunc{gin.WrapH(opts.Metrics.Registry)}
+		if opts.MetricsAccess != nil {
+			chain = append([]gin.HandlerFunc{opts.MetricsAccess}, chain...)
+		}
+		router.GET(path, chain...)
+	}
+
+	// 健康检查路由
+	router.GET("/api/health", deprecated("/health"), healthHandler.Health)
+
+	// 用户相关路由
+	router.POST("/api/user/register", deprecated("/users"), userHandler.Register)
+	router.POST("/api/user/login", deprecated("/sessions"), authLimit, userHandler.Login)
+	router.POST("/api/user/reset-password", deprecated("/password-resets"), authLimit, userHandler.RequestPasswordReset)
+
+	// 问卷相关路由
+	router.POST("/api/questionnaire/create", deprecated("/questionnaires"), questionnaireHandler.CreateQuestionnaire)
+	router.GET("/api/questionnaire/list", deprecated("/questionnaires"), questionnaireHandler.GetQuestionnaires)
+	router.GET("/api/questionnaire/detail", deprecated("/questionnaires/{id}"), questionnaireHandler.GetQuestionnaireDetail)
+	router.POST("/api/questionnaire/submit", deprecated("/questionnaires/{id}/submissions"), userAuth, questionnaireHandler.SubmitQuestionnaire)
+	router.PUT("/api/questionnaire/update", deprecated("/questionnaires/{id}"), questionnaireHandler.UpdateQuestionnaire)
+	router.PUT("/api/questionnaire/update-status", deprecated("/questionnaires/{id}/status"), questionnaireHandler.UpdateQuestionnaireStatus)
+	router.DELETE("/api/questionnaire/delete", deprecated("/questionnaires/{id}"), questionnaireHandler.DeleteQuestionnaire)
+	router.GET("/api/questionnaire/results", deprecated("/questionnaires/{id}/submissions"), userAuth, questionnaireHandler.GetQuestionnaireResults)
+	router.GET("/api/questionnaire/results/stream", deprecated("/questionnaires/{id}/results/stream"), streamAuth, liveResultsHandler.StreamResults)
+	router.GET("/api/questionnaire/check-submission", deprecated("/questionnaires/{id}/submission-status"), questionnaireHandler.CheckSubmission)
+	router.GET("/api/questionnaire/stats", deprecated("/stats"), questionnaireHandler.GetSystemStats)
+
+	// 结果导出路由（需要登录，权限与问卷结果接口一致）
+	router.GET("/api/questionnaire/export/xlsx", deprecated("/questionnaires/{id}/exports/xlsx"), userAuth, exportHandler.ExportXLSX)
+	router.GET("/api/questionnaire/export/sav", deprecated("/questionnaires/{id}/exports/sav"), userAuth, exportHandler.ExportSAV)
+	router.GET("/api/questionnaire/export/csv-bundle", deprecated("/questionnaires/{id}/exports/csv-bundle"), userAuth, exportHandler.ExportCSVBundle)
+	router.GET("/api/questionnaire/export/pdf", deprecated("/questionnaires/{id}/exports/pdf"), userAuth, exportHandler.ExportPDF)
+
+	// Webhook订阅路由（问卷创建者或管理员）
+	router.POST("/api/webhook/create", deprecated("/webhooks"), userAuth, webhookHandler.CreateWebhook)
+	router.GET("/api/webhook/list", deprecated("/webhooks"), userAuth, webhookHandler.GetWebhooks)
+	router.DELETE("/api/webhook/delete", deprecated("/webhooks/{id}"), userAuth, webhookHandler.DeleteWebhook)
+	router.GET("/api/webhook/deliveries", deprecated("/webhooks/{id}/deliveries"), userAuth, webhookHandler.GetWebhookDeliveries)
+	router.POST("/api/webhook/redeliver", deprecated("/webhook-deliveries/{id}/redeliver"), userAuth, webhookHandler.RedeliverWebhook)
+
+	// 管理员路由组 - 使用管理员权限中间件
+	adminGroup := router.Group("/api/admin")
+	adminGroup.Use(adminAuth)
+	{
+		// 用户管理
+		adminGroup.GET("/users", deprecated("/users"), adminHandler.GetAllUsers)
+		adminGroup.GET("/user/detail", deprecated("/users/{id}"), adminHandler.GetUserDetail)
+		adminGroup.PUT("/user/update", deprecated("/users/{id}"), adminHandler.UpdateUser)
+		adminGroup.DELETE("/user/delete", deprecated("/users/{id}"), adminHandler.DeleteUser)
+
+		// 问卷管理
+		adminGroup.GET("/questionnaires", deprecated("/admin/questionnaires"), adminHandler.GetAllQuestionnaires)
+		adminGroup.GET("/questionnaire/submissions", deprecated("/admin/questionnaires/{id}/submissions"), adminHandler.GetQuestionnaireSubmissions)
+
+		// 系统统计
+		adminGroup.GET("/statistics", deprecated("/admin/statistics"), adminHandler.GetSystemStatistics)
+
+		// 全局Webhook订阅
+		adminGroup.POST("/webhook/create", deprecated("/webhooks"), webhookHandler.CreateWebhook)
+		adminGroup.GET("/webhooks", deprecated("/webhooks"), webhookHandler.GetWebhooks)
+		adminGroup.DELETE("/webhook/delete", deprecated("/webhooks/{id}"), webhookHandler.DeleteWebhook)
+		adminGroup.GET("/webhook/deliveries", deprecated("/webhooks/{id}/deliveries"), webhookHandler.GetWebhookDeliveries)
+		adminGroup.POST("/webhook/redeliver", deprecated("/webhook-deliveries/{id}/redeliver"), webhookHandler.RedeliverWebhook)
+	}
+
+	return router
+}
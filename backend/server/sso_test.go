@@ -0,0 +1,419 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/oidc"
+	"questionnaire-system/backend/session"
+)
+
+// 测试身份提供方登记的客户端
+const (
+	ssoClientID    = "questionnaire"
+	ssoRedirectURL = "https://app.example.com/sso/callback"
+)
+
+// mockIdP 测试用的OpenID Connect身份提供方：发现文档、JWKS、授权端点和令牌端点
+// 不经过浏览器和登录页：authorize校验授权地址后直接为传入的声明签发授权码；令牌端点校验PKCE的code_verifier
+type mockIdP struct {
+	t      testing.TB
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant // 授权码 → 授权，换取令牌后删除
+}
+
+type mockGrant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      map[string]interface{}
+}
+
+func newMockIdP(t testing.TB) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{t: t, key: key, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// provider 指向测试身份提供方的客户端
+func (idp *mockIdP) provider() *oidc.Provider {
+	p := oidc.New(oidc.Config{
+		Issuer:      idp.server.URL,
+		ClientID:    ssoClientID,
+		RedirectURL: ssoRedirectURL,
+		Scopes:      []string{"openid", "email", "profile"},
+	})
+	p.HTTPClient = idp.server.Client()
+	return p
+}
+
+// authorize 用户在身份提供方登录：校验授权地址，以claims签发授权码，返回回调地址中的code和state
+func (idp *mockIdP) authorize(authorizationURL string, claims map[string]interface{}) (code, state string) {
+	idp.t.Helper()
+	u, err := url.Parse(authorizationURL)
+	if err != nil || u.Scheme+"://"+u.Host+u.Path != idp.server.URL+"/authorize" {
+		idp.t.Fatalf("授权地址不正确: %s", authorizationURL)
+	}
+	query := u.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != ssoClientID ||
+		query.Get("redirect_uri") != ssoRedirectURL || query.Get("code_challenge_method") != "S256" ||
+		query.Get("code_challenge") == "" || query.Get("nonce") == "" || query.Get("scope") != "openid email profile" {
+		idp.t.Fatalf("授权请求的参数不正确: %s", authorizationURL)
+	}
+
+	code = fmt.Sprintf("code-%d", time.Now().UnixNano())
+	idp.mu.Lock()
+	idp.grants[code] = mockGrant{
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		claims:      claims,
+	}
+	idp.mu.Unlock()
+	return code, query.Get("state")
+}
+
+// token 令牌端点：授权码只能使用一次，redirect_uri和code_verifier必须与授权请求一致
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != ssoClientID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	idp.mu.Lock()
+	grant, ok := idp.grants[code]
+	delete(idp.grants, code)
+	idp.mu.Unlock()
+	if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if oidc.Challenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE校验失败"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   idp.server.URL,
+		"aud":   ssoClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id_token":     idp.sign(claims),
+		"access_token": "access-" + code,
+		"token_type":   "Bearer",
+	})
+}
+
+// sign 用RS256签发ID令牌
+func (idp *mockIdP) sign(claims map[string]interface{}) string {
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			idp.t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test-key"}) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// withSSO 使用测试身份提供方和可拨动的时钟重新创建路由，configure可修改其他选项
+func (e *testEnv) withSSO(idp *mockIdP, clock *fakeClock, configure func(*Options)) {
+	e.t.Helper()
+	opts := e.options()
+	opts.OIDC, opts.OIDCName, opts.Now = idp.provider(), "Example SSO", clock.Now
+	if configure != nil {
+		configure(&opts)
+	}
+	e.router = New(opts)
+}
+
+// ssoLogin 完成一次单点登录：发起登录，在身份提供方以claims登录，再提交回调中的code和state
+func ssoLogin(env *testEnv, idp *mockIdP, claims map[string]interface{}) *response {
+	env.t.Helper()
+	start := env.post("/api/v1/sso/oidc/authorizations", nil).expect(http.StatusCreated)
+	code, state := idp.authorize(start.path("authorization_url").(string), claims)
+	if state != start.path("state") {
+		env.t.Fatalf("回调中的state应与发起登录时相同: %s", start.Body)
+	}
+	return env.post("/api/v1/sessions/oidc", map[string]string{"code": code, "state": state})
+}
+
+// staffClaims 身份提供方中员工的声明，邮箱已验证
+func staffClaims(subject, username, email string, groups ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":                subject,
+		"email":              email,
+		"email_verified":     true,
+		"preferred_username": username,
+		"groups":             groups,
+	}
+}
+
+func TestSSOProvisioning(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		idp := newMockIdP(t)
+		clock := &fakeClock{now: time.Now()}
+		env.withSSO(idp, clock, nil)
+
+		options := env.get("/api/v1/login-options").expect(http.StatusOK)
+		if options.path("data.password_login") != true || options.path("data.oidc") != true || options.path("data.oidc_name") != "Example SSO" {
+			t.Fatalf("登录方式不正确: %s", options.Body)
+		}
+
+		// 第一次登录时创建用户，邮箱已由身份提供方验证；令牌可以访问需要登录的接口
+		first := ssoLogin(env, idp, staffClaims("sub-carol", "carol", "carol@corp.example")).expect(http.StatusOK)
+		carolID := first.path("user_id")
+		if first.path("username") != "carol" || first.path("email_verified") != true || first.path("is_admin") != false {
+			t.Fatalf("开通的用户不正确: %s", first.Body)
+		}
+		env.get("/api/v1/mfa", bearer(first.path("token").(string))).expect(http.StatusOK)
+
+		// 之后按subject找到同一用户，即使身份提供方中的用户名和邮箱已修改
+		again := ssoLogin(env, idp, staffClaims("sub-carol", "carol.w", "carol.w@corp.example")).expect(http.StatusOK)
+		if again.path("user_id") != carolID || again.path("username") != "carol" {
+			t.Fatalf("应登录为同一用户: %s", again.Body)
+		}
+		identity, err := env.store.SSO().GetIdentity(env.ctx(), idp.server.URL, "sub-carol")
+		if err != nil || identity.Email != "carol.w@corp.example" || identity.LastLoginAt == nil {
+			t.Fatalf("应记录最近一次登录: %+v %v", identity, err)
+		}
+
+		// 邮箱相同且双方都已验证时关联到已有用户；用户名重复时追加序号
+		alice := env.createUser(userOpts{Username: "alice", Verified: true})
+		linked := ssoLogin(env, idp, staffClaims("sub-alice", "alice.smith", alice.Email)).expect(http.StatusOK)
+		if linked.path("user_id") != float64(alice.ID) || linked.path("username") != "alice" {
+			t.Fatalf("应关联到已有用户: %s", linked.Body)
+		}
+		renamed := ssoLogin(env, idp, staffClaims("sub-other-alice", "alice", "alice@corp.example")).expect(http.StatusOK)
+		if renamed.path("username") != "alice2" {
+			t.Fatalf("用户名重复时应追加序号: %s", renamed.Body)
+		}
+		noName := staffClaims("sub-dan", "", "dan.lee@corp.example")
+		if ssoLogin(env, idp, noName).expect(http.StatusOK).path("username") != "dan.lee" {
+			t.Fatal("没有preferred_username时应使用邮箱的用户名部分")
+		}
+
+		// 邮箱被未验证的用户占用、或身份提供方未验证邮箱时不关联
+		env.createUser(userOpts{Username: "mallory", Email: "boss@corp.example"})
+		ssoLogin(env, idp, staffClaims("sub-boss", "boss", "boss@corp.example")).expectCode(http.StatusConflict, "EMAIL_TAKEN")
+		unverified := staffClaims("sub-eve", "eve", alice.Email)
+		unverified["email_verified"] = false
+		ssoLogin(env, idp, unverified).expectCode(http.StatusConflict, "EMAIL_TAKEN")
+		noEmail := staffClaims("sub-ghost", "ghost", "")
+		delete(noEmail, "email")
+		ssoLogin(env, idp, noEmail).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+
+		// 单点登录开通的用户没有密码
+		login(env, "carol", "").expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+
+		// 启用了两步验证的用户同样需要提交验证码
+		_, codes, _ := enableMFA(env, clock, bearer(first.path("token").(string)))
+		challenge := ssoLogin(env, idp, staffClaims("sub-carol", "carol", "carol@corp.example")).expect(http.StatusOK)
+		if challenge.path("mfa_required") != true || challenge.path("token") != nil {
+			t.Fatalf("应返回登录挑战: %s", challenge.Body)
+		}
+		env.post("/api/v1/sessions/mfa", map[string]string{"mfa_token": challenge.path("mfa_token").(string), "code": codes[0]}).
+			expect(http.StatusOK)
+
+		// 删除用户时删除外部身份，再次登录时重新开通
+		admin := env.createAdmin()
+		env.delete(fmt.Sprintf("/api/v1/users/%d", uint(carolID.(float64))), env.asUser(admin.Username)).expect(http.StatusOK)
+		recreated := ssoLogin(env, idp, staffClaims("sub-carol", "carol", "carol@corp.example")).expect(http.StatusOK)
+		if recreated.path("user_id") == carolID {
+			t.Fatalf("删除用户后应重新开通: %s", recreated.Body)
+		}
+	})
+}
+
+func TestSSOUserCannotUsePassword(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		idp := newMockIdP(t)
+		box := env.withMailOptions(Options{OIDC: idp.provider()})
+		carol := ssoLogin(env, idp, staffClaims("sub-carol", "carol", "carol@corp.example")).expect(http.StatusOK)
+		carolID := uint(carol.path("user_id").(float64))
+
+		// 没有密码的用户不能用任何密码登录，也不能通过重置密码设置密码
+		for _, pass := range []string{"", defaultPassword} {
+			login(env, "carol", pass).expectCode(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+		}
+		env.post("/api/v1/password-resets", map[string]string{"email": "carol@corp.example"}).expect(http.StatusOK)
+		select {
+		case <-box.sent:
+			t.Fatal("不应向单点登录开通的用户发送重置密码邮件")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		// 只有单点登录签发的令牌有效，伪造的令牌被拒绝
+		other, _ := session.NewSigner([]byte("another-secret"), time.Hour).Issue(carolID, time.Now())
+		for _, forged := range []string{fmt.Sprintf("token_carol_%s", time.Now().Format("20060102150405")), other} {
+			env.get("/api/v1/mfa", bearer(forged)).expectError(http.StatusUnauthorized, "无效的令牌")
+		}
+		env.get("/api/v1/mfa", bearer(carol.path("token").(string))).expect(http.StatusOK)
+	})
+}
+
+func TestSSORoleMapping(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		idp := newMockIdP(t)
+		env.withSSO(idp, &fakeClock{now: time.Now()}, func(o *Options) {
+			o.OIDCRoleMapping = map[string]string{"qs-admins": "admin", "staff": "user"}
+		})
+
+		admin := ssoLogin(env, idp, staffClaims("sub-root", "root", "root@corp.example", "staff", "qs-admins")).
+			expect(http.StatusOK)
+		if admin.path("is_admin") != true {
+			t.Fatalf("qs-admins组的成员应为管理员: %s", admin.Body)
+		}
+		env.get("/api/v1/users", bearer(admin.path("token").(string))).expect(http.StatusOK)
+
+		// 移出组后下次登录即降为普通用户
+		demoted := ssoLogin(env, idp, staffClaims("sub-root", "root", "root@corp.example", "staff")).expect(http.StatusOK)
+		if demoted.path("is_admin") != false {
+			t.Fatalf("移出管理员组后应降为普通用户: %s", demoted.Body)
+		}
+		env.get("/api/v1/users", bearer(demoted.path("token").(string))).expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		// 组声明可以是单个字符串
+		single := staffClaims("sub-ops", "ops", "ops@corp.example")
+		single["groups"] = "qs-admins"
+		if ssoLogin(env, idp, single).expect(http.StatusOK).path("is_admin") != true {
+			t.Fatal("字符串形式的组声明同样应生效")
+		}
+	})
+}
+
+func TestSSOCallbackValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		idp := newMockIdP(t)
+		clock := &fakeClock{now: time.Now()}
+		env.withSSO(idp, clock, nil)
+		claims := staffClaims("sub-carol", "carol", "carol@corp.example")
+		complete := func(code, state string) *response {
+			return env.post("/api/v1/sessions/oidc", map[string]string{"code": code, "state": state})
+		}
+
+		// state只能使用一次
+		start := env.post("/api/v1/sso/oidc/authorizations", nil).expect(http.StatusCreated)
+		code, state := idp.authorize(start.path("authorization_url").(string), claims)
+		complete(code, state).expect(http.StatusOK)
+		complete(code, state).expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		complete(code, "forged").expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+		env.post("/api/v1/sessions/oidc", map[string]string{"state": state}).expectCode(http.StatusBadRequest, "VALIDATION_FAILED")
+
+		// 超过时限后state失效
+		start = env.post("/api/v1/sso/oidc/authorizations", nil).expect(http.StatusCreated)
+		code, state = idp.authorize(start.path("authorization_url").(string), claims)
+		clock.now = clock.now.Add(11 * time.Minute)
+		complete(code, state).expectError(http.StatusBadRequest, "单点登录已失效，请重新登录")
+
+		// 身份提供方拒绝授权码
+		start = env.post("/api/v1/sso/oidc/authorizations", nil).expect(http.StatusCreated)
+		complete("forged", start.path("state").(string)).expectError(http.StatusUnauthorized, "单点登录失败")
+
+		// ID令牌中的nonce与发起登录时不同（如被截获的令牌重放到另一次登录）
+		replayed := staffClaims("sub-carol", "carol", "carol@corp.example")
+		replayed["nonce"] = "other"
+		ssoLogin(env, idp, replayed).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+
+		// audience不包含本系统的客户端ID
+		foreign := staffClaims("sub-carol", "carol", "carol@corp.example")
+		foreign["aud"] = "another-app"
+		ssoLogin(env, idp, foreign).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+	})
+}
+
+func TestPasswordLoginDisabled(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		idp := newMockIdP(t)
+		user := env.createUser(userOpts{Username: "alice", Verified: true})
+		env.withSSO(idp, &fakeClock{now: time.Now()}, func(o *Options) { o.DisablePasswordLogin = true })
+
+		options := env.get("/api/v1/login-options").expect(http.StatusOK)
+		if options.path("data.password_login") != false || options.path("data.oidc") != true {
+			t.Fatalf("登录方式不正确: %s", options.Body)
+		}
+		login(env, "alice", defaultPassword).expectError(http.StatusForbidden, "已关闭密码登录，请使用单点登录")
+		env.post("/api/v1/users", map[string]string{"username": "bob", "password": "secret123", "email": "bob@example.com"}).
+			expectCode(http.StatusForbidden, "FORBIDDEN")
+		env.post("/api/v1/password-resets", map[string]string{"email": user.Email}).expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		// 已有用户仍可通过单点登录登录
+		resp := ssoLogin(env, idp, staffClaims("sub-alice", "alice", user.Email)).expect(http.StatusOK)
+		if resp.path("user_id") != float64(user.ID) {
+			t.Fatalf("应关联到已有用户: %s", resp.Body)
+		}
+	})
+}
+
+func TestSSODisabled(t *testing.T) {
+	env := newTestEnv(t, backendMemory)
+
+	options := env.get("/api/v1/login-options").expect(http.StatusOK)
+	if options.path("data.password_login") != true || options.path("data.oidc") != false || options.path("data.oidc_name") != nil {
+		t.Fatalf("登录方式不正确: %s", options.Body)
+	}
+	env.post("/api/v1/sso/oidc/authorizations", nil).expectError(http.StatusNotFound, "未启用单点登录")
+	env.post("/api/v1/sessions/oidc", map[string]string{"code": "c", "state": "s"}).expectCode(http.StatusNotFound, "NOT_FOUND")
+}
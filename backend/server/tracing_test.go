@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// 调用方传入的追踪上下文
+const (
+	upstreamTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	upstreamSpanID   = "00f067aa0ba902b7"
+	upstreamTraceCtx = "00-" + upstreamTraceID + "-" + upstreamSpanID + "-01"
+)
+
+// withTracing 使用记录到内存的TracerProvider重新创建路由，并为数据库注册追踪插件
+func (e *testEnv) withTracing() (*tracetest.SpanRecorder, *logRecorder) {
+	e.t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	if err := e.db.DB.Use(tracing.NewGormPlugin(provider)); err != nil {
+		e.t.Fatal(err)
+	}
+
+	logs := &logRecorder{t: e.t}
+	logger := slog.New(logging.NewHandler(&logs.buf, "json", slog.LevelDebug))
+	opts := e.options()
+	opts.Logger, opts.TracerProvider = logger, provider
+	e.router = New(opts)
+	return recorder, logs
+}
+
+// serverSpan 按名称查找唯一的请求span
+func serverSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	var found sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		if s.Name() == name {
+			if found != nil {
+				t.Fatalf("存在多个名为 %s 的span", name)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		t.Fatalf("没有名为 %s 的span", name)
+	}
+	return found
+}
+
+func TestTracing(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+	env.submit(q, env.createUser(userOpts{}))
+	// 先登录，只记录结果页请求的span
+	as := env.asUser(owner.Username)
+	recorder, logs := env.withTracing()
+
+	resp := env.get(fmt.Sprintf("/api/v1/questionnaires/%d/submissions", q.ID),
+		as, withHeader("traceparent", upstreamTraceCtx)).expect(http.StatusOK)
+
+	// 请求span延续调用方的trace
+	spans := recorder.Ended()
+	root := serverSpan(t, spans, "GET /api/v1/questionnaires/:id/submissions")
+	if root.SpanContext().TraceID().String() != upstreamTraceID || root.Parent().SpanID().String() != upstreamSpanID {
+		t.Fatalf("请求span没有延续调用方的trace: trace=%s parent=%s", root.SpanContext().TraceID(), root.Parent().SpanID())
+	}
+	attrs := map[string]string{}
+	for _, kv := range root.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["http.route"] != "/api/v1/questionnaires/:id/submissions" || attrs["http.response.status_code"] != "200" ||
+		attrs["request.id"] != resp.Header.Get("X-Request-ID") {
+		t.Fatalf("请求span的属性不正确: %v", attrs)
+	}
+
+	// 每次查询都是请求span的子span，只记录带占位符的SQL
+	queries := 0
+	for _, s := range spans {
+		if !strings.HasPrefix(s.Name(), "gorm.") {
+			continue
+		}
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Fatalf("查询span %s 不是请求span的子span", s.Name())
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key == "db.query.text" && strings.Contains(kv.Value.AsString(), owner.Username) {
+				t.Fatalf("查询span不应包含参数: %s", kv.Value.AsString())
+			}
+		}
+		queries++
+	}
+	if queries < 2 {
+		t.Fatalf("结果页应记录多个查询span，实际%d个", queries)
+	}
+
+	// 日志带有trace_id
+	requestID := resp.Header.Get("X-Request-ID")
+	if entry := logs.find("请求完成", requestID); entry["trace_id"] != upstreamTraceID || entry["span_id"] != root.SpanContext().SpanID().String() {
+		t.Fatalf("访问日志缺少追踪信息: %v", entry)
+	}
+}
+
+func TestTracingErrorResponses(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+
+	// 未启用追踪时仍传递调用方的trace_id
+	resp := env.get("/api/v1/questionnaires/999", withHeader("traceparent", upstreamTraceCtx)).
+		expectCode(http.StatusNotFound, "QUESTIONNAIRE_NOT_FOUND")
+	if resp.path("error.trace_id") != upstreamTraceID {
+		t.Fatalf("错误响应缺少trace_id: %s", resp.Body)
+	}
+	resp = env.get("/api/v1/questionnaires/999").expectCode(http.StatusNotFound, "QUESTIONNAIRE_NOT_FOUND")
+	if resp.path("error.trace_id") != nil {
+		t.Fatalf("没有追踪上下文时不应返回trace_id: %s", resp.Body)
+	}
+
+	// 启用追踪后每个请求都有trace，服务端错误标记在span上
+	recorder, _ := env.withTracing()
+	resp = env.get("/api/v1/questionnaires/999").expectCode(http.StatusNotFound, "QUESTIONNAIRE_NOT_FOUND")
+	span := serverSpan(t, recorder.Ended(), "GET /api/v1/questionnaires/:id")
+	if resp.path("error.trace_id") != span.SpanContext().TraceID().String() || span.Status().Code == codes.Error {
+		t.Fatalf("4xx的trace_id或状态不正确: %s %v", resp.Body, span.Status())
+	}
+
+	sqlDB, err := env.db.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	resp = env.get("/api/v1/questionnaires/1").expect(http.StatusInternalServerError)
+	spans := recorder.Ended()
+	span = spans[len(spans)-1]
+	if span.Status().Code != codes.Error || resp.path("error.trace_id") != span.SpanContext().TraceID().String() {
+		t.Fatalf("5xx应标记span失败: %v %s", span.Status(), resp.Body)
+	}
+}
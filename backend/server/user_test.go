@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.post("/api/user/register", map[string]interface{}{
+			"username": "alice",
+			"password": "pw123456",
+			"email":    "alice@example.com",
+			"phone":    "13800000000",
+		}).expect(http.StatusCreated).assertGolden("user_register")
+
+		login := env.post("/api/user/login", map[string]string{
+			"username": "alice",
+			"password": "pw123456",
+		}).expect(http.StatusOK)
+		login.assertGolden("user_login")
+
+		if login.path("is_admin") != false {
+			t.Fatalf("新注册用户不应是管理员: %s", login.Body)
+		}
+	})
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.createUser(userOpts{Username: "bob", Email: "bob@example.com"})
+
+		env.post("/api/user/register", map[string]string{
+			"username": "bob", "password": "x", "email": "other@example.com",
+		}).expectError(http.StatusConflict, "用户名已存在")
+
+		env.post("/api/user/register", map[string]string{
+			"username": "bobby", "password": "x", "email": "bob@example.com",
+		}).expectError(http.StatusConflict, "邮箱已存在")
+	})
+}
+
+func TestRegisterIgnoresAdminFlag(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		env.post("/api/user/register", map[string]interface{}{
+			"username": "mallory", "password": "x", "email": "m@example.com", "is_admin": true,
+		}).expect(http.StatusCreated)
+
+		user, err := env.services.Users.GetByUsername(env.ctx(), "mallory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.IsAdmin {
+			t.Fatal("注册接口不应允许设置管理员权限")
+		}
+	})
+}
+
+func TestLoginFailures(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		user := env.createUser(userOpts{})
+
+		resp := env.post("/api/user/login", map[string]string{"username": user.Username, "password": "wrong"})
+		resp.expectError(http.StatusUnauthorized, "用户名或密码错误")
+		resp.assertGolden("user_login_failed")
+
+		env.post("/api/user/login", map[string]string{"username": "nobody", "password": defaultPassword}).
+			expectError(http.StatusUnauthorized, "用户名或密码错误")
+
+		env.do(http.MethodPost, "/api/user/login", "not an object").
+			expectError(http.StatusBadRequest, "无效的请求数据")
+	})
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/webhook"
+)
+
+// receiver 记录收到的Webhook请求，按status依次返回状态码（用完后返回最后一个）
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   []int
+	requests []receivedHook
+}
+
+// receivedHook 收到的一次投递
+type receivedHook struct {
+	Header http.Header
+	Body   []byte
+}
+
+func newReceiver(t *testing.T, status ...int) *receiver {
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedHook{Header: req.Header.Clone(), Body: body})
+		code := r.status[0]
+		if len(r.status) > 1 {
+			r.status = r.status[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedHook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedHook(nil), r.requests...)
+}
+
+// allowPrivateTargets 允许订阅本机的目标地址（webhook.allow_private_targets）重新创建路由，测试接收方在本机
+func (e *testEnv) allowPrivateTargets() {
+	opts := e.options()
+	opts.AllowPrivateWebhookTargets = true
+	e.router = New(opts)
+}
+
+// subscribe 以问卷创建者的身份通过接口创建订阅
+func (e *testEnv) subscribe(q *questionnaireFixture, target, secret string) *models.WebhookSubscription {
+	e.t.Helper()
+	owner, err := e.store.Users().Get(e.ctx(), q.CreatedBy)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	resp := e.post("/api/v1/webhooks", map[string]interface{}{
+		"questionnaire_id": q.ID,
+		"target_url":       target,
+		"secret":           secret,
+		"events":           []string{webhook.EventSubmissionCreated},
+	}, e.asUser(owner.Username)).expect(http.StatusCreated)
+	sub, err := e.store.Webhooks().GetSubscription(e.ctx(), uint(resp.path("data.subscription.id").(float64)))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return sub
+}
+
+// outboxEntry 发件箱中订阅的唯一一条记录
+func (e *testEnv) outboxEntry(sub *models.WebhookSubscription) models.WebhookOutbox {
+	e.t.Helper()
+	entries, err := e.store.Webhooks().ListDeliveries(e.ctx(), sub.ID, repository.Page{})
+	if err != nil || len(entries) != 1 {
+		e.t.Fatalf("发件箱应有一条记录: %v %v", entries, err)
+	}
+	return entries[0]
+}
+
+// testDispatcher 使用可拨动时钟的投递器，最多尝试3次；与allowPrivateTargets相同，允许投递到本机的测试接收方
+// 投递器直接使用数据库，投递相关的测试只在GORM仓储上运行
+func (e *testEnv) testDispatcher(clock *fakeClock) *webhook.Dispatcher {
+	d := webhook.NewDispatcher(e.db.DB, true)
+	d.MaxAttempts = 3
+	d.Now = clock.Now
+	return d
+}
+
+// process 处理到期的记录，返回处理的条数
+func process(t *testing.T, d *webhook.Dispatcher) int {
+	t.Helper()
+	n, err := d.ProcessDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWebhookAuthorization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		other := env.createUser(userOpts{})
+		admin := env.createAdmin()
+		q := env.createQuestionnaire(owner, true)
+		asOwner, asOther := env.asUser(owner.Username), env.asUser(other.Username)
+
+		create := func(body map[string]interface{}, opts ...requestOption) *response {
+			return env.post("/api/v1/webhooks", body, opts...)
+		}
+		hook := map[string]interface{}{
+			"questionnaire_id": q.ID,
+			"target_url":       "https://203.0.113.10/hook",
+			"events":           []string{webhook.EventSubmissionCreated},
+		}
+
+		// 未登录时拒绝，请求中的user_id不能冒充其他用户
+		create(hook).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.post("/api/webhook/create", hook).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		forged := map[string]interface{}{"user_id": owner.ID}
+		for k, v := range hook {
+			forged[k] = v
+		}
+		create(forged, asOther).expectCode(http.StatusForbidden, "FORBIDDEN")
+		env.get(fmt.Sprintf("/api/webhook/list?questionnaire_id=%d&user_id=%d", q.ID, owner.ID), asOther).
+			expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		// 订阅的创建者为当前登录用户
+		created := create(hook, asOwner).expect(http.StatusCreated)
+		if created.path("data.subscription.created_by") != float64(owner.ID) {
+			t.Fatalf("订阅的创建者不正确: %s", created.Body)
+		}
+		hookID := uint(created.path("data.subscription.id").(float64))
+		env.get(fmt.Sprintf("/api/v1/webhooks?questionnaire_id=%d", q.ID), asOwner).expect(http.StatusOK)
+		env.get(fmt.Sprintf("/api/v1/webhooks/%d/deliveries", hookID), asOther).expectCode(http.StatusForbidden, "FORBIDDEN")
+		env.get(fmt.Sprintf("/api/webhook/deliveries?subscription_id=%d", hookID)).expectCode(http.StatusUnauthorized, "UNAUTHORIZED")
+		env.delete(fmt.Sprintf("/api/v1/webhooks/%d", hookID), asOther).expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		// 全局订阅仅管理员
+		global := map[string]interface{}{"target_url": "https://203.0.113.10/hook", "events": []string{webhook.EventQuestionnaireClosed}}
+		create(global, asOwner).expectCode(http.StatusForbidden, "FORBIDDEN")
+		create(global, env.asUser(admin.Username)).expect(http.StatusCreated)
+		env.get("/api/v1/webhooks", asOwner).expectCode(http.StatusForbidden, "FORBIDDEN")
+
+		// 查询失败时返回500而不是空列表
+		if env.backend != backendSQLite {
+			return
+		}
+		sqlDB, err := env.db.DB.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDB.Close()
+		env.get("/api/v1/webhooks", env.asUser(admin.Username)).expectCode(http.StatusInternalServerError, "INTERNAL_ERROR")
+	})
+}
+
+func TestWebhookTargetValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		as := env.asUser(owner.Username)
+
+		create := func(target string) *response {
+			return env.post("/api/v1/webhooks", map[string]interface{}{
+				"questionnaire_id": q.ID,
+				"target_url":       target,
+				"events":           []string{webhook.EventSubmissionCreated},
+			}, as)
+		}
+
+		for _, target := range []string{"ftp://203.0.113.10/hook", "https://", "not a url"} {
+			create(target).expectError(http.StatusBadRequest, "无效的目标地址")
+		}
+		for _, target := range []string{
+			"http://127.0.0.1:8080/hook",
+			"http://localhost/hook",
+			"http://[::1]/hook",
+			"http://[::ffff:10.0.0.1]/hook",
+			"http://10.1.2.3/hook",
+			"http://192.168.1.1/hook",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://100.100.100.200/latest/meta-data/",
+			"http://0.0.0.0/hook",
+		} {
+			create(target).expectError(http.StatusBadRequest, "目标地址不能指向本机、内网或元数据服务")
+		}
+		create("https://203.0.113.10/hook").expect(http.StatusCreated)
+
+		// 允许内网地址时可以订阅本机和内网的接收程序，组播和保留地址仍然拒绝
+		env.allowPrivateTargets()
+		for _, target := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://10.1.2.3/hook", "http://[fd00::1]/hook"} {
+			create(target).expect(http.StatusCreated)
+		}
+		for _, target := range []string{"http://0.0.0.0/hook", "http://224.0.0.1/hook", "http://240.0.0.1/hook"} {
+			create(target).expectError(http.StatusBadRequest, "目标地址不能指向本机、内网或元数据服务")
+		}
+	})
+}
+
+func TestWebhookOutbox(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, env *testEnv) {
+		owner := env.createUser(userOpts{})
+		q := env.createQuestionnaire(owner, true)
+		as := env.asUser(owner.Username)
+		sub := env.subscribe(q, "https://203.0.113.10/hook", "s3cret")
+		other := env.subscribe(env.createQuestionnaire(owner, true), "https://203.0.113.10/hook", "s3cret")
+
+		// 提交时只为订阅了该问卷的订阅写入发件箱记录
+		env.submit(q, env.createUser(userOpts{}))
+		entry := env.outboxEntry(sub)
+		if entry.Status != models.WebhookStatusPending || !strings.Contains(entry.Payload, fmt.Sprintf(`"questionnaire_id":%d`, q.ID)) {
+			t.Fatalf("发件箱记录不正确: %+v", entry)
+		}
+		resp := env.get(fmt.Sprintf("/api/v1/webhooks/%d/deliveries?include_total=true", other.ID), as).expect(http.StatusOK)
+		if resp.path("data.deliveries") != nil || resp.path("data.total") != float64(0) {
+			t.Fatalf("其他问卷的订阅不应有投递记录: %s", resp.Body)
+		}
+
+		resp = env.get(fmt.Sprintf("/api/v1/webhooks/%d/deliveries", sub.ID), as).expect(http.StatusOK)
+		if deliveries := resp.path("data.deliveries").([]interface{}); len(deliveries) != 1 {
+			t.Fatalf("应有1条投递记录: %s", resp.Body)
+		}
+		env.post(fmt.Sprintf("/api/v1/webhook-deliveries/%d/redeliver", entry.ID), nil, as).expect(http.StatusOK)
+		env.post("/api/v1/webhook-deliveries/999/redeliver", nil, as).expectCode(http.StatusNotFound, "NOT_FOUND")
+
+		// 停用后不再写入新的记录
+		env.delete(fmt.Sprintf("/api/v1/webhooks/%d", sub.ID), as).expect(http.StatusOK)
+		env.delete("/api/v1/webhooks/999", as).expectCode(http.StatusNotFound, "NOT_FOUND")
+		env.submit(q, env.createUser(userOpts{}))
+		env.outboxEntry(sub)
+		resp = env.get(fmt.Sprintf("/api/v1/webhooks?questionnaire_id=%d", q.ID), as).expect(http.StatusOK)
+		if subs := resp.path("data.subscriptions").([]interface{}); len(subs) != 1 || subs[0].(map[string]interface{})["is_active"] != false {
+			t.Fatalf("订阅应已停用: %s", resp.Body)
+		}
+	})
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	env.allowPrivateTargets()
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+	recv := newReceiver(t, http.StatusOK)
+	sub := env.subscribe(q, recv.URL+"/hook", "s3cret")
+	env.submit(q, env.createUser(userOpts{}))
+
+	clock := &fakeClock{now: time.Now().Add(time.Minute).Truncate(time.Second)}
+	dispatcher := env.testDispatcher(clock)
+	if n := process(t, dispatcher); n != 1 {
+		t.Fatalf("应投递1条记录，实际%d条", n)
+	}
+
+	// 请求头带有事件、发件箱ID、时间戳和签名
+	hooks := recv.received()
+	if len(hooks) != 1 {
+		t.Fatalf("接收方应收到1次请求，实际%d次", len(hooks))
+	}
+	entry := env.outboxEntry(sub)
+	header := hooks[0].Header
+	timestamp, _ := strconv.ParseInt(header.Get(webhook.HeaderTimestamp), 10, 64)
+	if header.Get(webhook.HeaderEvent) != webhook.EventSubmissionCreated ||
+		header.Get(webhook.HeaderDelivery) != strconv.FormatUint(uint64(entry.ID), 10) ||
+		timestamp != clock.now.Unix() {
+		t.Fatalf("请求头不正确: %v", header)
+	}
+	if want := webhook.Sign("s3cret", timestamp, hooks[0].Body); header.Get(webhook.HeaderSignature) != want ||
+		!webhook.Verify("s3cret", timestamp, hooks[0].Body, want) || webhook.Verify("other", timestamp, hooks[0].Body, want) {
+		t.Fatalf("签名不正确: %s", header.Get(webhook.HeaderSignature))
+	}
+	if string(hooks[0].Body) != entry.Payload || !strings.Contains(entry.Payload, `"event":"submission.created"`) {
+		t.Fatalf("请求体不正确: %s", hooks[0].Body)
+	}
+	if entry.Status != models.WebhookStatusDelivered || entry.Attempts != 1 {
+		t.Fatalf("投递成功后状态不正确: %+v", entry)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	env.allowPrivateTargets()
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+	recv := newReceiver(t, http.StatusInternalServerError)
+	sub := env.subscribe(q, recv.URL, "s3cret")
+	env.submit(q, env.createUser(userOpts{}))
+
+	clock := &fakeClock{now: time.Now().Add(time.Minute).Truncate(time.Second)}
+	dispatcher := env.testDispatcher(clock)
+
+	// 第1、2次失败后分别等待10秒、20秒
+	for attempt, wait := range []time.Duration{10 * time.Second, 20 * time.Second} {
+		process(t, dispatcher)
+		entry := env.outboxEntry(sub)
+		if entry.Status != models.WebhookStatusPending || entry.Attempts != attempt+1 ||
+			!entry.NextAttemptAt.Equal(clock.now.Add(wait)) || entry.LastError != "HTTP 500" {
+			t.Fatalf("第%d次失败后应等待%s重试: %+v", attempt+1, wait, entry)
+		}
+		// 未到重试时间不投递
+		clock.now = clock.now.Add(wait - time.Second)
+		if n := process(t, dispatcher); n != 0 {
+			t.Fatalf("未到重试时间不应投递: %d", n)
+		}
+		clock.now = clock.now.Add(time.Second)
+	}
+
+	// 达到最大次数后不再重试
+	process(t, dispatcher)
+	entry := env.outboxEntry(sub)
+	if entry.Status != models.WebhookStatusFailed || entry.Attempts != 3 {
+		t.Fatalf("达到最大次数后应标记为失败: %+v", entry)
+	}
+	clock.now = clock.now.Add(24 * time.Hour)
+	if n := process(t, dispatcher); n != 0 || len(recv.received()) != 3 {
+		t.Fatalf("失败的记录不应再投递: %d %d", n, len(recv.received()))
+	}
+
+	// 每次尝试都有记录
+	as := env.asUser(owner.Username)
+	resp := env.get(fmt.Sprintf("/api/v1/webhooks/%d/deliveries", sub.ID), as).expect(http.StatusOK)
+	deliveries := resp.path("data.deliveries").([]interface{})
+	attempts := deliveries[0].(map[string]interface{})["attempts"].([]interface{})
+	if len(deliveries) != 1 || len(attempts) != 3 || attempts[2].(map[string]interface{})["status_code"] != float64(http.StatusInternalServerError) {
+		t.Fatalf("投递记录不正确: %s", resp.Body)
+	}
+
+	// 重新投递：重置尝试次数并立即排队
+	recv.mu.Lock()
+	recv.status = []int{http.StatusNoContent}
+	recv.mu.Unlock()
+	env.post(fmt.Sprintf("/api/v1/webhook-deliveries/%d/redeliver", entry.ID), nil, env.asUser(env.createUser(userOpts{}).Username)).
+		expectCode(http.StatusForbidden, "FORBIDDEN")
+	env.post(fmt.Sprintf("/api/v1/webhook-deliveries/%d/redeliver", entry.ID), nil, as).expect(http.StatusOK)
+	if entry := env.outboxEntry(sub); entry.Status != models.WebhookStatusPending || entry.Attempts != 0 {
+		t.Fatalf("重新投递后应重新排队: %+v", entry)
+	}
+	dispatcher.Now = time.Now
+	process(t, dispatcher)
+	if entry := env.outboxEntry(sub); entry.Status != models.WebhookStatusDelivered || entry.Attempts != 1 {
+		t.Fatalf("重新投递应成功: %+v", entry)
+	}
+
+	// 旧接口的ID在请求体中；投递中的记录不能重新投递
+	env.db.DB.Model(&models.WebhookOutbox{}).Where("id = ?", entry.ID).Update("status", models.WebhookStatusProcessing)
+	env.post("/api/webhook/redeliver", map[string]uint{"id": entry.ID}, as).expectCode(http.StatusConflict, "CONFLICT")
+}
+
+func TestWebhookInactiveSubscription(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	env.allowPrivateTargets()
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+	recv := newReceiver(t, http.StatusOK)
+	sub := env.subscribe(q, recv.URL, "s3cret")
+	env.submit(q, env.createUser(userOpts{}))
+
+	// 停用订阅后，已排队的事件不再投递
+	env.delete(fmt.Sprintf("/api/v1/webhooks/%d", sub.ID), env.asUser(owner.Username)).expect(http.StatusOK)
+	process(t, env.testDispatcher(&fakeClock{now: time.Now()}))
+	if entry := env.outboxEntry(sub); entry.Status != models.WebhookStatusFailed || entry.LastError != "订阅已停用" {
+		t.Fatalf("停用的订阅应标记为失败: %+v", entry)
+	}
+	if len(recv.received()) != 0 {
+		t.Fatal("停用的订阅不应收到请求")
+	}
+}
+
+func TestWebhookDialGuard(t *testing.T) {
+	env := newTestEnv(t, backendSQLite)
+	env.allowPrivateTargets()
+	owner := env.createUser(userOpts{})
+	q := env.createQuestionnaire(owner, true)
+	recv := newReceiver(t, http.StatusOK)
+	// 订阅后域名被解析到本机，或关闭了allow_private_targets
+	sub := env.subscribe(q, recv.URL, "s3cret")
+	env.submit(q, env.createUser(userOpts{}))
+
+	// 默认的客户端在连接前检查地址，不会重试
+	dispatcher := webhook.NewDispatcher(env.db.DB, false)
+	process(t, dispatcher)
+	entry := env.outboxEntry(sub)
+	if entry.Status != models.WebhookStatusFailed || !strings.Contains(entry.LastError, webhook.ErrForbiddenTarget.Error()) {
+		t.Fatalf("连接本机地址应直接失败: %+v", entry)
+	}
+	if len(recv.received()) != 0 {
+		t.Fatal("接收方不应收到请求")
+	}
+}
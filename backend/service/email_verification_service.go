@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/i18n"
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/mailer"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/repository"
+)
+
+// EmailVerificationService 通过邮件中的一次性令牌验证用户的邮箱
+// 注册后发送验证邮件，用户可以重新发送，两次发送的间隔不少于ResendInterval；令牌在TTL后过期，使用一次后失效
+type EmailVerificationService struct {
+	Store          repository.Store
+	Mailer         mailer.Mailer
+	TTL            time.Duration // 令牌有效期
+	ResendInterval time.Duration // 同一用户两次发送验证邮件的最短间隔
+	URL            string        // 验证页面地址，令牌作为token查询参数附加在后面；为空时邮件中只包含令牌
+	Now            func() time.Time
+}
+
+// NewEmailVerificationService 创建邮箱验证服务，令牌有效期默认为24小时，重新发送间隔默认为1分钟
+func NewEmailVerificationService(store repository.Store, m mailer.Mailer) *EmailVerificationService {
+	return &EmailVerificationService{Store: store, Mailer: m, TTL: 24 * time.Hour, ResendInterval: time.Minute, Now: time.Now}
+}
+
+// Send 生成验证令牌并发送到用户当前的邮箱，该用户之前的令牌随之失效；邮件在后台发送，发送失败只记录日志
+func (s *EmailVerificationService) Send(ctx context.Context, user *models.User, locale string) error {
+	token, err := newOneTimeToken()
+	if err != nil {
+		return err
+	}
+	now := s.Now()
+	record := &models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hashOneTimeToken(token),
+		ExpiresAt: now.Add(s.TTL),
+		CreatedAt: now,
+	}
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.EmailVerifications().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return tx.EmailVerifications().Create(ctx, record)
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("已生成邮箱验证令牌", "user_id", user.ID, "expires_at", record.ExpiresAt)
+
+	sendInBackground(ctx, s.Mailer, mailer.Message{
+		To:      user.Email,
+		Subject: i18n.T(locale, "mail.verify_email_subject"),
+		Body:    i18n.T(locale, "mail.verify_email_body", user.Username, tokenLink(s.URL, token), int(s.TTL.Hours())),
+	}, user.ID)
+	return nil
+}
+
+// Resend 重新发送验证邮件
+// 邮箱未注册或已验证时不发送并返回nil；距上次发送不足ResendInterval时返回*ThrottledError
+func (s *EmailVerificationService) Resend(ctx context.Context, email, locale string) error {
+	email = strings.TrimSpace(email)
+	log := logging.FromContext(ctx)
+
+	user, err := s.Store.Users().GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Info("重新发送验证邮件的邮箱未注册", "email", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		log.Info("邮箱已验证，不再发送验证邮件", "user_id", user.ID)
+		return nil
+	}
+
+	latest, err := s.Store.EmailVerifications().Latest(ctx, user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if latest != nil {
+		if wait := latest.CreatedAt.Add(s.ResendInterval).Sub(s.Now()); wait > 0 {
+			return &ThrottledError{RetryAfter: wait}
+		}
+	}
+	return s.Send(ctx, user, locale)
+}
+
+// Confirm 校验验证令牌并将用户的邮箱标记为已验证
+// 令牌不存在、已使用、已过期，或用户此后修改了邮箱时返回ErrInvalidVerificationToken
+func (s *EmailVerificationService) Confirm(ctx context.Context, token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	record, err := s.Store.EmailVerifications().GetByHash(ctx, hashOneTimeToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	if record.UsedAt != nil || !now.Before(record.ExpiresAt) {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var user *models.User
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		// 并发使用同一令牌时只有一个请求能标记成功
+		if err := tx.EmailVerifications().MarkUsed(ctx, record.ID, now); errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidVerificationToken
+		} else if err != nil {
+			return err
+		}
+
+		var err error
+		user, err = tx.Users().Get(ctx, record.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+		if user.Email != record.Email {
+			return ErrInvalidVerificationToken
+		}
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+		}
+		return tx.Users().Save(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("邮箱验证成功", "user_id", user.ID)
+	user.Password = ""
+	return user, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// 业务错误，错误信息可直接返回给前端
+var (
+	ErrUserNotFound       = errors.New("用户不存在")
+	ErrUsernameTaken      = errors.New("用户名已存在")
+	ErrEmailTaken         = errors.New("邮箱已存在")
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrCannotDeleteAdmin  = errors.New("不允许删除管理员账户")
+	ErrAccountLocked      = errors.New("登录失败次数过多，账户已被临时锁定")
+	ErrInvalidToken       = errors.New("无效的令牌")
+	ErrSessionRevoked     = errors.New("会话已失效，请重新登录")
+	ErrSessionExpired     = errors.New("会话已过期，请重新登录")
+	ErrInvalidResetToken  = errors.New("重置链接无效或已过期")
+
+	ErrInvalidVerificationToken = errors.New("验证链接无效或已过期")
+	ErrEmailUnverified          = errors.New("请先验证邮箱")
+	ErrTooFrequent              = errors.New("操作过于频繁，请稍后再试")
+
+	ErrInvalidMFACode      = errors.New("验证码错误")
+	ErrInvalidMFAChallenge = errors.New("登录验证已失效，请重新登录")
+	ErrMFAAlreadyEnabled   = errors.New("已启用两步验证")
+	ErrMFANotEnabled       = errors.New("未启用两步验证")
+	ErrMFANotEnrolled      = errors.New("请先生成两步验证密钥")
+
+	ErrPasswordLoginDisabled = errors.New("已关闭密码登录，请使用单点登录")
+	ErrSSODisabled           = errors.New("未启用单点登录")
+	ErrInvalidSSOState       = errors.New("单点登录已失效，请重新登录")
+	ErrSSOFailed             = errors.New("单点登录失败")
+
+	ErrQuestionnaireNotFound = errors.New("问卷不存在")
+	ErrInvalidCreator        = errors.New("无效的创建者ID")
+	ErrNoQuestions           = errors.New("问卷必须包含至少一个问题")
+	ErrEditForbidden         = errors.New("您没有权限编辑此问卷")
+	ErrPublishedReadOnly     = errors.New("已发布的问卷不能编辑")
+	ErrResultsForbidden      = errors.New("您没有权限查看此问卷的结果")
+	ErrInvalidLocale         = errors.New("无效的翻译语言代码")
+	ErrTranslationMismatch   = errors.New("翻译的问题或选项数量与原问卷不一致")
+
+	ErrInvalidSubmission   = errors.New("无效的问卷ID或用户ID")
+	ErrQuestionnaireClosed = errors.New("问卷未发布或不在填写时间内")
+	ErrAlreadySubmitted    = errors.New("您已经提交过该问卷，不能重复提交")
+
+	ErrWebhookNotFound    = errors.New("Webhook订阅不存在")
+	ErrWebhookForbidden   = errors.New("您没有权限管理此问卷的Webhook订阅")
+	ErrDeliveryNotFound   = errors.New("投递记录不存在")
+	ErrDeliveryInProgress = errors.New("该记录正在投递中，请稍后再试")
+)
+
+// LockedError 账户被临时锁定，errors.Is(err, ErrAccountLocked)为true
+type LockedError struct {
+	Until time.Time // 解锁时间
+}
+
+func (e *LockedError) Error() string { return ErrAccountLocked.Error() }
+
+func (e *LockedError) Unwrap() error { return ErrAccountLocked }
+
+// ThrottledError 距上次操作时间太短，errors.Is(err, ErrTooFrequent)为true
+type ThrottledError struct {
+	RetryAfter time.Duration // 需要等待的时间
+}
+
+func (e *ThrottledError) Error() string { return ErrTooFrequent.Error() }
+
+func (e *ThrottledError) Unwrap() error { return ErrTooFrequent }
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/mailer"
+)
+
+// 重置密码和验证邮箱共用的一次性令牌：原文只出现在邮件中，数据库只保存哈希
+
+// sendTimeout 后台发送一封邮件的最长时间
+const sendTimeout = time.Minute
+
+// newOneTimeToken 生成32字节的随机令牌（URL安全的Base64）
+func newOneTimeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashOneTimeToken 数据库中保存的令牌哈希
+func hashOneTimeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenLink 邮件中的链接：base加上token查询参数，base为空或无效时为令牌本身
+func tokenLink(base, token string) string {
+	if base == "" {
+		return token
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// sendInBackground 在后台发送邮件，不受请求结束的影响，发送失败只记录日志
+func sendInBackground(ctx context.Context, m mailer.Mailer, msg mailer.Message, userID uint) {
+	log := logging.FromContext(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+		defer cancel()
+		if err := m.Send(ctx, msg); err != nil {
+			log.Error("发送邮件失败", "user_id", userID, "subject", msg.Subject, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/totp"
+)
+
+const (
+	// totpSkew 校验验证码时允许客户端时钟偏差的时间步数
+	totpSkew = 1
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+)
+
+// recoveryEncoding 恢复码使用小写的Base32字符，不含容易混淆的0和1
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// MFAPolicy 两步验证的策略
+type MFAPolicy struct {
+	ChallengeTTL time.Duration // 密码校验通过后输入验证码的时限
+	MaxFailures  int           // 每次登录最多可输错验证码的次数，达到后需重新输入密码
+	RequireAdmin bool          // 管理员必须启用两步验证才能访问管理接口
+}
+
+// DefaultMFAPolicy 5分钟内完成第二步验证，最多输错5次，不强制管理员启用
+func DefaultMFAPolicy() MFAPolicy {
+	return MFAPolicy{ChallengeTTL: 5 * time.Minute, MaxFailures: 5}
+}
+
+// MFAService 用户自助绑定和解绑基于TOTP的两步验证，管理恢复码
+// 绑定分两步：Enroll生成密钥，用户在验证器App中添加后用Activate提交第一个验证码；时间取自Users.Now
+type MFAService struct {
+	Store  repository.Store
+	Users  *UserService
+	Issuer string // 验证器App中显示的发行方
+}
+
+// NewMFAService 创建两步验证服务
+func NewMFAService(store repository.Store, users *UserService) *MFAService {
+	return &MFAService{Store: store, Users: users, Issuer: "问卷系统"}
+}
+
+// MFAStatus 用户的两步验证状态
+type MFAStatus struct {
+	Enabled       bool       `json:"enabled"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodes int64      `json:"recovery_codes_remaining"` // 未使用的恢复码数量
+}
+
+// Enrollment 待绑定的密钥，URI可生成二维码供验证器App扫描
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// Activation 启用两步验证的结果：恢复码只在此时返回一次；之前签发的会话令牌全部失效，Token为新的会话令牌
+type Activation struct {
+	RecoveryCodes []string
+	Token         string
+}
+
+// Status 获取用户的两步验证状态
+func (s *MFAService) Status(ctx context.Context, userID uint) (*MFAStatus, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &MFAStatus{Enabled: user.TOTPEnabledAt != nil, EnabledAt: user.TOTPEnabledAt}
+	if status.Enabled {
+		if status.RecoveryCodes, err = s.Store.MFA().CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Enroll 生成新的密钥，之前未完成绑定的密钥随之失效；已启用两步验证时返回ErrMFAAlreadyEnabled
+func (s *MFAService) Enroll(ctx context.Context, userID uint) (*Enrollment, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Store.Users().SetTOTP(ctx, userID, secret, nil); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("已生成两步验证密钥", "user_id", userID)
+	return &Enrollment{Secret: secret, URI: totp.URI(s.Issuer, user.Username, secret)}, nil
+}
+
+// Activate 校验验证App生成的第一个验证码，启用两步验证并生成恢复码
+// 没有待绑定的密钥时返回ErrMFANotEnrolled，验证码错误时返回ErrInvalidMFACode
+func (s *MFAService) Activate(ctx context.Context, userID uint, code string) (*Activation, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	now := s.Users.Now()
+	counter, ok, err := totp.Validate(user.TOTPSecret, code, now, totpSkew)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, records, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		user, err := tx.Users().Get(ctx, userID)
+		if err != nil {
+			return err
+		}
+		// 会话令牌的时间精确到毫秒；启用后只有通过两步验证签发的令牌有效
+		revokedAt := now.Truncate(time.Millisecond)
+		user.TOTPEnabledAt = &now
+		user.TOTPLastCounter = counter
+		user.SessionsRevokedAt = &revokedAt
+		if err := tx.Users().Save(ctx, user); err != nil {
+			return err
+		}
+		for i := range records {
+			records[i].UserID = userID
+		}
+		return tx.MFA().ReplaceRecoveryCodes(ctx, userID, records)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("已启用两步验证", "user_id", userID)
+	user.Password = ""
+	return &Activation{RecoveryCodes: codes, Token: s.Users.issueToken(user)}, nil
+}
+
+// Disable 校验验证码或恢复码后关闭两步验证，删除密钥和全部恢复码
+func (s *MFAService) Disable(ctx context.Context, userID uint, code string) error {
+	user, err := s.enabledUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkSecondFactor(ctx, s.Store, user, code, s.Users.Now()); err != nil {
+		return err
+	}
+
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().SetTOTP(ctx, userID, "", nil); err != nil {
+			return err
+		}
+		return tx.MFA().DeleteByUser(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("已关闭两步验证", "user_id", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes 校验验证码或恢复码后重新生成恢复码，之前的恢复码全部失效
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.enabledUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSecondFactor(ctx, s.Store, user, code, s.Users.Now()); err != nil {
+		return nil, err
+	}
+
+	codes, records, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		records[i].UserID = userID
+	}
+	if err := s.Store.MFA().ReplaceRecoveryCodes(ctx, userID, records); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("已重新生成恢复码", "user_id", userID)
+	return codes, nil
+}
+
+func (s *MFAService) user(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.Store.Users().Get(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// enabledUser 获取已启用两步验证的用户，未启用时返回ErrMFANotEnabled
+func (s *MFAService) enabledUser(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	return user, nil
+}
+
+// checkSecondFactor 校验用户输入的验证码或恢复码：6位数字按验证码校验，其余按恢复码校验
+// 验证码和恢复码都只能使用一次，不正确或已使用过时返回ErrInvalidMFACode
+func checkSecondFactor(ctx context.Context, store repository.Store, user *models.User, code string, now time.Time) error {
+	code = strings.TrimSpace(code)
+	if isPasscode(code) {
+		counter, ok, err := totp.Validate(user.TOTPSecret, code, now, totpSkew)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		// 并发提交同一验证码时只有一个请求能记录成功
+		if err := store.Users().UseTOTPCounter(ctx, user.ID, counter); errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidMFACode
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	err := store.MFA().UseRecoveryCode(ctx, user.ID, hashOneTimeToken(normalizeRecoveryCode(code)), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidMFACode
+	}
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Warn("已使用恢复码", "user_id", user.ID)
+	return nil
+}
+
+// isPasscode 是否为验证器App生成的验证码
+func isPasscode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecoveryCodes 生成恢复码，返回展示给用户的原文（形如 abcde-fghij）和只包含哈希的记录
+func newRecoveryCodes() ([]string, []models.RecoveryCode, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := recoveryEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		records[i] = models.RecoveryCode{CodeHash: hashOneTimeToken(code)}
+	}
+	return codes, records, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空白和连字符
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/i18n"
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/mailer"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/repository"
+)
+
+// PasswordResetService 通过邮件中的一次性令牌自助重置密码
+// 数据库只保存令牌的SHA-256哈希；令牌在TTL后过期，使用一次后失效，重置成功后此前签发的会话令牌全部失效
+type PasswordResetService struct {
+	Store  repository.Store
+	Users  *UserService // 新密码使用其密码哈希算法和密码策略
+	Mailer mailer.Mailer
+	TTL    time.Duration // 令牌有效期
+	URL    string        // 重置页面地址，令牌作为token查询参数附加在后面；为空时邮件中只包含令牌
+	Now    func() time.Time
+}
+
+// NewPasswordResetService 创建重置密码服务，令牌有效期默认为1小时
+func NewPasswordResetService(store repository.Store, users *UserService, m mailer.Mailer) *PasswordResetService {
+	return &PasswordResetService{Store: store, Users: users, Mailer: m, TTL: time.Hour, Now: time.Now}
+}
+
+// Request 为邮箱对应的用户生成重置令牌并发送邮件，同一用户之前未使用的令牌随之失效
+// 邮箱不存在时同样返回nil，调用方无法据此判断邮箱是否已注册；邮件在后台发送，发送失败只记录日志
+func (s *PasswordResetService) Request(ctx context.Context, email, locale string) error {
+	if s.Users.DisablePasswordLogin {
+		return ErrPasswordLoginDisabled
+	}
+	email = strings.TrimSpace(email)
+	log := logging.FromContext(ctx)
+
+	user, err := s.Store.Users().GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Info("重置密码的邮箱未注册", "email", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 单点登录开通的用户没有密码，不能通过重置密码获得密码登录
+	if user.Password == "" {
+		log.Info("单点登录开通的用户不能重置密码", "user_id", user.ID)
+		return nil
+	}
+
+	token, err := newOneTimeToken()
+	if err != nil {
+		return err
+	}
+	now := s.Now()
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashOneTimeToken(token),
+		ExpiresAt: now.Add(s.TTL),
+	}
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.PasswordResets().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return tx.PasswordResets().Create(ctx, record)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("已生成重置密码令牌", "user_id", user.ID, "expires_at", record.ExpiresAt)
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: i18n.T(locale, "mail.password_reset_subject"),
+		Body:    i18n.T(locale, "mail.password_reset_body", user.Username, tokenLink(s.URL, token), int(s.TTL.Minutes())),
+	}
+	// 在后台发送，已注册和未注册的邮箱响应时间一致
+	sendInBackground(ctx, s.Mailer, msg, user.ID)
+	return nil
+}
+
+// Confirm 校验重置令牌并设置新密码，同时解除账户锁定、使此前签发的会话令牌失效
+// 令牌不存在、已使用或已过期时返回ErrInvalidResetToken；新密码不符合策略时返回*password.PolicyError，令牌仍然有效
+func (s *PasswordResetService) Confirm(ctx context.Context, token, newPassword string) error {
+	if s.Users.DisablePasswordLogin {
+		return ErrPasswordLoginDisabled
+	}
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+	resets := s.Store.PasswordResets()
+
+	record, err := resets.GetByHash(ctx, hashOneTimeToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	now := s.Now()
+	if record.UsedAt != nil || !now.Before(record.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.Store.Users().Get(ctx, record.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.Users.Policy.Check(newPassword, user.Username); err != nil {
+		return err
+	}
+	hashed, err := s.Users.Passwords.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		// 并发使用同一令牌时只有一个请求能标记成功
+		if err := tx.PasswordResets().MarkUsed(ctx, record.ID, now); errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		} else if err != nil {
+			return err
+		}
+
+		user, err := tx.Users().Get(ctx, record.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		// 会话令牌的时间精确到毫秒
+		revokedAt := now.Truncate(time.Millisecond)
+		user.Password = hashed
+		user.FailedLogins = 0
+		user.LockedUntil = nil
+		user.SessionsRevokedAt = &revokedAt
+		return tx.Users().Save(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("密码重置成功", "user_id", record.UserID)
+	return nil
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"questionnaire-system/backend/metrics"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/pagination"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/webhook"
+)
+
+// QuestionnaireService 问卷的创建、编辑、发布与删除
+type QuestionnaireService struct {
+	Store   repository.Store
+	Metrics *metrics.Metrics // 可为nil
+}
+
+// NewQuestionnaireService 创建问卷服务
+func NewQuestionnaireService(store repository.Store) *QuestionnaireService {
+	return &QuestionnaireService{Store: store}
+}
+
+// QuestionInput 问题内容，排序按在问卷中的位置生成
+type QuestionInput struct {
+	Title    string
+	Type     string
+	Required bool
+	Options  string
+}
+
+// QuestionnaireInput 创建或更新问卷的内容
+type QuestionnaireInput struct {
+	ID          uint // 更新时使用
+	Title       string
+	Description string
+	CreatedBy   uint
+	StartTime   time.Time
+	EndTime     time.Time
+	IsPublished bool // 仅创建时使用，更新时通过SetPublished修改
+	Questions   []QuestionInput
+
+	RequireVerifiedEmail bool // 只允许已验证邮箱的用户填写
+
+	DefaultLocale string             // 原文语言，为空时为中文
+	Translations  []TranslationInput // 其他语言的翻译，更新时整体替换
+}
+
+// QuestionnaireSummary 问卷及创建者用户名
+type QuestionnaireSummary struct {
+	Questionnaire models.Questionnaire `json:"questionnaire"`
+	CreatorName   string               `json:"creator_name"`
+}
+
+// QuestionnaireOverview 管理后台的问卷列表项
+type QuestionnaireOverview struct {
+	Questionnaire   models.Questionnaire `json:"questionnaire"`
+	CreatorName     string               `json:"creator_name"`
+	SubmissionCount int64                `json:"submission_count"`
+	QuestionCount   int64                `json:"question_count"`
+}
+
+// getQuestionnaire 获取问卷，不存在时返回ErrQuestionnaireNotFound
+func getQuestionnaire(ctx context.Context, store repository.Store, id uint) (*models.Questionnaire, error) {
+	questionnaire, err := store.Questionnaires().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrQuestionnaireNotFound
+	}
+	return questionnaire, err
+}
+
+// createQuestions 按顺序为问卷创建问题
+func createQuestions(ctx context.Context, tx repository.Store, questionnaireID uint, inputs []QuestionInput) ([]models.Question, error) {
+	var questions []models.Question
+	for i, q := range inputs {
+		question := models.Question{
+			QuestionnaireID: questionnaireID,
+			Title:           q.Title,
+			Type:            q.Type,
+			Required:        q.Required,
+			Options:         q.Options,
+			Sort:            i,
+		}
+		if err := tx.Questionnaires().CreateQuestion(ctx, &question); err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// Create 创建问卷及其问题和翻译
+func (s *QuestionnaireService) Create(ctx context.Context, in QuestionnaireInput) (*models.Questionnaire, []models.Question, error) {
+	if in.CreatedBy == 0 {
+		return nil, nil, ErrInvalidCreator
+	}
+	if len(in.Questions) == 0 {
+		return nil, nil, ErrNoQuestions
+	}
+	locale, translations, err := validateLocales(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	questionnaire := &models.Questionnaire{
+		Title:         in.Title,
+		Description:   in.Description,
+		CreatedBy:     in.CreatedBy,
+		StartTime:     in.StartTime,
+		EndTime:       in.EndTime,
+		IsPublished:   in.IsPublished,
+		DefaultLocale: locale,
+
+		RequireVerifiedEmail: in.RequireVerifiedEmail,
+	}
+
+	var questions []models.Question
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Questionnaires().Create(ctx, questionnaire); err != nil {
+			return err
+		}
+		var err error
+		if questions, err = createQuestions(ctx, tx, questionnaire.ID, in.Questions); err != nil {
+			return err
+		}
+		return createTranslations(ctx, tx, questionnaire.ID, questions, translations)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if questionnaire.IsPublished {
+		s.Metrics.QuestionnairePublished()
+	}
+	return questionnaire, questions, nil
+}
+
+// Detail 获取问卷及其问题
+func (s *QuestionnaireService) Detail(ctx context.Context, id uint) (*models.Questionnaire, []models.Question, error) {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	questions, err := s.Store.Questionnaires().Questions(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return questionnaire, questions, nil
+}
+
+// usernames 批量查询用户名，已删除的用户不在结果中
+func usernames(ctx context.Context, store repository.Store, ids []uint) (map[uint]string, error) {
+	users, err := store.Users().GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	return names, nil
+}
+
+// questionnaireIDs 问卷ID及创建者ID（去重）
+func questionnaireIDs(questionnaires []models.Questionnaire) (ids, creators []uint) {
+	seen := make(map[uint]bool)
+	for _, q := range questionnaires {
+		ids = append(ids, q.ID)
+		if !seen[q.CreatedBy] {
+			seen[q.CreatedBy] = true
+			creators = append(creators, q.CreatedBy)
+		}
+	}
+	return ids, creators
+}
+
+// listPage 按分页参数查询一页问卷，按需统计总数
+func (s *QuestionnaireService) listPage(ctx context.Context, filter repository.QuestionnaireFilter, params pagination.Params) ([]models.Questionnaire, pagination.Page, error) {
+	questionnaires, err := s.Store.Questionnaires().List(ctx, filter, repository.PageFor(params))
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	questionnaires, page := pagination.Trim(questionnaires, params, func(q models.Questionnaire) uint { return q.ID })
+
+	if params.WithTotal() {
+		total, err := s.Store.Questionnaires().Count(ctx, filter)
+		if err != nil {
+			return nil, pagination.Page{}, err
+		}
+		page.Total = &total
+	}
+	return questionnaires, page, nil
+}
+
+// List 分页获取问卷列表，visibleTo大于0时只返回已发布或由该用户创建的问卷
+func (s *QuestionnaireService) List(ctx context.Context, visibleTo uint, params pagination.Params) ([]QuestionnaireSummary, pagination.Page, error) {
+	questionnaires, page, err := s.listPage(ctx, repository.QuestionnaireFilter{VisibleTo: visibleTo}, params)
+	if err != nil {
+		return nil, page, err
+	}
+
+	_, creators := questionnaireIDs(questionnaires)
+	names, err := usernames(ctx, s.Store, creators)
+	if err != nil {
+		return nil, page, err
+	}
+
+	var result []QuestionnaireSummary
+	for _, q := range questionnaires {
+		result = append(result, QuestionnaireSummary{Questionnaire: q, CreatorName: names[q.CreatedBy]})
+	}
+	return result, page, nil
+}
+
+// Overview 分页获取全部问卷及创建者、提交数、问题数（管理后台）
+// 查询次数与分页大小无关：问卷、创建者、提交数、问题数各一次批量查询
+func (s *QuestionnaireService) Overview(ctx context.Context, params pagination.Params) ([]QuestionnaireOverview, pagination.Page, error) {
+	questionnaires, page, err := s.listPage(ctx, repository.QuestionnaireFilter{}, params)
+	if err != nil {
+		return nil, page, err
+	}
+
+	ids, creators := questionnaireIDs(questionnaires)
+	names, err := usernames(ctx, s.Store, creators)
+	if err != nil {
+		return nil, page, err
+	}
+	submissionCounts, err := s.Store.Submissions().CountByQuestionnaire(ctx, ids)
+	if err != nil {
+		return nil, page, err
+	}
+	questionCounts, err := s.Store.Questionnaires().CountQuestionsByQuestionnaire(ctx, ids)
+	if err != nil {
+		return nil, page, err
+	}
+
+	var result []QuestionnaireOverview
+	for _, q := range questionnaires {
+		result = append(result, QuestionnaireOverview{
+			Questionnaire:   q,
+			CreatorName:     names[q.CreatedBy],
+			SubmissionCount: submissionCounts[q.ID],
+			QuestionCount:   questionCounts[q.ID],
+		})
+	}
+	return result, page, nil
+}
+
+// Update 更新未发布的问卷并替换全部问题和翻译，只有创建者可以编辑
+func (s *QuestionnaireService) Update(ctx context.Context, in QuestionnaireInput) (*models.Questionnaire, []models.Question, error) {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, in.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if questionnaire.CreatedBy != in.CreatedBy {
+		return nil, nil, ErrEditForbidden
+	}
+	if questionnaire.IsPublished {
+		return nil, nil, ErrPublishedReadOnly
+	}
+	locale, translations, err := validateLocales(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	questionnaire.Title = in.Title
+	questionnaire.Description = in.Description
+	questionnaire.StartTime = in.StartTime
+	questionnaire.EndTime = in.EndTime
+	questionnaire.DefaultLocale = locale
+	questionnaire.RequireVerifiedEmail = in.RequireVerifiedEmail
+
+	var questions []models.Question
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Questionnaires().Save(ctx, questionnaire); err != nil {
+			return err
+		}
+		if err := tx.Questionnaires().DeleteTranslations(ctx, questionnaire.ID); err != nil {
+			return err
+		}
+		if err := tx.Questionnaires().DeleteQuestions(ctx, questionnaire.ID); err != nil {
+			return err
+		}
+		var err error
+		if questions, err = createQuestions(ctx, tx, questionnaire.ID, in.Questions); err != nil {
+			return err
+		}
+		return createTranslations(ctx, tx, questionnaire.ID, questions, translations)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return questionnaire, questions, nil
+}
+
+// SetPublished 发布或取消发布问卷，状态变化时触发Webhook事件
+func (s *QuestionnaireService) SetPublished(ctx context.Context, id uint, published bool) (*models.Questionnaire, error) {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wasPublished := questionnaire.IsPublished
+	questionnaire.IsPublished = published
+
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Questionnaires().Save(ctx, questionnaire); err != nil {
+			return err
+		}
+		if wasPublished == published {
+			return nil
+		}
+		event := webhook.EventQuestionnaireClosed
+		if published {
+			event = webhook.EventQuestionnairePublished
+		}
+		return tx.Events().Enqueue(ctx, questionnaire.ID, event, questionnaire)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if published && !wasPublished {
+		s.Metrics.QuestionnairePublished()
+	}
+	return questionnaire, nil
+}
+
+// Delete 删除问卷及其问题、翻译、答案和提交记录，并停用该问卷的Webhook订阅
+func (s *QuestionnaireService) Delete(ctx context.Context, id uint) error {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, id)
+	if err != nil {
+		return err
+	}
+
+	return s.Store.Transaction(ctx, func(tx repository.Store) error {
+		// 答案通过问题关联到问卷，需在删除问题之前删除
+		if err := tx.Submissions().DeleteAnswersByQuestionnaire(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Questionnaires().DeleteTranslations(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Questionnaires().DeleteQuestions(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Submissions().DeleteByQuestionnaire(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Questionnaires().Delete(ctx, id); err != nil {
+			return err
+		}
+
+		err := tx.Events().Enqueue(ctx, id, webhook.EventQuestionnaireDeleted, map[string]interface{}{
+			"id":    questionnaire.ID,
+			"title": questionnaire.Title,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Events().DeactivateSubscriptions(ctx, id)
+	})
+}
+
+// AuthorizeResults 校验已登录用户查看问卷结果的权限（仅创建者或管理员），isAdmin取自登录时加载的用户
+func (s *QuestionnaireService) AuthorizeResults(ctx context.Context, id, userID uint, isAdmin bool) (*models.Questionnaire, error) {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, id)
+	if err != nil {
+		return nil, err
+	}
+	if questionnaire.CreatedBy != userID && !isAdmin {
+		return nil, ErrResultsForbidden
+	}
+	return questionnaire, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"questionnaire-system/backend/export"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/stats"
+)
+
+// ResultsService 问卷结果的汇总统计和导出数据
+type ResultsService struct {
+	Store repository.Store
+	Now   func() time.Time
+}
+
+// NewResultsService 创建结果服务
+func NewResultsService(store repository.Store) *ResultsService {
+	return &ResultsService{Store: store, Now: time.Now}
+}
+
+// Summary 计算问卷的汇总统计，只统计已提交用户的答案
+func (s *ResultsService) Summary(ctx context.Context, questionnaireID uint) (*stats.Summary, error) {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, questionnaireID)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := s.Store.Questionnaires().Questions(ctx, questionnaireID)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := s.Store.Questionnaires().QuestionTranslations(ctx, questionnaireID)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.Store.Submissions().Count(ctx, repository.SubmissionFilter{QuestionnaireID: questionnaireID})
+	if err != nil {
+		return nil, err
+	}
+	answerCounts, err := s.Store.Submissions().CountAnswersByContent(ctx, questionnaireID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]stats.ContentCount, 0, len(answerCounts))
+	for _, ac := range answerCounts {
+		counts = append(counts, stats.ContentCount{QuestionID: ac.QuestionID, Content: ac.Content, Total: ac.Total})
+	}
+	return stats.Summarize(*questionnaire, questions, translations, total, counts, s.Now()), nil
+}
+
+// Dataset 按提交顺序加载问卷的全部答卷
+func (s *ResultsService) Dataset(ctx context.Context, questionnaireID uint) (*export.Dataset, error) {
+	questionnaire, err := getQuestionnaire(ctx, s.Store, questionnaireID)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := s.Store.Questionnaires().Questions(ctx, questionnaireID)
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := s.Store.Submissions().List(ctx, repository.SubmissionFilter{QuestionnaireID: questionnaireID}, repository.Page{})
+	if err != nil {
+		return nil, err
+	}
+	if len(submissions) == 0 {
+		return export.NewDataset(*questionnaire, questions, nil, nil, nil), nil
+	}
+
+	userIDs := make([]uint, 0, len(submissions))
+	for _, sub := range submissions {
+		userIDs = append(userIDs, sub.UserID)
+	}
+	names, err := usernames(ctx, s.Store, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	var answers []models.Answer
+	if len(questions) > 0 {
+		if answers, err = s.Store.Submissions().AnswersByUsers(ctx, questionnaireID, userIDs); err != nil {
+			return nil, err
+		}
+	}
+	return export.NewDataset(*questionnaire, questions, submissions, names, answers), nil
+}
+
+// CreatorName 问卷创建者的用户名，用户已删除时返回空字符串
+func (s *ResultsService) CreatorName(ctx context.Context, questionnaire *models.Questionnaire) (string, error) {
+	user, err := s.Store.Users().Get(ctx, questionnaire.CreatedBy)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/metrics"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/oidc"
+	"questionnaire-system/backend/repository"
+)
+
+// 按身份提供方的组映射的角色
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// ssoUsernameMaxLen 自动生成的用户名（不含重名时追加的序号）的最大长度，用户名列最长50个字符
+const ssoUsernameMaxLen = 40
+
+// SSOService OpenID Connect单点登录（授权码流程，带PKCE）
+// Begin生成授权地址，浏览器登录后身份提供方带着授权码和state回调前端，前端再调用Complete换取会话令牌。
+// 第一次登录的外部身份按邮箱关联已有用户或自动创建用户；时间取自Users.Now
+type SSOService struct {
+	Store    repository.Store
+	Users    *UserService
+	Provider *oidc.Provider // 为nil时未启用单点登录
+	// Name 登录页中单点登录按钮显示的名称
+	Name string
+	// GroupsClaim ID令牌中组的声明名称
+	GroupsClaim string
+	// RoleMapping 组 → 角色（RoleAdmin或RoleUser），为空时不按组修改用户的角色；
+	// 不为空时每次登录都按组重新设置管理员权限，不在任何映射为RoleAdmin的组中的用户降为普通用户
+	RoleMapping map[string]string
+	// LoginTTL 发起登录后需在此时间内完成回调
+	LoginTTL time.Duration
+}
+
+// NewSSOService 创建单点登录服务，provider为nil时未启用；默认从groups声明读取组，10分钟内完成登录
+func NewSSOService(store repository.Store, users *UserService, provider *oidc.Provider) *SSOService {
+	return &SSOService{
+		Store:       store,
+		Users:       users,
+		Provider:    provider,
+		Name:        "企业账号",
+		GroupsClaim: "groups",
+		LoginTTL:    10 * time.Minute,
+	}
+}
+
+// Enabled 是否启用了单点登录
+func (s *SSOService) Enabled() bool {
+	return s.Provider != nil
+}
+
+// SSOAuthorization 发起的单点登录：浏览器跳转到URL，身份提供方回调时原样带回State
+type SSOAuthorization struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
+
+// Begin 发起单点登录，生成state、nonce和PKCE的code_verifier并保存，返回身份提供方的授权地址
+func (s *SSOService) Begin(ctx context.Context) (*SSOAuthorization, error) {
+	if !s.Enabled() {
+		return nil, ErrSSODisabled
+	}
+	state, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+	url, err := s.Provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	logins := s.Store.SSO()
+	now := s.Users.Now()
+	// 顺便清理没有完成的登录，失败不影响本次登录
+	if err := logins.DeleteExpiredLogins(ctx, now); err != nil {
+		logging.FromContext(ctx).Error("清理过期的单点登录失败", "error", err)
+	}
+	login := &models.SSOLogin{
+		StateHash:    hashOneTimeToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(s.LoginTTL),
+	}
+	if err := logins.CreateLogin(ctx, login); err != nil {
+		return nil, err
+	}
+	return &SSOAuthorization{URL: url, State: state, ExpiresAt: login.ExpiresAt}, nil
+}
+
+// Complete 处理身份提供方的回调：校验state，用授权码换取并校验ID令牌，找到或开通用户后与密码登录一样开始会话
+// state不存在、已使用或已过期时返回ErrInvalidSSOState；身份提供方拒绝授权码或ID令牌无效时返回包装了ErrSSOFailed的错误
+func (s *SSOService) Complete(ctx context.Context, state, code string) (*LoginResult, error) {
+	if !s.Enabled() {
+		return nil, ErrSSODisabled
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidSSOState
+	}
+	now := s.Users.Now()
+	login, err := s.Store.SSO().TakeLogin(ctx, hashOneTimeToken(state))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidSSOState
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(login.ExpiresAt) {
+		return nil, ErrInvalidSSOState
+	}
+
+	tokens, err := s.Provider.Exchange(ctx, code, login.CodeVerifier)
+	var oauthErr *oidc.Error
+	if errors.As(err, &oauthErr) {
+		return nil, s.failed(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.Provider.Verify(ctx, tokens.IDToken, login.Nonce)
+	if errors.Is(err, oidc.ErrInvalidIDToken) {
+		return nil, s.failed(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.provision(ctx, claims, now)
+	if err != nil {
+		return nil, err
+	}
+	return s.Users.startSession(ctx, user)
+}
+
+func (s *SSOService) failed(err error) error {
+	s.Users.Metrics.LoginFailed(metrics.ReasonSSOFailed)
+	return fmt.Errorf("%w: %v", ErrSSOFailed, err)
+}
+
+// provision 按发行方和subject找到外部身份对应的用户，第一次登录时关联或创建用户，并按组同步管理员权限
+func (s *SSOService) provision(ctx context.Context, claims *oidc.Claims, now time.Time) (*models.User, error) {
+	issuer := s.Provider.Config.Issuer
+	var user *models.User
+	err := s.Store.Transaction(ctx, func(tx repository.Store) error {
+		identity, err := tx.SSO().GetIdentity(ctx, issuer, claims.Subject)
+		switch {
+		case err == nil:
+			if user, err = tx.Users().Get(ctx, identity.UserID); err != nil {
+				return err
+			}
+		case errors.Is(err, repository.ErrNotFound):
+			if user, err = s.link(ctx, tx, claims, now); err != nil {
+				return err
+			}
+			identity = &models.Identity{UserID: user.ID, Issuer: issuer, Subject: claims.Subject, Email: claims.Email}
+			if err := tx.SSO().CreateIdentity(ctx, identity); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if err := tx.SSO().RecordIdentityLogin(ctx, identity.ID, claims.Email, now); err != nil {
+			return err
+		}
+		return s.syncRole(ctx, tx, user, claims)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// link 外部身份第一次登录时找到对应的用户：
+// 身份提供方确认了邮箱、且已有用户验证过相同的邮箱时关联到该用户；邮箱被其他未验证的用户占用时返回ErrEmailTaken；
+// 否则创建没有密码的新用户，用户名取preferred_username或邮箱的用户名部分，重名时追加序号
+func (s *SSOService) link(ctx context.Context, tx repository.Store, claims *oidc.Claims, now time.Time) (*models.User, error) {
+	log := logging.FromContext(ctx)
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return nil, s.failed(errors.New("ID令牌中没有邮箱"))
+	}
+
+	existing, err := tx.Users().GetByEmail(ctx, email)
+	if err == nil {
+		if claims.EmailVerified && existing.EmailVerifiedAt != nil {
+			log.Info("外部身份关联到已有用户", "user_id", existing.ID, "subject", claims.Subject)
+			return existing, nil
+		}
+		log.Warn("外部身份的邮箱已被未验证的用户使用", "user_id", existing.ID, "subject", claims.Subject)
+		return nil, ErrEmailTaken
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	username, err := s.availableUsername(ctx, tx, ssoUsername(claims))
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{Username: username, Email: email}
+	if claims.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+	if err := tx.Users().Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			// 与同时进行的注册冲突
+			return nil, ErrUsernameTaken
+		}
+		return nil, err
+	}
+	log.Info("单点登录开通用户", "user_id", user.ID, "username", user.Username, "subject", claims.Subject)
+	return user, nil
+}
+
+// availableUsername base未被使用时返回base，否则依次尝试base2、base3……
+// 先查询再创建，不依赖唯一约束冲突重试（PostgreSQL的事务在语句出错后不能继续使用）
+func (s *SSOService) availableUsername(ctx context.Context, tx repository.Store, base string) (string, error) {
+	for i := 1; ; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = base + strconv.Itoa(i)
+		}
+		_, err := tx.Users().GetByUsername(ctx, candidate)
+		if errors.Is(err, repository.ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// ssoUsername 新用户的用户名：preferred_username，没有时取邮箱@之前的部分，去掉空白字符并截断
+func ssoUsername(claims *oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > ssoUsernameMaxLen {
+		name = string(runes[:ssoUsernameMaxLen])
+	}
+	if name == "" {
+		name = "user"
+	}
+	return name
+}
+
+// syncRole 按ID令牌中的组设置管理员权限，未配置RoleMapping时不修改
+func (s *SSOService) syncRole(ctx context.Context, tx repository.Store, user *models.User, claims *oidc.Claims) error {
+	if len(s.RoleMapping) == 0 {
+		return nil
+	}
+	admin := false
+	for _, group := range claims.Strings(s.GroupsClaim) {
+		if s.RoleMapping[group] == RoleAdmin {
+			admin = true
+		}
+	}
+	if user.IsAdmin == admin {
+		return nil
+	}
+	user.IsAdmin = admin
+	logging.FromContext(ctx).Info("按身份提供方的组修改管理员权限", "user_id", user.ID, "is_admin", admin)
+	return tx.Users().Save(ctx, user)
+}
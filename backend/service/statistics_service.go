@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"questionnaire-system/backend/repository"
+)
+
+// StatisticsService 系统统计
+type StatisticsService struct {
+	Store repository.Store
+	Now   func() time.Time
+}
+
+// NewStatisticsService 创建统计服务
+func NewStatisticsService(store repository.Store) *StatisticsService {
+	return &StatisticsService{Store: store, Now: time.Now}
+}
+
+// Totals 用户、问卷、提交总数
+type Totals struct {
+	Users          int64
+	Questionnaires int64
+	Submissions    int64
+}
+
+// SystemStatistics 管理后台的系统统计
+type SystemStatistics struct {
+	Totals
+	Admins                      int64
+	PublishedQuestionnaires     int64
+	Questions                   int64
+	Answers                     int64
+	RecentSubmissions           int64   // 最近7天的提交数
+	AverageAnswersPerSubmission float64 // 没有提交时为0
+}
+
+// Totals 统计用户、问卷和提交总数
+func (s *StatisticsService) Totals(ctx context.Context) (Totals, error) {
+	var totals Totals
+	var err error
+	if totals.Users, err = s.Store.Users().Count(ctx, false); err != nil {
+		return totals, err
+	}
+	if totals.Questionnaires, err = s.Store.Questionnaires().Count(ctx, repository.QuestionnaireFilter{}); err != nil {
+		return totals, err
+	}
+	totals.Submissions, err = s.Store.Submissions().Count(ctx, repository.SubmissionFilter{})
+	return totals, err
+}
+
+// OpenQuestionnaires 当前可以填写的问卷数
+func (s *StatisticsService) OpenQuestionnaires(ctx context.Context) (int64, error) {
+	return s.Store.Questionnaires().Count(ctx, repository.QuestionnaireFilter{OpenAt: s.Now()})
+}
+
+// System 统计管理后台展示的全部系统数据
+func (s *StatisticsService) System(ctx context.Context) (*SystemStatistics, error) {
+	totals, err := s.Totals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	published := true
+	st := &SystemStatistics{Totals: totals}
+	if st.Admins, err = s.Store.Users().Count(ctx, true); err != nil {
+		return nil, err
+	}
+	if st.PublishedQuestionnaires, err = s.Store.Questionnaires().Count(ctx, repository.QuestionnaireFilter{Published: &published}); err != nil {
+		return nil, err
+	}
+	if st.Questions, err = s.Store.Questionnaires().CountQuestions(ctx, 0); err != nil {
+		return nil, err
+	}
+	if st.Answers, err = s.Store.Submissions().CountAnswers(ctx); err != nil {
+		return nil, err
+	}
+	since := s.Now().AddDate(0, 0, -7)
+	if st.RecentSubmissions, err = s.Store.Submissions().Count(ctx, repository.SubmissionFilter{Since: since}); err != nil {
+		return nil, err
+	}
+
+	if st.Submissions > 0 {
+		st.AverageAnswersPerSubmission = float64(st.Answers) / float64(st.Submissions)
+	}
+	return st, nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"questionnaire-system/backend/i18n"
+	"questionnaire-system/backend/metrics"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/pagination"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/webhook"
+)
+
+// ResultsPublisher 通知问卷结果发生变化（如实时结果推送）
+type ResultsPublisher interface {
+	Publish(questionnaireID uint)
+}
+
+// SubmissionService 答卷的提交与查询
+type SubmissionService struct {
+	Store     repository.Store
+	Publisher ResultsPublisher // 可为nil
+	Metrics   *metrics.Metrics // 可为nil
+	Now       func() time.Time
+}
+
+// NewSubmissionService 创建答卷服务
+func NewSubmissionService(store repository.Store, publisher ResultsPublisher) *SubmissionService {
+	return &SubmissionService{Store: store, Publisher: publisher, Now: time.Now}
+}
+
+// SubmitInput 提交的答卷
+type SubmitInput struct {
+	QuestionnaireID uint
+	UserID          uint // 提交答卷的用户，由调用方从登录会话获取
+	IPAddress       string
+	Answers         []models.Answer
+	Locale          string // 填写时使用的语言（语言代码或Accept-Language）
+}
+
+// Response 一份答卷：提交记录、答题用户及答案
+type Response struct {
+	Submission models.Submission
+	User       models.User // 仅包含ID和用户名，用户已删除时为空
+	Answers    []models.Answer
+}
+
+// Submit 保存答卷，问卷须处于可填写状态，每个用户对同一问卷只能提交一次
+// 问卷要求验证邮箱时，邮箱未验证的用户返回ErrEmailUnverified
+// 选择题的译文选项换算为原文选项保存，答卷记录填写时使用的语言
+func (s *SubmissionService) Submit(ctx context.Context, in SubmitInput) (*models.Submission, error) {
+	if in.QuestionnaireID == 0 || in.UserID == 0 {
+		return nil, ErrInvalidSubmission
+	}
+
+	questionnaire, err := getQuestionnaire(ctx, s.Store, in.QuestionnaireID)
+	if err != nil {
+		return nil, err
+	}
+	if !acceptsResponses(questionnaire, s.Now()) {
+		return nil, ErrQuestionnaireClosed
+	}
+	if questionnaire.RequireVerifiedEmail {
+		user, err := s.Store.Users().Get(ctx, in.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidSubmission
+		}
+		if err != nil {
+			return nil, err
+		}
+		if user.EmailVerifiedAt == nil {
+			return nil, ErrEmailUnverified
+		}
+	}
+
+	if _, err := s.Store.Submissions().Find(ctx, in.QuestionnaireID, in.UserID); err == nil {
+		return nil, ErrAlreadySubmitted
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	locale, aliases, err := s.answerLocale(ctx, questionnaire, in.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	submission := &models.Submission{
+		QuestionnaireID: in.QuestionnaireID,
+		UserID:          in.UserID,
+		SubmittedAt:     s.Now(),
+		IPAddress:       in.IPAddress,
+		Locale:          locale,
+	}
+
+	err = s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Submissions().Create(ctx, submission); err != nil {
+			return err
+		}
+
+		var saved []models.Answer
+		for _, answer := range in.Answers {
+			answer.ID = 0
+			answer.UserID = in.UserID
+			if a, ok := aliases[answer.QuestionID]; ok {
+				answer.Content = canonicalAnswer(answer.Content, a)
+			}
+			if err := tx.Submissions().CreateAnswer(ctx, &answer); err != nil {
+				return err
+			}
+			saved = append(saved, answer)
+		}
+
+		// 在同一事务中写入Webhook发件箱
+		return tx.Events().Enqueue(ctx, in.QuestionnaireID, webhook.EventSubmissionCreated, map[string]interface{}{
+			"submission": submission,
+			"answers":    saved,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrAlreadySubmitted
+		}
+		return nil, err
+	}
+
+	s.Metrics.SubmissionCreated()
+
+	// 通知实时结果订阅者
+	if s.Publisher != nil {
+		s.Publisher.Publish(in.QuestionnaireID)
+	}
+	return submission, nil
+}
+
+// answerLocale 匹配答卷使用的语言，并返回选择题译文选项到原文选项的对应关系
+func (s *SubmissionService) answerLocale(ctx context.Context, q *models.Questionnaire, requested string) (string, map[uint]map[string]string, error) {
+	original := originalLocale(q)
+	translations, err := s.Store.Questionnaires().Translations(ctx, q.ID)
+	if err != nil || len(translations) == 0 {
+		return original, nil, err
+	}
+
+	locales := []string{original}
+	for _, t := range translations {
+		locales = append(locales, t.Locale)
+	}
+	questions, err := s.Store.Questionnaires().Questions(ctx, q.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	questionTranslations, err := s.Store.Questionnaires().QuestionTranslations(ctx, q.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return i18n.Match(requested, locales, original), models.OptionAliases(questions, questionTranslations), nil
+}
+
+// acceptsResponses 问卷是否可以填写：已发布，且在设置的开始和结束时间之间（未设置的一端不限制）
+func acceptsResponses(q *models.Questionnaire, now time.Time) bool {
+	if !q.IsPublished {
+		return false
+	}
+	if !q.StartTime.IsZero() && now.Before(q.StartTime) {
+		return false
+	}
+	if !q.EndTime.IsZero() && now.After(q.EndTime) {
+		return false
+	}
+	return true
+}
+
+// Check 查询用户对问卷的提交记录，未提交时返回nil
+func (s *SubmissionService) Check(ctx context.Context, questionnaireID, userID uint) (*models.Submission, error) {
+	submission, err := s.Store.Submissions().Find(ctx, questionnaireID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	return submission, err
+}
+
+// Responses 分页获取问卷的答卷，newestFirst为true时按提交时间倒序
+// 提交记录、答案和答题用户各一次查询，再在内存中按用户分组
+func (s *SubmissionService) Responses(ctx context.Context, questionnaireID uint, newestFirst bool, params pagination.Params) ([]Response, pagination.Page, error) {
+	filter := repository.SubmissionFilter{QuestionnaireID: questionnaireID, NewestFirst: newestFirst}
+	submissions, err := s.Store.Submissions().List(ctx, filter, repository.PageFor(params))
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	submissions, page := pagination.Trim(submissions, params, func(s models.Submission) uint { return s.ID })
+
+	if params.WithTotal() {
+		total, err := s.Store.Submissions().Count(ctx, filter)
+		if err != nil {
+			return nil, page, err
+		}
+		page.Total = &total
+	}
+	if len(submissions) == 0 {
+		return nil, page, nil
+	}
+
+	userIDs := make([]uint, 0, len(submissions))
+	for _, submission := range submissions {
+		userIDs = append(userIDs, submission.UserID)
+	}
+
+	answers, err := s.Store.Submissions().AnswersByUsers(ctx, questionnaireID, userIDs)
+	if err != nil {
+		return nil, page, err
+	}
+	answersByUser := make(map[uint][]models.Answer)
+	for _, answer := range answers {
+		answersByUser[answer.UserID] = append(answersByUser[answer.UserID], answer)
+	}
+
+	names, err := usernames(ctx, s.Store, userIDs)
+	if err != nil {
+		return nil, page, err
+	}
+
+	var responses []Response
+	for _, submission := range submissions {
+		response := Response{Submission: submission, Answers: answersByUser[submission.UserID]}
+		if name, ok := names[submission.UserID]; ok {
+			response.User = models.User{ID: submission.UserID, Username: name}
+		}
+		responses = append(responses, response)
+	}
+	return responses, page, nil
+}
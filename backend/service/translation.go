@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"questionnaire-system/backend/i18n"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/repository"
+)
+
+// 问卷的多语言翻译
+//
+// 问卷以DefaultLocale编写，其他语言的标题、说明、问题和选项保存在翻译表中。
+// 选项按位置与原问题对应，位置（从1开始）即选项ID：不同语言的答案在提交时
+// 换算为原文选项保存，统计时按选项ID合并。
+
+// TranslationInput 问卷的一种翻译，Questions与问卷的问题按位置对应，可以为空
+type TranslationInput struct {
+	Locale      string
+	Title       string
+	Description string
+	Questions   []QuestionTranslationInput
+}
+
+// QuestionTranslationInput 问题的翻译，Options与原问题的选项按位置对应，为空时沿用原选项
+type QuestionTranslationInput struct {
+	Title   string
+	Options []string
+}
+
+// Translation 问卷的一种翻译（编辑问卷时使用）
+type Translation struct {
+	Locale      string                `json:"locale"`
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Questions   []QuestionTranslation `json:"questions"`
+}
+
+// QuestionTranslation 问题的翻译
+type QuestionTranslation struct {
+	QuestionID uint     `json:"question_id"`
+	Title      string   `json:"title"`
+	Options    []string `json:"options"`
+}
+
+// LocalizedQuestionnaire 按语言翻译后的问卷
+type LocalizedQuestionnaire struct {
+	Questionnaire *models.Questionnaire
+	Questions     []models.Question
+	Locale        string   // 实际使用的语言
+	Locales       []string // 问卷提供的全部语言，原文语言在前
+}
+
+// defaultLocale 规范化问卷的原文语言，未指定时为中文
+func defaultLocale(locale string) (string, error) {
+	if locale == "" {
+		return i18n.Default, nil
+	}
+	canonical, ok := i18n.Canonical(locale)
+	if !ok {
+		return "", ErrInvalidLocale
+	}
+	return canonical, nil
+}
+
+// originalLocale 问卷的原文语言，迁移前创建的问卷为中文
+func originalLocale(q *models.Questionnaire) string {
+	if q.DefaultLocale == "" {
+		return i18n.Default
+	}
+	return q.DefaultLocale
+}
+
+// validateLocales 校验问卷的原文语言和翻译，返回规范化的原文语言和翻译
+func validateLocales(in QuestionnaireInput) (string, []TranslationInput, error) {
+	locale, err := defaultLocale(in.DefaultLocale)
+	if err != nil {
+		return "", nil, err
+	}
+	translations, err := validateTranslations(locale, in.Questions, in.Translations)
+	if err != nil {
+		return "", nil, err
+	}
+	return locale, translations, nil
+}
+
+// validateTranslations 校验翻译并规范化语言代码：语言有效且不重复、不与原文语言相同，
+// 问题数与问卷一致，翻译的选项数与原问题一致
+func validateTranslations(original string, questions []QuestionInput, translations []TranslationInput) ([]TranslationInput, error) {
+	seen := map[string]bool{original: true}
+	result := make([]TranslationInput, 0, len(translations))
+	for _, t := range translations {
+		locale, ok := i18n.Canonical(t.Locale)
+		if !ok || seen[locale] {
+			return nil, ErrInvalidLocale
+		}
+		seen[locale] = true
+
+		if len(t.Questions) > 0 && len(t.Questions) != len(questions) {
+			return nil, ErrTranslationMismatch
+		}
+		for i, q := range t.Questions {
+			declared := models.Question{Options: questions[i].Options}.OptionList()
+			if len(q.Options) > 0 && len(q.Options) != len(declared) {
+				return nil, ErrTranslationMismatch
+			}
+		}
+		t.Locale = locale
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// createTranslations 保存问卷的翻译，questions为已创建的问题（与翻译按位置对应）
+func createTranslations(ctx context.Context, tx repository.Store, questionnaireID uint, questions []models.Question, translations []TranslationInput) error {
+	for _, t := range translations {
+		err := tx.Questionnaires().CreateTranslation(ctx, &models.QuestionnaireTranslation{
+			QuestionnaireID: questionnaireID,
+			Locale:          t.Locale,
+			Title:           t.Title,
+			Description:     t.Description,
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, q := range t.Questions {
+			translation := &models.QuestionTranslation{
+				QuestionnaireID: questionnaireID,
+				QuestionID:      questions[i].ID,
+				Locale:          t.Locale,
+				Title:           q.Title,
+			}
+			if len(q.Options) > 0 {
+				options, err := json.Marshal(q.Options)
+				if err != nil {
+					return err
+				}
+				translation.Options = string(options)
+			}
+			if err := tx.Questionnaires().CreateQuestionTranslation(ctx, translation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Translations 获取问卷的全部翻译
+func (s *QuestionnaireService) Translations(ctx context.Context, id uint) ([]Translation, error) {
+	if _, err := getQuestionnaire(ctx, s.Store, id); err != nil {
+		return nil, err
+	}
+	translations, err := s.Store.Questionnaires().Translations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	questionTranslations, err := s.Store.Questionnaires().QuestionTranslations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Translation, 0, len(translations))
+	for _, t := range translations {
+		translation := Translation{Locale: t.Locale, Title: t.Title, Description: t.Description}
+		for _, qt := range questionTranslations {
+			if qt.Locale == t.Locale {
+				translation.Questions = append(translation.Questions, QuestionTranslation{
+					QuestionID: qt.QuestionID,
+					Title:      qt.Title,
+					Options:    qt.OptionList(),
+				})
+			}
+		}
+		result = append(result, translation)
+	}
+	return result, nil
+}
+
+// Localize 获取按语言翻译的问卷及其问题
+// acceptLanguage为Accept-Language或单个语言代码，问卷没有对应翻译时依次回退到同一语言的其他地区和原文；
+// 翻译中缺少的标题、说明或选项使用原文
+func (s *QuestionnaireService) Localize(ctx context.Context, id uint, acceptLanguage string) (*LocalizedQuestionnaire, error) {
+	questionnaire, questions, err := s.Detail(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := s.Store.Questionnaires().Translations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	original := originalLocale(questionnaire)
+	result := &LocalizedQuestionnaire{
+		Questionnaire: questionnaire,
+		Questions:     questions,
+		Locale:        original,
+		Locales:       []string{original},
+	}
+	for _, t := range translations {
+		result.Locales = append(result.Locales, t.Locale)
+	}
+	result.Locale = i18n.Match(acceptLanguage, result.Locales, original)
+	if result.Locale == original {
+		return result, nil
+	}
+
+	for _, t := range translations {
+		if t.Locale != result.Locale {
+			continue
+		}
+		if t.Title != "" {
+			questionnaire.Title = t.Title
+		}
+		if t.Description != "" {
+			questionnaire.Description = t.Description
+		}
+	}
+
+	questionTranslations, err := s.Store.Questionnaires().QuestionTranslations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	byQuestion := make(map[uint]models.QuestionTranslation)
+	for _, qt := range questionTranslations {
+		if qt.Locale == result.Locale {
+			byQuestion[qt.QuestionID] = qt
+		}
+	}
+	for i, q := range questions {
+		qt, ok := byQuestion[q.ID]
+		if !ok {
+			continue
+		}
+		if qt.Title != "" {
+			questions[i].Title = qt.Title
+		}
+		if qt.Options != "" {
+			questions[i].Options = qt.Options
+		}
+	}
+	return result, nil
+}
+
+// canonicalAnswer 将翻译后的选项换算为原文选项，多选题保持JSON数组格式
+func canonicalAnswer(content string, aliases map[string]string) string {
+	values := models.AnswerValues(content)
+	changed := false
+	for i, v := range values {
+		if original, ok := aliases[v]; ok {
+			values[i] = original
+			changed = true
+		}
+	}
+	if !changed {
+		return content
+	}
+	if len(values) == 1 && !jsonArray(content) {
+		return values[0]
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return content
+	}
+	return string(data)
+}
+
+func jsonArray(content string) bool {
+	var values []string
+	return json.Unmarshal([]byte(content), &values) == nil
+}
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"questionnaire-system/backend/logging"
+	"questionnaire-system/backend/metrics"
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/pagination"
+	"questionnaire-system/backend/password"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/session"
+)
+
+// UserService 用户注册、登录及管理
+type UserService struct {
+	Store     repository.Store
+	Metrics   *metrics.Metrics // 可为nil
+	Lockout   LockoutPolicy    // 零值时不锁定账户
+	MFA       MFAPolicy
+	Passwords *password.Hasher
+	Policy    password.Policy // 注册和重置密码时检查，零值时不限制
+	Tokens    *session.Signer // 签发和校验会话令牌
+	// DisablePasswordLogin 为true时关闭密码登录、注册和重置密码，只能通过单点登录（SSOService）登录
+	DisablePasswordLogin bool
+	Now                  func() time.Time
+}
+
+// LockoutPolicy 连续登录失败后锁定账户的策略
+// 每连续失败Threshold次锁定一次，第n次锁定的时长为Cooldown*2^(n-1)，不超过MaxCooldown；
+// MaxCooldown不大于Cooldown时每次锁定的时长都是Cooldown
+type LockoutPolicy struct {
+	Threshold   int
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+}
+
+// cooldown 连续失败failures次后需要锁定的时长，不需要锁定时为0
+func (p LockoutPolicy) cooldown(failures int) time.Duration {
+	if p.Threshold <= 0 || p.Cooldown <= 0 || failures < p.Threshold || failures%p.Threshold != 0 {
+		return 0
+	}
+	d := p.Cooldown
+	for n := failures / p.Threshold; n > 1 && d < p.MaxCooldown; n-- {
+		d *= 2
+	}
+	if p.MaxCooldown > p.Cooldown && d > p.MaxCooldown {
+		d = p.MaxCooldown
+	}
+	return d
+}
+
+// DefaultTokenTTL 会话令牌的默认有效期
+const DefaultTokenTTL = 24 * time.Hour
+
+// NewUserService 创建用户服务，会话令牌使用随机密钥签名（服务重启后失效），多实例部署时应替换Tokens
+func NewUserService(store repository.Store) *UserService {
+	return &UserService{
+		Store:     store,
+		Passwords: password.Default(),
+		Tokens:    session.NewSigner(session.RandomKey(), DefaultTokenTTL),
+		MFA:       DefaultMFAPolicy(),
+		Now:       time.Now,
+	}
+}
+
+// RegisterInput 注册信息
+type RegisterInput struct {
+	Username string
+	Email    string
+	Phone    string
+	Password string
+}
+
+// UserDetail 用户详情及其问卷、答卷数量
+type UserDetail struct {
+	User               models.User `json:"user"`
+	QuestionnaireCount int64       `json:"questionnaire_count"`
+	SubmissionCount    int64       `json:"submission_count"`
+}
+
+// UserUpdate 管理员可修改的用户信息
+type UserUpdate struct {
+	ID      uint
+	Email   string
+	Phone   string
+	IsAdmin bool
+}
+
+// Register 注册普通用户，新用户的邮箱未验证（见EmailVerificationService）
+// 用户名和邮箱的唯一性由数据库的唯一索引保证，重复时返回ErrUsernameTaken或ErrEmailTaken；密码不符合策略时返回*password.PolicyError
+func (s *UserService) Register(ctx context.Context, in RegisterInput) (*models.User, error) {
+	if s.DisablePasswordLogin {
+		return nil, ErrPasswordLoginDisabled
+	}
+	users := s.Store.Users()
+
+	if err := s.Policy.Check(in.Password, in.Username); err != nil {
+		return nil, err
+	}
+
+	hashed, err := s.Passwords.Hash(in.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username: in.Username,
+		Email:    in.Email,
+		Phone:    in.Phone,
+		Password: hashed,
+	}
+	if err := users.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, s.duplicate(ctx, in.Username)
+		}
+		return nil, err
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// duplicate 违反唯一索引时判断重复的是用户名还是邮箱
+// 冲突的记录已经提交，此时再查询不存在竞争；各数据库唯一约束错误的格式不同，不解析错误信息
+func (s *UserService) duplicate(ctx context.Context, username string) error {
+	_, err := s.Store.Users().GetByUsername(ctx, username)
+	switch {
+	case err == nil:
+		return ErrUsernameTaken
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrEmailTaken
+	default:
+		return err
+	}
+}
+
+// LoginResult 登录结果
+// 用户启用了两步验证时Token为空，Challenge为登录挑战令牌，需在ChallengeExpiresAt之前调用CompleteLogin提交验证码
+type LoginResult struct {
+	User               *models.User
+	Token              string
+	Challenge          string
+	ChallengeExpiresAt time.Time
+}
+
+// Login 校验用户名和密码，返回用户及会话令牌；用户启用了两步验证时返回登录挑战
+// 账户锁定期间不校验密码，直接返回*LockedError；密码错误的次数达到锁定阈值时同样返回*LockedError。
+// 密码哈希由旧算法（如MD5）或旧参数生成时，登录成功后使用当前算法重新哈希
+func (s *UserService) Login(ctx context.Context, username, password string) (*LoginResult, error) {
+	if s.DisablePasswordLogin {
+		return nil, ErrPasswordLoginDisabled
+	}
+	users := s.Store.Users()
+
+	user, err := users.GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		s.Metrics.LoginFailed(metrics.ReasonInvalidCredentials)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkLocked(user); err != nil {
+		return nil, err
+	}
+
+	// 单点登录开通的用户没有密码，不能用密码登录
+	var ok, rehash bool
+	if user.Password != "" {
+		ok, rehash, err = s.Passwords.Verify(user.Password, password)
+		if err != nil {
+			// 无法识别的哈希按密码错误处理，不影响其他账户
+			logging.FromContext(ctx).Error("校验密码失败", "user_id", user.ID, "error", err)
+		}
+	}
+	if !ok {
+		s.Metrics.LoginFailed(metrics.ReasonInvalidCredentials)
+		return nil, s.loginFailed(ctx, user)
+	}
+	if rehash {
+		s.upgradeHash(ctx, user, password)
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := users.ResetLoginFailures(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		user.FailedLogins, user.LockedUntil = 0, nil
+	}
+	return s.startSession(ctx, user)
+}
+
+// startSession 身份校验通过后签发会话令牌，用户启用了两步验证时改为返回登录挑战
+func (s *UserService) startSession(ctx context.Context, user *models.User) (*LoginResult, error) {
+	user.Password = ""
+	if user.TOTPEnabledAt != nil {
+		return s.challenge(ctx, user)
+	}
+	return &LoginResult{User: user, Token: s.issueToken(user)}, nil
+}
+
+// checkLocked 账户锁定期间返回*LockedError
+func (s *UserService) checkLocked(user *models.User) error {
+	if user.LockedUntil != nil && s.Now().Before(*user.LockedUntil) {
+		s.Metrics.LoginFailed(metrics.ReasonAccountLocked)
+		return &LockedError{Until: *user.LockedUntil}
+	}
+	return nil
+}
+
+// challenge 密码或单点登录校验通过后创建登录挑战，等待用户提交验证码
+func (s *UserService) challenge(ctx context.Context, user *models.User) (*LoginResult, error) {
+	token, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	record := &models.MFAChallenge{
+		UserID:    user.ID,
+		TokenHash: hashOneTimeToken(token),
+		ExpiresAt: s.Now().Add(s.MFA.ChallengeTTL),
+	}
+	if err := s.Store.MFA().CreateChallenge(ctx, record); err != nil {
+		return nil, err
+	}
+	return &LoginResult{User: user, Challenge: token, ChallengeExpiresAt: record.ExpiresAt}, nil
+}
+
+// CompleteLogin 使用登录挑战令牌和验证码（或恢复码）完成登录，返回用户及会话令牌
+// 令牌无效、已过期、已使用或输错验证码的次数达到MFA.MaxFailures时返回ErrInvalidMFAChallenge，需重新输入密码；
+// 验证码错误时返回ErrInvalidMFACode
+func (s *UserService) CompleteLogin(ctx context.Context, token, code string) (*models.User, string, error) {
+	now := s.Now()
+	record, err := s.Store.MFA().GetChallenge(ctx, hashOneTimeToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if record.UsedAt != nil || !now.Before(record.ExpiresAt) || record.Failures >= s.MFA.MaxFailures {
+		return nil, "", ErrInvalidMFAChallenge
+	}
+
+	user, err := s.Store.Users().Get(ctx, record.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if user.TOTPEnabledAt == nil {
+		// 输入密码之后关闭了两步验证
+		return nil, "", ErrInvalidMFAChallenge
+	}
+	if err := s.checkLocked(user); err != nil {
+		return nil, "", err
+	}
+
+	if err := checkSecondFactor(ctx, s.Store, user, code, now); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.Metrics.LoginFailed(metrics.ReasonInvalidMFACode)
+			failures, ferr := s.Store.MFA().RecordChallengeFailure(ctx, record.ID)
+			if ferr != nil {
+				return nil, "", ferr
+			}
+			logging.FromContext(ctx).Warn("两步验证的验证码错误", "user_id", user.ID, "failures", failures)
+		}
+		return nil, "", err
+	}
+	// 并发提交时只有一个请求能完成登录
+	if err := s.Store.MFA().MarkChallengeUsed(ctx, record.ID, now); errors.Is(err, repository.ErrNotFound) {
+		return nil, "", ErrInvalidMFAChallenge
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	user.Password = ""
+	return user, s.issueToken(user), nil
+}
+
+// upgradeHash 使用当前算法重新哈希密码，失败时只记录日志，不影响本次登录
+func (s *UserService) upgradeHash(ctx context.Context, user *models.User, plain string) {
+	log := logging.FromContext(ctx)
+	hashed, err := s.Passwords.Hash(plain)
+	if err == nil {
+		err = s.Store.Users().SetPassword(ctx, user.ID, hashed)
+	}
+	if err != nil {
+		log.Error("升级密码哈希失败", "user_id", user.ID, "error", err)
+		return
+	}
+	log.Info("已升级密码哈希", "user_id", user.ID, "from", password.Identify(user.Password), "to", s.Passwords.Algorithm)
+}
+
+// loginFailed 记录一次密码错误，连续失败次数达到阈值时锁定账户并返回*LockedError，否则返回ErrInvalidCredentials
+func (s *UserService) loginFailed(ctx context.Context, user *models.User) error {
+	users := s.Store.Users()
+
+	failures, err := users.RecordLoginFailure(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	cooldown := s.Lockout.cooldown(failures)
+	if cooldown == 0 {
+		return ErrInvalidCredentials
+	}
+
+	until := s.Now().Add(cooldown)
+	if err := users.Lock(ctx, user.ID, until); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Warn("连续登录失败，账户已锁定",
+		"user_id", user.ID, "username", user.Username, "failures", failures, "locked_until", until)
+	return &LockedError{Until: until}
+}
+
+// Unlock 解除账户锁定并清零连续登录失败次数
+func (s *UserService) Unlock(ctx context.Context, id uint) error {
+	users := s.Store.Users()
+
+	if _, err := users.Get(ctx, id); errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	return users.ResetLoginFailures(ctx, id)
+}
+
+// issueToken 签发会话令牌（见session包）
+func (s *UserService) issueToken(user *models.User) string {
+	token, _ := s.Tokens.Issue(user.ID, s.Now())
+	return token
+}
+
+// Authenticate 校验会话令牌，返回令牌对应的用户（不含密码）
+// 签名不正确或格式错误时返回ErrInvalidToken，已过期时返回ErrSessionExpired，用户不存在时返回ErrUserNotFound，
+// 令牌在用户重置密码或启用两步验证之前签发时返回ErrSessionRevoked
+func (s *UserService) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	return s.authenticate(ctx, s.Tokens, token)
+}
+
+// StreamTokenTTL 实时结果令牌的有效期；令牌只在建立连接时校验，连接断开后客户端需重新申请
+const StreamTokenTTL = 5 * time.Minute
+
+// streamTokens 签发和校验问卷questionnaireID的实时结果令牌
+func (s *UserService) streamTokens(questionnaireID uint) *session.Signer {
+	return s.Tokens.Scoped("results-stream:"+strconv.FormatUint(uint64(questionnaireID), 10), StreamTokenTTL)
+}
+
+// IssueStreamToken 为用户签发只能订阅问卷questionnaireID实时结果的短期令牌，返回令牌及过期时间
+// 浏览器的EventSource不能设置Authorization头，令牌放在查询参数中；调用方需先校验用户查看结果的权限
+func (s *UserService) IssueStreamToken(userID, questionnaireID uint) (string, time.Time) {
+	token, claims := s.streamTokens(questionnaireID).Issue(userID, s.Now())
+	return token, claims.ExpiresAt
+}
+
+// AuthenticateStream 校验问卷questionnaireID的实时结果令牌，返回签发令牌的用户，错误与Authenticate相同
+// 其他问卷的令牌和会话令牌都返回ErrInvalidToken
+func (s *UserService) AuthenticateStream(ctx context.Context, token string, questionnaireID uint) (*models.User, error) {
+	return s.authenticate(ctx, s.streamTokens(questionnaireID), token)
+}
+
+// authenticate 使用signer校验令牌并加载用户（见Authenticate）
+func (s *UserService) authenticate(ctx context.Context, signer *session.Signer, token string) (*models.User, error) {
+	claims, err := signer.Parse(token, s.Now())
+	switch {
+	case errors.Is(err, session.ErrExpired):
+		return nil, ErrSessionExpired
+	case err != nil:
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.Get(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.SessionsRevokedAt != nil && claims.IssuedAt.Before(*user.SessionsRevokedAt) {
+		return nil, ErrSessionRevoked
+	}
+	return user, nil
+}
+
+// EnsureAdmin 创建管理员账号（邮箱视为已验证），已存在时重置其密码、解除锁定、关闭两步验证并确保具有管理员权限
+func (s *UserService) EnsureAdmin(ctx context.Context, username, email, pass string) (created bool, err error) {
+	users := s.Store.Users()
+
+	hashed, err := s.Passwords.Hash(pass)
+	if err != nil {
+		return false, err
+	}
+
+	user, err := users.GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		now := s.Now()
+		user = &models.User{Username: username, Email: email, Password: hashed, IsAdmin: true, EmailVerifiedAt: &now}
+		return true, users.Create(ctx, user)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	user.Password = hashed
+	user.IsAdmin = true
+	user.FailedLogins = 0
+	user.LockedUntil = nil
+	// 管理员丢失了验证器和恢复码时由此恢复，需要时重新绑定
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastCounter = 0
+	return false, s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.MFA().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return tx.Users().Save(ctx, user)
+	})
+}
+
+// Get 获取用户（不含密码）
+func (s *UserService) Get(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.Store.Users().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// GetByUsername 按用户名获取用户（不含密码）
+func (s *UserService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	user, err := s.Store.Users().GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// List 分页获取用户列表，按需统计总数
+func (s *UserService) List(ctx context.Context, params pagination.Params) ([]models.User, pagination.Page, error) {
+	users, err := s.Store.Users().List(ctx, repository.PageFor(params))
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	users, page := pagination.Trim(users, params, func(u models.User) uint { return u.ID })
+
+	if params.WithTotal() {
+		total, err := s.Store.Users().Count(ctx, false)
+		if err != nil {
+			return nil, pagination.Page{}, err
+		}
+		page.Total = &total
+	}
+	return users, page, nil
+}
+
+// Detail 获取用户详情及其创建的问卷数、提交的答卷数
+func (s *UserService) Detail(ctx context.Context, id uint) (*UserDetail, error) {
+	user, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	questionnaireCount, err := s.Store.Questionnaires().Count(ctx, repository.QuestionnaireFilter{CreatedBy: id})
+	if err != nil {
+		return nil, err
+	}
+	submissionCount, err := s.Store.Submissions().Count(ctx, repository.SubmissionFilter{UserID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDetail{
+		User:               *user,
+		QuestionnaireCount: questionnaireCount,
+		SubmissionCount:    submissionCount,
+	}, nil
+}
+
+// Update 更新用户的邮箱、手机号和管理员权限
+func (s *UserService) Update(ctx context.Context, in UserUpdate) error {
+	users := s.Store.Users()
+
+	user, err := users.Get(ctx, in.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.Email != in.Email {
+		// 新邮箱需要重新验证
+		user.EmailVerifiedAt = nil
+	}
+	user.Email = in.Email
+	user.Phone = in.Phone
+	user.IsAdmin = in.IsAdmin
+	if err := users.Save(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete 删除普通用户及其创建的问卷、提交的答卷、重置密码令牌、邮箱验证令牌和两步验证的恢复码
+func (s *UserService) Delete(ctx context.Context, id uint) error {
+	user, err := s.Store.Users().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin {
+		return ErrCannotDeleteAdmin
+	}
+
+	return s.Store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Questionnaires().DeleteByCreator(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Submissions().DeleteAnswersByUser(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Submissions().DeleteByUser(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.PasswordResets().DeleteByUser(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.EmailVerifications().DeleteByUser(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.MFA().DeleteByUser(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.SSO().DeleteByUser(ctx, id); err != nil {
+			return err
+		}
+		return tx.Users().Delete(ctx, id)
+	})
+}
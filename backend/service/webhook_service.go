@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"questionnaire-system/backend/models"
+	"questionnaire-system/backend/pagination"
+	"questionnaire-system/backend/repository"
+	"questionnaire-system/backend/webhook"
+)
+
+// WebhookService Webhook订阅的管理和投递记录
+// 全局订阅仅管理员可以管理，问卷订阅为问卷创建者或管理员
+type WebhookService struct {
+	Store repository.Store
+	Now   func() time.Time
+}
+
+// NewWebhookService 创建Webhook服务
+func NewWebhookService(store repository.Store) *WebhookService {
+	return &WebhookService{Store: store, Now: time.Now}
+}
+
+// WebhookInput 创建订阅的内容，目标地址和事件类型由调用方校验
+type WebhookInput struct {
+	QuestionnaireID uint // 为0时创建全局订阅
+	TargetURL       string
+	Secret          string // 为空时随机生成
+	Events          []string
+}
+
+// WebhookDelivery 发件箱记录及每次投递尝试的结果
+type WebhookDelivery struct {
+	Delivery models.WebhookOutbox            `json:"delivery"`
+	Attempts []models.WebhookDeliveryAttempt `json:"attempts"`
+}
+
+// authorize 检查用户是否可以管理问卷（nil为全局）的订阅，问卷不存在时同样返回ErrWebhookForbidden
+func (s *WebhookService) authorize(ctx context.Context, questionnaireID *uint, userID uint, isAdmin bool) error {
+	if isAdmin {
+		return nil
+	}
+	if questionnaireID != nil {
+		questionnaire, err := s.Store.Questionnaires().Get(ctx, *questionnaireID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err == nil && questionnaire.CreatedBy == userID {
+			return nil
+		}
+	}
+	return ErrWebhookForbidden
+}
+
+// subscription 获取订阅并检查管理权限，不存在时返回ErrWebhookNotFound
+func (s *WebhookService) subscription(ctx context.Context, id, userID uint, isAdmin bool) (*models.WebhookSubscription, error) {
+	subscription, err := s.Store.Webhooks().GetSubscription(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, subscription.QuestionnaireID, userID, isAdmin); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Create 创建订阅，返回订阅及签名密钥（密钥只在创建时返回一次）
+func (s *WebhookService) Create(ctx context.Context, input WebhookInput, userID uint, isAdmin bool) (*models.WebhookSubscription, string, error) {
+	var questionnaireID *uint
+	if input.QuestionnaireID > 0 {
+		if _, err := getQuestionnaire(ctx, s.Store, input.QuestionnaireID); err != nil {
+			return nil, "", err
+		}
+		questionnaireID = &input.QuestionnaireID
+	}
+	if err := s.authorize(ctx, questionnaireID, userID, isAdmin); err != nil {
+		return nil, "", err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.GenerateSecret(); err != nil {
+			return nil, "", fmt.Errorf("生成Webhook密钥失败: %w", err)
+		}
+	}
+
+	subscription := &models.WebhookSubscription{
+		QuestionnaireID: questionnaireID,
+		TargetURL:       input.TargetURL,
+		Secret:          secret,
+		Events:          strings.Join(input.Events, ","),
+		IsActive:        true,
+		CreatedBy:       userID,
+	}
+	if err := s.Store.Webhooks().CreateSubscription(ctx, subscription); err != nil {
+		return nil, "", err
+	}
+	return subscription, secret, nil
+}
+
+// List 分页获取问卷的订阅，questionnaireID为nil时获取全局订阅
+func (s *WebhookService) List(ctx context.Context, questionnaireID *uint, userID uint, isAdmin bool, params pagination.Params) ([]models.WebhookSubscription, pagination.Page, error) {
+	if err := s.authorize(ctx, questionnaireID, userID, isAdmin); err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	subscriptions, err := s.Store.Webhooks().ListSubscriptions(ctx, questionnaireID, repository.PageFor(params))
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	subscriptions, page := pagination.Trim(subscriptions, params, func(s models.WebhookSubscription) uint { return s.ID })
+
+	if params.WithTotal() {
+		total, err := s.Store.Webhooks().CountSubscriptions(ctx, questionnaireID)
+		if err != nil {
+			return nil, pagination.Page{}, err
+		}
+		page.Total = &total
+	}
+	return subscriptions, page, nil
+}
+
+// Deactivate 停用订阅而不是物理删除，保留投递记录
+func (s *WebhookService) Deactivate(ctx context.Context, id, userID uint, isAdmin bool) error {
+	if _, err := s.subscription(ctx, id, userID, isAdmin); err != nil {
+		return err
+	}
+	return s.Store.Webhooks().DeactivateSubscription(ctx, id)
+}
+
+// Deliveries 分页获取订阅的投递记录及每次尝试的结果
+func (s *WebhookService) Deliveries(ctx context.Context, subscriptionID, userID uint, isAdmin bool, params pagination.Params) ([]WebhookDelivery, pagination.Page, error) {
+	if _, err := s.subscription(ctx, subscriptionID, userID, isAdmin); err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	deliveries, err := s.Store.Webhooks().ListDeliveries(ctx, subscriptionID, repository.PageFor(params))
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	deliveries, page := pagination.Trim(deliveries, params, func(d models.WebhookOutbox) uint { return d.ID })
+
+	if params.WithTotal() {
+		total, err := s.Store.Webhooks().CountDeliveries(ctx, subscriptionID)
+		if err != nil {
+			return nil, pagination.Page{}, err
+		}
+		page.Total = &total
+	}
+
+	// 批量查询尝试记录
+	outboxIDs := make([]uint, 0, len(deliveries))
+	for _, d := range deliveries {
+		outboxIDs = append(outboxIDs, d.ID)
+	}
+	attempts, err := s.Store.Webhooks().Attempts(ctx, outboxIDs)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	attemptsByOutbox := make(map[uint][]models.WebhookDeliveryAttempt)
+	for _, a := range attempts {
+		attemptsByOutbox[a.OutboxID] = append(attemptsByOutbox[a.OutboxID], a)
+	}
+
+	var result []WebhookDelivery
+	for _, d := range deliveries {
+		result = append(result, WebhookDelivery{Delivery: d, Attempts: attemptsByOutbox[d.ID]})
+	}
+	return result, page, nil
+}
+
+// Redeliver 重置发件箱记录的尝试次数并立即排队，正在投递中时返回ErrDeliveryInProgress
+func (s *WebhookService) Redeliver(ctx context.Context, outboxID, userID uint, isAdmin bool) error {
+	delivery, err := s.Store.Webhooks().GetDelivery(ctx, outboxID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrDeliveryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.subscription(ctx, delivery.SubscriptionID, userID, isAdmin); err != nil {
+		return err
+	}
+
+	err = s.Store.Webhooks().Redeliver(ctx, outboxID, s.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrDeliveryInProgress
+	}
+	return err
+}
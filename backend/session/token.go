@@ -0,0 +1,126 @@
+// Package session 会话令牌
+//
+// 令牌的格式为 v1.<用户ID>.<签发时间>.<过期时间>.<签名>，时间为Unix毫秒，签名为前四段的HMAC-SHA256（base64url编码）。
+// 服务端不保存令牌：签名保证令牌不能伪造或修改，过期时间之后令牌失效；
+// 用户重置密码或启用两步验证后，签发时间早于User.SessionsRevokedAt的令牌失效（见service.UserService.Authenticate）。
+// 所有函数都显式接收当前时间，不读取系统时钟。
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version 令牌格式的版本，修改格式或签名算法时递增
+const version = "v1"
+
+// KeySize 随机生成的签名密钥字节数
+const KeySize = 32
+
+var (
+	// ErrInvalid 令牌格式错误或签名不正确
+	ErrInvalid = errors.New("无效的会话令牌")
+	// ErrExpired 令牌已过期
+	ErrExpired = errors.New("会话令牌已过期")
+)
+
+// Claims 令牌中的内容
+type Claims struct {
+	UserID    uint
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Signer 签发和校验会话令牌，多个实例需使用相同的密钥
+type Signer struct {
+	key []byte
+	TTL time.Duration // 令牌的有效期
+}
+
+// NewSigner 使用密钥secret创建Signer，令牌的有效期为ttl
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	return &Signer{key: secret, TTL: ttl}
+}
+
+// Scoped 返回使用派生密钥、有效期为ttl的Signer，用于只能在特定场合使用的令牌（如某个问卷的实时结果）；
+// 不同scope的令牌与会话令牌之间不能互相通过校验
+func (s *Signer) Scoped(scope string, ttl time.Duration) *Signer {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("scope:" + scope))
+	return &Signer{key: mac.Sum(nil), TTL: ttl}
+}
+
+// RandomKey 生成随机的签名密钥；未配置密钥时使用，服务重启后之前签发的令牌全部失效
+func RandomKey() []byte {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Issue 为用户签发令牌，签发时间为now（精确到毫秒）
+func (s *Signer) Issue(userID uint, now time.Time) (string, Claims) {
+	claims := Claims{
+		UserID:    userID,
+		IssuedAt:  now.Truncate(time.Millisecond),
+		ExpiresAt: now.Add(s.TTL).Truncate(time.Millisecond),
+	}
+	payload := version + "." + strconv.FormatUint(uint64(userID), 10) +
+		"." + strconv.FormatInt(claims.IssuedAt.UnixMilli(), 10) +
+		"." + strconv.FormatInt(claims.ExpiresAt.UnixMilli(), 10)
+	return payload + "." + s.sign(payload), claims
+}
+
+// Parse 校验令牌的签名和有效期，返回令牌中的内容
+// 格式错误或签名不正确时返回ErrInvalid，已过期时返回ErrExpired
+func (s *Signer) Parse(token string, now time.Time) (*Claims, error) {
+	sep := strings.LastIndexByte(token, '.')
+	if sep < 0 {
+		return nil, ErrInvalid
+	}
+	payload, signature := token[:sep], token[sep+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return nil, ErrInvalid
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 4 || parts[0] != version {
+		return nil, ErrInvalid
+	}
+	userID, err := strconv.ParseUint(parts[1], 10, 0)
+	if err != nil || userID == 0 {
+		return nil, ErrInvalid
+	}
+	issuedAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	expiresAt, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	claims := &Claims{
+		UserID:    uint(userID),
+		IssuedAt:  time.UnixMilli(issuedAt),
+		ExpiresAt: time.UnixMilli(expiresAt),
+	}
+	if !now.Before(claims.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return claims, nil
+}
+
+// sign 计算payload的签名
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
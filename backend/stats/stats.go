@@ -0,0 +1,193 @@
+package stats
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"questionnaire-system/backend/models"
+)
+
+// OptionCount 选项计数
+type OptionCount struct {
+	OptionID int     `json:"option_id,omitempty"` // 选项在问题中的位置（从1开始），未声明的答案为0
+	Option   string  `json:"option"`
+	Count    int64   `json:"count"`
+	Ratio    float64 `json:"ratio"` // 占作答人数的比例
+}
+
+// TextAnswer 填空题答案及出现次数
+type TextAnswer struct {
+	Content string `json:"content"`
+	Count   int64  `json:"count"`
+}
+
+// QuestionStats 单个问题的汇总统计
+type QuestionStats struct {
+	QuestionID  uint          `json:"question_id"`
+	Title       string        `json:"title"`
+	Type        string        `json:"type"`
+	Required    bool          `json:"required"`
+	Answered    int64         `json:"answered"` // 作答人数
+	Skipped     int64         `json:"skipped"`  // 未作答人数
+	Options     []OptionCount `json:"options,omitempty"`
+	Average     *float64      `json:"average,omitempty"` // 评分题平均分
+	Min         *float64      `json:"min,omitempty"`
+	Max         *float64      `json:"max,omitempty"`
+	TextAnswers []TextAnswer  `json:"text_answers,omitempty"`
+}
+
+// Summary 问卷汇总统计
+type Summary struct {
+	Questionnaire    models.Questionnaire `json:"questionnaire"`
+	TotalSubmissions int64                `json:"total_submissions"`
+	Questions        []QuestionStats      `json:"questions"`
+	GeneratedAt      time.Time            `json:"generated_at"`
+}
+
+// ContentCount 问题的一种答案内容及其作答次数
+type ContentCount struct {
+	QuestionID uint
+	Content    string
+	Total      int64
+}
+
+// Summarize 根据按答案内容分组的计数计算问卷的汇总统计
+// counts只应包含已提交用户的答案，与问卷结果接口保持一致；选择题的译文答案按选项ID合并到原文选项
+func Summarize(questionnaire models.Questionnaire, questions []models.Question, translations []models.QuestionTranslation,
+	totalSubmissions int64, counts []ContentCount, generatedAt time.Time) *Summary {
+	summary := &Summary{
+		Questionnaire:    questionnaire,
+		TotalSubmissions: totalSubmissions,
+		GeneratedAt:      generatedAt,
+	}
+
+	aliases := models.OptionAliases(questions, translations)
+	byQuestion := make(map[uint][]ContentCount)
+	for _, cc := range counts {
+		byQuestion[cc.QuestionID] = append(byQuestion[cc.QuestionID], cc)
+	}
+
+	for _, q := range questions {
+		summary.Questions = append(summary.Questions, summarizeQuestion(q, byQuestion[q.ID], aliases[q.ID], totalSubmissions))
+	}
+
+	return summary
+}
+
+// summarizeQuestion 根据题型汇总单个问题的分组计数，aliases为译文选项到原文选项的对应关系
+func summarizeQuestion(q models.Question, counts []ContentCount, aliases map[string]string, totalSubmissions int64) QuestionStats {
+	qs := QuestionStats{
+		QuestionID: q.ID,
+		Title:      q.Title,
+		Type:       q.Type,
+		Required:   q.Required,
+	}
+
+	valueCounts := make(map[string]int64)
+	var order []string
+	for _, cc := range counts {
+		values := models.AnswerValues(cc.Content)
+		if len(values) == 0 {
+			continue
+		}
+		qs.Answered += cc.Total
+		for _, v := range values {
+			if original, ok := aliases[v]; ok {
+				v = original
+			}
+			if _, ok := valueCounts[v]; !ok {
+				order = append(order, v)
+			}
+			valueCounts[v] += cc.Total
+		}
+	}
+
+	qs.Skipped = totalSubmissions - qs.Answered
+	if qs.Skipped < 0 {
+		qs.Skipped = 0
+	}
+
+	switch {
+	case q.IsChoice():
+		// 先按问卷中声明的顺序输出选项，再追加未声明的答案
+		declared := q.OptionList()
+		seen := make(map[string]bool)
+		for i, opt := range declared {
+			seen[opt] = true
+			oc := optionCount(opt, valueCounts[opt], qs.Answered)
+			oc.OptionID = i + 1
+			qs.Options = append(qs.Options, oc)
+		}
+		for _, v := range order {
+			if !seen[v] {
+				qs.Options = append(qs.Options, optionCount(v, valueCounts[v], qs.Answered))
+			}
+		}
+
+	case q.Type == models.QuestionTypeRating:
+		sort.SliceStable(order, func(i, j int) bool {
+			a, errA := strconv.ParseFloat(order[i], 64)
+			b, errB := strconv.ParseFloat(order[j], 64)
+			if errA != nil || errB != nil {
+				return order[i] < order[j]
+			}
+			return a < b
+		})
+
+		var sum float64
+		var scored int64
+		for _, v := range order {
+			qs.Options = append(qs.Options, optionCount(v, valueCounts[v], qs.Answered))
+
+			score, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			sum += score * float64(valueCounts[v])
+			scored += valueCounts[v]
+			if qs.Min == nil || score < *qs.Min {
+				qs.Min = floatPtr(score)
+			}
+			if qs.Max == nil || score > *qs.Max {
+				qs.Max = floatPtr(score)
+			}
+		}
+		if scored > 0 {
+			qs.Average = floatPtr(sum / float64(scored))
+		}
+
+	default:
+		for _, v := range order {
+			qs.TextAnswers = append(qs.TextAnswers, TextAnswer{Content: v, Count: valueCounts[v]})
+		}
+		sort.SliceStable(qs.TextAnswers, func(i, j int) bool {
+			if qs.TextAnswers[i].Count != qs.TextAnswers[j].Count {
+				return qs.TextAnswers[i].Count > qs.TextAnswers[j].Count
+			}
+			return qs.TextAnswers[i].Content < qs.TextAnswers[j].Content
+		})
+	}
+
+	return qs
+}
+
+// TopTextAnswers 返回出现次数最多的前n个填空题答案
+func (qs QuestionStats) TopTextAnswers(n int) []TextAnswer {
+	if n <= 0 || len(qs.TextAnswers) <= n {
+		return qs.TextAnswers
+	}
+	return qs.TextAnswers[:n]
+}
+
+func optionCount(option string, count, answered int64) OptionCount {
+	oc := OptionCount{Option: option, Count: count}
+	if answered > 0 {
+		oc.Ratio = float64(count) / float64(answered)
+	}
+	return oc
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
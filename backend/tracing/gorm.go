@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey 保存在gorm.Statement中的span
+const spanKey = "tracing:span"
+
+// GormPlugin 为每次数据库操作创建子span的GORM插件
+// 查询必须通过WithContext传入请求的ctx才能挂在请求的span下；span只记录带占位符的SQL，不记录参数
+type GormPlugin struct {
+	tracer trace.Tracer
+}
+
+// NewGormPlugin 创建GORM插件，provider为nil时使用全局的TracerProvider
+func NewGormPlugin(provider trace.TracerProvider) *GormPlugin {
+	return &GormPlugin{tracer: Tracer(provider)}
+}
+
+// Name 实现gorm.Plugin
+func (p *GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize 实现gorm.Plugin，在每种操作的前后注册回调
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", p.before("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", p.before("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", p.after),
+	)
+}
+
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		if ctx == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			// 不在请求中的查询（如迁移、后台任务）不单独创建根span
+			return
+		}
+		_, span := p.tracer.Start(ctx, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", tx.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+			))
+		tx.InstanceSet(spanKey, span)
+	}
+}
+
+func (p *GormPlugin) after(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	defer span.End()
+
+	if table := tx.Statement.Table; table != "" {
+		span.SetAttributes(attribute.String("db.collection.name", table))
+	}
+	span.SetAttributes(
+		attribute.String("db.query.text", tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if err := tx.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
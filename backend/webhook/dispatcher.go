@@ -0,0 +1,274 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"questionnaire-system/backend/health"
+	"questionnaire-system/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// maxResponseBody 记录的响应体最大长度
+const maxResponseBody = 1024
+
+// Dispatcher 从发件箱读取到期的事件并投递，失败时按指数退避重试
+type Dispatcher struct {
+	DB           *gorm.DB
+	Client       *http.Client
+	MaxAttempts  int           // 最大尝试次数，超过后标记为失败
+	BaseBackoff  time.Duration // 第一次重试的等待时间
+	MaxBackoff   time.Duration // 重试等待时间上限
+	PollInterval time.Duration // 轮询发件箱的间隔
+	BatchSize    int           // 每次处理的最大记录数
+	LockTimeout  time.Duration // 处理中记录的锁定时间，超时后可被重新领取
+
+	// Now 返回当前时间，测试时可替换
+	Now func() time.Time
+
+	mu       sync.Mutex
+	lastPoll time.Time // 最近一次处理发件箱的时间
+	lastErr  error     // 最近一次处理发件箱的错误
+}
+
+// NewDispatcher 使用默认参数创建投递器，allowPrivate为true时允许投递到本机和内网地址（见NewClient）
+func NewDispatcher(db *gorm.DB, allowPrivate bool) *Dispatcher {
+	return &Dispatcher{
+		DB:           db,
+		Client:       NewClient(10*time.Second, allowPrivate),
+		MaxAttempts:  8,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+		LockTimeout:  time.Minute,
+		Now:          time.Now,
+	}
+}
+
+// Run 循环处理发件箱，直到ctx被取消
+func (d *Dispatcher) Run(ctx context.Context) {
+	slog.Info("Webhook投递器已启动")
+
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.ProcessDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("处理Webhook发件箱失败", "error", err)
+		}
+		d.mu.Lock()
+		d.lastPoll, d.lastErr = d.Now(), err
+		d.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Webhook投递器已停止")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Probe 就绪检查项（非关键依赖）：投递器长时间没有轮询或最近一次轮询失败时异常，
+// 附带待投递的事件数
+func (d *Dispatcher) Probe() health.Probe {
+	return health.Probe{
+		Name: "webhook_dispatcher",
+		Check: func(ctx context.Context) (interface{}, error) {
+			d.mu.Lock()
+			lastPoll, lastErr := d.lastPoll, d.lastErr
+			d.mu.Unlock()
+
+			pending, err := d.Pending(ctx)
+			if err != nil {
+				return nil, err
+			}
+			details := map[string]interface{}{"pending": pending}
+			if lastPoll.IsZero() {
+				return details, errors.New("投递器未运行")
+			}
+			details["last_poll_at"] = lastPoll
+			if d.Now().Sub(lastPoll) > 3*d.PollInterval {
+				return details, fmt.Errorf("投递器超过%s没有轮询", 3*d.PollInterval)
+			}
+			return details, lastErr
+		},
+	}
+}
+
+// Pending 发件箱中等待投递的事件数（包括等待重试的事件）
+func (d *Dispatcher) Pending(ctx context.Context) (int64, error) {
+	var pending int64
+	err := d.DB.WithContext(ctx).Model(&models.WebhookOutbox{}).
+		Where("status = ?", models.WebhookStatusPending).Count(&pending).Error
+	return pending, err
+}
+
+// ProcessDue 投递所有到期的事件，返回处理的记录数
+func (d *Dispatcher) ProcessDue(ctx context.Context) (int, error) {
+	now := d.Now()
+
+	var due []models.WebhookOutbox
+	err := d.DB.WithContext(ctx).
+		Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+			models.WebhookStatusPending, now, models.WebhookStatusProcessing, now).
+		Order("next_attempt_at, id").
+		Limit(d.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, entry := range due {
+		if ctx.Err() != nil {
+			break
+		}
+
+		claimed, err := d.claim(ctx, entry)
+		if err != nil {
+			return processed, err
+		}
+		if !claimed {
+			// 已被其他实例领取
+			continue
+		}
+
+		if err := d.deliver(ctx, entry); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// claim 通过条件更新领取记录，避免多个实例重复投递
+func (d *Dispatcher) claim(ctx context.Context, entry models.WebhookOutbox) (bool, error) {
+	now := d.Now()
+
+	query := d.DB.WithContext(ctx).Model(&models.WebhookOutbox{}).Where("id = ?", entry.ID)
+	if entry.Status == models.WebhookStatusPending {
+		query = query.Where("status = ? AND next_attempt_at <= ?", models.WebhookStatusPending, now)
+	} else {
+		query = query.Where("status = ? AND locked_until < ?", models.WebhookStatusProcessing, now)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"status":       models.WebhookStatusProcessing,
+		"locked_until": now.Add(d.LockTimeout),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// deliver 发送一次请求，记录尝试结果并更新发件箱状态
+func (d *Dispatcher) deliver(ctx context.Context, entry models.WebhookOutbox) error {
+	var sub models.WebhookSubscription
+	if err := d.DB.WithContext(ctx).First(&sub, entry.SubscriptionID).Error; err != nil {
+		return d.finish(ctx, entry, models.WebhookDeliveryAttempt{Error: "订阅不存在"}, false)
+	}
+	if !sub.IsActive {
+		// 订阅停用后不再投递已排队的事件
+		return d.finish(ctx, entry, models.WebhookDeliveryAttempt{Error: "订阅已停用"}, false)
+	}
+
+	attempt := models.WebhookDeliveryAttempt{OutboxID: entry.ID, Attempt: entry.Attempts + 1}
+	start := time.Now()
+
+	body := []byte(entry.Payload)
+	timestamp := d.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.TargetURL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return d.finish(ctx, entry, attempt, false)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "questionnaire-system-webhook/1.0")
+	req.Header.Set(HeaderEvent, entry.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(entry.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		// 目标地址指向内网时重试也不会成功
+		return d.finish(ctx, entry, attempt, !errors.Is(err, ErrForbiddenTarget))
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseBody = string(respBody)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return d.finish(ctx, entry, attempt, true)
+	}
+
+	return d.finish(ctx, entry, attempt, false)
+}
+
+// finish 记录尝试并更新状态；retryable为true且未超过最大次数时安排重试
+func (d *Dispatcher) finish(ctx context.Context, entry models.WebhookOutbox, attempt models.WebhookDeliveryAttempt, retryable bool) error {
+	attempt.OutboxID = entry.ID
+	attempt.Attempt = entry.Attempts + 1
+	now := d.Now()
+
+	updates := map[string]interface{}{
+		"attempts":     attempt.Attempt,
+		"locked_until": nil,
+		"last_error":   attempt.Error,
+	}
+
+	switch {
+	case attempt.Error == "":
+		updates["status"] = models.WebhookStatusDelivered
+		updates["delivered_at"] = now
+		slog.Info("Webhook投递成功", "outbox_id", entry.ID, "event", entry.EventType, "status", attempt.StatusCode)
+	case retryable && attempt.Attempt < d.MaxAttempts:
+		next := now.Add(d.Backoff(attempt.Attempt))
+		updates["status"] = models.WebhookStatusPending
+		updates["next_attempt_at"] = next
+		slog.Warn("Webhook投递失败，稍后重试", "outbox_id", entry.ID, "attempt", attempt.Attempt, "next_attempt_at", next, "error", attempt.Error)
+	default:
+		updates["status"] = models.WebhookStatusFailed
+		slog.Error("Webhook投递失败，不再重试", "outbox_id", entry.ID, "attempt", attempt.Attempt, "error", attempt.Error)
+	}
+
+	// 停机时请求可能被取消，但投递结果仍需写入
+	return d.DB.WithContext(context.WithoutCancel(ctx)).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WebhookOutbox{}).Where("id = ?", entry.ID).Updates(updates).Error
+	})
+}
+
+// Backoff 第n次失败后的等待时间：BaseBackoff * 2^(n-1)，不超过MaxBackoff
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget 目标地址指向本机、内网或云平台元数据服务
+var ErrForbiddenTarget = errors.New("不允许投递到本机、内网或元数据地址")
+
+// ErrInvalidTarget 目标地址不是有效的http/https地址
+var ErrInvalidTarget = errors.New("无效的目标地址")
+
+// forbiddenPrefixes IsPrivate等方法未覆盖的保留网段
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // 本网络
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF协议分配
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),   // 保留及广播
+}
+
+// sharedPrefix 运营商级NAT，部分云平台的元数据服务在此网段
+var sharedPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// localAddr 是否为本机、内网或链路本地地址（含169.254.169.254等元数据服务）
+func localAddr(addr netip.Addr) bool {
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || sharedPrefix.Contains(addr)
+}
+
+// forbiddenAddr 是否为不允许投递的地址：组播和保留地址总是拒绝；
+// 本机、内网和链路本地地址在allowPrivate为false时拒绝
+func forbiddenAddr(addr netip.Addr, allowPrivate bool) bool {
+	addr = addr.Unmap()
+	if addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return !allowPrivate && localAddr(addr)
+}
+
+// CheckTarget 创建订阅时校验目标地址：只允许http/https，
+// 主机为IP或解析出的任一地址是不允许投递的地址时返回ErrForbiddenTarget（allowPrivate见forbiddenAddr，仅用于开发和测试）。
+// 域名暂时无法解析时不拒绝，投递时仍会检查实际连接的地址（见NewClient）
+func CheckTarget(ctx context.Context, rawURL string, allowPrivate bool) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrInvalidTarget
+	}
+
+	host := target.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if forbiddenAddr(addr, allowPrivate) {
+			return ErrForbiddenTarget
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if forbiddenAddr(addr, allowPrivate) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// guardDial 建立连接前检查实际连接的地址，防止域名在订阅后被解析到内网（DNS重绑定）或重定向到内网
+func guardDial(allowPrivate bool) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil || forbiddenAddr(addr, allowPrivate) {
+			return ErrForbiddenTarget
+		}
+		return nil
+	}
+}
+
+// NewClient 投递使用的HTTP客户端：不使用代理，拒绝连接不允许投递的地址（allowPrivate与CheckTarget相同）
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: guardDial(allowPrivate)}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
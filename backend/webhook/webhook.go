@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"questionnaire-system/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 事件类型
+const (
+	EventSubmissionCreated      = "submission.created"
+	EventQuestionnairePublished = "questionnaire.published"
+	EventQuestionnaireClosed    = "questionnaire.closed"
+	EventQuestionnaireDeleted   = "questionnaire.deleted"
+)
+
+// Events 支持订阅的全部事件类型
+var Events = []string{
+	EventSubmissionCreated,
+	EventQuestionnairePublished,
+	EventQuestionnaireClosed,
+	EventQuestionnaireDeleted,
+}
+
+// IsValidEvent 是否为支持的事件类型
+func IsValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// 请求头
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload 投递的事件内容
+type Payload struct {
+	Event           string      `json:"event"`
+	QuestionnaireID uint        `json:"questionnaire_id"`
+	OccurredAt      time.Time   `json:"occurred_at"`
+	Data            interface{} `json:"data"`
+}
+
+// MarshalPayload 编码投递的请求体
+func MarshalPayload(questionnaireID uint, event string, data interface{}, occurredAt time.Time) ([]byte, error) {
+	return json.Marshal(Payload{
+		Event:           event,
+		QuestionnaireID: questionnaireID,
+		OccurredAt:      occurredAt,
+		Data:            data,
+	})
+}
+
+// Enqueue 为匹配的订阅写入发件箱记录
+// 应在业务事务内调用（tx），保证事件与业务数据同时提交或回滚
+func Enqueue(tx *gorm.DB, questionnaireID uint, event string, data interface{}) error {
+	var subscriptions []models.WebhookSubscription
+	err := tx.Where("is_active = ? AND (questionnaire_id = ? OR questionnaire_id IS NULL)", true, questionnaireID).
+		Find(&subscriptions).Error
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	now := time.Now()
+	for _, sub := range subscriptions {
+		if !sub.Subscribes(event) {
+			continue
+		}
+
+		if body == nil {
+			if body, err = MarshalPayload(questionnaireID, event, data, now); err != nil {
+				return err
+			}
+		}
+
+		entry := models.WebhookOutbox{
+			SubscriptionID: sub.ID,
+			EventType:      event,
+			Payload:        string(body),
+			Status:         models.WebhookStatusPending,
+			NextAttemptAt:  now,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sign 计算签名：HMAC-SHA256(secret, "时间戳.请求体")
+// 接收方应使用相同方式计算并比较X-Webhook-Signature头
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// GenerateSecret 生成随机签名密钥
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成密钥失败: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
@@ -30,6 +30,11 @@ api.interceptors.response.use(
     return response
   },
   error => {
+    // 策略要求管理员启用两步验证时，先去绑定验证器
+    if (error.response && error.response.data?.error?.code === 'MFA_REQUIRED') {
+      window.location.href = '/security'
+      return Promise.reject(error)
+    }
     // 处理401和403错误
     if (error.response && (error.response.status === 401 || error.response.status === 403)) {
       // 清除本地存储的token和用户信息
@@ -73,9 +78,17 @@ export const questionnaireApi = {
     return api.get('/questionnaires', { params: { page, page_size: pageSize } })
   },
   
-  // 获取问卷提交详情
-  getQuestionnaireSubmissions(id) {
-    return api.get('/questionnaire/submissions', { params: { id } })
+  // 获取问卷提交详情（沿next_cursor取完全部提交记录）
+  async getQuestionnaireSubmissions(id) {
+    const response = await api.get('/questionnaire/submissions', { params: { id, limit: 500 } })
+    let cursor = response.data?.data?.next_cursor
+    while (cursor) {
+      const next = await api.get('/questionnaire/submissions', { params: { id, limit: 500, cursor } })
+      const details = next.data.data.submission_details || []
+      response.data.data.submission_details = (response.data.data.submission_details || []).concat(details)
+      cursor = next.data.data.next_cursor
+    }
+    return response
   }
 }
 
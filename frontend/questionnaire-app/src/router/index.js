@@ -2,6 +2,9 @@ import { createRouter, createWebHistory } from 'vue-router'
 import Home from '../views/Home.vue'
 import Login from '../views/Login.vue'
 import Register from '../views/Register.vue'
+import VerifyEmail from '../views/VerifyEmail.vue'
+import Security from '../views/Security.vue'
+import SSOCallback from '../views/SSOCallback.vue'
 import QuestionnaireList from '../views/questionnaire/List.vue'
 import QuestionnaireDetail from '../views/questionnaire/Detail.vue'
 import QuestionnaireFill from '../views/questionnaire/Fill.vue'
@@ -30,6 +33,21 @@ const routes = [
     name: 'Register',
     component: Register
   },
+  {
+    path: '/verify-email',
+    name: 'VerifyEmail',
+    component: VerifyEmail
+  },
+  {
+    path: '/security',
+    name: 'Security',
+    component: Security
+  },
+  {
+    path: '/sso/callback',
+    name: 'SSOCallback',
+    component: SSOCallback
+  },
   {
     path: '/questionnaire/list',
     name: 'QuestionnaireList',
@@ -105,7 +123,7 @@ router.beforeEach((to, from, next) => {
   const isAdmin = userInfo.is_admin || false
   
   // 需要登录的页面
-  const authPages = ['/questionnaire/create', '/questionnaire/edit', '/questionnaire/results']
+  const authPages = ['/questionnaire/create', '/questionnaire/edit', '/questionnaire/results', '/security']
   
   // 需要管理员权限的页面
   const adminPages = ['/admin', '/admin/users', '/admin/questionnaires', '/admin/statistics']
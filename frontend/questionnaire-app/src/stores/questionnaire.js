@@ -273,15 +273,20 @@ export const useQuestionnaireStore = defineStore('questionnaire', {
     // 获取问卷结果
     async getQuestionnaireResults(id) {
       try {
-        // 获取用户信息
-        const userInfo = JSON.parse(localStorage.getItem('userInfo') || '{}')
-        const userId = userInfo.id || 0
-        
-        if (userId <= 0) {
+        // 权限按请求头中的会话令牌判断
+        if (!localStorage.getItem('token')) {
           throw new Error('未登录或登录已过期')
         }
         
-        const response = await api.get(`/questionnaire/results?id=${id}&user_id=${userId}`)
+        // 答卷按页返回，沿next_cursor取完全部答卷用于统计
+        const url = `/questionnaire/results?id=${id}&limit=500`
+        const response = await api.get(url)
+        let cursor = response?.data?.next_cursor
+        while (cursor) {
+          const next = await api.get(`${url}&cursor=${encodeURIComponent(cursor)}`)
+          response.data.submissions = response.data.submissions.concat(next.data.submissions || [])
+          cursor = next.data.next_cursor
+        }
         return Promise.resolve(response)
       } catch (error) {
         console.error('获取问卷结果失败:', error)
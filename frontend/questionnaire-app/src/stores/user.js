@@ -62,18 +62,13 @@ export const useUserStore = defineStore('user', {
         
         const data = response.data;
         
-        this.token = data.token
-        this.userInfo = {
-          id: data.user_id,
-          username: data.username,
-          email: data.email,
-          is_admin: data.is_admin
+        // 启用了两步验证时需要再提交验证码，由completeLogin完成登录
+        if (data.mfa_required) {
+          console.log('需要两步验证')
+          return Promise.resolve(data)
         }
         
-        // 保存到本地存储
-        localStorage.setItem('token', this.token)
-        localStorage.setItem('userInfo', JSON.stringify(this.userInfo))
-        
+        this.setSession(data)
         console.log('登录成功:', this.userInfo)
         return Promise.resolve(data)
       } catch (error) {
@@ -87,8 +82,8 @@ export const useUserStore = defineStore('user', {
           console.error('错误响应状态:', error.response.status);
           console.error('错误响应数据:', error.response.data);
           
-          if (error.response.data && error.response.data.error) {
-            errorMessage = error.response.data.error;
+          if (error.response.data && error.response.data.message) {
+            errorMessage = error.response.data.message;
           } else {
             errorMessage = `服务器错误 (${error.response.status})`;
           }
@@ -106,6 +101,79 @@ export const useUserStore = defineStore('user', {
       }
     },
     
+    // 两步验证：提交验证器App中的验证码或恢复码，完成登录
+    async completeLogin(mfaToken, code) {
+      try {
+        const response = await api.post('/v1/sessions/mfa', {
+          mfa_token: mfaToken,
+          code
+        });
+        
+        const data = response.data;
+        this.setSession(data)
+        console.log('两步验证成功:', this.userInfo)
+        return Promise.resolve(data)
+      } catch (error) {
+        console.error('两步验证失败:', error)
+        
+        let errorMessage = '验证失败';
+        if (error.response) {
+          errorMessage = (error.response.data && error.response.data.message) || `服务器错误 (${error.response.status})`;
+        } else if (error.request) {
+          errorMessage = '服务器无响应，请检查后端服务是否启动';
+        } else {
+          errorMessage = error.message;
+        }
+        
+        return Promise.reject(new Error(errorMessage));
+      }
+    },
+    
+    // 发起单点登录：保存state供回调页核对，返回身份提供方的授权地址
+    async startSSO() {
+      try {
+        const response = await api.post('/v1/sso/oidc/authorizations')
+        const data = response.data
+        sessionStorage.setItem('ssoState', data.state)
+        return Promise.resolve(data.authorization_url)
+      } catch (error) {
+        console.error('发起单点登录失败:', error)
+        const errorMessage = (error.response && error.response.data && error.response.data.message) || '发起单点登录失败'
+        return Promise.reject(new Error(errorMessage))
+      }
+    },
+    
+    // 单点登录回调：提交身份提供方返回的code和state；启用了两步验证时与密码登录一样返回mfa_token
+    async completeSSO(code, state) {
+      try {
+        const response = await api.post('/v1/sessions/oidc', { code, state })
+        const data = response.data
+        if (!data.mfa_required) {
+          this.setSession(data)
+        }
+        return Promise.resolve(data)
+      } catch (error) {
+        console.error('单点登录失败:', error)
+        const errorMessage = (error.response && error.response.data && error.response.data.message) || '单点登录失败'
+        return Promise.reject(new Error(errorMessage))
+      }
+    },
+    
+    // 保存登录响应中的令牌和用户信息
+    setSession(data) {
+      this.token = data.token
+      this.userInfo = {
+        id: data.user_id,
+        username: data.username,
+        email: data.email,
+        is_admin: data.is_admin
+      }
+      
+      // 保存到本地存储
+      localStorage.setItem('token', this.token)
+      localStorage.setItem('userInfo', JSON.stringify(this.userInfo))
+    },
+    
     // 注册
     async register(userData) {
       try {
@@ -130,8 +198,13 @@ export const useUserStore = defineStore('user', {
           console.error('错误响应状态:', error.response.status);
           console.error('错误响应数据:', error.response.data);
           
-          if (error.response.data && error.response.data.error) {
-            errorMessage = error.response.data.error;
+          if (error.response.data && error.response.data.message) {
+            errorMessage = error.response.data.message;
+            // 密码不符合要求时，details中列出违反的每条规则
+            const details = (error.response.data.error?.details || []).map(d => d.message).join('，');
+            if (details) {
+              errorMessage += '：' + details;
+            }
           } else {
             errorMessage = `服务器错误 (${error.response.status})`;
           }